@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/consensus/bft"
+	"github.com/afrodynamic/gochain/api/internal/consensus/pos"
+	"github.com/afrodynamic/gochain/api/internal/consensus/pow"
+	"github.com/afrodynamic/gochain/api/internal/platform/config"
+)
+
+const defaultEngine = "pow"
+
+func newConsensusRegistry() *consensus.Registry {
+	registry := consensus.NewRegistry()
+	registry.Register("pow", pow.Factory)
+	registry.Register("pos", pos.Factory)
+	registry.Register("bft", bft.Factory)
+
+	return registry
+}
+
+// loadGenesis reads the genesis file when one is configured and otherwise
+// builds a single-node development genesis for the configured engine.
+func loadGenesis(cfg config.Config, validatorKey ed25519.PrivateKey) (genesis.Genesis, error) {
+	if cfg.GenesisPath != "" {
+		loaded, err := genesis.Load(cfg.GenesisPath)
+
+		if err != nil {
+			return genesis.Genesis{}, err
+		}
+
+		if cfg.Consensus != "" && cfg.Consensus != loaded.Consensus.Engine {
+			return genesis.Genesis{}, fmt.Errorf("GOCHAIN_CONSENSUS=%q conflicts with genesis engine %q", cfg.Consensus, loaded.Consensus.Engine)
+		}
+
+		return loaded, nil
+	}
+
+	engine := cfg.Consensus
+
+	if engine == "" {
+		engine = defaultEngine
+	}
+
+	var validatorPublicKey []byte
+
+	if engine != "pow" {
+		if validatorKey == nil {
+			return genesis.Genesis{}, fmt.Errorf("GOCHAIN_VALIDATOR_KEY is required for consensus engine %q without a genesis file", engine)
+		}
+
+		validatorPublicKey = validatorKey.Public().(ed25519.PublicKey)
+	}
+
+	return genesis.Default(engine, validatorPublicKey), nil
+}
+
+// parseValidatorKey accepts either a hex ed25519 seed or a full hex private
+// key, as returned by the gochain wallet's NewKey.
+func parseValidatorKey(value string) (ed25519.PrivateKey, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	decoded, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+
+	if err != nil {
+		return nil, errors.New("GOCHAIN_VALIDATOR_KEY must be hex encoded")
+	}
+
+	switch len(decoded) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(decoded), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(decoded), nil
+	default:
+		return nil, fmt.Errorf("GOCHAIN_VALIDATOR_KEY must be %d or %d bytes", ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	httpapi "github.com/afrodynamic/gochain/api/internal/api/http"
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/p2p"
+	"github.com/afrodynamic/gochain/api/internal/platform/config"
+)
+
+const devnetChainID = "gochain-devnet"
+
+// devnetEngines maps the devnet's consensus names to registered engines.
+// Proof of authority is the BFT engine with every node as a validator.
+var devnetEngines = map[string]string{
+	"pow": "pow",
+	"pos": "pos",
+	"poa": "bft",
+}
+
+type devnetConfig struct {
+	Nodes     int
+	Consensus string
+	DataPath  string
+	Host      string
+	HTTPPort  int
+	P2PPort   int
+}
+
+type devnetNode struct {
+	*node
+	index       int
+	dataPath    string
+	httpAddress string
+	validator   ed25519.PublicKey
+	httpServer  *http.Server
+}
+
+// devnet runs a local network in process. Every node gets its own data
+// directory and validator key under the devnet's data path, shares one
+// genesis and is a static peer of every node started before it, so the nodes
+// form a full mesh. Ports are assigned sequentially from the base ports; a
+// base port of 0 picks free ports instead.
+type devnet struct {
+	genesis genesis.Genesis
+	nodes   []*devnetNode
+}
+
+func startDevnet(config devnetConfig) (*devnet, error) {
+	engine, exists := devnetEngines[config.Consensus]
+
+	if !exists {
+		return nil, fmt.Errorf("unknown devnet consensus %q (available: pow, pos, poa)", config.Consensus)
+	}
+
+	if config.Nodes < 1 {
+		return nil, errors.New("a devnet needs at least one node")
+	}
+
+	validatorKeys := make([]ed25519.PrivateKey, 0, config.Nodes)
+
+	for i := range config.Nodes {
+		validatorKey, err := p2p.LoadOrCreateKey(filepath.Join(devnetNodePath(config.DataPath, i), "validator.key"))
+
+		if err != nil {
+			return nil, err
+		}
+
+		validatorKeys = append(validatorKeys, validatorKey)
+	}
+
+	genesisConfig := devnetGenesis(engine, validatorKeys)
+
+	if err := genesisConfig.Save(filepath.Join(config.DataPath, "genesis.json")); err != nil {
+		return nil, err
+	}
+
+	network := &devnet{genesis: genesisConfig}
+
+	for i, validatorKey := range validatorKeys {
+		started, err := network.startNode(config, i, validatorKey)
+
+		if err != nil {
+			network.Stop()
+
+			return nil, fmt.Errorf("node %d: %w", i, err)
+		}
+
+		network.nodes = append(network.nodes, started)
+	}
+
+	return network, nil
+}
+
+func devnetNodePath(dataPath string, index int) string {
+	return filepath.Join(dataPath, "node"+strconv.Itoa(index))
+}
+
+// devnetGenesis is deterministic in the validator keys, so restarting a devnet
+// on the same data path reopens the same chain.
+func devnetGenesis(engine string, validatorKeys []ed25519.PrivateKey) genesis.Genesis {
+	genesisConfig := genesis.Default(engine, nil)
+	genesisConfig.ChainID = devnetChainID
+	genesisConfig.Consensus.BlockTime = genesis.Duration(time.Second)
+
+	if engine == "pow" {
+		return genesisConfig
+	}
+
+	for _, validatorKey := range validatorKeys {
+		genesisConfig.Consensus.Validators = append(genesisConfig.Consensus.Validators, genesis.Validator{
+			PublicKey: p2p.NodeID(validatorKey.Public().(ed25519.PublicKey)),
+			Power:     1,
+		})
+	}
+
+	return genesisConfig
+}
+
+func (network *devnet) startNode(config devnetConfig, index int, validatorKey ed25519.PrivateKey) (*devnetNode, error) {
+	staticPeers := make([]string, 0, len(network.nodes))
+
+	for _, peer := range network.nodes {
+		staticPeers = append(staticPeers, peer.network.Addr())
+	}
+
+	dataPath := devnetNodePath(config.DataPath, index)
+	started, err := startNode(config.nodeConfig(index, dataPath, staticPeers), network.genesis, validatorKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", devnetAddress(config.Host, config.HTTPPort, index))
+
+	if err != nil {
+		started.Stop()
+
+		return nil, err
+	}
+
+	httpServer := &http.Server{Handler: httpapi.CreateH2CHandler(started.handler, started.grpcServer)}
+
+	go httpServer.Serve(listener)
+
+	return &devnetNode{
+		node:        started,
+		index:       index,
+		dataPath:    dataPath,
+		httpAddress: listener.Addr().String(),
+		validator:   validatorKey.Public().(ed25519.PublicKey),
+		httpServer:  httpServer,
+	}, nil
+}
+
+func (options devnetConfig) nodeConfig(index int, dataPath string, staticPeers []string) config.Config {
+	return config.Config{
+		Chain:          "gochain",
+		DataPath:       dataPath,
+		P2PAddress:     devnetAddress(options.Host, options.P2PPort, index),
+		P2PStaticPeers: staticPeers,
+		P2PMaxPeers:    max(options.Nodes, 25),
+	}
+}
+
+func devnetAddress(host string, basePort, index int) string {
+	if basePort == 0 {
+		return net.JoinHostPort(host, "0")
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(basePort+index))
+}
+
+func (network *devnet) Stop() {
+	for i := len(network.nodes) - 1; i >= 0; i-- {
+		network.nodes[i].httpServer.Close()
+		network.nodes[i].Stop()
+	}
+
+	network.nodes = nil
+}
+
+func (network *devnet) printEndpoints(output io.Writer) {
+	writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "NODE\tHTTP/gRPC\tP2P\tNODE ID\tVALIDATOR\tDATA\n")
+
+	for _, peer := range network.nodes {
+		validator := "-"
+
+		if len(network.genesis.Consensus.Validators) > 0 {
+			validator = p2p.NodeID(peer.validator)[:16]
+		}
+
+		fmt.Fprintf(writer, "%d\thttp://%s\t%s\t%s\t%s\t%s\n", peer.index, peer.httpAddress, peer.network.Addr(), peer.network.ID()[:16], validator, peer.dataPath)
+	}
+
+	writer.Flush()
+}
+
+func runDevnet(args []string) error {
+	flags := flag.NewFlagSet("devnet", flag.ContinueOnError)
+	config := devnetConfig{Host: "127.0.0.1"}
+	flags.IntVar(&config.Nodes, "nodes", 4, "number of nodes")
+	flags.StringVar(&config.Consensus, "consensus", "pow", "consensus engine: pow, pos or poa")
+	flags.StringVar(&config.DataPath, "data", "devnet", "directory holding each node's data and keys")
+	flags.IntVar(&config.HTTPPort, "http-port", 8080, "HTTP/gRPC port of the first node; later nodes count up from it")
+	flags.IntVar(&config.P2PPort, "p2p-port", 30303, "p2p port of the first node; later nodes count up from it")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	network, err := startDevnet(config)
+
+	if err != nil {
+		return err
+	}
+	defer network.Stop()
+
+	fmt.Printf("devnet %s: %d %s nodes, genesis %s\n\n", network.genesis.ChainID, len(network.nodes), config.Consensus, filepath.Join(config.DataPath, "genesis.json"))
+	network.printEndpoints(os.Stdout)
+	fmt.Println("\npress Ctrl+C to stop")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/afrodynamic/gochain/api/internal/testutil"
+)
+
+func startTestDevnet(t *testing.T, consensus string, nodes int) *devnet {
+	t.Helper()
+
+	network, err := startDevnet(devnetConfig{
+		Nodes:     nodes,
+		Consensus: consensus,
+		DataPath:  t.TempDir(),
+		Host:      "127.0.0.1",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(network.Stop)
+
+	return network
+}
+
+func TestDevnetNodesConverge(t *testing.T) {
+	t.Parallel()
+
+	for _, consensus := range []string{"pow", "pos", "poa"} {
+		t.Run(consensus, func(t *testing.T) {
+			t.Parallel()
+
+			network := startTestDevnet(t, consensus, 3)
+
+			testutil.Eventually(t, "a full mesh", func() bool {
+				for _, peer := range network.nodes {
+					if len(peer.network.Peers()) != len(network.nodes)-1 {
+						return false
+					}
+				}
+
+				return true
+			})
+
+			account := []byte("devnet-account")
+			network.nodes[1].chain.Credit(account, 25)
+
+			testutil.Eventually(t, "every node to apply the credit", func() bool {
+				for _, peer := range network.nodes {
+					if balance, _ := peer.chain.GetBalance(account); balance != 25 {
+						return false
+					}
+				}
+
+				return true
+			})
+		})
+	}
+}
+
+func TestDevnetServesNodeInfo(t *testing.T) {
+	t.Parallel()
+
+	network := startTestDevnet(t, "pow", 2)
+
+	for _, peer := range network.nodes {
+		response, err := http.Get("http://" + peer.httpAddress + "/v1/node/info")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var info struct {
+			ChainID   string `json:"chainId"`
+			Consensus string `json:"consensus"`
+		}
+
+		err = json.NewDecoder(response.Body).Decode(&info)
+		response.Body.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.ChainID != devnetChainID || info.Consensus != "proof_of_work" {
+			t.Fatalf("node %d: got chainId=%q consensus=%q", peer.index, info.ChainID, info.Consensus)
+		}
+	}
+}
@@ -25,6 +25,7 @@ type transactionPayload struct {
 	Amount      uint64    `json:"amount"`
 	Fee         uint64    `json:"fee"`
 	Nonce       uint64    `json:"nonce"`
+	Data        string    `json:"data,omitempty"`
 	BlockHeight uint64    `json:"blockHeight"`
 	Timestamp   time.Time `json:"timestamp"`
 	Status      string    `json:"status"`
@@ -143,6 +144,7 @@ func convertTx(tx core.Transaction) transactionPayload {
 		Amount:      tx.Amount,
 		Fee:         tx.Fee,
 		Nonce:       tx.Nonce,
+		Data:        encodeHex(tx.Data),
 		BlockHeight: tx.BlockHeight,
 		Timestamp:   tx.Timestamp,
 		Status:      string(tx.Status),
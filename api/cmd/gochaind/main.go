@@ -5,55 +5,50 @@ import (
 	"net/http"
 	"os"
 
-	"github.com/afrodynamic/gochain/api/internal/adapter"
-	"github.com/afrodynamic/gochain/api/internal/adapter/bitcoin"
-	"github.com/afrodynamic/gochain/api/internal/adapter/ethereum"
-	goadapter "github.com/afrodynamic/gochain/api/internal/adapter/gochain"
-	grpcapi "github.com/afrodynamic/gochain/api/internal/api/grpc"
 	httpapi "github.com/afrodynamic/gochain/api/internal/api/http"
-	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
-	"github.com/afrodynamic/gochain/api/internal/consensus/pow"
-	"github.com/afrodynamic/gochain/api/internal/storage/pebble"
+	"github.com/afrodynamic/gochain/api/internal/platform/config"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "devnet" {
+		if err := runDevnet(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	cfg, err := config.Load()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	port := os.Getenv("PORT")
 
 	if port == "" {
 		port = "8080"
 	}
 
-	dataPath := os.Getenv("GOCHAIN_DATA_PATH")
-
-	store, err := pebble.New(dataPath)
+	validatorKey, err := parseValidatorKey(cfg.ValidatorKey)
 
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer store.Close()
 
-	bc := gochain.New(pow.New(8), store)
+	genesisConfig, err := loadGenesis(cfg, validatorKey)
 
-	reg := adapter.NewRegistry()
-	reg.Register("gochain", goadapter.NewAdapter(bc))
-	reg.Register("ethereum", ethereum.NewAdapter())
-	reg.Register("bitcoin", bitcoin.NewAdapter())
-	adp, _ := reg.Get("gochain")
-
-	cs := grpcapi.NewChain(bc)
-	ws := grpcapi.NewWallet(adp)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	handler, gs, err := httpapi.NewHandler(cs, ws)
+	node, err := startNode(cfg, genesisConfig, validatorKey)
 
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer node.Stop()
 
-	mux := http.NewServeMux()
-	mux.Handle("/demo/blocks", withDemoCORS(newBlocksHandler(bc)))
-	mux.Handle("/demo/transactions", withDemoCORS(newTransactionsHandler(bc)))
-	mux.Handle("/", handler)
-
-	log.Printf("listening on :%s (REST+gRPC-Web+health), chain=gochain", port)
-	log.Fatal(http.Serve(httpapi.CreateTCPListener(":"+port), httpapi.CreateH2CHandler(mux, gs)))
+	log.Printf("listening on :%s (REST+gRPC-Web+health), chain=%s, consensus=%s, chainId=%s", port, cfg.Chain, node.engine.Name(), genesisConfig.ChainID)
+	log.Fatal(http.Serve(httpapi.CreateTCPListener(":"+port), httpapi.CreateH2CHandler(node.handler, node.grpcServer)))
 }
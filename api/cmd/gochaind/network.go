@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"path/filepath"
+
+	"github.com/afrodynamic/gochain/api/internal/blocksync"
+	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/consensus/bft"
+	"github.com/afrodynamic/gochain/api/internal/p2p"
+	"github.com/afrodynamic/gochain/api/internal/platform/config"
+)
+
+// startNetwork joins the p2p network, subscribes it to the chain so accepted
+// blocks and transactions are gossiped, and starts syncing from peers.
+func startNetwork(cfg config.Config, bc *gochain.Chain, engine consensus.Engine) (*p2p.Server, *blocksync.Manager, error) {
+	keyPath := cfg.NodeKeyPath
+
+	if keyPath == "" {
+		keyPath = filepath.Join(cfg.DataPath, "node.key")
+	}
+
+	nodeKey, err := p2p.LoadOrCreateKey(keyPath)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server, err := p2p.New(p2p.Config{
+		PrivateKey:     nodeKey,
+		ListenAddress:  cfg.P2PAddress,
+		StaticPeers:    cfg.P2PStaticPeers,
+		BootstrapPeers: cfg.P2PBootstrapPeers,
+		MaxPeers:       cfg.P2PMaxPeers,
+		BanListPath:    filepath.Join(cfg.DataPath, "bans.json"),
+	}, bc)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	syncManager := blocksync.New(blocksync.DefaultConfig(), bc, engine, server)
+	bc.Subscribe(server)
+
+	if bftEngine, ok := engine.(*bft.Engine); ok {
+		connectConsensus(server, bftEngine)
+	}
+
+	if err := server.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := syncManager.Start(); err != nil {
+		server.Stop()
+
+		return nil, nil, err
+	}
+
+	log.Printf("p2p listening on %s as node %s", server.Addr(), server.ID())
+
+	return server, syncManager, nil
+}
+
+const messageConsensus = "consensus"
+
+// consensusTransport carries BFT messages to directly connected peers only, so
+// validators must be configured as static peers of each other.
+type consensusTransport struct {
+	network *p2p.Server
+}
+
+func (transport consensusTransport) Broadcast(message bft.Message) {
+	if err := transport.network.Broadcast(messageConsensus, message); err != nil {
+		log.Printf("consensus: broadcast %s: %v", message.Type, err)
+	}
+}
+
+func connectConsensus(server *p2p.Server, engine *bft.Engine) {
+	server.Handle(messageConsensus, func(peer *p2p.Peer, payload json.RawMessage) error {
+		var message bft.Message
+
+		if err := json.Unmarshal(payload, &message); err != nil {
+			return p2p.Misbehaving(p2p.OffenceInvalidMessage, err)
+		}
+
+		engine.HandleMessage(message)
+
+		return nil
+	})
+
+	engine.SetTransport(consensusTransport{network: server})
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+	"github.com/afrodynamic/gochain/api/internal/adapter/bitcoin"
+	"github.com/afrodynamic/gochain/api/internal/adapter/ethereum"
+	goadapter "github.com/afrodynamic/gochain/api/internal/adapter/gochain"
+	grpcapi "github.com/afrodynamic/gochain/api/internal/api/grpc"
+	httpapi "github.com/afrodynamic/gochain/api/internal/api/http"
+	"github.com/afrodynamic/gochain/api/internal/blocksync"
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/core"
+	"github.com/afrodynamic/gochain/api/internal/p2p"
+	"github.com/afrodynamic/gochain/api/internal/platform/config"
+	"github.com/afrodynamic/gochain/api/internal/storage/pebble"
+)
+
+// evmStartupTimeout bounds the chain ID check against each EVM network's node.
+const evmStartupTimeout = 15 * time.Second
+
+// node is a fully wired gochain node: storage, consensus, chain, optional p2p
+// networking and sync, and the API handler. It does not listen for HTTP
+// itself, so callers decide where the handler is served.
+type node struct {
+	store       *pebble.Store
+	chain       *gochain.Chain
+	engine      consensus.Engine
+	network     *p2p.Server
+	syncManager *blocksync.Manager
+	handler     http.Handler
+	grpcServer  *grpc.Server
+}
+
+func startNode(cfg config.Config, genesisConfig genesis.Genesis, validatorKey ed25519.PrivateKey) (*node, error) {
+	store, err := pebble.Open(cfg.DataPath, genesisConfig, pebble.Options{AdoptGenesis: cfg.AdoptGenesis})
+
+	if err != nil {
+		return nil, err
+	}
+
+	started := &node{store: store}
+
+	if err := started.start(cfg, genesisConfig, validatorKey); err != nil {
+		started.Stop()
+
+		return nil, err
+	}
+
+	return started, nil
+}
+
+func (node *node) start(cfg config.Config, genesisConfig genesis.Genesis, validatorKey ed25519.PrivateKey) error {
+	head := node.store.Blocks[len(node.store.Blocks)-1]
+	schedule, err := genesisConfig.Schedule()
+
+	if err != nil {
+		return err
+	}
+
+	// The engine is created before the chain it validates proposals against;
+	// it only calls Validate once started, after the chain exists.
+	var bc *gochain.Chain
+
+	engine, err := newConsensusRegistry().New(genesisConfig.Consensus.Engine, consensus.Params{
+		Genesis:    genesisConfig.Consensus,
+		Schedule:   schedule,
+		Height:     head.Height + 1,
+		PrevHash:   head.Hash,
+		PrivateKey: validatorKey,
+		Validate:   func(block core.Block) error { return bc.ValidateBlock(block) },
+	})
+
+	if err != nil {
+		return err
+	}
+
+	bc, err = gochain.New(engine, node.store)
+
+	if err != nil {
+		return err
+	}
+
+	if err := bc.Start(); err != nil {
+		return err
+	}
+
+	node.engine = engine
+	node.chain = bc
+
+	var syncReporter core.SyncReporter
+
+	if cfg.P2PAddress != "" {
+		network, syncManager, err := startNetwork(cfg, bc, engine)
+
+		if err != nil {
+			return err
+		}
+
+		node.network = network
+		node.syncManager = syncManager
+		syncReporter = syncManager
+	}
+
+	reg := adapter.NewRegistry()
+	reg.Register("gochain", goadapter.NewAdapter(bc))
+
+	for _, name := range cfg.EVMNetworks {
+		evmAdapter, err := newEVMAdapter(cfg, name)
+
+		if err != nil {
+			return err
+		}
+
+		if err := register(reg, evmAdapter.Network(), evmAdapter); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range cfg.BitcoinNetworks {
+		network, err := bitcoin.LookupNetwork(name)
+
+		if err != nil {
+			return err
+		}
+
+		backend := cfg.BitcoinBackends[name]
+		source, err := bitcoin.NewSource(backend.RPCURL, backend.EsploraURL)
+
+		if err != nil {
+			return err
+		}
+
+		sent, err := bitcoin.LoadSentTxs(sentTxsPath(cfg, network.ID))
+
+		if err != nil {
+			return fmt.Errorf("bitcoin network %q sent transactions: %w", name, err)
+		}
+
+		bitcoinAdapter := bitcoin.NewAdapter(network, source)
+		bitcoinAdapter.SetSentTxs(sent)
+
+		if err := register(reg, network.ID, bitcoinAdapter); err != nil {
+			return err
+		}
+	}
+
+	adp, ok := reg.Get(cfg.Chain)
+
+	if !ok {
+		return fmt.Errorf("unknown chain %q", cfg.Chain)
+	}
+
+	cs := grpcapi.NewChain(bc, syncReporter)
+	ws := grpcapi.NewWallet(adp)
+	as := grpcapi.NewAdmin(node.network, reg, cfg.AdminToken)
+
+	handler, gs, err := httpapi.NewHandler(cs, ws, as, syncReporter)
+
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/demo/blocks", withDemoCORS(newBlocksHandler(bc)))
+	mux.Handle("/demo/transactions", withDemoCORS(newTransactionsHandler(bc)))
+	mux.Handle("/", handler)
+
+	node.handler = mux
+	node.grpcServer = gs
+
+	return nil
+}
+
+// Stop shuts the node down in reverse start order; it is safe on a node that
+// failed part way through starting.
+func (node *node) Stop() {
+	if node.syncManager != nil {
+		node.syncManager.Stop()
+	}
+
+	if node.network != nil {
+		node.network.Stop()
+	}
+
+	if node.chain != nil {
+		node.chain.Stop()
+	}
+
+	node.store.Close()
+}
+
+// register adds the adapter for a chain, which must not already have one:
+// adapters keep their records under the data path by chain.
+func register(reg *adapter.Registry, chain string, chainAdapter adapter.ChainAdapter) error {
+	if _, exists := reg.Get(chain); exists {
+		return fmt.Errorf("chain %q is configured more than once", chain)
+	}
+
+	reg.Register(chain, chainAdapter)
+
+	return nil
+}
+
+// sentTxsPath is where the adapter registered for the chain keeps the
+// transactions it broadcast.
+func sentTxsPath(cfg config.Config, chain string) string {
+	return filepath.Join(cfg.DataPath, "sent", chain+".json")
+}
+
+// newEVMAdapter builds the adapter for an EVM network: a known one with any
+// defaults the configuration overrides, or a custom one it describes in
+// full. Ethereum mainnet registers the mainnet tokens, which configured
+// tokens with the same symbol replace. Nonces allocated for its senders and
+// the transactions it broadcast are persisted under the data path. The nodes
+// at the network's RPC URLs must report its chain ID; one that cannot be
+// reached is only logged.
+func newEVMAdapter(cfg config.Config, name string) (*ethereum.Adapter, error) {
+	if cfg.EthereumGasMargin < 0 {
+		return nil, fmt.Errorf("ETH_GAS_MARGIN must not be negative, got %d", cfg.EthereumGasMargin)
+	}
+
+	backend := cfg.EVMBackends[name]
+	network, err := ethereum.LookupNetwork(name)
+
+	if err != nil {
+		network = ethereum.Network{Name: name}
+	}
+
+	if backend.ChainID < 0 {
+		return nil, fmt.Errorf("EVM network %q: chain ID must not be negative, got %d", name, backend.ChainID)
+	}
+
+	if backend.ChainID != 0 {
+		network.ChainID = uint64(backend.ChainID)
+	}
+
+	if network.ChainID == 0 {
+		return nil, fmt.Errorf("%w, or a custom one with a chain ID", err)
+	}
+
+	network.RPCURLs = backend.RPCURLs
+
+	if backend.Symbol != "" {
+		network.Symbol = backend.Symbol
+	}
+
+	if backend.ExplorerURL != "" {
+		network.ExplorerURL = backend.ExplorerURL
+	}
+
+	if backend.ENSRegistry != "" {
+		if network.ENSRegistry, err = ethereum.ParseAddress(backend.ENSRegistry); err != nil {
+			return nil, fmt.Errorf("EVM network %q ENS registry: %w", name, err)
+		}
+	}
+
+	var tokens []adapter.Token
+
+	if network.Name == ethereum.Mainnet.Name {
+		tokens = slices.Clone(ethereum.MainnetTokens)
+	}
+
+	for _, spec := range backend.Tokens {
+		token, err := ethereum.ParseToken(spec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	evmAdapter := ethereum.NewAdapter(network, uint64(cfg.EthereumGasMargin), tokens)
+	nonces, err := ethereum.LoadNonceManager(filepath.Join(cfg.DataPath, "nonces", name+".json"))
+
+	if err != nil {
+		return nil, fmt.Errorf("EVM network %q nonces: %w", name, err)
+	}
+
+	evmAdapter.SetNonceManager(nonces)
+	sent, err := ethereum.LoadSentTxs(sentTxsPath(cfg, network.Name))
+
+	if err != nil {
+		return nil, fmt.Errorf("EVM network %q sent transactions: %w", name, err)
+	}
+
+	evmAdapter.SetSentTxs(sent)
+	ctx, cancel := context.WithTimeout(context.Background(), evmStartupTimeout)
+	defer cancel()
+
+	if err := evmAdapter.CheckChainID(ctx); err != nil {
+		return nil, err
+	}
+
+	return evmAdapter, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+	"github.com/afrodynamic/gochain/api/internal/adapter/bitcoin"
+	"github.com/afrodynamic/gochain/api/internal/adapter/ethereum"
+	"github.com/afrodynamic/gochain/api/internal/platform/config"
+)
+
+// chainIDServer is a JSON-RPC node that reports chain ID 0x7a69, 31337.
+func chainIDServer(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x7a69"}`))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func TestNewEVMAdapter(t *testing.T) {
+	t.Parallel()
+
+	url := chainIDServer(t)
+	cfg := config.Config{
+		DataPath:          t.TempDir(),
+		EthereumGasMargin: 20,
+		EVMBackends: map[string]config.EVMBackend{
+			"anvil":    {RPCURLs: []string{url}, ChainID: 31337, Symbol: "ETH"},
+			"sepolia":  {RPCURLs: []string{url}},
+			"ethereum": {Tokens: []string{"USDC:0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238:6"}},
+			"base":     {ENSRegistry: "0xens"},
+			"offline":  {RPCURLs: []string{"http://127.0.0.1:0"}, ChainID: 31337},
+		},
+	}
+
+	anvil, err := newEVMAdapter(cfg, "anvil")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if anvil.Network() != "anvil" || anvil.ChainID() != 31337 || len(anvil.Tokens()) != 0 {
+		t.Fatalf("got network=%s chain=%d tokens=%d", anvil.Network(), anvil.ChainID(), len(anvil.Tokens()))
+	}
+
+	// Offline, the mainnet tokens are registered and the configured USDC
+	// replaces the built-in one.
+	mainnet, err := newEVMAdapter(cfg, "ethereum")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usdc, err := mainnet.Token("USDC"); err != nil || usdc.Contract != "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238" || len(mainnet.Tokens()) != 4 {
+		t.Fatalf("got usdc=%+v err=%v tokens=%d", usdc, err, len(mainnet.Tokens()))
+	}
+
+	// A node that is down does not keep the server from starting.
+	if _, err := newEVMAdapter(cfg, "offline"); err != nil {
+		t.Fatalf("unreachable node: %v", err)
+	}
+
+	for _, name := range []string{"sepolia", "custom", "base"} {
+		if _, err := newEVMAdapter(cfg, name); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRegisterRefusesAChainTwice(t *testing.T) {
+	t.Parallel()
+
+	reg := adapter.NewRegistry()
+	custom := ethereum.NewAdapter(ethereum.Network{Name: bitcoin.MainNet.ID, ChainID: 31337}, 20, nil)
+
+	if err := register(reg, custom.Network(), custom); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := register(reg, bitcoin.MainNet.ID, bitcoin.NewAdapter(bitcoin.MainNet, nil)); err == nil {
+		t.Fatal("expected a bitcoin network with the EVM network's name to be refused")
+	}
+
+	if got, _ := reg.Get(bitcoin.MainNet.ID); got != custom {
+		t.Fatal("the first adapter was replaced")
+	}
+}
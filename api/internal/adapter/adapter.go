@@ -1,14 +1,85 @@
 package adapter
 
-import "context"
+import (
+	"context"
+	"math/big"
+)
 
+// ChainAdapter is a network the wallet can use. Amounts and balances are in
+// the network's smallest unit, 10^-Decimals of its coin.
 type ChainAdapter interface {
 	Network() string
+	Decimals() uint32
 	NewKey(seed []byte) (priv, pub, addr string, err error)
 	ParseAddress(s string) (string, error)
-	Balance(ctx context.Context, addr string) (uint64, error)
-	BuildTx(ctx context.Context, from, to string, amt uint64, feeHint FeeHint) (Tx, error)
+	Balance(ctx context.Context, addr string) (*big.Int, error)
+	BuildTx(ctx context.Context, from, to string, amt *big.Int, feeHint FeeHint) (Tx, error)
 	SignTx(priv string, tx Tx) (SignedTx, error)
 	Broadcast(ctx context.Context, stx SignedTx) (string, error)
-	TxStatus(ctx context.Context, id string) (Status, error)
+	TxStatus(ctx context.Context, id string) (TxStatus, error)
+}
+
+// AddressTypeAdapter is implemented by adapters that can encode a key as more
+// than one kind of address.
+type AddressTypeAdapter interface {
+	AddressTypes() []string
+	NewKeyWithAddressType(seed []byte, addressType string) (priv, pub, addr string, err error)
+}
+
+// CoinSelectionAdapter is implemented by adapters that can choose how the
+// inputs of a transaction are selected.
+type CoinSelectionAdapter interface {
+	CoinSelections() []string
+	BuildTxWithCoinSelection(ctx context.Context, from, to string, amt *big.Int, feeHint FeeHint, strategy string) (Tx, error)
+}
+
+// DescriptorAdapter is implemented by adapters that can watch the addresses of
+// an output descriptor, such as a multisig wallet's, and build transactions
+// spending from them without holding any of its keys.
+type DescriptorAdapter interface {
+	ImportDescriptor(descriptor string) (string, error)
+	DescriptorAddresses(descriptor string, change bool, start, count uint32) ([]string, error)
+	DescriptorBalance(ctx context.Context, descriptor string) (*big.Int, error)
+	BuildDescriptorTx(ctx context.Context, descriptor, to string, amt *big.Int, feeHint FeeHint, strategy string) (Tx, error)
+}
+
+// CallDataAdapter is implemented by adapters whose transactions can carry
+// call data, such as a contract call on an account-based network, and can be
+// built from explicit TxParams to be signed offline.
+type CallDataAdapter interface {
+	BuildTxWithData(ctx context.Context, from, to string, amt *big.Int, data []byte, feeHint FeeHint, params TxParams) (Tx, error)
+}
+
+// TokenAdapter is implemented by adapters that can hold and send tokens
+// issued by contracts, with amounts in each token's smallest unit.
+type TokenAdapter interface {
+	Tokens() []Token
+	Token(symbolOrContract string) (Token, error)
+	TokenBalance(ctx context.Context, token Token, addr string) (*big.Int, error)
+	BuildTokenTx(ctx context.Context, token Token, from, to string, amt *big.Int, feeHint FeeHint, params TxParams) (Tx, error)
+}
+
+// ReplacementAdapter is implemented by adapters that can replace a pending
+// transaction with one paying a higher fee: a speed-up with the same effect,
+// or a cancellation that pays the sender back. The replacement is returned
+// unsigned, to be signed and broadcast like any other, and TxStatus reports
+// the original replaced once it takes its place.
+type ReplacementAdapter interface {
+	SpeedUpTx(ctx context.Context, id string, feeHint FeeHint) (Tx, error)
+	CancelTx(ctx context.Context, id string, feeHint FeeHint) (Tx, error)
+}
+
+// NameResolvingAdapter is implemented by adapters that accept a name, such as
+// an ENS name, wherever an address is expected. ResolveAddress parses an
+// address like ParseAddress, or resolves a name to one.
+type NameResolvingAdapter interface {
+	ResolveAddress(ctx context.Context, value string) (string, error)
+}
+
+// RPCStatsAdapter is implemented by adapters that talk to their network's
+// nodes over JSON-RPC, reporting the health of each node URL and the metrics
+// of each method.
+type RPCStatsAdapter interface {
+	RPCEndpoints() []RPCEndpoint
+	RPCMetrics() map[string]RPCMetrics
 }
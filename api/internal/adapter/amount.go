@@ -0,0 +1,50 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ParseAmount reads a non-negative whole number of a network's smallest unit,
+// such as wei or satoshis, written in decimal. An empty string is zero.
+func ParseAmount(value string) (*big.Int, error) {
+	if value == "" {
+		return new(big.Int), nil
+	}
+
+	for _, digit := range value {
+		if digit < '0' || digit > '9' {
+			return nil, fmt.Errorf("%w %q: expected a whole number of the smallest unit", ErrInvalidAmount, value)
+		}
+	}
+
+	amount, _ := new(big.Int).SetString(value, 10)
+
+	return amount, nil
+}
+
+// FormatAmount writes the amount in decimal, with nil as zero.
+func FormatAmount(amount *big.Int) string {
+	if amount == nil {
+		return "0"
+	}
+
+	return amount.String()
+}
+
+// Uint64Amount is the amount as a uint64 for networks whose amounts fit one,
+// with nil as zero.
+func Uint64Amount(amount *big.Int) (uint64, error) {
+	if amount == nil {
+		return 0, nil
+	}
+
+	if amount.Sign() < 0 || !amount.IsUint64() {
+		return 0, fmt.Errorf("%w %s: out of range", ErrInvalidAmount, amount)
+	}
+
+	return amount.Uint64(), nil
+}
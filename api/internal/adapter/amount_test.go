@@ -0,0 +1,55 @@
+package adapter
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"":                      "0",
+		"0":                     "0",
+		"007":                   "7",
+		"18446744073709551616":  "18446744073709551616",
+		"100000000000000000000": "100000000000000000000",
+	}
+
+	for value, want := range cases {
+		if amount, err := ParseAmount(value); err != nil || amount.String() != want {
+			t.Fatalf("%q: got=%v err=%v want=%s", value, amount, err, want)
+		}
+	}
+
+	for _, value := range []string{"-1", "1.5", "1e18", "0x10", " 1"} {
+		if _, err := ParseAmount(value); !errors.Is(err, ErrInvalidAmount) {
+			t.Fatalf("%q: got err=%v, want ErrInvalidAmount", value, err)
+		}
+	}
+}
+
+func TestUint64Amount(t *testing.T) {
+	t.Parallel()
+
+	if amount, err := Uint64Amount(nil); err != nil || amount != 0 {
+		t.Fatalf("nil: got=%d err=%v", amount, err)
+	}
+
+	if amount, err := Uint64Amount(big.NewInt(42)); err != nil || amount != 42 {
+		t.Fatalf("got=%d err=%v want=42", amount, err)
+	}
+
+	overflow := new(big.Int).Lsh(big.NewInt(1), 64)
+
+	for _, amount := range []*big.Int{overflow, big.NewInt(-1)} {
+		if _, err := Uint64Amount(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Fatalf("%s: got err=%v, want ErrInvalidAmount", amount, err)
+		}
+	}
+
+	if FormatAmount(nil) != "0" || FormatAmount(overflow) != "18446744073709551616" {
+		t.Fatal("unexpected formatting")
+	}
+}
@@ -1,71 +1,501 @@
 package bitcoin
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
+	"math/big"
 
 	"github.com/afrodynamic/gochain/api/internal/adapter"
 	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 )
 
-type Adapter struct{}
+// confirmationDepth is how many blocks deep a transaction must be for
+// TxStatus to report it confirmed rather than included.
+const confirmationDepth = 6
 
-func NewAdapter() *Adapter {
-	return &Adapter{}
+// Adapter works on a bitcoin network through a UTXO source. It remembers the
+// transactions it broadcast, so one that disappears can be reported replaced
+// or dropped, and one broadcast as a PSBT can be replaced.
+type Adapter struct {
+	network Network
+	source  UTXOSource
+	sent    *SentTxs
+}
+
+// NewAdapter works on the network, reading UTXOs from the source. Without a
+// source balances are zero and transactions cannot be built.
+func NewAdapter(network Network, source UTXOSource) *Adapter {
+	sent, _ := LoadSentTxs("")
+
+	return &Adapter{network: network, source: source, sent: sent}
+}
+
+// SetSentTxs replaces the in-memory record of broadcast transactions, e.g.
+// with one that persists it.
+func (ad *Adapter) SetSentTxs(sent *SentTxs) {
+	ad.sent = sent
 }
 
 func (ad *Adapter) Network() string {
-	return "bitcoin"
+	return ad.network.ID
+}
+
+// Decimals is 8: amounts are in satoshis.
+func (ad *Adapter) Decimals() uint32 {
+	return 8
 }
 
 func (ad *Adapter) NewKey(seed []byte) (privateKey, publicKey, address string, err error) {
-	hash := sha256.Sum256(seed)
-	keyHex := hex.EncodeToString(hash[:])
+	return newKey(seed, DefaultAddressType, ad.network.Params)
+}
 
-	return keyHex, keyHex, keyHex, nil
+func (ad *Adapter) AddressTypes() []string {
+	return addressTypes
+}
+
+func (ad *Adapter) NewKeyWithAddressType(seed []byte, addressType string) (privateKey, publicKey, address string, err error) {
+	return newKey(seed, addressType, ad.network.Params)
 }
 
 func (ad *Adapter) ParseAddress(address string) (string, error) {
-	if _, err := btcutil.DecodeAddress(address, &chaincfg.MainNetParams); err != nil {
-		return "", errors.New("invalid bitcoin address")
+	if _, err := ad.decodeAddress(address); err != nil {
+		return "", err
 	}
 
 	return address, nil
 }
 
-func (ad *Adapter) Balance(ctx context.Context, address string) (uint64, error) {
-	return 0, nil
+func (ad *Adapter) decodeAddress(address string) (btcutil.Address, error) {
+	decoded, err := btcutil.DecodeAddress(address, ad.network.Params)
+
+	if err != nil || !decoded.IsForNet(ad.network.Params) {
+		return nil, errors.New("invalid bitcoin address")
+	}
+
+	return decoded, nil
 }
 
-func (ad *Adapter) BuildTx(ctx context.Context, senderAddress, recipientAddress string, amount uint64, feeHint adapter.FeeHint) (adapter.Tx, error) {
-	fee := feeHint.MaxFeePerGas
+// confirmedUTXOs returns the address's outputs that are safe to count and
+// spend.
+func (ad *Adapter) confirmedUTXOs(ctx context.Context, address string) ([]UTXO, error) {
+	utxos, err := ad.source.UTXOs(ctx, address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	confirmed := utxos[:0]
 
-	if fee == 0 {
-		fee = 1
+	for _, utxo := range utxos {
+		if utxo.Confirmed() {
+			confirmed = append(confirmed, utxo)
+		}
 	}
 
-	return adapter.Tx{From: senderAddress, To: recipientAddress, Amount: amount, Fee: fee, Nonce: 0}, nil
+	return confirmed, nil
 }
 
-func (ad *Adapter) SignTx(privateKey string, tx adapter.Tx) (adapter.SignedTx, error) {
-	rawTxBytes := []byte(tx.From + tx.To)
-	txHash := sha256.Sum256(rawTxBytes)
+// Balance is the sum of the address's confirmed unspent outputs in satoshis.
+func (ad *Adapter) Balance(ctx context.Context, address string) (*big.Int, error) {
+	if _, err := ad.decodeAddress(address); err != nil {
+		return nil, err
+	}
+
+	if ad.source == nil {
+		return new(big.Int), nil
+	}
+
+	utxos, err := ad.confirmedUTXOs(ctx, address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var balance uint64
+
+	for _, utxo := range utxos {
+		balance += utxo.Value
+	}
+
+	return new(big.Int).SetUint64(balance), nil
+}
+
+// feeConfirmationTarget is the number of blocks fee estimates aim for.
+const feeConfirmationTarget = 6
+
+// BuildTx selects confirmed outputs of the sender with the automatic coin
+// selection.
+func (ad *Adapter) BuildTx(ctx context.Context, senderAddress, recipientAddress string, amount *big.Int, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	return ad.BuildTxWithCoinSelection(ctx, senderAddress, recipientAddress, amount, feeHint, CoinSelectionAuto)
+}
+
+func (ad *Adapter) CoinSelections() []string {
+	return coinSelections
+}
+
+// BuildTxWithCoinSelection pays the amount from confirmed outputs of the sender
+// chosen by the strategy, at the fee rate in sat/vB from FeeHint.FeeRate
+// or the backend's estimate. The unsigned PSBT is returned in Data along with
+// its fee, estimated vsize and fee rate; change goes back to the sender.
+func (ad *Adapter) BuildTxWithCoinSelection(ctx context.Context, senderAddress, recipientAddress string, amount *big.Int, feeHint adapter.FeeHint, strategy string) (adapter.Tx, error) {
+	if ad.source == nil {
+		return adapter.Tx{}, fmt.Errorf("%w for %s", ErrNoBackend, ad.network.ID)
+	}
+
+	sender, err := ad.decodeAddress(senderAddress)
+
+	if err != nil {
+		return adapter.Tx{}, fmt.Errorf("sender: %w", err)
+	}
+
+	addressType, err := inputType(sender)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	script, err := txscript.PayToAddrScript(sender)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	utxos, err := ad.confirmedUTXOs(ctx, senderAddress)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	info := spendInfo{address: sender, script: script}
+	sending := funds{owners: make(map[UTXO]spendInfo, len(utxos)), inputWeight: inputWeight(addressType), change: info}
+
+	for _, utxo := range utxos {
+		sending.add(utxo, info)
+	}
+
+	return ad.spend(ctx, senderAddress, sending, recipientAddress, amount, feeHint, strategy)
+}
+
+// spend pays the amount to the recipient from the funds and returns the
+// unsigned PSBT with what each signer needs to sign its inputs.
+func (ad *Adapter) spend(ctx context.Context, sender string, sending funds, recipientAddress string, amount *big.Int, feeHint adapter.FeeHint, strategy string) (adapter.Tx, error) {
+	recipient, err := ad.decodeAddress(recipientAddress)
+
+	if err != nil {
+		return adapter.Tx{}, fmt.Errorf("recipient: %w", err)
+	}
+
+	recipientScript, err := txscript.PayToAddrScript(recipient)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	satoshis, err := adapter.Uint64Amount(amount)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	if satoshis < dustThreshold(recipientScript) {
+		return adapter.Tx{}, fmt.Errorf("%w: %d sat is below %d sat", ErrDustAmount, satoshis, dustThreshold(recipientScript))
+	}
+
+	feeRate, err := ad.feeRate(ctx, feeHint)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	chosen, err := selectCoins(strategy, sending.utxos, spendRequest{
+		amount:          satoshis,
+		feeRate:         feeRate,
+		longTermFeeRate: ad.network.FeeRate,
+		inputWeight:     sending.inputWeight,
+		recipientScript: recipientScript,
+		changeScript:    sending.change.script,
+	})
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	msgTx, err := unsignedTx(chosen, satoshis, recipientScript, sending.change.script)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	spent := make([]*wire.TxOut, 0, len(chosen.inputs))
+
+	for _, utxo := range chosen.inputs {
+		spent = append(spent, wire.NewTxOut(int64(utxo.Value), sending.owners[utxo].script))
+	}
+
+	packet, err := newPacket(msgTx, spent)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	for i, utxo := range chosen.inputs {
+		sending.owners[utxo].describeInput(&packet.Inputs[i])
+	}
+
+	if chosen.change > 0 {
+		sending.change.describeOutput(&packet.Outputs[1])
+	}
+
+	encoded, err := encodePacket(packet)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
 
-	return adapter.SignedTx{
-		RawHex: hex.EncodeToString(rawTxBytes),
-		TxID:   hex.EncodeToString(txHash[:]),
+	return adapter.Tx{
+		From:    sender,
+		To:      recipientAddress,
+		Amount:  new(big.Int).SetUint64(satoshis),
+		Fee:     chosen.fee,
+		Data:    encoded,
+		VSize:   chosen.vsize,
+		FeeRate: feeRate,
 	}, nil
 }
 
+// feeRate is the hinted rate, or the backend's estimate when there is no
+// hint. A backend without an estimate yet, like a fresh regtest node, falls
+// back to the network default.
+func (ad *Adapter) feeRate(ctx context.Context, feeHint adapter.FeeHint) (uint64, error) {
+	if feeHint.FeeRate != 0 {
+		return feeHint.FeeRate, nil
+	}
+
+	feeRate, err := ad.source.FeeRate(ctx, feeConfirmationTarget)
+
+	if errors.Is(err, ErrNoFeeEstimate) {
+		return ad.network.FeeRate, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("fee estimate: %w", err)
+	}
+
+	return max(feeRate, 1), nil
+}
+
+// SignTx signs the inputs of the PSBT in tx.Data that the key owns and
+// returns the updated PSBT as RawHex, so another key can sign the rest. The
+// key is a WIF, or an extended private key that signs the inputs whose BIP-32
+// origins it derives, such as a multisig cosigner's. It never touches the
+// network.
+func (ad *Adapter) SignTx(privateKey string, tx adapter.Tx) (adapter.SignedTx, error) {
+	signer, err := ad.signingKey(privateKey)
+
+	if err != nil {
+		return adapter.SignedTx{}, err
+	}
+
+	packet, err := decodePacket(tx.Data)
+
+	if err != nil {
+		return adapter.SignedTx{}, err
+	}
+
+	signed, err := signPacket(packet, signer)
+
+	if err != nil {
+		return adapter.SignedTx{}, err
+	}
+
+	if signed == 0 {
+		return adapter.SignedTx{}, ErrNothingToSign
+	}
+
+	encoded, err := encodePacket(packet)
+
+	if err != nil {
+		return adapter.SignedTx{}, err
+	}
+
+	return adapter.SignedTx{RawHex: hex.EncodeToString(encoded), TxID: packet.UnsignedTx.TxHash().String()}, nil
+}
+
+func (ad *Adapter) signingKey(privateKey string) (signingKey, error) {
+	if extended, err := hdkeychain.NewKeyFromString(privateKey); err == nil {
+		if !extended.IsPrivate() {
+			return signingKey{}, errors.New("extended key is not private")
+		}
+
+		if !extended.IsForNet(ad.network.Params) {
+			return signingKey{}, fmt.Errorf("private key is not for %s", ad.network.ID)
+		}
+
+		return signingKey{extended: extended}, nil
+	}
+
+	wif, err := btcutil.DecodeWIF(privateKey)
+
+	if err != nil {
+		return signingKey{}, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	if !wif.IsForNet(ad.network.Params) {
+		return signingKey{}, fmt.Errorf("private key is not for %s", ad.network.ID)
+	}
+
+	return signingKey{key: wif.PrivKey}, nil
+}
+
+// Broadcast finalizes a fully signed PSBT, or takes a raw transaction as is,
+// and relays it through the backend. A PSBT is kept so SpeedUpTx and CancelTx
+// can replace it.
 func (ad *Adapter) Broadcast(ctx context.Context, signedTx adapter.SignedTx) (string, error) {
-	return signedTx.TxID, nil
+	if ad.source == nil {
+		return "", fmt.Errorf("%w for %s", ErrNoBackend, ad.network.ID)
+	}
+
+	raw, err := hex.DecodeString(signedTx.RawHex)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid transaction hex: %w", err)
+	}
+
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	var sent sentTx
+
+	if isPacket(raw) {
+		packet, err := decodePacket(raw)
+
+		if err != nil {
+			return "", err
+		}
+
+		// Extracting finalizes the packet in place; the signed one is kept
+		// to build replacements from.
+		sent.Packet = raw
+
+		if sent.Fee, err = packetFee(packet); err != nil {
+			sent.Packet = nil
+		}
+
+		if msgTx, err = extractTx(packet); err != nil {
+			return "", err
+		}
+
+		sent.Weight = int64(msgTx.SerializeSizeStripped()*3 + msgTx.SerializeSize())
+
+		var serialized bytes.Buffer
+
+		if err := msgTx.Serialize(&serialized); err != nil {
+			return "", err
+		}
+
+		raw = serialized.Bytes()
+	} else if err := msgTx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return "", fmt.Errorf("invalid transaction: %w", err)
+	}
+
+	txID, err := ad.source.Broadcast(ctx, raw)
+
+	if err != nil {
+		return "", err
+	}
+
+	sent.Inputs = make([]wire.OutPoint, len(msgTx.TxIn))
+
+	for i, input := range msgTx.TxIn {
+		sent.Inputs[i] = input.PreviousOutPoint
+	}
+
+	// The transaction is out whether or not the record is saved.
+	if err := ad.sent.add(txID, sent); err != nil {
+		log.Printf("%s: cannot record broadcast transaction %s: %v", ad.network.ID, txID, err)
+	}
+
+	return txID, nil
 }
 
-func (ad *Adapter) TxStatus(ctx context.Context, txID string) (adapter.Status, error) {
-	return adapter.StatusPending, nil
+// TxStatus looks the transaction up with the source. One the source does not
+// know is unknown, unless the adapter broadcast it: then it was replaced if
+// another transaction spent one of its inputs, as with BIP-125 replace by
+// fee, and dropped if not. Once a transaction is confirmed, the adapter
+// forgets it and the ones it broadcast that conflict with it.
+func (ad *Adapter) TxStatus(ctx context.Context, txID string) (adapter.TxStatus, error) {
+	if ad.source == nil {
+		return adapter.TxStatus{}, fmt.Errorf("%w for %s", ErrNoBackend, ad.network.ID)
+	}
+
+	source, ok := ad.source.(TxStatusSource)
+
+	if !ok {
+		return adapter.TxStatus{Status: adapter.StatusUnknown}, nil
+	}
+
+	lookup, err := source.LookupTx(ctx, txID)
+
+	if err != nil {
+		return adapter.TxStatus{}, err
+	}
+
+	switch {
+	case lookup.Found && lookup.Height > 0:
+		status := adapter.TxStatus{Status: adapter.StatusIncluded, Confirmations: 1, BlockHeight: lookup.Height, BlockHash: lookup.BlockHash}
+
+		if lookup.TipHeight >= lookup.Height {
+			status.Confirmations = lookup.TipHeight - lookup.Height + 1
+		}
+
+		if status.Confirmations >= confirmationDepth {
+			status.Status = adapter.StatusConfirmed
+
+			if err := ad.sent.settle(txID); err != nil {
+				log.Printf("%s: cannot forget settled transactions: %v", ad.network.ID, err)
+			}
+		}
+
+		return status, nil
+
+	case lookup.Found:
+		return adapter.TxStatus{Status: adapter.StatusPending}, nil
+	}
+
+	sent, ok := ad.sent.get(txID)
+
+	if !ok {
+		return adapter.TxStatus{Status: adapter.StatusUnknown}, nil
+	}
+
+	for _, input := range sent.Inputs {
+		spender, spent, err := source.Spender(ctx, input.Hash.String(), input.Index)
+
+		if err != nil {
+			return adapter.TxStatus{}, err
+		}
+
+		switch {
+		case !spent || spender == txID:
+			continue
+
+		case spender == "":
+			// Spent in a block, perhaps by this very transaction when the
+			// source cannot look confirmed ones up.
+			return adapter.TxStatus{Status: adapter.StatusUnknown}, nil
+		}
+
+		return adapter.TxStatus{Status: adapter.StatusReplaced, ReplacedBy: spender}, nil
+	}
+
+	return adapter.TxStatus{Status: adapter.StatusDropped, Reason: "no longer in the mempool"}, nil
 }
 
 var _ adapter.ChainAdapter = (*Adapter)(nil)
+var _ adapter.AddressTypeAdapter = (*Adapter)(nil)
+var _ adapter.CoinSelectionAdapter = (*Adapter)(nil)
@@ -0,0 +1,120 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Transaction weights in weight units, four per non-witness byte. Witness
+// inputs assume a 72-byte DER signature.
+const (
+	overheadWeight    = 4 * (4 + 1 + 1 + 4)
+	segwitFlagWeight  = 2
+	p2pkhInputWeight  = 4 * (36 + 1 + 107 + 4)
+	nestedInputWeight = 4*(36+1+23+4) + (1 + 1 + 72 + 1 + 33)
+	p2wpkhInputWeight = 4*(36+1+4) + (1 + 1 + 72 + 1 + 33)
+	p2trInputWeight   = 4*(36+1+4) + (1 + 1 + 64)
+	rbfInputSequence  = wire.MaxTxInSequenceNum - 2
+	unsignedTxVersion = 2
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrDustAmount        = errors.New("amount is below the dust limit")
+)
+
+// inputType is the address type of an address whose outputs SignTx can spend.
+func inputType(address btcutil.Address) (string, error) {
+	switch address.(type) {
+	case *btcutil.AddressWitnessPubKeyHash:
+		return AddressP2WPKH, nil
+
+	case *btcutil.AddressTaproot:
+		return AddressP2TR, nil
+	}
+
+	return "", fmt.Errorf("cannot spend from %s: only p2wpkh and p2tr addresses are supported", address.String())
+}
+
+func inputWeight(addressType string) int64 {
+	switch addressType {
+	case AddressP2PKH:
+		return p2pkhInputWeight
+
+	case AddressP2SHP2WPKH:
+		return nestedInputWeight
+
+	case AddressP2TR:
+		return p2trInputWeight
+	}
+
+	return p2wpkhInputWeight
+}
+
+func outputWeight(script []byte) int64 {
+	return 4 * int64(8+1+len(script))
+}
+
+// txWeight estimates the weight of a signed transaction spending inputs of the
+// weight to the output scripts. Every input the adapter spends is segwit.
+func txWeight(inputWeight int64, inputs int, outputs ...[]byte) int64 {
+	weight := int64(overheadWeight+segwitFlagWeight) + int64(inputs)*inputWeight
+
+	for _, script := range outputs {
+		weight += outputWeight(script)
+	}
+
+	return weight
+}
+
+// feeFor is the fee at the rate in sat/vB for a transaction of the weight.
+func feeFor(weight int64, feeRate uint64) uint64 {
+	return uint64((weight+3)/4) * feeRate
+}
+
+// dustRelayFeeRate is the rate in sat/vB at which Bitcoin Core's relay policy
+// considers an output not worth spending.
+const dustRelayFeeRate = 3
+
+// dustThreshold is the smallest value an output with the script may have to
+// be relayed: what it costs to create and later spend at the dust relay rate.
+func dustThreshold(script []byte) uint64 {
+	spendSize := 36 + 1 + 107 + 4
+
+	if txscript.IsWitnessProgram(script) {
+		spendSize = 36 + 1 + 107/4 + 4
+	}
+
+	return uint64(8+1+len(script)+spendSize) * dustRelayFeeRate
+}
+
+// unsignedTx spends the selected inputs to the recipient and any change. Every
+// input signals BIP-125 replaceability.
+func unsignedTx(chosen selection, amount uint64, recipientScript, changeScript []byte) (*wire.MsgTx, error) {
+	msgTx := wire.NewMsgTx(unsignedTxVersion)
+
+	for _, utxo := range chosen.inputs {
+		hash, err := chainhash.NewHashFromStr(utxo.TxID)
+
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.TxID, utxo.Vout, err)
+		}
+
+		input := wire.NewTxIn(wire.NewOutPoint(hash, utxo.Vout), nil, nil)
+		input.Sequence = rbfInputSequence
+		msgTx.AddTxIn(input)
+	}
+
+	msgTx.AddTxOut(wire.NewTxOut(int64(amount), recipientScript))
+
+	if chosen.change > 0 {
+		msgTx.AddTxOut(wire.NewTxOut(int64(chosen.change), changeScript))
+	}
+
+	return msgTx, nil
+}
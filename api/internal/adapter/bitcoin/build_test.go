@@ -0,0 +1,234 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// fakeSource serves fixed UTXOs, to every address or to those in byAddress
+// when it is set, and a fixed fee rate, having no estimate when the rate is
+// zero. It records what is broadcast.
+type fakeSource struct {
+	utxos     []UTXO
+	byAddress map[string][]UTXO
+	feeRate   uint64
+	mutex     sync.Mutex
+	broadcast [][]byte
+}
+
+func newFakeSource(utxos ...UTXO) *fakeSource {
+	return &fakeSource{utxos: utxos}
+}
+
+func (source *fakeSource) UTXOs(ctx context.Context, address string) ([]UTXO, error) {
+	if source.byAddress != nil {
+		return append([]UTXO(nil), source.byAddress[address]...), nil
+	}
+
+	return append([]UTXO(nil), source.utxos...), nil
+}
+
+func (source *fakeSource) FeeRate(ctx context.Context, targetBlocks int) (uint64, error) {
+	if source.feeRate == 0 {
+		return 0, ErrNoFeeEstimate
+	}
+
+	return source.feeRate, nil
+}
+
+func (source *fakeSource) Broadcast(ctx context.Context, rawTx []byte) (string, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	source.broadcast = append(source.broadcast, rawTx)
+	msgTx := wire.NewMsgTx(0)
+
+	if err := msgTx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		return "", err
+	}
+
+	return msgTx.TxHash().String(), nil
+}
+
+func txID(fill string) string {
+	return strings.Repeat(fill, 64)
+}
+
+func regtestAddresses(t *testing.T) (sender, recipient string) {
+	t.Helper()
+
+	ad := NewAdapter(RegTest, nil)
+	_, _, sender, _ = ad.NewKey([]byte("sender"))
+	_, _, recipient, _ = ad.NewKeyWithAddressType([]byte("recipient"), AddressP2TR)
+
+	return sender, recipient
+}
+
+func decodeTx(t *testing.T, data []byte) *wire.MsgTx {
+	t.Helper()
+
+	packet, err := decodePacket(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return packet.UnsignedTx
+}
+
+func TestBalanceCountsConfirmedUTXOs(t *testing.T) {
+	t.Parallel()
+
+	sender, _ := regtestAddresses(t)
+	ad := NewAdapter(RegTest, newFakeSource(
+		UTXO{TxID: txID("a"), Value: 1000, Height: 5},
+		UTXO{TxID: txID("b"), Value: 2500, Height: 9},
+		UTXO{TxID: txID("c"), Value: 9999},
+	))
+
+	balance, err := ad.Balance(context.Background(), sender)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Uint64() != 3500 {
+		t.Fatalf("got=%d want=3500", balance)
+	}
+
+	if balance, err := NewAdapter(RegTest, nil).Balance(context.Background(), sender); err != nil || balance.Sign() != 0 {
+		t.Fatalf("without a backend: got=%d err=%v", balance, err)
+	}
+}
+
+func TestBuildTxSpendsUTXOsWithChange(t *testing.T) {
+	t.Parallel()
+
+	sender, recipient := regtestAddresses(t)
+	ad := NewAdapter(RegTest, newFakeSource(
+		UTXO{TxID: txID("a"), Vout: 0, Value: 20_000, Height: 1},
+		UTXO{TxID: txID("b"), Vout: 3, Value: 50_000, Height: 2},
+		UTXO{TxID: txID("c"), Vout: 1, Value: 90_000},
+	))
+
+	tx, err := ad.BuildTx(context.Background(), sender, recipient, big.NewInt(60_000), adapter.FeeHint{FeeRate: 5})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgTx := decodeTx(t, tx.Data)
+
+	if len(msgTx.TxIn) != 2 || len(msgTx.TxOut) != 2 {
+		t.Fatalf("got %d inputs and %d outputs, want 2 and 2", len(msgTx.TxIn), len(msgTx.TxOut))
+	}
+
+	if msgTx.TxIn[0].PreviousOutPoint.Hash.String() != txID("b") || msgTx.TxIn[0].PreviousOutPoint.Index != 3 {
+		t.Fatalf("expected the largest confirmed output first, got %v", msgTx.TxIn[0].PreviousOutPoint)
+	}
+
+	recipientAddress, _ := btcutil.DecodeAddress(recipient, RegTest.Params)
+	senderAddress, _ := btcutil.DecodeAddress(sender, RegTest.Params)
+	recipientScript, _ := txscript.PayToAddrScript(recipientAddress)
+	changeScript, _ := txscript.PayToAddrScript(senderAddress)
+
+	if msgTx.TxOut[0].Value != 60_000 || !bytes.Equal(msgTx.TxOut[0].PkScript, recipientScript) || !bytes.Equal(msgTx.TxOut[1].PkScript, changeScript) {
+		t.Fatalf("unexpected outputs %+v", msgTx.TxOut)
+	}
+
+	// Two P2WPKH inputs and P2TR and P2WPKH outputs weigh 882 WU, 221 vB.
+	if tx.Fee != 221*5 || uint64(msgTx.TxOut[1].Value) != 70_000-60_000-tx.Fee {
+		t.Fatalf("got fee=%d change=%d", tx.Fee, msgTx.TxOut[1].Value)
+	}
+
+	if msgTx.TxIn[0].Sequence != rbfInputSequence {
+		t.Fatal("expected inputs to signal replaceability")
+	}
+}
+
+func TestBuildTxLeavesDustChangeAsFee(t *testing.T) {
+	t.Parallel()
+
+	sender, recipient := regtestAddresses(t)
+	ad := NewAdapter(RegTest, newFakeSource(UTXO{TxID: txID("a"), Value: 10_400, Height: 1}))
+
+	tx, err := ad.BuildTx(context.Background(), sender, recipient, big.NewInt(10_000), adapter.FeeHint{FeeRate: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msgTx := decodeTx(t, tx.Data); len(msgTx.TxOut) != 1 || tx.Fee != 400 {
+		t.Fatalf("got %d outputs and fee=%d, want 1 output and fee=400", len(msgTx.TxOut), tx.Fee)
+	}
+}
+
+func TestBuildTxRejectsUnfundedRequests(t *testing.T) {
+	t.Parallel()
+
+	sender, recipient := regtestAddresses(t)
+	ad := NewAdapter(RegTest, newFakeSource(UTXO{TxID: txID("a"), Value: 10_000, Height: 1}, UTXO{TxID: txID("b"), Value: 90_000}))
+	ctx := context.Background()
+
+	if _, err := ad.BuildTx(ctx, sender, recipient, big.NewInt(10_000), adapter.FeeHint{}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got err=%v, want ErrInsufficientFunds", err)
+	}
+
+	if _, err := ad.BuildTx(ctx, sender, recipient, big.NewInt(100), adapter.FeeHint{}); !errors.Is(err, ErrDustAmount) {
+		t.Fatalf("got err=%v, want ErrDustAmount", err)
+	}
+
+	if _, err := NewAdapter(RegTest, nil).BuildTx(ctx, sender, recipient, big.NewInt(1000), adapter.FeeHint{}); !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("got err=%v, want ErrNoBackend", err)
+	}
+
+	if _, err := ad.BuildTx(ctx, sender, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", big.NewInt(1000), adapter.FeeHint{}); err == nil {
+		t.Fatal("expected a mainnet recipient to be rejected on regtest")
+	}
+}
+
+func TestBuildTxUsesEstimatedFeeRate(t *testing.T) {
+	t.Parallel()
+
+	sender, recipient := regtestAddresses(t)
+	source := newFakeSource(UTXO{TxID: txID("a"), Value: 100_000, Height: 1})
+	ad := NewAdapter(RegTest, source)
+	ctx := context.Background()
+
+	tx, err := ad.BuildTx(ctx, sender, recipient, big.NewInt(30_000), adapter.FeeHint{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One P2WPKH input and P2TR and P2WPKH outputs weigh 610 WU, 153 vB.
+	if tx.FeeRate != RegTest.FeeRate || tx.VSize != 153 || tx.Fee != 153*RegTest.FeeRate {
+		t.Fatalf("without an estimate: got rate=%d vsize=%d fee=%d", tx.FeeRate, tx.VSize, tx.Fee)
+	}
+
+	source.feeRate = 12
+
+	if tx, err = ad.BuildTx(ctx, sender, recipient, big.NewInt(30_000), adapter.FeeHint{}); err != nil || tx.FeeRate != 12 || tx.Fee != 153*12 {
+		t.Fatalf("estimated: got rate=%d fee=%d err=%v", tx.FeeRate, tx.Fee, err)
+	}
+
+	if tx, err = ad.BuildTx(ctx, sender, recipient, big.NewInt(30_000), adapter.FeeHint{FeeRate: 3}); err != nil || tx.FeeRate != 3 || tx.Fee != 153*3 {
+		t.Fatalf("hinted: got rate=%d fee=%d err=%v", tx.FeeRate, tx.Fee, err)
+	}
+
+	// Gas prices are for EVM networks and never set a bitcoin fee rate.
+	if tx, err = ad.BuildTx(ctx, sender, recipient, big.NewInt(30_000), adapter.FeeHint{MaxFeePerGas: 30_000_000_000}); err != nil || tx.FeeRate != 12 {
+		t.Fatalf("gas price: got rate=%d err=%v", tx.FeeRate, err)
+	}
+}
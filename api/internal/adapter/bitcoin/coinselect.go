@@ -0,0 +1,388 @@
+package bitcoin
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// Coin selection strategies. Auto looks for a changeless Branch-and-Bound
+// match first and otherwise keeps whichever of knapsack and largest-first
+// wastes less.
+const (
+	CoinSelectionAuto           = "auto"
+	CoinSelectionBranchAndBound = "bnb"
+	CoinSelectionKnapsack       = "knapsack"
+	CoinSelectionLargestFirst   = "largest-first"
+)
+
+var coinSelections = []string{CoinSelectionAuto, CoinSelectionBranchAndBound, CoinSelectionKnapsack, CoinSelectionLargestFirst}
+
+const (
+	branchAndBoundTries = 100_000
+	knapsackIterations  = 1000
+)
+
+// spendRequest is what coin selection has to pay for. Fees are in sat/vB;
+// the long-term rate prices spending change later. Every input weighs the
+// same, since they all spend the sender's outputs.
+type spendRequest struct {
+	amount          uint64
+	feeRate         uint64
+	longTermFeeRate uint64
+	inputWeight     int64
+	recipientScript []byte
+	changeScript    []byte
+}
+
+// selection is a funded transaction: the inputs, the change back to the
+// sender (zero for none), the fee and the estimated virtual size.
+type selection struct {
+	inputs []UTXO
+	change uint64
+	fee    uint64
+	vsize  uint64
+	waste  int64
+}
+
+// inputFee is what adding one input costs at the rate. Pricing each part of
+// the transaction by its rounded-up vsize never undercuts feeFor the whole.
+func (request spendRequest) inputFee(feeRate uint64) uint64 {
+	return feeFor(request.inputWeight, feeRate)
+}
+
+func (request spendRequest) effectiveValue(utxo UTXO) int64 {
+	return int64(utxo.Value) - int64(request.inputFee(request.feeRate))
+}
+
+// target is what the inputs' effective values must cover: the amount plus
+// the fee for everything but the inputs.
+func (request spendRequest) target(withChange bool) uint64 {
+	weight := txWeight(request.inputWeight, 0, request.recipientScript)
+
+	if withChange {
+		weight += outputWeight(request.changeScript)
+	}
+
+	return request.amount + feeFor(weight, request.feeRate)
+}
+
+// costOfChange is what a change output costs now plus what spending it will
+// cost later; a changeless match may overshoot the target by this much.
+func (request spendRequest) costOfChange() uint64 {
+	return feeFor(outputWeight(request.changeScript), request.feeRate) + request.inputFee(request.longTermFeeRate)
+}
+
+// minChange is the smallest change output worth creating.
+func (request spendRequest) minChange() uint64 {
+	return max(dustThreshold(request.changeScript), request.costOfChange())
+}
+
+// finish prices the chosen inputs exactly, adds change when it is worth
+// creating and leaves any smaller excess to the miner.
+func (request spendRequest) finish(inputs []UTXO) (selection, bool) {
+	var total uint64
+
+	for _, utxo := range inputs {
+		total += utxo.Value
+	}
+
+	chosen := selection{inputs: inputs}
+	withChange := txWeight(request.inputWeight, len(inputs), request.recipientScript, request.changeScript)
+	changeFee := feeFor(withChange, request.feeRate)
+
+	if total >= request.amount+changeFee && total-request.amount-changeFee >= request.minChange() {
+		chosen.change = total - request.amount - changeFee
+		chosen.fee = changeFee
+		chosen.vsize = uint64((withChange + 3) / 4)
+		chosen.waste = request.inputWaste(len(inputs)) + int64(request.costOfChange())
+
+		return chosen, true
+	}
+
+	withoutChange := txWeight(request.inputWeight, len(inputs), request.recipientScript)
+	exactFee := feeFor(withoutChange, request.feeRate)
+
+	if total < request.amount+exactFee {
+		return selection{}, false
+	}
+
+	chosen.fee = total - request.amount
+	chosen.vsize = uint64((withoutChange + 3) / 4)
+	chosen.waste = request.inputWaste(len(inputs)) + int64(chosen.fee-exactFee)
+
+	return chosen, true
+}
+
+// inputWaste is what spending the inputs now costs over spending them at the
+// long-term rate, negative when fees are cheap.
+func (request spendRequest) inputWaste(inputs int) int64 {
+	return int64(inputs) * (int64(request.inputFee(request.feeRate)) - int64(request.inputFee(request.longTermFeeRate)))
+}
+
+// spendable sorts the outputs that are worth spending at the fee rate by
+// effective value, largest first.
+func (request spendRequest) spendable(utxos []UTXO) []UTXO {
+	candidates := make([]UTXO, 0, len(utxos))
+
+	for _, utxo := range utxos {
+		if request.effectiveValue(utxo) > 0 {
+			candidates = append(candidates, utxo)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Value > candidates[j].Value })
+
+	return candidates
+}
+
+func selectCoins(strategy string, utxos []UTXO, request spendRequest) (selection, error) {
+	if strategy == "" {
+		strategy = CoinSelectionAuto
+	}
+
+	candidates := request.spendable(utxos)
+	var chosen selection
+	var found bool
+
+	switch strategy {
+	case CoinSelectionBranchAndBound:
+		chosen, found = request.finish(branchAndBound(candidates, request))
+
+	case CoinSelectionKnapsack:
+		chosen, found = request.finish(knapsack(candidates, request))
+
+	case CoinSelectionLargestFirst:
+		chosen, found = request.finish(largestFirst(candidates, request))
+
+	case CoinSelectionAuto:
+		if inputs := branchAndBound(candidates, request); inputs != nil {
+			chosen, found = request.finish(inputs)
+
+			break
+		}
+
+		chosen, found = request.finish(knapsack(candidates, request))
+		fallback, fallbackFound := request.finish(largestFirst(candidates, request))
+
+		if fallbackFound && (!found || fallback.waste < chosen.waste) {
+			chosen, found = fallback, true
+		}
+
+	default:
+		return selection{}, fmt.Errorf("unknown coin selection %q, expected one of %v", strategy, coinSelections)
+	}
+
+	if !found {
+		var total uint64
+
+		for _, utxo := range utxos {
+			total += utxo.Value
+		}
+
+		return selection{}, fmt.Errorf("%w: %s found no inputs paying %d sat plus fees at %d sat/vB from %d sat", ErrInsufficientFunds, strategy, request.amount, request.feeRate, total)
+	}
+
+	return chosen, nil
+}
+
+// largestFirst adds the largest outputs until they pay for the transaction.
+func largestFirst(candidates []UTXO, request spendRequest) []UTXO {
+	for count := 1; count <= len(candidates); count++ {
+		if _, ok := request.finish(candidates[:count]); ok {
+			return candidates[:count]
+		}
+	}
+
+	return nil
+}
+
+// branchAndBound searches for inputs whose effective value lands between the
+// changeless target and that target plus the cost of change, so no change
+// output is needed, preferring the least waste. It gives up after a fixed
+// number of tries and returns nil when there is no such match.
+func branchAndBound(candidates []UTXO, request spendRequest) []UTXO {
+	target := int64(request.target(false))
+	window := int64(request.costOfChange())
+	inputWaste := int64(request.inputFee(request.feeRate)) - int64(request.inputFee(request.longTermFeeRate))
+	values := make([]int64, len(candidates))
+	remaining := int64(0)
+
+	for i, utxo := range candidates {
+		values[i] = request.effectiveValue(utxo)
+		remaining += values[i]
+	}
+
+	var best []int
+	bestWaste := int64(math.MaxInt64)
+	selected := make([]int, 0, len(candidates))
+	tries := 0
+
+	var search func(index int, value, remaining, waste int64)
+	search = func(index int, value, remaining, waste int64) {
+		tries++
+
+		if tries > branchAndBoundTries || value > target+window || value+remaining < target {
+			return
+		}
+
+		if value >= target {
+			if total := waste + value - target; total < bestWaste {
+				best = append(best[:0], selected...)
+				bestWaste = total
+			}
+
+			return
+		}
+
+		if index == len(values) {
+			return
+		}
+
+		// Including an output equal to the one just left out explores the
+		// same sums as the branch that included that one instead.
+		previousLeftOut := index > 0 && values[index] == values[index-1] && (len(selected) == 0 || selected[len(selected)-1] != index-1)
+
+		if !previousLeftOut {
+			selected = append(selected, index)
+			search(index+1, value+values[index], remaining-values[index], waste+inputWaste)
+			selected = selected[:len(selected)-1]
+		}
+
+		search(index+1, value, remaining-values[index], waste)
+	}
+
+	search(0, 0, remaining, 0)
+
+	if best == nil {
+		return nil
+	}
+
+	inputs := make([]UTXO, 0, len(best))
+
+	for _, index := range best {
+		inputs = append(inputs, candidates[index])
+	}
+
+	return inputs
+}
+
+// knapsack is Bitcoin Core's original selection: an exact match if there is
+// one, otherwise the better of the smallest single output that covers the
+// target and a randomized search for the subset of smaller outputs that
+// overshoots the least. It first aims to leave room for change and falls back
+// to the changeless target.
+func knapsack(candidates []UTXO, request spendRequest) []UTXO {
+	for _, target := range []uint64{request.target(true) + request.minChange(), request.target(false)} {
+		if inputs := knapsackTarget(candidates, request, int64(target)); inputs != nil {
+			return inputs
+		}
+	}
+
+	return nil
+}
+
+func knapsackTarget(candidates []UTXO, request spendRequest, target int64) []UTXO {
+	shuffled := append([]UTXO(nil), candidates...)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	var smaller []UTXO
+	var smallerTotal int64
+	var lowestLarger *UTXO
+
+	for i, utxo := range shuffled {
+		value := request.effectiveValue(utxo)
+
+		switch {
+		case value == target:
+			return []UTXO{utxo}
+
+		case value < target:
+			smaller = append(smaller, utxo)
+			smallerTotal += value
+
+		case lowestLarger == nil || value < request.effectiveValue(*lowestLarger):
+			lowestLarger = &shuffled[i]
+		}
+	}
+
+	if smallerTotal == target {
+		return smaller
+	}
+
+	if smallerTotal < target {
+		if lowestLarger == nil {
+			return nil
+		}
+
+		return []UTXO{*lowestLarger}
+	}
+
+	sort.SliceStable(smaller, func(i, j int) bool { return smaller[i].Value > smaller[j].Value })
+	values := make([]int64, len(smaller))
+
+	for i, utxo := range smaller {
+		values[i] = request.effectiveValue(utxo)
+	}
+
+	included, best := approximateBestSubset(values, smallerTotal, target)
+
+	if lowestLarger != nil && (best != target && request.effectiveValue(*lowestLarger) <= best) {
+		return []UTXO{*lowestLarger}
+	}
+
+	inputs := make([]UTXO, 0, len(smaller))
+
+	for i, utxo := range smaller {
+		if included[i] {
+			inputs = append(inputs, utxo)
+		}
+	}
+
+	return inputs
+}
+
+// approximateBestSubset randomly includes values, then fills in the rest in
+// order, keeping the subset that reaches the target with the smallest total.
+func approximateBestSubset(values []int64, total, target int64) ([]bool, int64) {
+	best := make([]bool, len(values))
+	bestTotal := total
+
+	for i := range best {
+		best[i] = true
+	}
+
+	included := make([]bool, len(values))
+
+	for iteration := 0; iteration < knapsackIterations && bestTotal != target; iteration++ {
+		clear(included)
+		var sum int64
+		reached := false
+
+		for pass := 0; pass < 2 && !reached; pass++ {
+			for i, value := range values {
+				if pass == 0 && rand.IntN(2) == 0 || pass == 1 && included[i] {
+					continue
+				}
+
+				sum += value
+				included[i] = true
+
+				if sum >= target {
+					reached = true
+
+					if sum < bestTotal {
+						bestTotal = sum
+						copy(best, included)
+					}
+
+					sum -= value
+					included[i] = false
+				}
+			}
+		}
+	}
+
+	return best, bestTotal
+}
@@ -0,0 +1,228 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func testSpendRequest(amount, feeRate uint64) spendRequest {
+	script, _ := hex.DecodeString("0014751e76e8199196d454941c45d1b3a323f1433bd6")
+
+	return spendRequest{
+		amount:          amount,
+		feeRate:         feeRate,
+		longTermFeeRate: 2,
+		inputWeight:     p2wpkhInputWeight,
+		recipientScript: script,
+		changeScript:    script,
+	}
+}
+
+func testUTXOs(values ...uint64) []UTXO {
+	utxos := make([]UTXO, 0, len(values))
+
+	for i, value := range values {
+		utxos = append(utxos, UTXO{TxID: txID("a"), Vout: uint32(i), Value: value, Height: 1})
+	}
+
+	return utxos
+}
+
+// checkSelection verifies the selection balances and prices its inputs and
+// outputs at no less than the fee rate.
+func checkSelection(t *testing.T, chosen selection, request spendRequest) {
+	t.Helper()
+
+	var total uint64
+
+	for _, utxo := range chosen.inputs {
+		total += utxo.Value
+	}
+
+	if total != request.amount+chosen.fee+chosen.change {
+		t.Fatalf("inputs=%d do not pay amount=%d fee=%d change=%d", total, request.amount, chosen.fee, chosen.change)
+	}
+
+	outputs := [][]byte{request.recipientScript}
+
+	if chosen.change > 0 {
+		outputs = append(outputs, request.changeScript)
+
+		if chosen.change < request.minChange() {
+			t.Fatalf("change=%d is below the minimum of %d", chosen.change, request.minChange())
+		}
+	}
+
+	weight := txWeight(request.inputWeight, len(chosen.inputs), outputs...)
+
+	if chosen.fee < feeFor(weight, request.feeRate) || chosen.vsize != uint64((weight+3)/4) {
+		t.Fatalf("fee=%d vsize=%d for %d WU at %d sat/vB", chosen.fee, chosen.vsize, weight, request.feeRate)
+	}
+}
+
+func TestDustThreshold(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		script string
+		want   uint64
+	}{
+		"p2pkh":  {"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", 546},
+		"p2wpkh": {"0014751e76e8199196d454941c45d1b3a323f1433bd6", 294},
+		"p2tr":   {"5120d2f8a48a5ce6fa81d2d4d7b2c2a4b4a3b8e4b1e1e5d5b5a5f5e5d5c5b5a5f5e5", 330},
+	}
+
+	for name, testCase := range cases {
+		script, _ := hex.DecodeString(testCase.script)
+
+		if got := dustThreshold(script); got != testCase.want {
+			t.Fatalf("%s: got=%d want=%d", name, got, testCase.want)
+		}
+	}
+}
+
+func TestBranchAndBoundFindsChangelessMatch(t *testing.T) {
+	t.Parallel()
+
+	base := testSpendRequest(0, 3)
+	utxos := testUTXOs(80_000, 31_000, 12_000, 9_000, 4_000)
+	// Pay exactly what the 31,000 and 9,000 outputs are worth after their fees.
+	request := testSpendRequest(uint64(base.effectiveValue(utxos[1])+base.effectiveValue(utxos[3]))-base.target(false), 3)
+
+	chosen, err := selectCoins(CoinSelectionBranchAndBound, utxos, request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSelection(t, chosen, request)
+
+	if len(chosen.inputs) != 2 || chosen.inputs[0].Value != 31_000 || chosen.inputs[1].Value != 9_000 || chosen.change != 0 {
+		t.Fatalf("got inputs=%+v change=%d", chosen.inputs, chosen.change)
+	}
+
+	if auto, err := selectCoins(CoinSelectionAuto, utxos, request); err != nil || auto.change != 0 || len(auto.inputs) != 2 {
+		t.Fatalf("auto: got inputs=%+v change=%d err=%v", auto.inputs, auto.change, err)
+	}
+
+	if _, err := selectCoins(CoinSelectionBranchAndBound, utxos, testSpendRequest(50_000, 3)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("without a match: got err=%v, want ErrInsufficientFunds", err)
+	}
+}
+
+func TestBranchAndBoundSkipsEquivalentOutputs(t *testing.T) {
+	t.Parallel()
+
+	base := testSpendRequest(0, 1)
+	values := make([]uint64, 0, 40)
+
+	for range 40 {
+		values = append(values, 10_000)
+	}
+
+	// Twenty equal outputs cover the amount exactly; exploring every
+	// combination of them would run out of tries long before finding it.
+	utxos := testUTXOs(values...)
+	request := testSpendRequest(uint64(20*base.effectiveValue(utxos[0]))-base.target(false), 1)
+
+	chosen, err := selectCoins(CoinSelectionBranchAndBound, utxos, request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chosen.inputs) != 20 || chosen.change != 0 {
+		t.Fatalf("got %d inputs and change=%d, want 20 and no change", len(chosen.inputs), chosen.change)
+	}
+}
+
+func TestLargestFirst(t *testing.T) {
+	t.Parallel()
+
+	request := testSpendRequest(60_000, 5)
+	chosen, err := selectCoins(CoinSelectionLargestFirst, testUTXOs(20_000, 50_000, 3_000, 15_000), request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSelection(t, chosen, request)
+
+	if len(chosen.inputs) != 2 || chosen.inputs[0].Value != 50_000 || chosen.inputs[1].Value != 20_000 || chosen.change == 0 {
+		t.Fatalf("got inputs=%+v change=%d", chosen.inputs, chosen.change)
+	}
+}
+
+func TestKnapsackPaysTheTarget(t *testing.T) {
+	t.Parallel()
+
+	utxos := testUTXOs(1_200, 7_000, 13_000, 26_000, 2_500, 40_000, 650, 9_800, 18_000, 5_400)
+
+	for _, amount := range []uint64{1_000, 6_000, 21_000, 47_000, 90_000, 120_000} {
+		request := testSpendRequest(amount, 2)
+
+		for range 20 {
+			chosen, err := selectCoins(CoinSelectionKnapsack, utxos, request)
+
+			if err != nil {
+				t.Fatalf("amount=%d: %v", amount, err)
+			}
+
+			checkSelection(t, chosen, request)
+		}
+	}
+
+	if _, err := selectCoins(CoinSelectionKnapsack, utxos, testSpendRequest(130_000, 2)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got err=%v, want ErrInsufficientFunds", err)
+	}
+}
+
+func TestSelectCoinsLeavesSmallChangeAsFee(t *testing.T) {
+	t.Parallel()
+
+	request := testSpendRequest(10_000, 1)
+
+	for _, strategy := range []string{CoinSelectionAuto, CoinSelectionKnapsack, CoinSelectionLargestFirst} {
+		chosen, err := selectCoins(strategy, testUTXOs(10_400), request)
+
+		if err != nil {
+			t.Fatalf("%s: %v", strategy, err)
+		}
+
+		checkSelection(t, chosen, request)
+
+		if chosen.change != 0 || chosen.fee != 400 {
+			t.Fatalf("%s: got change=%d fee=%d, want no change and fee=400", strategy, chosen.change, chosen.fee)
+		}
+	}
+}
+
+func TestSelectCoinsSkipsUneconomicalOutputs(t *testing.T) {
+	t.Parallel()
+
+	// At 50 sat/vB a P2WPKH input costs 3,400 sat, more than the small outputs
+	// are worth.
+	request := testSpendRequest(20_000, 50)
+	chosen, err := selectCoins(CoinSelectionLargestFirst, testUTXOs(3_000, 30_000, 3_000, 3_000), request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chosen.inputs) != 1 || chosen.inputs[0].Value != 30_000 {
+		t.Fatalf("got inputs=%+v", chosen.inputs)
+	}
+
+	if _, err := selectCoins(CoinSelectionAuto, testUTXOs(3_000, 3_000, 3_000), testSpendRequest(1_000, 50)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got err=%v, want ErrInsufficientFunds", err)
+	}
+}
+
+func TestSelectCoinsRejectsUnknownStrategies(t *testing.T) {
+	t.Parallel()
+
+	if _, err := selectCoins("smallest-first", testUTXOs(50_000), testSpendRequest(1_000, 1)); err == nil || errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got err=%v, want an unknown strategy error", err)
+	}
+}
@@ -0,0 +1,485 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Output descriptor script types.
+const (
+	DescriptorWSHSortedMulti = "wsh-sortedmulti"
+	DescriptorTR             = "tr"
+)
+
+const (
+	maxMultisigKeys = 20
+
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+var ErrInvalidDescriptor = errors.New("invalid output descriptor")
+
+// Descriptor is a BIP-380 output descriptor the adapter can watch and spend
+// from: wsh(sortedmulti(k,KEY,...)) or tr(KEY) with no script tree. A KEY is
+// a hex public key or an extended public key with an optional [fingerprint/
+// path] origin, unhardened derivation steps, an optional <receive;change>
+// step and a trailing /* when the descriptor has a range of addresses.
+type Descriptor struct {
+	body       string
+	scriptType string
+	threshold  int
+	keys       []descriptorKey
+	params     *chaincfg.Params
+}
+
+type descriptorKey struct {
+	public      *btcec.PublicKey
+	extended    *hdkeychain.ExtendedKey
+	fingerprint uint32
+	path        []uint32
+	branches    []uint32
+	ranged      bool
+}
+
+// derivedKey is a descriptor key at one index with its BIP-32 origin.
+type derivedKey struct {
+	publicKey   *btcec.PublicKey
+	fingerprint uint32
+	path        []uint32
+}
+
+// ParseDescriptor parses the descriptor for the network. A checksum is
+// optional but must match when present.
+func ParseDescriptor(text string, params *chaincfg.Params) (*Descriptor, error) {
+	body, checksum, hasChecksum := strings.Cut(strings.TrimSpace(text), "#")
+	want, err := descriptorChecksum(body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if hasChecksum && checksum != want {
+		return nil, fmt.Errorf("%w: checksum %q, expected %q", ErrInvalidDescriptor, checksum, want)
+	}
+
+	descriptor := &Descriptor{body: body, params: params}
+	var expressions []string
+
+	switch {
+	case strings.HasPrefix(body, "wsh(sortedmulti(") && strings.HasSuffix(body, "))"):
+		arguments := strings.Split(strings.TrimSuffix(strings.TrimPrefix(body, "wsh(sortedmulti("), "))"), ",")
+		threshold, err := strconv.Atoi(arguments[0])
+		expressions = arguments[1:]
+
+		if err != nil || threshold < 1 || threshold > len(expressions) || len(expressions) > maxMultisigKeys {
+			return nil, fmt.Errorf("%w: sortedmulti needs 1 <= k <= n <= %d, got %s of %d keys", ErrInvalidDescriptor, maxMultisigKeys, arguments[0], len(expressions))
+		}
+
+		descriptor.scriptType = DescriptorWSHSortedMulti
+		descriptor.threshold = threshold
+
+	case strings.HasPrefix(body, "tr(") && strings.HasSuffix(body, ")"):
+		expression := strings.TrimSuffix(strings.TrimPrefix(body, "tr("), ")")
+
+		if strings.Contains(expression, ",") {
+			return nil, fmt.Errorf("%w: tr script trees are not supported", ErrInvalidDescriptor)
+		}
+
+		descriptor.scriptType = DescriptorTR
+		descriptor.threshold = 1
+		expressions = []string{expression}
+
+	default:
+		return nil, fmt.Errorf("%w: only wsh(sortedmulti(...)) and tr(KEY) are supported", ErrInvalidDescriptor)
+	}
+
+	for _, expression := range expressions {
+		key, err := parseDescriptorKey(expression, params, descriptor.scriptType == DescriptorTR)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %v", ErrInvalidDescriptor, expression, err)
+		}
+
+		descriptor.keys = append(descriptor.keys, key)
+	}
+
+	return descriptor, nil
+}
+
+// parseDescriptorKey parses a key expression. Taproot keys may also be given
+// as 32-byte x-only public keys.
+func parseDescriptorKey(expression string, params *chaincfg.Params, xOnly bool) (descriptorKey, error) {
+	var key descriptorKey
+	var originPath []uint32
+	hasOrigin := strings.HasPrefix(expression, "[")
+
+	if hasOrigin {
+		origin, rest, found := strings.Cut(expression[1:], "]")
+
+		if !found {
+			return key, errors.New("unterminated key origin")
+		}
+
+		steps := strings.Split(origin, "/")
+		fingerprint, err := hex.DecodeString(steps[0])
+
+		if err != nil || len(fingerprint) != 4 {
+			return key, errors.New("key origin fingerprint must be 8 hex characters")
+		}
+
+		key.fingerprint = binary.LittleEndian.Uint32(fingerprint)
+
+		for _, step := range steps[1:] {
+			index, err := parsePathStep(step, true)
+
+			if err != nil {
+				return key, err
+			}
+
+			originPath = append(originPath, index)
+		}
+
+		expression = rest
+	}
+
+	steps := strings.Split(expression, "/")
+
+	if publicKey, err := hex.DecodeString(steps[0]); err == nil {
+		if len(steps) > 1 {
+			return key, errors.New("a hex public key cannot be derived from")
+		}
+
+		switch {
+		case xOnly && len(publicKey) == schnorr.PubKeyBytesLen:
+			key.public, err = schnorr.ParsePubKey(publicKey)
+
+		case len(publicKey) == btcec.PubKeyBytesLenCompressed:
+			key.public, err = btcec.ParsePubKey(publicKey)
+
+		default:
+			err = errors.New("expected a compressed public key")
+		}
+
+		if err != nil {
+			return key, err
+		}
+
+		key.path = originPath
+
+		return key, nil
+	}
+
+	extended, err := hdkeychain.NewKeyFromString(steps[0])
+
+	if err != nil {
+		return key, err
+	}
+
+	if extended.IsPrivate() {
+		return key, errors.New("private keys do not belong in a watch-only descriptor")
+	}
+
+	if !extended.IsForNet(params) {
+		return key, errors.New("extended key is for another network")
+	}
+
+	if !hasOrigin {
+		publicKey, err := extended.ECPubKey()
+
+		if err != nil {
+			return key, err
+		}
+
+		key.fingerprint = binary.LittleEndian.Uint32(btcutil.Hash160(publicKey.SerializeCompressed())[:4])
+	}
+
+	key.extended = extended
+	key.path = originPath
+
+	for i, step := range steps[1:] {
+		last := i == len(steps)-2
+
+		switch {
+		case step == "*":
+			if !last {
+				return key, errors.New("/* must be the last step")
+			}
+
+			key.ranged = true
+
+		case strings.HasPrefix(step, "<") && strings.HasSuffix(step, ">"):
+			branches := strings.Split(step[1:len(step)-1], ";")
+
+			if key.branches != nil || len(branches) != 2 || !(last || i == len(steps)-3 && steps[len(steps)-1] == "*") {
+				return key, errors.New("expected a single <receive;change> step before /*")
+			}
+
+			for _, branch := range branches {
+				index, err := parsePathStep(branch, false)
+
+				if err != nil {
+					return key, err
+				}
+
+				key.branches = append(key.branches, index)
+			}
+
+		default:
+			index, err := parsePathStep(step, false)
+
+			if err != nil {
+				return key, err
+			}
+
+			if key.extended, err = key.extended.Derive(index); err != nil {
+				return key, err
+			}
+
+			key.path = append(key.path, index)
+		}
+	}
+
+	return key, nil
+}
+
+// parsePathStep parses a BIP-32 child index, hardened with ' or h.
+func parsePathStep(step string, hardenedAllowed bool) (uint32, error) {
+	trimmed := strings.TrimRight(step, "'h")
+	hardened := trimmed != step
+	index, err := strconv.ParseUint(trimmed, 10, 32)
+
+	if err != nil || index >= hdkeychain.HardenedKeyStart || len(step)-len(trimmed) > 1 {
+		return 0, fmt.Errorf("invalid derivation step %q", step)
+	}
+
+	if hardened && !hardenedAllowed {
+		return 0, fmt.Errorf("hardened step %q cannot be derived from a public key", step)
+	}
+
+	if hardened {
+		index += hdkeychain.HardenedKeyStart
+	}
+
+	return uint32(index), nil
+}
+
+// String is the descriptor with its checksum.
+func (descriptor *Descriptor) String() string {
+	checksum, _ := descriptorChecksum(descriptor.body)
+
+	return descriptor.body + "#" + checksum
+}
+
+// Ranged reports whether the descriptor has a range of addresses rather than
+// a single one.
+func (descriptor *Descriptor) Ranged() bool {
+	for _, key := range descriptor.keys {
+		if key.ranged {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasChange reports whether change has its own branch; otherwise it goes to
+// the receive addresses.
+func (descriptor *Descriptor) hasChange() bool {
+	for _, key := range descriptor.keys {
+		if key.branches != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Address is the descriptor's receive or change address at the index.
+func (descriptor *Descriptor) Address(change bool, index uint32) (string, error) {
+	info, err := descriptor.derive(change, index)
+
+	if err != nil {
+		return "", err
+	}
+
+	return info.address.EncodeAddress(), nil
+}
+
+func (key descriptorKey) derive(change bool, index uint32) (derivedKey, error) {
+	if key.extended == nil {
+		return derivedKey{publicKey: key.public, fingerprint: key.fingerprint, path: key.path}, nil
+	}
+
+	extended := key.extended
+	path := append([]uint32(nil), key.path...)
+	steps := []uint32{}
+
+	if key.branches != nil {
+		branch := key.branches[0]
+
+		if change {
+			branch = key.branches[1]
+		}
+
+		steps = append(steps, branch)
+	}
+
+	if key.ranged {
+		steps = append(steps, index)
+	}
+
+	for _, step := range steps {
+		var err error
+
+		if extended, err = extended.Derive(step); err != nil {
+			return derivedKey{}, err
+		}
+
+		path = append(path, step)
+	}
+
+	publicKey, err := extended.ECPubKey()
+
+	if err != nil {
+		return derivedKey{}, err
+	}
+
+	return derivedKey{publicKey: publicKey, fingerprint: key.fingerprint, path: path}, nil
+}
+
+// derive is the descriptor's receive or change address at the index with
+// what a signer needs to spend from it.
+func (descriptor *Descriptor) derive(change bool, index uint32) (spendInfo, error) {
+	if index >= hdkeychain.HardenedKeyStart || !descriptor.Ranged() && index > 0 {
+		return spendInfo{}, fmt.Errorf("descriptor has no address at index %d", index)
+	}
+
+	keys := make([]derivedKey, 0, len(descriptor.keys))
+
+	for _, key := range descriptor.keys {
+		derived, err := key.derive(change, index)
+
+		if err != nil {
+			return spendInfo{}, err
+		}
+
+		keys = append(keys, derived)
+	}
+
+	info := spendInfo{keys: keys}
+	var err error
+
+	if descriptor.scriptType == DescriptorTR {
+		info.internalKey = keys[0].publicKey
+		info.address, err = btcutil.NewAddressTaproot(schnorr.SerializePubKey(txscript.ComputeTaprootKeyNoScript(info.internalKey)), descriptor.params)
+	} else {
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i].publicKey.SerializeCompressed(), keys[j].publicKey.SerializeCompressed()) < 0
+		})
+
+		builder := txscript.NewScriptBuilder().AddInt64(int64(descriptor.threshold))
+
+		for _, key := range keys {
+			builder.AddData(key.publicKey.SerializeCompressed())
+		}
+
+		info.witnessScript, err = builder.AddInt64(int64(len(keys))).AddOp(txscript.OP_CHECKMULTISIG).Script()
+
+		if err != nil {
+			return spendInfo{}, err
+		}
+
+		scriptHash := sha256.Sum256(info.witnessScript)
+		info.address, err = btcutil.NewAddressWitnessScriptHash(scriptHash[:], descriptor.params)
+	}
+
+	if err != nil {
+		return spendInfo{}, err
+	}
+
+	info.script, err = txscript.PayToAddrScript(info.address)
+
+	return info, err
+}
+
+// inputWeight is the weight of an input spending one of the descriptor's
+// outputs, with threshold signatures for a multisig.
+func (descriptor *Descriptor) inputWeight() int64 {
+	if descriptor.scriptType == DescriptorTR {
+		return p2trInputWeight
+	}
+
+	scriptSize := 3 + 34*len(descriptor.keys)
+	witnessSize := 1 + 1 + descriptor.threshold*(1+72) + wire.VarIntSerializeSize(uint64(scriptSize)) + scriptSize
+
+	return 4*(36+1+4) + int64(witnessSize)
+}
+
+// descriptorChecksum computes the BIP-380 checksum of a descriptor.
+func descriptorChecksum(body string) (string, error) {
+	checksum := uint64(1)
+	classes, classCount := 0, 0
+
+	for _, character := range body {
+		position := strings.IndexRune(descriptorInputCharset, character)
+
+		if position < 0 {
+			return "", fmt.Errorf("%w: unexpected character %q", ErrInvalidDescriptor, character)
+		}
+
+		checksum = descriptorPolymod(checksum, position&31)
+		classes = classes*3 + position>>5
+		classCount++
+
+		if classCount == 3 {
+			checksum = descriptorPolymod(checksum, classes)
+			classes, classCount = 0, 0
+		}
+	}
+
+	if classCount > 0 {
+		checksum = descriptorPolymod(checksum, classes)
+	}
+
+	for range 8 {
+		checksum = descriptorPolymod(checksum, 0)
+	}
+
+	checksum ^= 1
+	encoded := make([]byte, 8)
+
+	for i := range encoded {
+		encoded[i] = descriptorChecksumCharset[(checksum>>(5*(7-i)))&31]
+	}
+
+	return string(encoded), nil
+}
+
+func descriptorPolymod(checksum uint64, value int) uint64 {
+	top := checksum >> 35
+	checksum = (checksum&0x7ffffffff)<<5 ^ uint64(value)
+
+	for i, generator := range []uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd} {
+		if top>>i&1 == 1 {
+			checksum ^= generator
+		}
+	}
+
+	return checksum
+}
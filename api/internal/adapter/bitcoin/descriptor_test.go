@@ -0,0 +1,229 @@
+package bitcoin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// bip86Root is the master key of the "abandon ... about" mnemonic used by the
+// BIP-86 test vectors.
+const bip86Root = "xprv9s21ZrQH143K3GJpoapnV8SFfukcVBSfeCficPSGfubmSFDxo1kuHnLisriDvSnRRuL2Qrg5ggqHKNVpxR86QEC8w35uxmGoggxtQTPvfUu"
+
+// cosigner is a multisig participant: a master key and its account key at
+// m/48'/1'/0'/2', as BIP-48 lays out P2WSH multisig accounts.
+type cosigner struct {
+	master  *hdkeychain.ExtendedKey
+	account *hdkeychain.ExtendedKey
+}
+
+func newCosigner(t *testing.T, seed string) cosigner {
+	t.Helper()
+
+	seedHash := btcutil.Hash160([]byte(seed))
+	master, err := hdkeychain.NewMaster(append(seedHash, seedHash...), RegTest.Params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := master
+
+	for _, step := range []uint32{48, 1, 0, 2} {
+		if account, err = account.Derive(step + hdkeychain.HardenedKeyStart); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return cosigner{master: master, account: account}
+}
+
+// expression is the cosigner's account key with its origin and receive and
+// change branches.
+func (signer cosigner) expression(t *testing.T) string {
+	t.Helper()
+
+	publicKey, _ := signer.master.ECPubKey()
+	fingerprint := make([]byte, 4)
+	binary.LittleEndian.PutUint32(fingerprint, binary.LittleEndian.Uint32(btcutil.Hash160(publicKey.SerializeCompressed())[:4]))
+	neutered, err := signer.account.Neuter()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "[" + hex.EncodeToString(fingerprint) + "/48h/1h/0h/2h]" + neutered.String() + "/<0;1>/*"
+}
+
+func multisigDescriptor(t *testing.T, threshold int, signers ...cosigner) string {
+	t.Helper()
+
+	keys := make([]string, 0, len(signers))
+
+	for _, signer := range signers {
+		keys = append(keys, signer.expression(t))
+	}
+
+	return fmt.Sprintf("wsh(sortedmulti(%d,%s))", threshold, strings.Join(keys, ","))
+}
+
+func TestDescriptorChecksum(t *testing.T) {
+	t.Parallel()
+
+	if checksum, err := descriptorChecksum("raw(deadbeef)"); err != nil || checksum != "89f8spxm" {
+		t.Fatalf("got=%s err=%v want=89f8spxm", checksum, err)
+	}
+}
+
+func TestTaprootDescriptorMatchesBIP86(t *testing.T) {
+	t.Parallel()
+
+	root, _ := hdkeychain.NewKeyFromString(bip86Root)
+	account := root
+
+	for _, step := range []uint32{86, 0, 0} {
+		account, _ = account.Derive(step + hdkeychain.HardenedKeyStart)
+	}
+
+	neutered, _ := account.Neuter()
+	descriptor, err := ParseDescriptor("tr([73c5da0a/86'/0'/0']"+neutered.String()+"/<0;1>/*)", MainNet.Params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		change bool
+		index  uint32
+		want   string
+	}{
+		{false, 0, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+		{false, 1, "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh"},
+		{true, 0, "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7"},
+	}
+
+	for _, testCase := range cases {
+		if got, err := descriptor.Address(testCase.change, testCase.index); err != nil || got != testCase.want {
+			t.Fatalf("change=%t index=%d: got=%s err=%v want=%s", testCase.change, testCase.index, got, err, testCase.want)
+		}
+	}
+
+	reparsed, err := ParseDescriptor(descriptor.String(), MainNet.Params)
+
+	if err != nil || reparsed.String() != descriptor.String() {
+		t.Fatalf("round trip: got=%v err=%v", reparsed, err)
+	}
+}
+
+func TestSortedMultiDescriptor(t *testing.T) {
+	t.Parallel()
+
+	alice, bob, carol := newCosigner(t, "alice"), newCosigner(t, "bob"), newCosigner(t, "carol")
+	descriptor, err := ParseDescriptor(multisigDescriptor(t, 2, alice, bob, carol), RegTest.Params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reordered, _ := ParseDescriptor(multisigDescriptor(t, 2, carol, alice, bob), RegTest.Params)
+
+	for _, change := range []bool{false, true} {
+		info, err := descriptor.derive(change, 7)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		branch := uint32(0)
+
+		if change {
+			branch = 1
+		}
+
+		// The witness script holds each cosigner's key at .../branch/7.
+		for _, signer := range []cosigner{alice, bob, carol} {
+			child, _ := signer.account.Derive(branch)
+			child, _ = child.Derive(7)
+			publicKey, _ := child.ECPubKey()
+
+			if !strings.Contains(hex.EncodeToString(info.witnessScript), hex.EncodeToString(publicKey.SerializeCompressed())) {
+				t.Fatalf("change=%t: witness script is missing a cosigner's key", change)
+			}
+		}
+
+		if publicKeys, threshold, err := txscript.CalcMultiSigStats(info.witnessScript); err != nil || publicKeys != 3 || threshold != 2 {
+			t.Fatalf("got %d-of-%d err=%v, want 2-of-3", threshold, publicKeys, err)
+		}
+
+		if other, _ := reordered.derive(change, 7); other.address.EncodeAddress() != info.address.EncodeAddress() {
+			t.Fatal("expected the address not to depend on the order of the keys")
+		}
+
+		if !strings.HasPrefix(info.address.EncodeAddress(), "bcrt1q") || len(info.keys) != 3 || len(info.keys[0].path) != 6 {
+			t.Fatalf("got address=%s with %d keys", info.address.EncodeAddress(), len(info.keys))
+		}
+	}
+
+	if receive, _ := descriptor.Address(false, 0); receive == "" || receive == mustAddress(t, descriptor, true, 0) {
+		t.Fatal("expected receive and change addresses to differ")
+	}
+}
+
+func mustAddress(t *testing.T, descriptor *Descriptor, change bool, index uint32) string {
+	t.Helper()
+
+	address, err := descriptor.Address(change, index)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return address
+}
+
+func TestParseDescriptorRejectsInvalidDescriptors(t *testing.T) {
+	t.Parallel()
+
+	alice, bob := newCosigner(t, "alice"), newCosigner(t, "bob")
+	valid := multisigDescriptor(t, 1, alice, bob)
+	checksum, _ := descriptorChecksum(valid)
+	private := strings.Replace(valid, alice.expression(t), "[00000000/48h/1h/0h/2h]"+alice.account.String()+"/<0;1>/*", 1)
+	mainnetRoot, _ := hdkeychain.NewKeyFromString(bip86Root)
+	mainnetPublic, _ := mainnetRoot.Neuter()
+
+	cases := map[string]string{
+		"checksum":          valid + "#" + strings.Repeat("q", 8),
+		"threshold":         strings.Replace(valid, "sortedmulti(1,", "sortedmulti(3,", 1),
+		"zero threshold":    strings.Replace(valid, "sortedmulti(1,", "sortedmulti(0,", 1),
+		"private key":       private,
+		"other network":     "tr(" + mainnetPublic.String() + "/0/*)",
+		"hardened step":     "tr(" + mainnetPublic.String() + "/0h/*)",
+		"wildcard position": "tr(" + mainnetPublic.String() + "/*/0)",
+		"script tree":       "tr(" + hex.EncodeToString(make([]byte, 32)) + ",pk(" + mainnetPublic.String() + "))",
+		"script type":       "pkh(" + mainnetPublic.String() + ")",
+		"character":         "tr(é)",
+	}
+
+	for name, text := range cases {
+		params := RegTest.Params
+
+		if name == "hardened step" || name == "wildcard position" {
+			params = MainNet.Params
+		}
+
+		if _, err := ParseDescriptor(text, params); !errors.Is(err, ErrInvalidDescriptor) {
+			t.Fatalf("%s: got err=%v, want ErrInvalidDescriptor", name, err)
+		}
+	}
+
+	if _, err := ParseDescriptor(valid+"#"+checksum, RegTest.Params); err != nil {
+		t.Fatalf("valid descriptor: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EsploraSource reads UTXOs from an Esplora-compatible REST API such as
+// Blockstream's or mempool.space's, e.g. https://mempool.space/api.
+type EsploraSource struct {
+	url    string
+	client *http.Client
+}
+
+func NewEsploraSource(baseURL string) *EsploraSource {
+	return &EsploraSource{url: strings.TrimSuffix(baseURL, "/"), client: newHTTPClient()}
+}
+
+func (source *EsploraSource) get(ctx context.Context, path string, out any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, source.url+path, nil)
+
+	if err != nil {
+		return err
+	}
+
+	response, err := source.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("esplora %s: %w", path, errNotFound)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("esplora %s: http %d", path, response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+var errNotFound = errors.New("not found")
+
+func (source *EsploraSource) UTXOs(ctx context.Context, address string) ([]UTXO, error) {
+	var unspents []struct {
+		TxID   string `json:"txid"`
+		Vout   uint32 `json:"vout"`
+		Value  uint64 `json:"value"`
+		Status struct {
+			Confirmed   bool   `json:"confirmed"`
+			BlockHeight uint64 `json:"block_height"`
+		} `json:"status"`
+	}
+
+	if err := source.get(ctx, "/address/"+url.PathEscape(address)+"/utxo", &unspents); err != nil {
+		return nil, err
+	}
+
+	utxos := make([]UTXO, 0, len(unspents))
+
+	for _, unspent := range unspents {
+		utxo := UTXO{TxID: unspent.TxID, Vout: unspent.Vout, Value: unspent.Value}
+
+		if unspent.Status.Confirmed {
+			utxo.Height = unspent.Status.BlockHeight
+		}
+
+		utxos = append(utxos, utxo)
+	}
+
+	return utxos, nil
+}
+
+// UsedAddresses asks /address for the number of confirmed and mempool
+// transactions of each address.
+func (source *EsploraSource) UsedAddresses(ctx context.Context, addresses []string) (map[string]bool, error) {
+	used := make(map[string]bool)
+
+	for _, address := range addresses {
+		var stats struct {
+			ChainStats struct {
+				TxCount uint64 `json:"tx_count"`
+			} `json:"chain_stats"`
+			MempoolStats struct {
+				TxCount uint64 `json:"tx_count"`
+			} `json:"mempool_stats"`
+		}
+
+		if err := source.get(ctx, "/address/"+url.PathEscape(address), &stats); err != nil {
+			return nil, err
+		}
+
+		if stats.ChainStats.TxCount+stats.MempoolStats.TxCount > 0 {
+			used[address] = true
+		}
+	}
+
+	return used, nil
+}
+
+// Broadcast posts the transaction hex to /tx, which answers with its txid.
+func (source *EsploraSource) Broadcast(ctx context.Context, rawTx []byte) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, source.url+"/tx", strings.NewReader(hex.EncodeToString(rawTx)))
+
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Content-Type", "text/plain")
+
+	response, err := source.client.Do(request)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 4096))
+
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("esplora /tx: http %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// FeeRate reads /fee-estimates, which maps confirmation targets to sat/vB,
+// and uses the nearest target that is no later than the one asked for.
+func (source *EsploraSource) FeeRate(ctx context.Context, targetBlocks int) (uint64, error) {
+	var estimates map[string]float64
+
+	if err := source.get(ctx, "/fee-estimates", &estimates); err != nil {
+		return 0, err
+	}
+
+	bestTarget := 0
+	var rate float64
+
+	for key, estimate := range estimates {
+		target, err := strconv.Atoi(key)
+
+		if err != nil || target > targetBlocks || target <= bestTarget {
+			continue
+		}
+
+		bestTarget, rate = target, estimate
+	}
+
+	if bestTarget == 0 || rate <= 0 {
+		return 0, ErrNoFeeEstimate
+	}
+
+	return uint64(math.Ceil(rate)), nil
+}
+
+// LookupTx reads /tx/:txid/status, and the tip height for a confirmed
+// transaction.
+func (source *EsploraSource) LookupTx(ctx context.Context, txID string) (TxLookup, error) {
+	var status struct {
+		Confirmed   bool   `json:"confirmed"`
+		BlockHeight uint64 `json:"block_height"`
+		BlockHash   string `json:"block_hash"`
+	}
+
+	if err := source.get(ctx, "/tx/"+url.PathEscape(txID)+"/status", &status); errors.Is(err, errNotFound) {
+		return TxLookup{}, nil
+	} else if err != nil {
+		return TxLookup{}, err
+	}
+
+	if !status.Confirmed {
+		return TxLookup{Found: true}, nil
+	}
+
+	var tipHeight uint64
+
+	if err := source.get(ctx, "/blocks/tip/height", &tipHeight); err != nil {
+		return TxLookup{}, err
+	}
+
+	return TxLookup{Found: true, Height: status.BlockHeight, BlockHash: status.BlockHash, TipHeight: tipHeight}, nil
+}
+
+// Spender reads /tx/:txid/outspend/:vout.
+func (source *EsploraSource) Spender(ctx context.Context, txID string, vout uint32) (string, bool, error) {
+	var outspend struct {
+		Spent bool   `json:"spent"`
+		TxID  string `json:"txid"`
+	}
+
+	if err := source.get(ctx, fmt.Sprintf("/tx/%s/outspend/%d", url.PathEscape(txID), vout), &outspend); err != nil {
+		return "", false, err
+	}
+
+	return outspend.TxID, outspend.Spent, nil
+}
+
+var _ UTXOSource = (*EsploraSource)(nil)
+var _ TxStatusSource = (*EsploraSource)(nil)
+var _ AddressHistorySource = (*EsploraSource)(nil)
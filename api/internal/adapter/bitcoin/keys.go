@@ -0,0 +1,98 @@
+package bitcoin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Address types a key can be encoded as.
+const (
+	AddressP2PKH      = "p2pkh"
+	AddressP2SHP2WPKH = "p2sh-p2wpkh"
+	AddressP2WPKH     = "p2wpkh"
+	AddressP2TR       = "p2tr"
+)
+
+const DefaultAddressType = AddressP2WPKH
+
+var addressTypes = []string{AddressP2PKH, AddressP2SHP2WPKH, AddressP2WPKH, AddressP2TR}
+
+// newPrivateKey derives the key from the seed, or generates a random one when
+// there is no seed.
+func newPrivateKey(seed []byte) (*btcec.PrivateKey, error) {
+	if len(seed) == 0 {
+		return btcec.NewPrivateKey()
+	}
+
+	seedHash := sha256.Sum256(seed)
+	privateKey, _ := btcec.PrivKeyFromBytes(seedHash[:])
+
+	return privateKey, nil
+}
+
+// DeriveAddress encodes the public key as an address of the given type. P2TR
+// addresses commit to the key with no script path, as in BIP-86.
+func DeriveAddress(publicKey *btcec.PublicKey, addressType string, params *chaincfg.Params) (btcutil.Address, error) {
+	publicKeyHash := btcutil.Hash160(publicKey.SerializeCompressed())
+
+	switch addressType {
+	case AddressP2PKH:
+		return btcutil.NewAddressPubKeyHash(publicKeyHash, params)
+
+	case AddressP2WPKH:
+		return btcutil.NewAddressWitnessPubKeyHash(publicKeyHash, params)
+
+	case AddressP2SHP2WPKH:
+		witnessAddress, err := btcutil.NewAddressWitnessPubKeyHash(publicKeyHash, params)
+
+		if err != nil {
+			return nil, err
+		}
+
+		redeemScript, err := txscript.PayToAddrScript(witnessAddress)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return btcutil.NewAddressScriptHash(redeemScript, params)
+
+	case AddressP2TR:
+		outputKey := txscript.ComputeTaprootKeyNoScript(publicKey)
+
+		return btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), params)
+	}
+
+	return nil, fmt.Errorf("unknown bitcoin address type %q", addressType)
+}
+
+// newKey returns the key as WIF, its compressed public key in hex and its
+// address of the given type.
+func newKey(seed []byte, addressType string, params *chaincfg.Params) (privateKey, publicKey, address string, err error) {
+	key, err := newPrivateKey(seed)
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	derived, err := DeriveAddress(key.PubKey(), addressType, params)
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	wif, err := btcutil.NewWIF(key, params, true)
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return wif.String(), hex.EncodeToString(key.PubKey().SerializeCompressed()), derived.EncodeAddress(), nil
+}
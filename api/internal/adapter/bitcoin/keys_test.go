@@ -0,0 +1,142 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestDeriveAddress(t *testing.T) {
+	t.Parallel()
+
+	// The well-known addresses of private key 1.
+	one := make([]byte, 32)
+	one[31] = 1
+	privateKey, _ := btcec.PrivKeyFromBytes(one)
+
+	tests := map[string]string{
+		AddressP2PKH:      "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+		AddressP2SHP2WPKH: "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+		AddressP2WPKH:     "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		AddressP2TR:       "bc1pmfr3p9j00pfxjh0zmgp99y8zftmd3s5pmedqhyptwy6lm87hf5sspknck9",
+	}
+
+	for addressType, want := range tests {
+		address, err := DeriveAddress(privateKey.PubKey(), addressType, &chaincfg.MainNetParams)
+
+		if err != nil {
+			t.Fatalf("%s: %v", addressType, err)
+		}
+
+		if got := address.EncodeAddress(); got != want {
+			t.Fatalf("%s: got=%s want=%s", addressType, got, want)
+		}
+	}
+
+	if _, err := DeriveAddress(privateKey.PubKey(), "p2wsh", &chaincfg.MainNetParams); err == nil {
+		t.Fatal("expected an error for an unknown address type")
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	t.Parallel()
+
+	adapter := NewAdapter(MainNet, nil)
+
+	for _, addressType := range adapter.AddressTypes() {
+		privateKey, publicKey, address, err := adapter.NewKeyWithAddressType([]byte("seed"), addressType)
+
+		if err != nil {
+			t.Fatalf("%s: %v", addressType, err)
+		}
+
+		if _, err := adapter.ParseAddress(address); err != nil {
+			t.Fatalf("%s: generated address %s rejected: %v", addressType, address, err)
+		}
+
+		wif, err := btcutil.DecodeWIF(privateKey)
+
+		if err != nil {
+			t.Fatalf("%s: %v", addressType, err)
+		}
+
+		if !wif.IsForNet(&chaincfg.MainNetParams) || !wif.CompressPubKey || publicKey != hex.EncodeToString(wif.SerializePubKey()) {
+			t.Fatalf("%s: unexpected key encoding priv=%s pub=%s", addressType, privateKey, publicKey)
+		}
+
+		derived, _ := DeriveAddress(wif.PrivKey.PubKey(), addressType, &chaincfg.MainNetParams)
+
+		if derived.EncodeAddress() != address {
+			t.Fatalf("%s: address %s does not belong to the exported key", addressType, address)
+		}
+	}
+
+	first, _, _, _ := adapter.NewKey([]byte("seed"))
+	second, _, _, _ := adapter.NewKey([]byte("seed"))
+	random, _, _, _ := adapter.NewKey(nil)
+
+	if first != second || first == random {
+		t.Fatal("expected seeded keys to be deterministic and unseeded keys random")
+	}
+}
+
+func TestParseAddressRejectsOtherNetworks(t *testing.T) {
+	t.Parallel()
+
+	for _, address := range []string{"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", "mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r", "not-an-address"} {
+		if _, err := NewAdapter(MainNet, nil).ParseAddress(address); err == nil {
+			t.Fatalf("%s: expected it to be rejected on mainnet", address)
+		}
+	}
+}
+
+func TestNetworksEncodeForTheirParams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		network Network
+		prefix  string
+	}{
+		{network: MainNet, prefix: "bc1q"},
+		{network: TestNet, prefix: "tb1q"},
+		{network: SigNet, prefix: "tb1q"},
+		{network: RegTest, prefix: "bcrt1q"},
+	}
+
+	for _, test := range tests {
+		adapter := NewAdapter(test.network, nil)
+		privateKey, _, address, err := adapter.NewKey([]byte("seed"))
+
+		if err != nil {
+			t.Fatalf("%s: %v", test.network.Name, err)
+		}
+
+		wif, err := btcutil.DecodeWIF(privateKey)
+
+		if err != nil || !wif.IsForNet(test.network.Params) || !strings.HasPrefix(address, test.prefix) {
+			t.Fatalf("%s: got address=%s key=%s", test.network.Name, address, privateKey)
+		}
+
+		if _, err := adapter.ParseAddress(address); err != nil {
+			t.Fatalf("%s: own address rejected: %v", test.network.Name, err)
+		}
+
+		if test.network.ID != "bitcoin" {
+			if _, err := NewAdapter(MainNet, nil).ParseAddress(address); err == nil {
+				t.Fatalf("%s: address %s accepted on mainnet", test.network.Name, address)
+			}
+		}
+	}
+
+	if _, err := NewAdapter(RegTest, nil).ParseAddress("bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"); err == nil {
+		t.Fatal("mainnet address accepted on regtest")
+	}
+
+	if _, err := LookupNetwork("testnet4"); err == nil {
+		t.Fatal("expected an unknown network to be rejected")
+	}
+}
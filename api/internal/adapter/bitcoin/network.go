@@ -0,0 +1,54 @@
+package bitcoin
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// Network selects the chain an adapter works on. ID is the adapter registry
+// entry, and FeeRate the default fee rate in sat/vB when the caller gives none.
+type Network struct {
+	Name    string
+	ID      string
+	Params  *chaincfg.Params
+	FeeRate uint64
+}
+
+var networks = map[string]Network{
+	"mainnet": {Name: "mainnet", ID: "bitcoin", Params: &chaincfg.MainNetParams, FeeRate: 10},
+	"testnet": {Name: "testnet", ID: "bitcoin-testnet", Params: &chaincfg.TestNet3Params, FeeRate: 2},
+	"signet":  {Name: "signet", ID: "bitcoin-signet", Params: &chaincfg.SigNetParams, FeeRate: 1},
+	"regtest": {Name: "regtest", ID: "bitcoin-regtest", Params: &chaincfg.RegressionNetParams, FeeRate: 1},
+}
+
+var (
+	MainNet = networks["mainnet"]
+	TestNet = networks["testnet"]
+	SigNet  = networks["signet"]
+	RegTest = networks["regtest"]
+)
+
+// NetworkNames lists the networks LookupNetwork knows, in name order.
+func NetworkNames() []string {
+	names := make([]string, 0, len(networks))
+
+	for name := range networks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func LookupNetwork(name string) (Network, error) {
+	network, ok := networks[name]
+
+	if !ok {
+		return Network{}, fmt.Errorf("unknown bitcoin network %q, expected one of %v", name, NetworkNames())
+	}
+
+	return network, nil
+}
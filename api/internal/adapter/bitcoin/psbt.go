@@ -0,0 +1,297 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	ErrNothingToSign = errors.New("key does not own any unsigned input")
+	ErrIncompleteTx  = errors.New("transaction is not fully signed")
+	psbtMagicPrefix  = []byte("psbt\xff")
+)
+
+// newPacket wraps the unsigned transaction in a PSBT that records the output
+// each input spends, which is all a signer needs to sign offline.
+func newPacket(msgTx *wire.MsgTx, spent []*wire.TxOut) (*psbt.Packet, error) {
+	packet, err := psbt.NewFromUnsignedTx(msgTx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, output := range spent {
+		packet.Inputs[i].WitnessUtxo = output
+	}
+
+	return packet, nil
+}
+
+func encodePacket(packet *psbt.Packet) ([]byte, error) {
+	var encoded bytes.Buffer
+
+	if err := packet.Serialize(&encoded); err != nil {
+		return nil, err
+	}
+
+	return encoded.Bytes(), nil
+}
+
+func decodePacket(data []byte) (*psbt.Packet, error) {
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader(data), false)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid psbt: %w", err)
+	}
+
+	return packet, nil
+}
+
+func isPacket(data []byte) bool {
+	return bytes.HasPrefix(data, psbtMagicPrefix)
+}
+
+// sigHashes commits to every output the transaction spends, which segwit v0
+// and taproot signatures both need.
+func sigHashes(packet *psbt.Packet) (*txscript.TxSigHashes, error) {
+	spent := make(map[wire.OutPoint]*wire.TxOut, len(packet.Inputs))
+
+	for i, input := range packet.Inputs {
+		if input.WitnessUtxo == nil {
+			return nil, fmt.Errorf("input %d: missing the spent output", i)
+		}
+
+		spent[packet.UnsignedTx.TxIn[i].PreviousOutPoint] = input.WitnessUtxo
+	}
+
+	return txscript.NewTxSigHashes(packet.UnsignedTx, txscript.NewMultiPrevOutFetcher(spent)), nil
+}
+
+// signingKey is what SignTx signs with: a single key from a WIF, or an
+// extended private key whose children sign the inputs whose BIP-32 origins
+// lead back to it.
+type signingKey struct {
+	key      *btcec.PrivateKey
+	extended *hdkeychain.ExtendedKey
+}
+
+// keysFor returns the keys that may own the input.
+func (signer signingKey) keysFor(input *psbt.PInput) []*btcec.PrivateKey {
+	if signer.extended == nil {
+		return []*btcec.PrivateKey{signer.key}
+	}
+
+	var keys []*btcec.PrivateKey
+
+	for _, derivation := range input.Bip32Derivation {
+		if key := signer.derive(derivation.Bip32Path); key != nil && bytes.Equal(key.PubKey().SerializeCompressed(), derivation.PubKey) {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, derivation := range input.TaprootBip32Derivation {
+		if key := signer.derive(derivation.Bip32Path); key != nil && bytes.Equal(schnorr.SerializePubKey(key.PubKey()), derivation.XOnlyPubKey) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// derive follows the path below the extended key's depth, so both a master
+// key and an account key derive the same child.
+func (signer signingKey) derive(path []uint32) *btcec.PrivateKey {
+	depth := int(signer.extended.Depth())
+
+	if len(path) < depth {
+		return nil
+	}
+
+	extended := signer.extended
+
+	for _, step := range path[depth:] {
+		var err error
+
+		if extended, err = extended.Derive(step); err != nil {
+			return nil
+		}
+	}
+
+	key, err := extended.ECPrivKey()
+
+	if err != nil {
+		return nil
+	}
+
+	return key
+}
+
+// multisigThreshold is how many signatures the input's witness script needs,
+// or zero when it is not a multisig.
+func multisigThreshold(input *psbt.PInput) int {
+	if input.WitnessScript == nil {
+		return 0
+	}
+
+	_, threshold, err := txscript.CalcMultiSigStats(input.WitnessScript)
+
+	if err != nil {
+		return 0
+	}
+
+	return threshold
+}
+
+func hasPartialSig(input *psbt.PInput, publicKey []byte) bool {
+	for _, signature := range input.PartialSigs {
+		if bytes.Equal(signature.PubKey, publicKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// signPacket adds the signer's signatures to the inputs it can sign that are
+// not signed yet: its P2WPKH and BIP-86 P2TR outputs, and P2WSH multisigs it
+// is a cosigner of that still need signatures. It returns how many
+// signatures it added.
+func signPacket(packet *psbt.Packet, signer signingKey) (int, error) {
+	hashes, err := sigHashes(packet)
+
+	if err != nil {
+		return 0, err
+	}
+
+	updater, err := psbt.NewUpdater(packet)
+
+	if err != nil {
+		return 0, err
+	}
+
+	signed := 0
+
+	for i := range packet.Inputs {
+		input := &packet.Inputs[i]
+
+		if input.FinalScriptWitness != nil {
+			continue
+		}
+
+		for _, key := range signer.keysFor(input) {
+			ok, err := signInput(packet, updater, hashes, i, key)
+
+			if err != nil {
+				return signed, fmt.Errorf("input %d: %w", i, err)
+			}
+
+			if ok {
+				signed++
+			}
+		}
+	}
+
+	return signed, nil
+}
+
+func signInput(packet *psbt.Packet, updater *psbt.Updater, hashes *txscript.TxSigHashes, i int, key *btcec.PrivateKey) (bool, error) {
+	input := &packet.Inputs[i]
+	spent := input.WitnessUtxo
+	publicKey := key.PubKey().SerializeCompressed()
+
+	witnessKeyScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(btcutil.Hash160(publicKey)).Script()
+
+	if err != nil {
+		return false, err
+	}
+
+	taprootScript, err := txscript.PayToTaprootScript(txscript.ComputeTaprootKeyNoScript(key.PubKey()))
+
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case bytes.Equal(spent.PkScript, witnessKeyScript) && len(input.PartialSigs) == 0:
+		signature, err := txscript.RawTxInWitnessSignature(packet.UnsignedTx, hashes, i, spent.Value, spent.PkScript, txscript.SigHashAll, key)
+
+		if err != nil {
+			return false, err
+		}
+
+		_, err = updater.Sign(i, signature, publicKey, nil, nil)
+
+		return err == nil, err
+
+	case bytes.Equal(spent.PkScript, taprootScript) && len(input.TaprootKeySpendSig) == 0:
+		signature, err := txscript.RawTxInTaprootSignature(packet.UnsignedTx, hashes, i, spent.Value, spent.PkScript, nil, txscript.SigHashDefault, key)
+
+		if err != nil {
+			return false, err
+		}
+
+		input.TaprootKeySpendSig = signature
+		input.TaprootInternalKey = schnorr.SerializePubKey(key.PubKey())
+
+		return true, nil
+
+	case txscript.IsPayToWitnessScriptHash(spent.PkScript) && len(input.PartialSigs) < multisigThreshold(input):
+		scriptHash := sha256.Sum256(input.WitnessScript)
+
+		if !bytes.Equal(spent.PkScript[2:], scriptHash[:]) || !bytes.Contains(input.WitnessScript, publicKey) || hasPartialSig(input, publicKey) {
+			return false, nil
+		}
+
+		signature, err := txscript.RawTxInWitnessSignature(packet.UnsignedTx, hashes, i, spent.Value, input.WitnessScript, txscript.SigHashAll, key)
+
+		if err != nil {
+			return false, err
+		}
+
+		_, err = updater.Sign(i, signature, publicKey, nil, nil)
+
+		return err == nil, err
+	}
+
+	return false, nil
+}
+
+// extractTx finalizes a fully signed packet into a network transaction.
+func extractTx(packet *psbt.Packet) (*wire.MsgTx, error) {
+	for i := range packet.Inputs {
+		input := &packet.Inputs[i]
+		threshold := multisigThreshold(input)
+
+		if threshold == 0 || input.FinalScriptWitness != nil {
+			continue
+		}
+
+		if len(input.PartialSigs) < threshold {
+			return nil, fmt.Errorf("%w: input %d has %d of %d signatures", ErrIncompleteTx, i, len(input.PartialSigs), threshold)
+		}
+
+		// CHECKMULTISIG leaves extra signatures on the stack, which makes the
+		// witness invalid.
+		input.PartialSigs = input.PartialSigs[:threshold]
+	}
+
+	if err := psbt.MaybeFinalizeAll(packet); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIncompleteTx, err)
+	}
+
+	if !packet.IsComplete() {
+		return nil, ErrIncompleteTx
+	}
+
+	return psbt.Extract(packet)
+}
@@ -0,0 +1,182 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// verifyTx runs every input's script against the outputs it spends.
+func verifyTx(t *testing.T, raw []byte, spent map[wire.OutPoint]*wire.TxOut) {
+	t.Helper()
+
+	msgTx := wire.NewMsgTx(0)
+
+	if err := msgTx.Deserialize(bytes.NewReader(raw)); err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher := txscript.NewMultiPrevOutFetcher(spent)
+	hashes := txscript.NewTxSigHashes(msgTx, fetcher)
+
+	for i, input := range msgTx.TxIn {
+		output := spent[input.PreviousOutPoint]
+		engine, err := txscript.NewEngine(output.PkScript, msgTx, i, txscript.StandardVerifyFlags, nil, hashes, output.Value, fetcher)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := engine.Execute(); err != nil {
+			t.Fatalf("input %d: %v", i, err)
+		}
+	}
+}
+
+func spentOutputs(t *testing.T, address string, utxos ...UTXO) map[wire.OutPoint]*wire.TxOut {
+	t.Helper()
+
+	decoded, _ := btcutil.DecodeAddress(address, RegTest.Params)
+	script, _ := txscript.PayToAddrScript(decoded)
+	spent := make(map[wire.OutPoint]*wire.TxOut)
+
+	for _, utxo := range utxos {
+		hash, _ := chainhash.NewHashFromStr(utxo.TxID)
+		spent[*wire.NewOutPoint(hash, utxo.Vout)] = wire.NewTxOut(int64(utxo.Value), script)
+	}
+
+	return spent
+}
+
+func TestPSBTSignAndBroadcast(t *testing.T) {
+	t.Parallel()
+
+	for _, addressType := range []string{AddressP2WPKH, AddressP2TR} {
+		t.Run(addressType, func(t *testing.T) {
+			t.Parallel()
+
+			utxos := []UTXO{{TxID: txID("a"), Vout: 1, Value: 40_000, Height: 3}, {TxID: txID("b"), Vout: 0, Value: 30_000, Height: 4}}
+			source := newFakeSource(utxos...)
+			online := NewAdapter(RegTest, source)
+			offline := NewAdapter(RegTest, nil)
+			privateKey, _, sender, _ := offline.NewKeyWithAddressType([]byte("signer"), addressType)
+			_, _, recipient, _ := offline.NewKey([]byte("recipient"))
+
+			tx, err := online.BuildTx(context.Background(), sender, recipient, big.NewInt(65_000), adapter.FeeHint{FeeRate: 2})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := offline.SignTx(privateKey, tx)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			txID, err := online.Broadcast(context.Background(), signed)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if txID != signed.TxID || len(source.broadcast) != 1 {
+				t.Fatalf("got txid=%s want=%s after %d broadcasts", txID, signed.TxID, len(source.broadcast))
+			}
+
+			verifyTx(t, source.broadcast[0], spentOutputs(t, sender, utxos...))
+		})
+	}
+}
+
+func TestPSBTCollectsSignaturesFromEachOwner(t *testing.T) {
+	t.Parallel()
+
+	ad := NewAdapter(RegTest, newFakeSource())
+	firstKey, _, first, _ := ad.NewKeyWithAddressType([]byte("first"), AddressP2WPKH)
+	secondKey, _, second, _ := ad.NewKeyWithAddressType([]byte("second"), AddressP2TR)
+
+	firstUTXO := UTXO{TxID: txID("1"), Value: 5000}
+	secondUTXO := UTXO{TxID: txID("2"), Value: 7000}
+	spent := spentOutputs(t, first, firstUTXO)
+
+	for outPoint, output := range spentOutputs(t, second, secondUTXO) {
+		spent[outPoint] = output
+	}
+
+	recipientScript, _ := hex.DecodeString("0014751e76e8199196d454941c45d1b3a323f1433bd6")
+	msgTx, _ := unsignedTx(selection{inputs: []UTXO{firstUTXO, secondUTXO}}, 11_000, recipientScript, nil)
+	outputs := make([]*wire.TxOut, 0, len(msgTx.TxIn))
+
+	for _, input := range msgTx.TxIn {
+		outputs = append(outputs, spent[input.PreviousOutPoint])
+	}
+
+	packet, _ := newPacket(msgTx, outputs)
+	data, _ := encodePacket(packet)
+
+	partial, err := ad.SignTx(firstKey, adapter.Tx{Data: data})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ad.Broadcast(context.Background(), partial); !errors.Is(err, ErrIncompleteTx) {
+		t.Fatalf("got err=%v, want ErrIncompleteTx", err)
+	}
+
+	partialData, _ := hex.DecodeString(partial.RawHex)
+
+	if _, err := ad.SignTx(firstKey, adapter.Tx{Data: partialData}); !errors.Is(err, ErrNothingToSign) {
+		t.Fatalf("signing twice: got err=%v, want ErrNothingToSign", err)
+	}
+
+	complete, err := ad.SignTx(secondKey, adapter.Tx{Data: partialData})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ad.Broadcast(context.Background(), complete); err != nil {
+		t.Fatal(err)
+	}
+
+	verifyTx(t, ad.source.(*fakeSource).broadcast[0], spent)
+}
+
+func TestSignTxRejectsForeignKeys(t *testing.T) {
+	t.Parallel()
+
+	ad := NewAdapter(RegTest, newFakeSource(UTXO{TxID: txID("a"), Value: 50_000, Height: 1}))
+	_, _, sender, _ := ad.NewKey([]byte("owner"))
+	strangerKey, _, _, _ := ad.NewKey([]byte("stranger"))
+	mainnetKey, _, _, _ := NewAdapter(MainNet, nil).NewKey([]byte("owner"))
+
+	tx, err := ad.BuildTx(context.Background(), sender, sender, big.NewInt(10_000), adapter.FeeHint{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ad.SignTx(strangerKey, tx); !errors.Is(err, ErrNothingToSign) {
+		t.Fatalf("got err=%v, want ErrNothingToSign", err)
+	}
+
+	if _, err := ad.SignTx(mainnetKey, tx); err == nil {
+		t.Fatal("expected a mainnet key to be rejected on regtest")
+	}
+
+	if _, err := ad.SignTx(strangerKey, adapter.Tx{Data: []byte("not a psbt")}); err == nil {
+		t.Fatal("expected an invalid psbt to be rejected")
+	}
+}
@@ -0,0 +1,269 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// incrementalRelayFeeRate is the rate in sat/vB Bitcoin Core's relay policy
+// charges a replacement for its own size, on top of the fee of the
+// transaction it replaces (BIP-125 rule 4).
+const incrementalRelayFeeRate = 1
+
+var ErrNotReplaceable = errors.New("transaction cannot be replaced")
+
+// packetFee is what the inputs of the packet spend minus what its outputs
+// pay, which needs the output each input spends.
+func packetFee(packet *psbt.Packet) (uint64, error) {
+	var in, out int64
+
+	for i, input := range packet.Inputs {
+		if input.WitnessUtxo == nil {
+			return 0, fmt.Errorf("input %d does not record the output it spends", i)
+		}
+
+		in += input.WitnessUtxo.Value
+	}
+
+	for _, output := range packet.UnsignedTx.TxOut {
+		out += output.Value
+	}
+
+	if out > in {
+		return 0, fmt.Errorf("outputs pay %d sat more than the inputs spend", out-in)
+	}
+
+	return uint64(in - out), nil
+}
+
+// SpeedUpTx rebuilds a transaction the adapter broadcast as a PSBT with the
+// same inputs and outputs, paying a higher fee out of its change, as a
+// BIP-125 replacement. The fee is at the hinted or estimated rate, but at
+// least the original's plus incrementalRelayFeeRate for its size.
+func (ad *Adapter) SpeedUpTx(ctx context.Context, txID string, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	original, packet, err := ad.replaceable(ctx, txID)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	change := changeOutput(packet)
+
+	if change < 0 {
+		return adapter.Tx{}, fmt.Errorf("%w: %s has no change to pay a higher fee from, cancel it instead", ErrNotReplaceable, txID)
+	}
+
+	fee, feeRate, err := ad.replacementFee(ctx, feeHint, original, original.Weight)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	changeTxOut := packet.UnsignedTx.TxOut[change]
+	extra := fee - original.Fee
+
+	if uint64(changeTxOut.Value) < extra+dustThreshold(changeTxOut.PkScript) {
+		return adapter.Tx{}, fmt.Errorf("%w: %d sat of change cannot pay %d sat more in fees", ErrInsufficientFunds, changeTxOut.Value, extra)
+	}
+
+	changeTxOut.Value -= int64(extra)
+
+	var to string
+	amount := new(big.Int)
+
+	for i, output := range packet.UnsignedTx.TxOut {
+		if i == change {
+			continue
+		}
+
+		if to == "" {
+			to = ad.scriptAddress(output.PkScript)
+		}
+
+		amount.Add(amount, big.NewInt(output.Value))
+	}
+
+	return ad.replacementTx(packet, to, amount, fee, original.Weight, feeRate)
+}
+
+// CancelTx replaces a transaction the adapter broadcast as a PSBT with one
+// spending the same inputs back to the sender, to its change address if it
+// had one, as a BIP-125 replacement. The fee is priced like SpeedUpTx's.
+func (ad *Adapter) CancelTx(ctx context.Context, txID string, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	original, packet, err := ad.replaceable(ctx, txID)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	script := packet.Inputs[0].WitnessUtxo.PkScript
+	var description psbt.POutput
+
+	if change := changeOutput(packet); change >= 0 {
+		script = packet.UnsignedTx.TxOut[change].PkScript
+		description = packet.Outputs[change]
+	}
+
+	weight := original.Weight + outputWeight(script)
+
+	for _, output := range packet.UnsignedTx.TxOut {
+		weight -= outputWeight(output.PkScript)
+	}
+
+	fee, feeRate, err := ad.replacementFee(ctx, feeHint, original, weight)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	total := original.Fee
+
+	for _, output := range packet.UnsignedTx.TxOut {
+		total += uint64(output.Value)
+	}
+
+	if total < fee+dustThreshold(script) {
+		return adapter.Tx{}, fmt.Errorf("%w: %d sat of inputs cannot pay a %d sat fee", ErrInsufficientFunds, total, fee)
+	}
+
+	packet.UnsignedTx.TxOut = []*wire.TxOut{wire.NewTxOut(int64(total-fee), script)}
+	packet.Outputs = []psbt.POutput{description}
+
+	return ad.replacementTx(packet, ad.scriptAddress(script), new(big.Int).SetUint64(total-fee), fee, weight, feeRate)
+}
+
+// replaceable returns the record of a transaction the adapter broadcast as a
+// PSBT and that is not yet in a block, with its packet stripped of
+// signatures to be signed again.
+func (ad *Adapter) replaceable(ctx context.Context, txID string) (sentTx, *psbt.Packet, error) {
+	if ad.source == nil {
+		return sentTx{}, nil, fmt.Errorf("%w for %s", ErrNoBackend, ad.network.ID)
+	}
+
+	original, ok := ad.sent.get(txID)
+
+	switch {
+	case !ok:
+		return sentTx{}, nil, fmt.Errorf("%w: %s was not broadcast through this node", ErrNotReplaceable, txID)
+
+	case original.Packet == nil:
+		return sentTx{}, nil, fmt.Errorf("%w: %s was broadcast without a PSBT recording the outputs it spends", ErrNotReplaceable, txID)
+	}
+
+	if source, ok := ad.source.(TxStatusSource); ok {
+		lookup, err := source.LookupTx(ctx, txID)
+
+		if err != nil {
+			return sentTx{}, nil, err
+		}
+
+		if lookup.Found && lookup.Height > 0 {
+			return sentTx{}, nil, fmt.Errorf("%w: %s is already in block %d", ErrNotReplaceable, txID, lookup.Height)
+		}
+	}
+
+	packet, err := decodePacket(original.Packet)
+
+	if err != nil {
+		return sentTx{}, nil, err
+	}
+
+	signals := false
+
+	for i := range packet.Inputs {
+		input := &packet.Inputs[i]
+		input.PartialSigs = nil
+		input.TaprootKeySpendSig = nil
+		input.TaprootScriptSpendSig = nil
+		input.FinalScriptSig = nil
+		input.FinalScriptWitness = nil
+
+		if packet.UnsignedTx.TxIn[i].Sequence < wire.MaxTxInSequenceNum-1 {
+			signals = true
+		}
+	}
+
+	if !signals {
+		return sentTx{}, nil, fmt.Errorf("%w: %s does not signal BIP-125 replaceability", ErrNotReplaceable, txID)
+	}
+
+	return original, packet, nil
+}
+
+// replacementFee is the fee for a replacement of the weight: at the hinted or
+// estimated rate, but no less than the original's fee plus
+// incrementalRelayFeeRate for the replacement's size, with the rate it
+// amounts to.
+func (ad *Adapter) replacementFee(ctx context.Context, feeHint adapter.FeeHint, original sentTx, weight int64) (uint64, uint64, error) {
+	feeRate, err := ad.feeRate(ctx, feeHint)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	vsize := uint64((weight + 3) / 4)
+	fee := max(feeFor(weight, feeRate), original.Fee+vsize*incrementalRelayFeeRate)
+
+	return fee, (fee + vsize - 1) / vsize, nil
+}
+
+func (ad *Adapter) replacementTx(packet *psbt.Packet, to string, amount *big.Int, fee uint64, weight int64, feeRate uint64) (adapter.Tx, error) {
+	encoded, err := encodePacket(packet)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	return adapter.Tx{
+		From:    ad.scriptAddress(packet.Inputs[0].WitnessUtxo.PkScript),
+		To:      to,
+		Amount:  amount,
+		Fee:     fee,
+		Data:    encoded,
+		VSize:   uint64((weight + 3) / 4),
+		FeeRate: feeRate,
+	}, nil
+}
+
+// changeOutput is the index of the output paying back to the sender, one to
+// the script of an input or described with the signers' key origins, or -1.
+func changeOutput(packet *psbt.Packet) int {
+	for i, output := range packet.UnsignedTx.TxOut {
+		description := packet.Outputs[i]
+
+		if len(description.Bip32Derivation) > 0 || len(description.TaprootBip32Derivation) > 0 {
+			return i
+		}
+
+		for _, input := range packet.Inputs {
+			if bytes.Equal(input.WitnessUtxo.PkScript, output.PkScript) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// scriptAddress is the address an output script pays, or empty for one
+// without a standard address.
+func (ad *Adapter) scriptAddress(script []byte) string {
+	_, addresses, _, err := txscript.ExtractPkScriptAddrs(script, ad.network.Params)
+
+	if err != nil || len(addresses) != 1 {
+		return ""
+	}
+
+	return addresses[0].EncodeAddress()
+}
+
+var _ adapter.ReplacementAdapter = (*Adapter)(nil)
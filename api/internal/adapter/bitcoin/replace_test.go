@@ -0,0 +1,193 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// broadcastPayment sends 50,000 sat of the sender's 70,000 at 2 sat/vB and
+// returns the adapter, its source, the signer's key and address, the txid
+// and the UTXOs it spent.
+func broadcastPayment(t *testing.T) (*Adapter, *statusSource, string, string, string, []UTXO) {
+	t.Helper()
+
+	utxos := []UTXO{{TxID: txID("a"), Vout: 1, Value: 40_000, Height: 3}, {TxID: txID("b"), Vout: 0, Value: 30_000, Height: 4}}
+	source := &statusSource{fakeSource: *newFakeSource(utxos...), lookups: map[string]TxLookup{}, spenders: map[string]string{}}
+	ad := NewAdapter(RegTest, source)
+	privateKey, _, sender, _ := ad.NewKeyWithAddressType([]byte("signer"), AddressP2WPKH)
+	_, _, recipient, _ := ad.NewKey([]byte("recipient"))
+
+	tx, err := ad.BuildTx(context.Background(), sender, recipient, big.NewInt(50_000), adapter.FeeHint{FeeRate: 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := ad.SignTx(privateKey, tx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := ad.Broadcast(context.Background(), signed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.lookups[id] = TxLookup{Found: true}
+
+	return ad, source, privateKey, sender, id, utxos
+}
+
+func deserializeTx(t *testing.T, raw []byte) *wire.MsgTx {
+	t.Helper()
+
+	msgTx := wire.NewMsgTx(0)
+
+	if err := msgTx.Deserialize(bytes.NewReader(raw)); err != nil {
+		t.Fatal(err)
+	}
+
+	return msgTx
+}
+
+func TestSpeedUpTx(t *testing.T) {
+	t.Parallel()
+
+	ad, source, privateKey, sender, id, utxos := broadcastPayment(t)
+	original := deserializeTx(t, source.broadcast[0])
+
+	tx, err := ad.SpeedUpTx(context.Background(), id, adapter.FeeHint{FeeRate: 10})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.From != sender || tx.Amount.Int64() != 50_000 || tx.FeeRate != 10 || tx.Fee != tx.VSize*10 {
+		t.Fatalf("got %+v, want 50000 sat at 10 sat/vB", tx)
+	}
+
+	signed, err := ad.SignTx(privateKey, tx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replacementID, err := ad.Broadcast(context.Background(), signed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replacement := deserializeTx(t, source.broadcast[1])
+	verifyTx(t, source.broadcast[1], spentOutputs(t, sender, utxos...))
+
+	if len(replacement.TxIn) != len(original.TxIn) || len(replacement.TxOut) != 2 || replacement.TxOut[0].Value != original.TxOut[0].Value {
+		t.Fatalf("got %d inputs and outputs %v, want the original's", len(replacement.TxIn), replacement.TxOut)
+	}
+
+	if originalFee := 70_000 - 50_000 - original.TxOut[1].Value; replacement.TxOut[1].Value != original.TxOut[1].Value-(int64(tx.Fee)-originalFee) {
+		t.Fatalf("got change %d, want it to pay the fee increase", replacement.TxOut[1].Value)
+	}
+
+	// The replacement takes the original's place.
+	delete(source.lookups, id)
+
+	for _, input := range original.TxIn {
+		source.spenders[fmt.Sprintf("%s:%d", input.PreviousOutPoint.Hash, input.PreviousOutPoint.Index)] = replacementID
+	}
+
+	if status, err := ad.TxStatus(context.Background(), id); err != nil || status.Status != adapter.StatusReplaced || status.ReplacedBy != replacementID {
+		t.Fatalf("got status=%+v err=%v, want replaced by %s", status, err, replacementID)
+	}
+}
+
+func TestSpeedUpTxPaysTheRelayIncrement(t *testing.T) {
+	t.Parallel()
+
+	ad, _, _, _, id, _ := broadcastPayment(t)
+	original, _ := ad.sent.get(id)
+
+	// A lower rate than the original's is raised to replace it.
+	tx, err := ad.SpeedUpTx(context.Background(), id, adapter.FeeHint{FeeRate: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.Fee != original.Fee+tx.VSize*incrementalRelayFeeRate {
+		t.Fatalf("got fee=%d want=%d", tx.Fee, original.Fee+tx.VSize)
+	}
+}
+
+func TestCancelTx(t *testing.T) {
+	t.Parallel()
+
+	ad, source, privateKey, sender, id, utxos := broadcastPayment(t)
+	tx, err := ad.CancelTx(context.Background(), id, adapter.FeeHint{FeeRate: 5})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.To != sender || tx.Amount.Uint64() != 70_000-tx.Fee || tx.FeeRate < 5 {
+		t.Fatalf("got %+v, want everything back to %s", tx, sender)
+	}
+
+	signed, err := ad.SignTx(privateKey, tx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ad.Broadcast(context.Background(), signed); err != nil {
+		t.Fatal(err)
+	}
+
+	verifyTx(t, source.broadcast[1], spentOutputs(t, sender, utxos...))
+
+	if outputs := deserializeTx(t, source.broadcast[1]).TxOut; len(outputs) != 1 {
+		t.Fatalf("got %d outputs, want 1", len(outputs))
+	}
+}
+
+func TestReplaceRejectsUnreplaceableTxs(t *testing.T) {
+	t.Parallel()
+
+	ad, source, _, _, id, _ := broadcastPayment(t)
+	raw := spendTx(t, txID("c"))
+
+	if _, err := ad.Broadcast(context.Background(), raw); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"unknown": txID("e"),
+		"raw":     raw.TxID,
+	}
+
+	for name, id := range cases {
+		if _, err := ad.SpeedUpTx(context.Background(), id, adapter.FeeHint{FeeRate: 10}); !errors.Is(err, ErrNotReplaceable) {
+			t.Fatalf("%s: got err=%v, want ErrNotReplaceable", name, err)
+		}
+	}
+
+	source.lookups[id] = TxLookup{Found: true, Height: 5, TipHeight: 5}
+
+	if _, err := ad.CancelTx(context.Background(), id, adapter.FeeHint{FeeRate: 10}); !errors.Is(err, ErrNotReplaceable) {
+		t.Fatalf("confirmed: got err=%v, want ErrNotReplaceable", err)
+	}
+
+	if _, err := NewAdapter(RegTest, nil).SpeedUpTx(context.Background(), id, adapter.FeeHint{FeeRate: 10}); !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("offline: got err=%v, want ErrNoBackend", err)
+	}
+}
@@ -0,0 +1,94 @@
+package bitcoin
+
+import (
+	"sync"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/afrodynamic/gochain/api/internal/platform/jsonfile"
+)
+
+// SentTxs are the transactions the adapter broadcast by txid. They are
+// persisted to a JSON file after every change so a restart can still report
+// and replace them. Once a transaction is confirmed, it and every other one
+// spending any of its inputs are settled and forgotten. An empty path keeps
+// them in memory.
+type SentTxs struct {
+	mutex sync.Mutex
+	path  string
+	txs   map[string]sentTx
+}
+
+// sentTx is a broadcast transaction: the outputs it spends and, if it was
+// broadcast as a PSBT, the signed packet, its fee and its weight.
+type sentTx struct {
+	Inputs []wire.OutPoint `json:"inputs"`
+	Packet []byte          `json:"packet,omitempty"`
+	Fee    uint64          `json:"fee,omitempty"`
+	Weight int64           `json:"weight,omitempty"`
+}
+
+func LoadSentTxs(path string) (*SentTxs, error) {
+	sent := &SentTxs{path: path, txs: make(map[string]sentTx)}
+
+	if err := jsonfile.Load(path, &sent.txs); err != nil {
+		return nil, err
+	}
+
+	return sent, nil
+}
+
+func (sent *SentTxs) add(txID string, tx sentTx) error {
+	sent.mutex.Lock()
+	defer sent.mutex.Unlock()
+
+	sent.txs[txID] = tx
+
+	return sent.saveLocked()
+}
+
+func (sent *SentTxs) get(txID string) (sentTx, bool) {
+	sent.mutex.Lock()
+	defer sent.mutex.Unlock()
+
+	tx, ok := sent.txs[txID]
+
+	return tx, ok
+}
+
+// settle forgets the transaction, if the adapter broadcast it, along with
+// the ones that conflict with it.
+func (sent *SentTxs) settle(txID string) error {
+	sent.mutex.Lock()
+	defer sent.mutex.Unlock()
+
+	settled, ok := sent.txs[txID]
+
+	if !ok {
+		return nil
+	}
+
+	spent := make(map[wire.OutPoint]bool, len(settled.Inputs))
+
+	for _, input := range settled.Inputs {
+		spent[input] = true
+	}
+
+	for other, tx := range sent.txs {
+		for _, input := range tx.Inputs {
+			if spent[input] {
+				delete(sent.txs, other)
+
+				break
+			}
+		}
+	}
+
+	delete(sent.txs, txID)
+
+	return sent.saveLocked()
+}
+
+func (sent *SentTxs) saveLocked() error {
+	return jsonfile.Save(sent.path, sent.txs)
+}
@@ -0,0 +1,92 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+// UTXO is an unspent output paying an address. Height is the block that
+// confirmed it, or zero while it is only in the mempool.
+type UTXO struct {
+	TxID   string
+	Vout   uint32
+	Value  uint64
+	Height uint64
+}
+
+func (utxo UTXO) Confirmed() bool {
+	return utxo.Height > 0
+}
+
+// UTXOSource is the adapter's view of the network, a full node or an indexer:
+// it looks up the unspent outputs of an address, estimates the fee rate in
+// sat/vB for confirmation within a number of blocks and relays transactions.
+type UTXOSource interface {
+	UTXOs(ctx context.Context, address string) ([]UTXO, error)
+	Broadcast(ctx context.Context, rawTx []byte) (string, error)
+	FeeRate(ctx context.Context, targetBlocks int) (uint64, error)
+}
+
+// TxLookup is what a source knows of a transaction. Height is the block that
+// confirmed it and TipHeight the chain's, both zero while it is only in the
+// mempool.
+type TxLookup struct {
+	Found     bool
+	Height    uint64
+	BlockHash string
+	TipHeight uint64
+}
+
+// TxStatusSource is implemented by sources that can look a transaction up and
+// tell whether an output is spent and by which transaction, where they know.
+type TxStatusSource interface {
+	LookupTx(ctx context.Context, txID string) (TxLookup, error)
+	Spender(ctx context.Context, txID string, vout uint32) (spender string, spent bool, err error)
+}
+
+// AddressHistorySource knows which addresses ever took part in a
+// transaction, even if none of their outputs are left, so a scan of a
+// wallet's addresses can count its gap limit over used addresses rather than
+// funded ones.
+type AddressHistorySource interface {
+	UsedAddresses(ctx context.Context, addresses []string) (map[string]bool, error)
+}
+
+var (
+	ErrNoBackend     = errors.New("no bitcoin backend configured")
+	ErrNoFeeEstimate = errors.New("backend has no fee estimate")
+)
+
+// NewSource returns the source for whichever backend is configured, preferring
+// bitcoind, or nil when there is none.
+func NewSource(rpcURL, esploraURL string) (UTXOSource, error) {
+	switch {
+	case rpcURL != "":
+		source, err := NewBitcoindSource(rpcURL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return source, nil
+
+	case esploraURL != "":
+		return NewEsploraSource(esploraURL), nil
+	}
+
+	return nil, nil
+}
+
+// satsPerVByte converts a fee rate in BTC/kvB, rounding up.
+func satsPerVByte(btcPerKVByte float64) uint64 {
+	return uint64(math.Ceil(btcPerKVByte * btcutil.SatoshiPerBitcoin / 1000))
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second}
+}
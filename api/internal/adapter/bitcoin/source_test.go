@@ -0,0 +1,251 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testAddress = "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"
+
+func TestBitcoindSource(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+
+		if user != "rpcuser" || password != "rpcpass" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		var request struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&request)
+
+		if request.Method == "estimatesmartfee" {
+			result := map[string]any{"feerate": 0.00012345, "blocks": 6}
+
+			if request.Params[0].(float64) == 1 {
+				result = map[string]any{"errors": []string{"Insufficient data or no feerate found"}, "blocks": 0}
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]any{"result": result, "error": nil})
+
+			return
+		}
+
+		if request.Method == "sendrawtransaction" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"result": "txid-of-" + request.Params[0].(string), "error": nil})
+
+			return
+		}
+
+		if request.Method != "scantxoutset" || request.Params[0] != "start" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]any{"result": nil, "error": map[string]any{"code": -32601, "message": "Method not found"}})
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"result": map[string]any{
+				"success": true,
+				"height":  120,
+				"unspents": []map[string]any{
+					{"txid": "aa", "vout": 1, "amount": 0.1, "height": 101},
+					{"txid": "bb", "vout": 0, "amount": 0.00000546, "height": 120},
+				},
+			},
+			"error": nil,
+		})
+	}))
+
+	defer server.Close()
+
+	source, err := NewBitcoindSource("http://rpcuser:rpcpass@" + server.Listener.Addr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utxos, err := source.UTXOs(context.Background(), testAddress)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(utxos) != 2 || utxos[0] != (UTXO{TxID: "aa", Vout: 1, Value: 10_000_000, Height: 101}) || utxos[1].Value != 546 {
+		t.Fatalf("got=%+v", utxos)
+	}
+
+	if err := source.call(context.Background(), "getblockcount", nil, nil); err == nil {
+		t.Fatal("expected the rpc error to be returned")
+	}
+
+	if txID, err := source.Broadcast(context.Background(), []byte{0xab}); err != nil || txID != "txid-of-ab" {
+		t.Fatalf("broadcast: got txid=%s err=%v", txID, err)
+	}
+
+	// 0.00012345 BTC/kvB is 12.345 sat/vB.
+	if feeRate, err := source.FeeRate(context.Background(), 6); err != nil || feeRate != 13 {
+		t.Fatalf("fee rate: got=%d err=%v want=13", feeRate, err)
+	}
+
+	if _, err := source.FeeRate(context.Background(), 1); !errors.Is(err, ErrNoFeeEstimate) {
+		t.Fatalf("fee rate: got err=%v, want ErrNoFeeEstimate", err)
+	}
+
+	unauthorized, _ := NewBitcoindSource(server.URL)
+
+	if _, err := unauthorized.UTXOs(context.Background(), testAddress); err == nil {
+		t.Fatal("expected an error without credentials")
+	}
+}
+
+func TestEsploraSource(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/tx" {
+			body, _ := io.ReadAll(r.Body)
+
+			if string(body) != "abcd" {
+				http.Error(w, "sendrawtransaction RPC error: TX decode failed", http.StatusBadRequest)
+
+				return
+			}
+
+			_, _ = w.Write([]byte("txid-of-abcd"))
+
+			return
+		}
+
+		if r.URL.Path == "/api/fee-estimates" {
+			_, _ = w.Write([]byte(`{"1":20.5,"3":11.2,"6":7.01,"144":1.0}`))
+
+			return
+		}
+
+		if r.URL.Path != "/api/address/"+testAddress+"/utxo" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`[
+			{"txid":"aa","vout":0,"value":5000,"status":{"confirmed":true,"block_height":7}},
+			{"txid":"bb","vout":2,"value":700,"status":{"confirmed":false}}
+		]`))
+	}))
+
+	defer server.Close()
+
+	utxos, err := NewEsploraSource(server.URL+"/api/").UTXOs(context.Background(), testAddress)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(utxos) != 2 || utxos[0] != (UTXO{TxID: "aa", Vout: 0, Value: 5000, Height: 7}) || utxos[1].Confirmed() {
+		t.Fatalf("got=%+v", utxos)
+	}
+
+	source := NewEsploraSource(server.URL + "/api")
+
+	if txID, err := source.Broadcast(context.Background(), []byte{0xab, 0xcd}); err != nil || txID != "txid-of-abcd" {
+		t.Fatalf("broadcast: got txid=%s err=%v", txID, err)
+	}
+
+	if _, err := source.Broadcast(context.Background(), []byte{0x00}); err == nil {
+		t.Fatal("expected a rejected transaction to return an error")
+	}
+
+	for target, want := range map[int]uint64{1: 21, 2: 21, 6: 8, 1008: 1} {
+		if feeRate, err := source.FeeRate(context.Background(), target); err != nil || feeRate != want {
+			t.Fatalf("fee rate for %d blocks: got=%d err=%v want=%d", target, feeRate, err, want)
+		}
+	}
+
+	if _, err := NewEsploraSource(server.URL).UTXOs(context.Background(), testAddress); err == nil {
+		t.Fatal("expected an error for a missing endpoint")
+	}
+}
+
+func TestSourcesReportUsedAddresses(t *testing.T) {
+	t.Parallel()
+
+	spentAddress, unusedAddress := regtestAddresses(t)
+	bitcoind := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		var result any
+
+		switch request.Method {
+		case "scanblocks":
+			result = map[string]any{"relevant_blocks": []string{"b1", "b2"}}
+
+		case "getblock":
+			// b1 pays testAddress; b2 spends from spentAddress, and matches
+			// unusedAddress only as a filter false positive.
+			address, spending := testAddress, request.Params[0] == "b2"
+
+			if spending {
+				address = spentAddress
+			}
+
+			output := map[string]any{"scriptPubKey": map[string]any{"address": address}}
+			tx := map[string]any{"vin": []any{map[string]any{"coinbase": "00"}}, "vout": []any{output}}
+
+			if spending {
+				tx = map[string]any{"vin": []any{map[string]any{"prevout": output}}, "vout": []any{}}
+			}
+
+			result = map[string]any{"tx": []any{tx}}
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": result, "error": nil})
+	}))
+
+	defer bitcoind.Close()
+
+	esplora := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/address/") {
+		case testAddress:
+			_, _ = w.Write([]byte(`{"chain_stats":{"tx_count":0},"mempool_stats":{"tx_count":1}}`))
+
+		case spentAddress:
+			_, _ = w.Write([]byte(`{"chain_stats":{"tx_count":2},"mempool_stats":{"tx_count":0}}`))
+
+		default:
+			_, _ = w.Write([]byte(`{"chain_stats":{"tx_count":0},"mempool_stats":{"tx_count":0}}`))
+		}
+	}))
+
+	defer esplora.Close()
+
+	bitcoindSource, _ := NewBitcoindSource(bitcoind.URL)
+	addresses := []string{testAddress, spentAddress, unusedAddress}
+	want := map[string]bool{testAddress: true, spentAddress: true}
+
+	for name, source := range map[string]AddressHistorySource{"bitcoind": bitcoindSource, "esplora": NewEsploraSource(esplora.URL)} {
+		used, err := source.UsedAddresses(context.Background(), addresses)
+
+		if err != nil || len(used) != len(want) || !used[testAddress] || !used[spentAddress] {
+			t.Fatalf("%s: got=%v err=%v want=%v", name, used, err, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// statusSource knows the transactions in lookups and who spent the outputs
+// in spenders, keyed by "txid:vout".
+type statusSource struct {
+	fakeSource
+	lookups  map[string]TxLookup
+	spenders map[string]string
+}
+
+func (source *statusSource) LookupTx(ctx context.Context, txID string) (TxLookup, error) {
+	return source.lookups[txID], nil
+}
+
+func (source *statusSource) Spender(ctx context.Context, txID string, vout uint32) (string, bool, error) {
+	spender, spent := source.spenders[fmt.Sprintf("%s:%d", txID, vout)]
+
+	return spender, spent, nil
+}
+
+// spendTx serializes a transaction spending the first output of prevTxID.
+func spendTx(t *testing.T, prevTxID string) adapter.SignedTx {
+	t.Helper()
+
+	hash, err := chainhash.NewHashFromStr(prevTxID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, 0), nil, nil))
+	msgTx.AddTxOut(wire.NewTxOut(1_000, []byte{0x51}))
+
+	var raw bytes.Buffer
+
+	if err := msgTx.Serialize(&raw); err != nil {
+		t.Fatal(err)
+	}
+
+	return adapter.SignedTx{RawHex: hex.EncodeToString(raw.Bytes()), TxID: msgTx.TxHash().String()}
+}
+
+func TestTxStatus(t *testing.T) {
+	t.Parallel()
+
+	source := &statusSource{lookups: map[string]TxLookup{
+		txID("1"): {Found: true},
+		txID("2"): {Found: true, Height: 100, BlockHash: txID("f"), TipHeight: 101},
+		txID("3"): {Found: true, Height: 100, BlockHash: txID("f"), TipHeight: 110},
+	}}
+	ad := NewAdapter(RegTest, source)
+	cases := map[string]adapter.TxStatus{
+		txID("1"): {Status: adapter.StatusPending},
+		txID("2"): {Status: adapter.StatusIncluded, Confirmations: 2, BlockHeight: 100, BlockHash: txID("f")},
+		txID("3"): {Status: adapter.StatusConfirmed, Confirmations: 11, BlockHeight: 100, BlockHash: txID("f")},
+		txID("4"): {Status: adapter.StatusUnknown},
+	}
+
+	for id, want := range cases {
+		if got, err := ad.TxStatus(context.Background(), id); err != nil || got != want {
+			t.Fatalf("%s: got=%+v err=%v want=%+v", id, got, err, want)
+		}
+	}
+
+	if _, err := NewAdapter(RegTest, nil).TxStatus(context.Background(), txID("1")); !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("got err=%v, want ErrNoBackend", err)
+	}
+}
+
+func TestTxStatusAfterBroadcast(t *testing.T) {
+	t.Parallel()
+
+	source := &statusSource{lookups: map[string]TxLookup{}, spenders: map[string]string{}}
+	ad := NewAdapter(RegTest, source)
+	replaced := spendTx(t, txID("a"))
+	dropped := spendTx(t, txID("b"))
+
+	for _, signed := range []adapter.SignedTx{replaced, dropped} {
+		if txID, err := ad.Broadcast(context.Background(), signed); err != nil || txID != signed.TxID {
+			t.Fatalf("got txid=%s err=%v want=%s", txID, err, signed.TxID)
+		}
+	}
+
+	// A fee bump spent the first transaction's input; the second's input is
+	// unspent again, as when the mempool evicted it.
+	source.spenders[txID("a")+":0"] = txID("e")
+
+	if got, err := ad.TxStatus(context.Background(), replaced.TxID); err != nil || got.Status != adapter.StatusReplaced || got.ReplacedBy != txID("e") {
+		t.Fatalf("got=%+v err=%v, want replaced by %s", got, err, txID("e"))
+	}
+
+	if got, err := ad.TxStatus(context.Background(), dropped.TxID); err != nil || got.Status != adapter.StatusDropped {
+		t.Fatalf("got=%+v err=%v, want dropped", got, err)
+	}
+
+	// Spent in a block by an unnamed transaction, which may be its own.
+	source.spenders[txID("b")+":0"] = ""
+
+	if got, err := ad.TxStatus(context.Background(), dropped.TxID); err != nil || got.Status != adapter.StatusUnknown {
+		t.Fatalf("got=%+v err=%v, want unknown", got, err)
+	}
+}
+
+func TestSentTxsSurviveRestartsUntilSettled(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sent.json")
+	source := &statusSource{lookups: map[string]TxLookup{}, spenders: map[string]string{}}
+	ad := NewAdapter(RegTest, source)
+	sent, _ := LoadSentTxs(path)
+	ad.SetSentTxs(sent)
+	settled := spendTx(t, txID("a"))
+	pending := spendTx(t, txID("b"))
+
+	for _, signed := range []adapter.SignedTx{settled, pending} {
+		if _, err := ad.Broadcast(context.Background(), signed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A restarted node still knows what it broadcast.
+	sent, err := LoadSentTxs(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ad = NewAdapter(RegTest, source)
+	ad.SetSentTxs(sent)
+	source.spenders[txID("b")+":0"] = txID("e")
+
+	if got, err := ad.TxStatus(context.Background(), pending.TxID); err != nil || got.Status != adapter.StatusReplaced {
+		t.Fatalf("got=%+v err=%v, want replaced", got, err)
+	}
+
+	source.lookups[settled.TxID] = TxLookup{Found: true, Height: 100, TipHeight: 105}
+
+	if got, err := ad.TxStatus(context.Background(), settled.TxID); err != nil || got.Status != adapter.StatusConfirmed {
+		t.Fatalf("got=%+v err=%v, want confirmed", got, err)
+	}
+
+	if sent, err = LoadSentTxs(path); err != nil || len(sent.txs) != 1 {
+		t.Fatalf("got %d sent transactions err=%v, want only the unsettled one", len(sent.txs), err)
+	}
+
+	if _, ok := sent.get(pending.TxID); !ok {
+		t.Fatalf("the unsettled transaction was forgotten")
+	}
+}
+
+func TestEsploraTxStatus(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tx/" + txID("1") + "/status":
+			_, _ = w.Write([]byte(`{"confirmed":false}`))
+
+		case "/tx/" + txID("2") + "/status":
+			_, _ = w.Write([]byte(`{"confirmed":true,"block_height":100,"block_hash":"` + txID("f") + `"}`))
+
+		case "/blocks/tip/height":
+			_, _ = w.Write([]byte(`104`))
+
+		case "/tx/" + txID("a") + "/outspend/1":
+			_, _ = w.Write([]byte(`{"spent":true,"txid":"` + txID("e") + `","vin":0}`))
+
+		case "/tx/" + txID("a") + "/outspend/2":
+			_, _ = w.Write([]byte(`{"spent":false}`))
+
+		default:
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+		}
+	}))
+
+	defer server.Close()
+
+	source := NewEsploraSource(server.URL)
+	cases := map[string]TxLookup{
+		txID("1"): {Found: true},
+		txID("2"): {Found: true, Height: 100, BlockHash: txID("f"), TipHeight: 104},
+		txID("3"): {},
+	}
+
+	for id, want := range cases {
+		if got, err := source.LookupTx(context.Background(), id); err != nil || got != want {
+			t.Fatalf("%s: got=%+v err=%v want=%+v", id, got, err, want)
+		}
+	}
+
+	if spender, spent, err := source.Spender(context.Background(), txID("a"), 1); err != nil || !spent || spender != txID("e") {
+		t.Fatalf("got spender=%s spent=%t err=%v", spender, spent, err)
+	}
+
+	if _, spent, err := source.Spender(context.Background(), txID("a"), 2); err != nil || spent {
+		t.Fatalf("got spent=%t err=%v", spent, err)
+	}
+}
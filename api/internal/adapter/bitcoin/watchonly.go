@@ -0,0 +1,300 @@
+package bitcoin
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+const (
+	// descriptorGapLimit is how many addresses in a row without outputs end a
+	// scan of a descriptor's addresses, as in BIP-44.
+	descriptorGapLimit = 20
+
+	maxDescriptorAddresses = 1000
+)
+
+// spendInfo is an address the sender owns with what signers need to spend
+// from it: the multisig witness script or taproot internal key, and the
+// BIP-32 origins of the keys for signers holding extended private keys.
+type spendInfo struct {
+	address       btcutil.Address
+	script        []byte
+	witnessScript []byte
+	internalKey   *btcec.PublicKey
+	keys          []derivedKey
+}
+
+// funds are the outputs a transaction may spend, each with its owner, what
+// one input weighs and where change goes.
+type funds struct {
+	utxos       []UTXO
+	owners      map[UTXO]spendInfo
+	inputWeight int64
+	change      spendInfo
+}
+
+func (sending *funds) add(utxo UTXO, owner spendInfo) {
+	sending.utxos = append(sending.utxos, utxo)
+	sending.owners[utxo] = owner
+}
+
+func (info spendInfo) derivations() ([]*psbt.Bip32Derivation, []*psbt.TaprootBip32Derivation) {
+	var derivations []*psbt.Bip32Derivation
+	var taprootDerivations []*psbt.TaprootBip32Derivation
+
+	for _, key := range info.keys {
+		if info.internalKey != nil {
+			taprootDerivations = append(taprootDerivations, &psbt.TaprootBip32Derivation{
+				XOnlyPubKey:          schnorr.SerializePubKey(key.publicKey),
+				MasterKeyFingerprint: key.fingerprint,
+				Bip32Path:            key.path,
+			})
+
+			continue
+		}
+
+		derivations = append(derivations, &psbt.Bip32Derivation{
+			PubKey:               key.publicKey.SerializeCompressed(),
+			MasterKeyFingerprint: key.fingerprint,
+			Bip32Path:            key.path,
+		})
+	}
+
+	return derivations, taprootDerivations
+}
+
+func (info spendInfo) describeInput(input *psbt.PInput) {
+	input.WitnessScript = info.witnessScript
+	input.Bip32Derivation, input.TaprootBip32Derivation = info.derivations()
+
+	if info.internalKey != nil {
+		input.TaprootInternalKey = schnorr.SerializePubKey(info.internalKey)
+	}
+}
+
+// describeOutput lets signers recognize the change as their own.
+func (info spendInfo) describeOutput(output *psbt.POutput) {
+	output.WitnessScript = info.witnessScript
+	output.Bip32Derivation, output.TaprootBip32Derivation = info.derivations()
+
+	if info.internalKey != nil {
+		output.TaprootInternalKey = schnorr.SerializePubKey(info.internalKey)
+	}
+}
+
+func (ad *Adapter) parseDescriptor(text string) (*Descriptor, error) {
+	return ParseDescriptor(text, ad.network.Params)
+}
+
+// ImportDescriptor checks the descriptor is one the adapter can watch on its
+// network and returns it with its checksum. Nothing is stored: the descriptor
+// itself identifies the wallet.
+func (ad *Adapter) ImportDescriptor(text string) (string, error) {
+	descriptor, err := ad.parseDescriptor(text)
+
+	if err != nil {
+		return "", err
+	}
+
+	return descriptor.String(), nil
+}
+
+// DescriptorAddresses lists count receive or change addresses of the
+// descriptor from the start index.
+func (ad *Adapter) DescriptorAddresses(text string, change bool, start, count uint32) ([]string, error) {
+	descriptor, err := ad.parseDescriptor(text)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if count > maxDescriptorAddresses {
+		return nil, fmt.Errorf("at most %d addresses can be listed at once", maxDescriptorAddresses)
+	}
+
+	addresses := make([]string, 0, count)
+
+	for index := start; index-start < count; index++ {
+		address, err := descriptor.Address(change, index)
+
+		if err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+// scanDescriptor collects the unspent outputs of the descriptor's receive and
+// change addresses, each until descriptorGapLimit addresses in a row are
+// unused, and returns the first change address past the last used one. An
+// address is used if the source's history has a transaction for it; with a
+// source that keeps no history, only if it has outputs left, so change can
+// land on an address whose outputs were all spent.
+func (ad *Adapter) scanDescriptor(ctx context.Context, descriptor *Descriptor) ([]UTXO, map[UTXO]spendInfo, spendInfo, error) {
+	var utxos []UTXO
+	owners := make(map[UTXO]spendInfo)
+	branches := []bool{false}
+	nextUnused := map[bool]uint32{}
+	history, hasHistory := ad.source.(AddressHistorySource)
+
+	if descriptor.hasChange() {
+		branches = append(branches, true)
+	}
+
+	for _, change := range branches {
+		var used map[string]bool
+
+		for index, gap := uint32(0), 0; gap < descriptorGapLimit; index++ {
+			if index > 0 && !descriptor.Ranged() {
+				break
+			}
+
+			info, err := descriptor.derive(change, index)
+
+			if err != nil {
+				return nil, nil, spendInfo{}, err
+			}
+
+			address := info.address.EncodeAddress()
+
+			// History is looked up for a gap limit's worth of addresses at a
+			// time.
+			if hasHistory && index%descriptorGapLimit == 0 {
+				if used, err = usedAddresses(ctx, history, descriptor, change, index); err != nil {
+					return nil, nil, spendInfo{}, err
+				}
+			}
+
+			var found []UTXO
+
+			if !hasHistory || used[address] {
+				if found, err = ad.source.UTXOs(ctx, address); err != nil {
+					return nil, nil, spendInfo{}, err
+				}
+			}
+
+			if !used[address] && len(found) == 0 {
+				gap++
+
+				continue
+			}
+
+			gap = 0
+			nextUnused[change] = index + 1
+
+			for _, utxo := range found {
+				utxos = append(utxos, utxo)
+				owners[utxo] = info
+			}
+		}
+	}
+
+	changeIndex := nextUnused[descriptor.hasChange()]
+
+	if !descriptor.Ranged() {
+		changeIndex = 0
+	}
+
+	change, err := descriptor.derive(descriptor.hasChange(), changeIndex)
+
+	return utxos, owners, change, err
+}
+
+// usedAddresses asks the source which of the descriptor's descriptorGapLimit
+// addresses on the branch from the start index are used.
+func usedAddresses(ctx context.Context, history AddressHistorySource, descriptor *Descriptor, change bool, start uint32) (map[string]bool, error) {
+	count := uint32(descriptorGapLimit)
+
+	if !descriptor.Ranged() {
+		count = 1
+	}
+
+	addresses := make([]string, count)
+
+	for i := range count {
+		address, err := descriptor.Address(change, start+i)
+
+		if err != nil {
+			return nil, err
+		}
+
+		addresses[i] = address
+	}
+
+	return history.UsedAddresses(ctx, addresses)
+}
+
+// DescriptorBalance is the sum of the confirmed unspent outputs of the
+// descriptor's addresses in satoshis.
+func (ad *Adapter) DescriptorBalance(ctx context.Context, text string) (*big.Int, error) {
+	descriptor, err := ad.parseDescriptor(text)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ad.source == nil {
+		return new(big.Int), nil
+	}
+
+	utxos, _, _, err := ad.scanDescriptor(ctx, descriptor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var balance uint64
+
+	for _, utxo := range utxos {
+		if utxo.Confirmed() {
+			balance += utxo.Value
+		}
+	}
+
+	return new(big.Int).SetUint64(balance), nil
+}
+
+// BuildDescriptorTx pays the amount from confirmed outputs of the descriptor's
+// addresses like BuildTxWithCoinSelection, with change to its next unused
+// change address. The PSBT carries the witness scripts, taproot internal
+// keys and BIP-32 origins each cosigner needs to add its signature in turn.
+func (ad *Adapter) BuildDescriptorTx(ctx context.Context, text, recipientAddress string, amount *big.Int, feeHint adapter.FeeHint, strategy string) (adapter.Tx, error) {
+	descriptor, err := ad.parseDescriptor(text)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	if ad.source == nil {
+		return adapter.Tx{}, fmt.Errorf("%w for %s", ErrNoBackend, ad.network.ID)
+	}
+
+	utxos, owners, change, err := ad.scanDescriptor(ctx, descriptor)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	sending := funds{owners: owners, inputWeight: descriptor.inputWeight(), change: change}
+
+	for _, utxo := range utxos {
+		if utxo.Confirmed() {
+			sending.utxos = append(sending.utxos, utxo)
+		}
+	}
+
+	return ad.spend(ctx, descriptor.String(), sending, recipientAddress, amount, feeHint, strategy)
+}
+
+var _ adapter.DescriptorAdapter = (*Adapter)(nil)
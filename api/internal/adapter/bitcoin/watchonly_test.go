@@ -0,0 +1,265 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// fundDescriptor pays one output to each of the descriptor's addresses given
+// as index to value, on the receive or change branch.
+func fundDescriptor(t *testing.T, source *fakeSource, descriptor string, change bool, values map[uint32]uint64) {
+	t.Helper()
+
+	parsed, err := ParseDescriptor(descriptor, RegTest.Params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if source.byAddress == nil {
+		source.byAddress = make(map[string][]UTXO)
+	}
+
+	for index, value := range values {
+		address := mustAddress(t, parsed, change, index)
+		fill := "a"
+
+		if change {
+			fill = "c"
+		}
+
+		utxo := UTXO{TxID: txID(fill), Vout: index, Value: value, Height: 1}
+		source.byAddress[address] = append(source.byAddress[address], utxo)
+	}
+}
+
+// fundedOutputs is every output the source serves, by outpoint.
+func fundedOutputs(t *testing.T, source *fakeSource) map[wire.OutPoint]*wire.TxOut {
+	t.Helper()
+
+	spent := make(map[wire.OutPoint]*wire.TxOut)
+
+	for address, utxos := range source.byAddress {
+		decoded, _ := btcutil.DecodeAddress(address, RegTest.Params)
+		script, _ := txscript.PayToAddrScript(decoded)
+
+		for _, utxo := range utxos {
+			hash, _ := chainhash.NewHashFromStr(utxo.TxID)
+			spent[*wire.NewOutPoint(hash, utxo.Vout)] = wire.NewTxOut(int64(utxo.Value), script)
+		}
+	}
+
+	return spent
+}
+
+func TestDescriptorBalanceScansToTheGapLimit(t *testing.T) {
+	t.Parallel()
+
+	descriptor := multisigDescriptor(t, 2, newCosigner(t, "alice"), newCosigner(t, "bob"), newCosigner(t, "carol"))
+	source := newFakeSource()
+	fundDescriptor(t, source, descriptor, false, map[uint32]uint64{0: 1000, 19: 2000, 39: 4000, 60: 8000})
+	fundDescriptor(t, source, descriptor, true, map[uint32]uint64{3: 16_000})
+	ctx := context.Background()
+
+	// Index 39 follows nineteen unused addresses; index 60 follows twenty.
+	if balance, err := NewAdapter(RegTest, source).DescriptorBalance(ctx, descriptor); err != nil || balance.Uint64() != 23_000 {
+		t.Fatalf("got=%d err=%v want=23000", balance, err)
+	}
+
+	if balance, err := NewAdapter(RegTest, nil).DescriptorBalance(ctx, descriptor); err != nil || balance.Sign() != 0 {
+		t.Fatalf("without a backend: got=%d err=%v", balance, err)
+	}
+
+	ad := NewAdapter(RegTest, source)
+	imported, err := ad.ImportDescriptor(descriptor)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses, err := ad.DescriptorAddresses(imported, true, 2, 3)
+
+	if err != nil || len(addresses) != 3 || source.byAddress[addresses[1]] == nil {
+		t.Fatalf("got addresses=%v err=%v, want change addresses 2 to 4", addresses, err)
+	}
+
+	if _, err := NewAdapter(MainNet, nil).ImportDescriptor(descriptor); !errors.Is(err, ErrInvalidDescriptor) {
+		t.Fatalf("got err=%v, want a regtest descriptor rejected on mainnet", err)
+	}
+}
+
+// historySource is a fakeSource that also knows the addresses in spent,
+// whose outputs have all been spent.
+type historySource struct {
+	*fakeSource
+	spent map[string]bool
+}
+
+func (source *historySource) UsedAddresses(ctx context.Context, addresses []string) (map[string]bool, error) {
+	used := make(map[string]bool)
+
+	for _, address := range addresses {
+		if source.spent[address] || len(source.byAddress[address]) > 0 {
+			used[address] = true
+		}
+	}
+
+	return used, nil
+}
+
+func TestDescriptorScanCountsTheGapOverAddressHistory(t *testing.T) {
+	t.Parallel()
+
+	descriptor := multisigDescriptor(t, 2, newCosigner(t, "alice"), newCosigner(t, "bob"), newCosigner(t, "carol"))
+	parsed, err := ParseDescriptor(descriptor, RegTest.Params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	funded := newFakeSource()
+	fundDescriptor(t, funded, descriptor, false, map[uint32]uint64{0: 1000, 30: 2000})
+	source := &historySource{fakeSource: funded, spent: map[string]bool{
+		mustAddress(t, parsed, false, 15): true,
+		mustAddress(t, parsed, true, 4):   true,
+	}}
+	ctx := context.Background()
+
+	// Without history index 30 follows twenty-nine addresses with nothing
+	// left; with it, the spent index 15 keeps the scan going.
+	if balance, err := NewAdapter(RegTest, funded).DescriptorBalance(ctx, descriptor); err != nil || balance.Uint64() != 1000 {
+		t.Fatalf("without history: got=%d err=%v want=1000", balance, err)
+	}
+
+	if balance, err := NewAdapter(RegTest, source).DescriptorBalance(ctx, descriptor); err != nil || balance.Uint64() != 3000 {
+		t.Fatalf("with history: got=%d err=%v want=3000", balance, err)
+	}
+
+	// Change never goes back to a spent address.
+	_, _, change, err := NewAdapter(RegTest, source).scanDescriptor(ctx, parsed)
+
+	if err != nil || change.address.EncodeAddress() != mustAddress(t, parsed, true, 5) {
+		t.Fatalf("got change=%v err=%v, want change address 5", change.address, err)
+	}
+}
+
+func TestMultisigCosignersSignInTurn(t *testing.T) {
+	t.Parallel()
+
+	alice, bob, carol := newCosigner(t, "alice"), newCosigner(t, "bob"), newCosigner(t, "carol")
+	descriptor := multisigDescriptor(t, 2, alice, bob, carol)
+	source := newFakeSource()
+	fundDescriptor(t, source, descriptor, false, map[uint32]uint64{0: 40_000, 2: 30_000})
+	fundDescriptor(t, source, descriptor, true, map[uint32]uint64{0: 5_000})
+
+	online := NewAdapter(RegTest, source)
+	offline := NewAdapter(RegTest, nil)
+	_, _, recipient, _ := offline.NewKey([]byte("vendor"))
+	ctx := context.Background()
+
+	tx, err := online.BuildDescriptorTx(ctx, descriptor, recipient, big.NewInt(60_000), adapter.FeeHint{FeeRate: 2}, CoinSelectionLargestFirst)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet, _ := decodePacket(tx.Data)
+
+	if len(packet.Inputs) != 2 || packet.Inputs[0].WitnessScript == nil || len(packet.Inputs[0].Bip32Derivation) != 3 {
+		t.Fatalf("expected two inputs with witness scripts and cosigner origins, got %+v", packet.Inputs)
+	}
+
+	parsed, _ := ParseDescriptor(descriptor, RegTest.Params)
+	change, _ := parsed.derive(true, 1)
+
+	if len(packet.UnsignedTx.TxOut) != 2 || !bytes.Equal(packet.UnsignedTx.TxOut[1].PkScript, change.script) || packet.Outputs[1].WitnessScript == nil {
+		t.Fatal("expected change to the first unused change address")
+	}
+
+	// Alice signs with her master key, Bob with his account key.
+	partial, err := offline.SignTx(alice.master.String(), tx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := online.Broadcast(ctx, partial); !errors.Is(err, ErrIncompleteTx) {
+		t.Fatalf("got err=%v, want ErrIncompleteTx", err)
+	}
+
+	partialData, _ := hex.DecodeString(partial.RawHex)
+
+	if _, err := offline.SignTx(alice.account.String(), adapter.Tx{Data: partialData}); !errors.Is(err, ErrNothingToSign) {
+		t.Fatalf("signing twice: got err=%v, want ErrNothingToSign", err)
+	}
+
+	complete, err := offline.SignTx(bob.account.String(), adapter.Tx{Data: partialData})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	completeData, _ := hex.DecodeString(complete.RawHex)
+
+	if _, err := offline.SignTx(carol.master.String(), adapter.Tx{Data: completeData}); !errors.Is(err, ErrNothingToSign) {
+		t.Fatalf("signing a complete input: got err=%v, want ErrNothingToSign", err)
+	}
+
+	txID, err := online.Broadcast(ctx, complete)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if txID != complete.TxID {
+		t.Fatalf("got txid=%s want=%s", txID, complete.TxID)
+	}
+
+	verifyTx(t, source.broadcast[0], fundedOutputs(t, source))
+}
+
+func TestTaprootDescriptorSpend(t *testing.T) {
+	t.Parallel()
+
+	owner := newCosigner(t, "treasurer")
+	descriptor := "tr(" + owner.expression(t) + ")"
+	source := newFakeSource()
+	fundDescriptor(t, source, descriptor, false, map[uint32]uint64{0: 25_000, 1: 25_000})
+
+	ad := NewAdapter(RegTest, source)
+	_, _, recipient, _ := ad.NewKey([]byte("vendor"))
+	ctx := context.Background()
+
+	tx, err := ad.BuildDescriptorTx(ctx, descriptor, recipient, big.NewInt(45_000), adapter.FeeHint{FeeRate: 1}, CoinSelectionAuto)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ad.SignTx(newCosigner(t, "stranger").master.String(), tx); !errors.Is(err, ErrNothingToSign) {
+		t.Fatalf("got err=%v, want ErrNothingToSign", err)
+	}
+
+	signed, err := ad.SignTx(owner.account.String(), tx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ad.Broadcast(ctx, signed); err != nil {
+		t.Fatal(err)
+	}
+
+	verifyTx(t, source.broadcast[0], fundedOutputs(t, source))
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"math/big"
 	"strings"
 
@@ -14,16 +15,70 @@ import (
 	gethcrypto "github.com/ethereum/go-ethereum/crypto"
 )
 
+// Adapter talks to a node of an EVM network at its RPC URLs, in order of
+// health. Estimated gas limits get gasMargin percent on top, tokens are the
+// ERC-20 tokens it knows by symbol, nonces allocates the nonces of the
+// transactions it builds, resolver resolves names given for addresses, and
+// sent are the transactions it broadcast by hash.
 type Adapter struct {
-	rpc *rpcClient
+	network   Network
+	rpc       *rpcClient
+	gasMargin uint64
+	tokens    map[string]adapter.Token
+	nonces    *NonceManager
+	resolver  NameResolver
+	sent      *SentTxs
 }
 
-func NewAdapter() *Adapter {
-	return &Adapter{rpc: newRPC()}
+func NewAdapter(network Network, gasMargin uint64, tokens []adapter.Token) *Adapter {
+	nonces, _ := LoadNonceManager("")
+	sent, _ := LoadSentTxs("")
+	ad := &Adapter{network: network, rpc: newRPC(network.RPCURLs...), gasMargin: gasMargin, nonces: nonces, sent: sent}
+	ad.registerTokens(tokens)
+
+	if network.ENSRegistry != "" {
+		if resolver, err := ad.NewENSResolver(network.ENSRegistry); err == nil {
+			ad.resolver = resolver
+		}
+	}
+
+	return ad
+}
+
+// SetNonceManager replaces the in-memory nonce manager, e.g. with one that
+// persists its allocations.
+func (ad *Adapter) SetNonceManager(nonces *NonceManager) {
+	ad.nonces = nonces
+}
+
+// SetSentTxs replaces the in-memory record of broadcast transactions, e.g.
+// with one that persists it.
+func (ad *Adapter) SetSentTxs(sent *SentTxs) {
+	ad.sent = sent
 }
 
 func (ad *Adapter) Network() string {
-	return "ethereum"
+	return ad.network.Name
+}
+
+// ChainID is the network's chain ID, which transactions are signed for.
+func (ad *Adapter) ChainID() uint64 {
+	return ad.network.ChainID
+}
+
+// Symbol is the network's native coin, e.g. ETH or POL.
+func (ad *Adapter) Symbol() string {
+	return ad.network.Symbol
+}
+
+// ExplorerURL is the network's block explorer, empty if it has none.
+func (ad *Adapter) ExplorerURL() string {
+	return ad.network.ExplorerURL
+}
+
+// Decimals is 18: amounts are in wei.
+func (ad *Adapter) Decimals() uint32 {
+	return 18
 }
 
 func (ad *Adapter) NewKey(seed []byte) (string, string, string, error) {
@@ -36,108 +91,218 @@ func (ad *Adapter) NewKey(seed []byte) (string, string, string, error) {
 	return hex.EncodeToString(gethcrypto.FromECDSA(privateKey)), hex.EncodeToString(publicKeyBytes), addressHex, nil
 }
 
+// ParseAddress validates a hex address and returns it EIP-55 checksummed.
+// Names are resolved with ResolveAddress.
 func (ad *Adapter) ParseAddress(address string) (string, error) {
-	if len(address) == 42 && strings.HasPrefix(strings.ToLower(address), "0x") {
-		return address, nil
-	}
-
-	return "", errors.New("invalid ethereum address")
+	return ParseAddress(address)
 }
 
-func (ad *Adapter) Balance(ctx context.Context, address string) (uint64, error) {
-	if ad.rpc.url == "" {
-		return 0, nil
+func (ad *Adapter) Balance(ctx context.Context, address string) (*big.Int, error) {
+	if ad.rpc.offline() {
+		return new(big.Int), nil
 	}
 
 	var balanceHex string
 
 	if err := ad.rpc.call(ctx, "eth_getBalance", []interface{}{address, "latest"}, &balanceHex); err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	balance := new(big.Int)
-	balance.SetString(strings.TrimPrefix(balanceHex, "0x"), 16)
+	balance, err := parseQuantity(balanceHex)
 
-	return balance.Uint64(), nil
+	if err != nil {
+		return nil, fmt.Errorf("eth_getBalance: %w", err)
+	}
+
+	return balance, nil
 }
 
-func (ad *Adapter) BuildTx(ctx context.Context, sender, recipient string, amount uint64, feeHint adapter.FeeHint) (adapter.Tx, error) {
-	return adapter.Tx{
-		From:   sender,
-		To:     recipient,
-		Amount: amount,
-		Fee:    feeHint.MaxFeePerGas,
-		Nonce:  0,
-		Data:   nil,
-	}, nil
+// BuildTx prices the transfer with EIP-1559 fees: the max fee per gas and
+// priority fee from the hint, or estimated from recent blocks at its speed.
+func (ad *Adapter) BuildTx(ctx context.Context, sender, recipient string, amount *big.Int, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	return ad.BuildTxWithData(ctx, sender, recipient, amount, nil, feeHint, adapter.TxParams{})
 }
 
-func (ad *Adapter) SignTx(privateKeyHex string, tx adapter.Tx) (adapter.SignedTx, error) {
-	if ad.rpc.url == "" {
-		raw := hex.EncodeToString([]byte(tx.From + tx.To))
+// BuildTxWithData is BuildTx for a contract call, with a gas limit estimated
+// for the call data. The recipient may be a name, and must not be the zero
+// address. The nonce and gas limit come from the params or the
+// node, so with both of them and the max fee given no node is needed. A nonce
+// from the node is allocated by the nonce manager, so concurrent builds from
+// one sender get consecutive ones. A chain ID in the params must be the
+// network's. Whatever the node is asked for is asked in one batch.
+func (ad *Adapter) BuildTxWithData(ctx context.Context, sender, recipient string, amount *big.Int, data []byte, feeHint adapter.FeeHint, params adapter.TxParams) (adapter.Tx, error) {
+	amount, err := uint256Amount(amount)
 
-		return adapter.SignedTx{RawHex: raw, TxID: "0x" + raw[:64]}, nil
+	if err != nil {
+		return adapter.Tx{}, err
 	}
 
-	ctx := context.Background()
-	var chainIDHex string
+	if params.ChainID != 0 && params.ChainID != ad.network.ChainID {
+		return adapter.Tx{}, fmt.Errorf("chain ID %d is not %s's %d", params.ChainID, ad.network.Name, ad.network.ChainID)
+	}
 
-	if err := ad.rpc.call(ctx, "eth_chainId", []interface{}{}, &chainIDHex); err != nil {
-		return adapter.SignedTx{}, err
+	if sender, err = ParseAddress(sender); err != nil {
+		return adapter.Tx{}, fmt.Errorf("sender: %w", err)
 	}
 
-	chainID := new(big.Int)
-	chainID.SetString(strings.TrimPrefix(chainIDHex, "0x"), 16)
+	if recipient, err = ad.recipientAddress(ctx, recipient); err != nil {
+		return adapter.Tx{}, fmt.Errorf("recipient: %w", err)
+	}
 
-	var nonceHex string
+	var calls []*rpcCall
+	var historyCall *rpcCall
+	var history *feeHistory
 
-	if err := ad.rpc.call(ctx, "eth_getTransactionCount", []interface{}{tx.From, "pending"}, &nonceHex); err != nil {
-		return adapter.SignedTx{}, err
+	if ad.wantsFeeEstimate(feeHint) {
+		if historyCall, history, err = feeHistoryCall(feeHint.Speed); err != nil {
+			return adapter.Tx{}, err
+		}
+
+		calls = append(calls, historyCall)
 	}
 
-	nonce := new(big.Int)
-	nonce.SetString(strings.TrimPrefix(nonceHex, "0x"), 16)
+	var nonceHex, gasHex string
+	nonceCall := &rpcCall{method: "eth_getTransactionCount", params: []any{sender, "pending"}, out: &nonceHex}
+	gasCall := estimateGasCall(sender, recipient, amount, data, &gasHex)
 
-	var tipHex, gasPriceHex string
-	_ = ad.rpc.call(ctx, "eth_maxPriorityFeePerGas", []interface{}{}, &tipHex)
-	_ = ad.rpc.call(ctx, "eth_gasPrice", []interface{}{}, &gasPriceHex)
+	if params.Nonce == nil {
+		if ad.rpc.offline() {
+			return adapter.Tx{}, fmt.Errorf("%w: a nonce is needed to build without a node", ErrNoRPC)
+		}
 
-	tipWei := new(big.Int)
+		calls = append(calls, nonceCall)
+	}
+
+	if params.GasLimit == 0 && !ad.rpc.offline() {
+		calls = append(calls, gasCall)
+	}
 
-	if strings.HasPrefix(tipHex, "0x") {
-		tipWei.SetString(strings.TrimPrefix(tipHex, "0x"), 16)
-	} else {
-		tipWei.SetUint64(1_500_000_000)
+	if err := ad.rpc.batch(ctx, calls...); err != nil {
+		return adapter.Tx{}, err
 	}
 
-	baseWei := new(big.Int)
+	var estimated fees
 
-	if strings.HasPrefix(gasPriceHex, "0x") {
-		baseWei.SetString(strings.TrimPrefix(gasPriceHex, "0x"), 16)
-	} else {
-		baseWei.SetUint64(5_000_000_000)
+	if history != nil {
+		if historyCall.err != nil {
+			return adapter.Tx{}, historyCall.err
+		}
+
+		if estimated, err = history.fees(); err != nil {
+			return adapter.Tx{}, err
+		}
 	}
 
-	maxFeePerGas := new(big.Int).Add(baseWei, tipWei)
-	toAddress := common.HexToAddress(tx.To)
-	valueWei := new(big.Int).SetUint64(tx.Amount)
+	resolved, err := ad.resolveFees(feeHint, estimated)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	var pending uint64
+
+	if params.Nonce == nil {
+		if nonceCall.err != nil {
+			return adapter.Tx{}, nonceCall.err
+		}
+
+		if pending, err = parseUint64Quantity(nonceHex); err != nil {
+			return adapter.Tx{}, fmt.Errorf("eth_getTransactionCount: %w", err)
+		}
+	}
+
+	gasLimit := params.GasLimit
+
+	switch {
+	case gasLimit != 0:
+
+	case ad.rpc.offline():
+		gasLimit, err = offlineGasLimit(data)
+
+	case gasCall.err != nil:
+		err = gasCall.err
 
-	dynamicTx := &types.DynamicFeeTx{
+	default:
+		gasLimit, err = ad.gasLimit(gasHex)
+	}
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	var nonce uint64
+
+	if params.Nonce != nil {
+		nonce = *params.Nonce
+	} else if nonce, err = ad.nonces.Allocate(common.HexToAddress(sender), pending); err != nil {
+		return adapter.Tx{}, fmt.Errorf("allocate nonce: %w", err)
+	}
+
+	return adapter.Tx{
+		From:        sender,
+		To:          recipient,
+		Amount:      amount,
+		Fee:         resolved.maxFeePerGas,
+		PriorityFee: resolved.maxPriorityFee,
+		Nonce:       nonce,
+		Data:        data,
+		GasLimit:    gasLimit,
+		MaxCost:     maxCost(amount, gasLimit, resolved.maxFeePerGas),
+		ChainID:     ad.network.ChainID,
+	}, nil
+}
+
+// SignTx signs the transaction exactly as built, as an EIP-1559 transaction
+// for its chain ID. It never contacts the node, so it works on an air-gapped
+// signer.
+func (ad *Adapter) SignTx(privateKeyHex string, tx adapter.Tx) (adapter.SignedTx, error) {
+	switch {
+	case tx.ChainID == 0:
+		return adapter.SignedTx{}, errors.New("transaction has no chain ID")
+
+	case tx.GasLimit == 0:
+		return adapter.SignedTx{}, errors.New("transaction has no gas limit")
+
+	case tx.Fee == 0:
+		return adapter.SignedTx{}, errors.New("transaction has no max fee per gas")
+
+	case tx.PriorityFee > tx.Fee:
+		return adapter.SignedTx{}, fmt.Errorf("max priority fee %d exceeds max fee per gas %d", tx.PriorityFee, tx.Fee)
+
+	case !common.IsHexAddress(tx.To):
+		return adapter.SignedTx{}, fmt.Errorf("invalid ethereum address %q", tx.To)
+	}
+
+	value, err := uint256Amount(tx.Amount)
+
+	if err != nil {
+		return adapter.SignedTx{}, err
+	}
+
+	privateKey, err := gethcrypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+
+	if err != nil {
+		return adapter.SignedTx{}, fmt.Errorf("private key: %w", err)
+	}
+
+	if tx.From != "" && !strings.EqualFold(gethcrypto.PubkeyToAddress(privateKey.PublicKey).Hex(), tx.From) {
+		return adapter.SignedTx{}, fmt.Errorf("private key does not belong to %s", tx.From)
+	}
+
+	toAddress := common.HexToAddress(tx.To)
+	chainID := new(big.Int).SetUint64(tx.ChainID)
+	unsigned := types.NewTx(&types.DynamicFeeTx{
 		ChainID:   chainID,
-		Nonce:     nonce.Uint64(),
-		GasTipCap: tipWei,
-		GasFeeCap: maxFeePerGas,
-		Gas:       21000,
+		Nonce:     tx.Nonce,
+		GasTipCap: new(big.Int).SetUint64(tx.PriorityFee),
+		GasFeeCap: new(big.Int).SetUint64(tx.Fee),
+		Gas:       tx.GasLimit,
 		To:        &toAddress,
-		Value:     valueWei,
-		Data:      nil,
-	}
+		Value:     value,
+		Data:      tx.Data,
+	})
 
-	txToSign := types.NewTx(dynamicTx)
-	privateKeyBytes, _ := hex.DecodeString(strings.TrimPrefix(privateKeyHex, "0x"))
-	ecdsaKey, _ := gethcrypto.ToECDSA(privateKeyBytes)
-	signer := types.LatestSignerForChainID(chainID)
-	signedTx, err := types.SignTx(txToSign, signer, ecdsaKey)
+	signedTx, err := types.SignTx(unsigned, types.LatestSignerForChainID(chainID), privateKey)
 
 	if err != nil {
 		return adapter.SignedTx{}, err
@@ -152,40 +317,66 @@ func (ad *Adapter) SignTx(privateKeyHex string, tx adapter.Tx) (adapter.SignedTx
 	return adapter.SignedTx{RawHex: "0x" + hex.EncodeToString(rawBytes), TxID: signedTx.Hash().Hex()}, nil
 }
 
+// Broadcast relays the transaction and remembers its sender and nonce, so
+// TxStatus can tell whether it was dropped or replaced if it disappears. The
+// nonce of a transaction the node rejects is released for reuse. When a
+// retry follows an attempt that may have reached the node, the node's
+// "already known" or "nonce too low" is checked against the transaction's
+// hash: if the node has it, the earlier attempt got through. A transaction
+// that may be in flight never gives its nonce back.
 func (ad *Adapter) Broadcast(ctx context.Context, signedTx adapter.SignedTx) (string, error) {
-	if ad.rpc.url == "" {
+	if ad.rpc.offline() {
 		return signedTx.TxID, nil
 	}
 
+	tx, from, decodeErr := decodeSignedTx(signedTx.RawHex)
 	var txID string
+	call := &rpcCall{method: "eth_sendRawTransaction", params: []any{signedTx.RawHex}, out: &txID}
+	err := ad.rpc.batch(ctx, call)
 
-	if err := ad.rpc.call(ctx, "eth_sendRawTransaction", []interface{}{signedTx.RawHex}, &txID); err != nil {
-		return "", err
+	if err == nil {
+		err = call.err
 	}
 
-	return txID, nil
-}
+	if err != nil && call.maybeDelivered && decodeErr == nil && alreadySent(err) && ad.knowsTx(ctx, tx.Hash()) {
+		txID, err = tx.Hash().Hex(), nil
+	}
+
+	if err != nil {
+		if decodeErr == nil && !call.maybeDelivered {
+			ad.nonces.Release(from, tx.Nonce())
+		}
 
-func (ad *Adapter) TxStatus(ctx context.Context, txID string) (adapter.Status, error) {
-	if ad.rpc.url == "" {
-		return adapter.StatusPending, nil
+		return "", err
 	}
 
-	type receipt struct {
-		BlockNumber string `json:"blockNumber"`
+	if decodeErr == nil {
+		ad.remember(tx, from)
 	}
 
-	var rcpt *receipt
+	return txID, nil
+}
 
-	if err := ad.rpc.call(ctx, "eth_getTransactionReceipt", []interface{}{txID}, &rcpt); err != nil {
-		return adapter.StatusPending, nil
-	}
+// alreadySent reports whether the node turned a transaction away because it
+// has it, or one with its nonce, already.
+func alreadySent(err error) bool {
+	message := strings.ToLower(err.Error())
 
-	if rcpt == nil || rcpt.BlockNumber == "" {
-		return adapter.StatusPending, nil
+	for _, known := range []string{"already known", "known transaction", "already imported", "nonce too low"} {
+		if strings.Contains(message, known) {
+			return true
+		}
 	}
 
-	return adapter.StatusMined, nil
+	return false
+}
+
+// knowsTx reports whether the node has the transaction, pending or mined.
+func (ad *Adapter) knowsTx(ctx context.Context, hash common.Hash) bool {
+	var tx *rpcTransaction
+
+	return ad.rpc.call(ctx, "eth_getTransactionByHash", []any{hash.Hex()}, &tx) == nil && tx != nil
 }
 
 var _ adapter.ChainAdapter = (*Adapter)(nil)
+var _ adapter.CallDataAdapter = (*Adapter)(nil)
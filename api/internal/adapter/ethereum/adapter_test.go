@@ -0,0 +1,125 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+func TestSignTxOffline(t *testing.T) {
+	t.Parallel()
+
+	// No ETH_RPC: everything the node would supply is given up front.
+	ad := NewAdapter(Sepolia, 20, nil)
+	priv, _, from, _ := ad.NewKey([]byte("air-gapped"))
+	to := "0x00000000000000000000000000000000000000aa"
+	nonce := uint64(42)
+	hint := adapter.FeeHint{MaxFeePerGas: 40 * gwei, MaxPriorityFee: 2 * gwei}
+
+	tx, err := ad.BuildTxWithData(context.Background(), from, to, big.NewInt(1_000), nil, hint, adapter.TxParams{ChainID: 11155111, Nonce: &nonce})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := ad.SignTx(priv, tx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded types.Transaction
+
+	if err := decoded.UnmarshalBinary(hexutil.MustDecode(signed.RawHex)); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Type() != types.DynamicFeeTxType || decoded.ChainId().Uint64() != 11155111 || decoded.Nonce() != 42 || decoded.Gas() != 21_000 {
+		t.Fatalf("got type=%d chain=%s nonce=%d gas=%d", decoded.Type(), decoded.ChainId(), decoded.Nonce(), decoded.Gas())
+	}
+
+	if decoded.GasFeeCap().Uint64() != 40*gwei || decoded.GasTipCap().Uint64() != 2*gwei || decoded.Value().Uint64() != 1_000 || !strings.EqualFold(decoded.To().Hex(), to) {
+		t.Fatalf("got fee=%s priority=%s value=%s to=%s", decoded.GasFeeCap(), decoded.GasTipCap(), decoded.Value(), decoded.To())
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(decoded.ChainId()), &decoded)
+
+	if err != nil || sender.Hex() != from {
+		t.Fatalf("got sender=%s err=%v want=%s", sender.Hex(), err, from)
+	}
+
+	if signed.TxID != decoded.Hash().Hex() {
+		t.Fatalf("got txid=%s want=%s", signed.TxID, decoded.Hash().Hex())
+	}
+}
+
+func TestBuildTxOfflineNeedsParams(t *testing.T) {
+	t.Parallel()
+
+	ad := NewAdapter(Mainnet, 20, nil)
+	nonce := uint64(0)
+	hint := adapter.FeeHint{MaxFeePerGas: 40 * gwei}
+	cases := map[string]struct {
+		hint   adapter.FeeHint
+		params adapter.TxParams
+	}{
+		"no max fee": {adapter.FeeHint{MaxPriorityFee: gwei}, adapter.TxParams{ChainID: 1, Nonce: &nonce}},
+		"no nonce":   {hint, adapter.TxParams{ChainID: 1}},
+	}
+
+	for name, testCase := range cases {
+		if _, err := ad.BuildTx(context.Background(), holder, recipient, big.NewInt(1), testCase.hint); !errors.Is(err, ErrNoRPC) {
+			t.Fatalf("%s via BuildTx: got err=%v, want ErrNoRPC", name, err)
+		}
+
+		if _, err := ad.BuildTxWithData(context.Background(), holder, recipient, big.NewInt(1), nil, testCase.hint, testCase.params); !errors.Is(err, ErrNoRPC) {
+			t.Fatalf("%s: got err=%v, want ErrNoRPC", name, err)
+		}
+	}
+
+	if _, err := ad.BuildTxWithData(context.Background(), holder, recipient, big.NewInt(1), nil, hint, adapter.TxParams{ChainID: Sepolia.ChainID, Nonce: &nonce}); err == nil {
+		t.Fatal("expected another network's chain ID to be rejected")
+	}
+}
+
+func TestSignTxRejectsIncompleteTx(t *testing.T) {
+	t.Parallel()
+
+	ad := NewAdapter(Mainnet, 20, nil)
+	priv, _, from, _ := ad.NewKey([]byte("incomplete"))
+	complete := adapter.Tx{From: from, To: recipient, Amount: big.NewInt(1), Fee: 30 * gwei, PriorityFee: gwei, GasLimit: 21_000, ChainID: 1}
+
+	if _, err := ad.SignTx(priv, complete); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]func(tx *adapter.Tx){
+		"no chain ID":     func(tx *adapter.Tx) { tx.ChainID = 0 },
+		"no gas limit":    func(tx *adapter.Tx) { tx.GasLimit = 0 },
+		"no max fee":      func(tx *adapter.Tx) { tx.Fee = 0 },
+		"tip above fee":   func(tx *adapter.Tx) { tx.PriorityFee = 31 * gwei },
+		"bad recipient":   func(tx *adapter.Tx) { tx.To = "0xto" },
+		"someone else's":  func(tx *adapter.Tx) { tx.From = holder },
+		"negative amount": func(tx *adapter.Tx) { tx.Amount = big.NewInt(-1) },
+	}
+
+	for name, mutate := range cases {
+		tx := complete
+		mutate(&tx)
+
+		if _, err := ad.SignTx(priv, tx); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := ad.SignTx("0xnotakey", complete); err == nil {
+		t.Fatal("expected an invalid private key to be rejected")
+	}
+}
@@ -0,0 +1,178 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// feeHistoryBlocks is how many recent blocks the priority fee is estimated
+// from.
+const feeHistoryBlocks = 20
+
+// rewardPercentiles are the percentiles of the priority fees paid in each
+// recent block that each speed tips at.
+var rewardPercentiles = map[string]float64{
+	adapter.FeeSpeedSlow:   10,
+	adapter.FeeSpeedNormal: 50,
+	adapter.FeeSpeedFast:   90,
+}
+
+var ErrNoFeeHistory = errors.New("no fee history")
+
+// fees are EIP-1559 fees per gas in wei.
+type fees struct {
+	maxFeePerGas   uint64
+	maxPriorityFee uint64
+}
+
+func parseQuantity(value string) (*big.Int, error) {
+	quantity, err := hexutil.DecodeBig(value)
+
+	if err != nil {
+		return nil, fmt.Errorf("quantity %q: %w", value, err)
+	}
+
+	return quantity, nil
+}
+
+func parseUint64Quantity(value string) (uint64, error) {
+	quantity, err := parseQuantity(value)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !quantity.IsUint64() {
+		return 0, fmt.Errorf("quantity %s overflows uint64", value)
+	}
+
+	return quantity.Uint64(), nil
+}
+
+// feeHistory is the eth_feeHistory result fees are estimated from.
+type feeHistory struct {
+	BaseFeePerGas []string   `json:"baseFeePerGas"`
+	GasUsedRatio  []float64  `json:"gasUsedRatio"`
+	Reward        [][]string `json:"reward"`
+}
+
+// feeHistoryCall asks eth_feeHistory for the recent blocks' base fees and the
+// priority fees they paid at the speed's percentile.
+func feeHistoryCall(speed string) (*rpcCall, *feeHistory, error) {
+	if speed == "" {
+		speed = adapter.FeeSpeedNormal
+	}
+
+	percentile, ok := rewardPercentiles[speed]
+
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown fee speed %q, expected %s, %s or %s", speed, adapter.FeeSpeedSlow, adapter.FeeSpeedNormal, adapter.FeeSpeedFast)
+	}
+
+	history := &feeHistory{}
+	params := []any{hexutil.EncodeUint64(feeHistoryBlocks), "latest", []float64{percentile}}
+
+	return &rpcCall{method: "eth_feeHistory", params: params, out: history}, history, nil
+}
+
+// fees estimates from the history the base fee of the next block and the
+// tip, the median of the priority fees across the blocks that held
+// transactions. The max fee leaves room for the base fee to double, which
+// takes six full blocks.
+func (history *feeHistory) fees() (fees, error) {
+	if len(history.BaseFeePerGas) == 0 {
+		return fees{}, fmt.Errorf("%w: the node reports no base fee", ErrNoFeeHistory)
+	}
+
+	// The last base fee is the one the next block will charge.
+	baseFee, err := parseUint64Quantity(history.BaseFeePerGas[len(history.BaseFeePerGas)-1])
+
+	if err != nil {
+		return fees{}, fmt.Errorf("eth_feeHistory base fee: %w", err)
+	}
+
+	var rewards []uint64
+
+	for block, reward := range history.Reward {
+		if len(reward) == 0 || (block < len(history.GasUsedRatio) && history.GasUsedRatio[block] == 0) {
+			continue
+		}
+
+		tip, err := parseUint64Quantity(reward[0])
+
+		if err != nil {
+			return fees{}, fmt.Errorf("eth_feeHistory reward: %w", err)
+		}
+
+		rewards = append(rewards, tip)
+	}
+
+	if len(rewards) == 0 {
+		return fees{}, fmt.Errorf("%w: none of the last %d blocks held transactions", ErrNoFeeHistory, feeHistoryBlocks)
+	}
+
+	slices.Sort(rewards)
+	tip := rewards[len(rewards)/2]
+
+	return fees{maxFeePerGas: 2*baseFee + tip, maxPriorityFee: tip}, nil
+}
+
+// estimateFees estimates fees at the speed from the node's fee history.
+func (ad *Adapter) estimateFees(ctx context.Context, speed string) (fees, error) {
+	call, history, err := feeHistoryCall(speed)
+
+	if err != nil {
+		return fees{}, err
+	}
+
+	if err := ad.rpc.call(ctx, call.method, call.params, call.out); err != nil {
+		return fees{}, err
+	}
+
+	return history.fees()
+}
+
+// wantsFeeEstimate reports whether the hint leaves a fee for the node to
+// estimate.
+func (ad *Adapter) wantsFeeEstimate(feeHint adapter.FeeHint) bool {
+	return !ad.rpc.offline() && (feeHint.MaxFeePerGas == 0 || feeHint.MaxPriorityFee == 0)
+}
+
+// resolveFees prices a transaction, taking whichever of the max fee and the
+// priority fee the hint sets and the rest from the estimate, which is only
+// read when wantsFeeEstimate. Without an RPC URL nothing can be estimated:
+// the hint needs a max fee, and a priority fee it leaves out is zero.
+func (ad *Adapter) resolveFees(feeHint adapter.FeeHint, estimated fees) (fees, error) {
+	resolved := fees{maxFeePerGas: feeHint.MaxFeePerGas, maxPriorityFee: feeHint.MaxPriorityFee}
+
+	if ad.rpc.offline() && feeHint.MaxFeePerGas == 0 {
+		return fees{}, fmt.Errorf("%w: a max fee per gas is needed to build without a node", ErrNoRPC)
+	}
+
+	if ad.wantsFeeEstimate(feeHint) {
+		switch {
+		case feeHint.MaxFeePerGas == 0 && feeHint.MaxPriorityFee == 0:
+			resolved = estimated
+
+		case feeHint.MaxFeePerGas == 0:
+			// Keep the estimate's headroom over the base fee for the caller's tip.
+			resolved.maxFeePerGas = estimated.maxFeePerGas - estimated.maxPriorityFee + feeHint.MaxPriorityFee
+
+		default:
+			resolved.maxPriorityFee = min(estimated.maxPriorityFee, feeHint.MaxFeePerGas)
+		}
+	}
+
+	if resolved.maxPriorityFee > resolved.maxFeePerGas {
+		return fees{}, fmt.Errorf("max priority fee %d exceeds max fee per gas %d", resolved.maxPriorityFee, resolved.maxFeePerGas)
+	}
+
+	return resolved, nil
+}
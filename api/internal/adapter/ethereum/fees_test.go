@@ -0,0 +1,248 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+const gwei = 1_000_000_000
+
+// testNet is the chain the test servers report.
+var testNet = Network{Name: "goerli", ChainID: 5, Symbol: "ETH"}
+
+// feeHistoryServer answers eth_feeHistory with the rewards of three blocks
+// and an empty one, at whichever single percentile is asked for.
+func feeHistoryServer(t *testing.T) *Adapter {
+	t.Helper()
+
+	rewards := map[float64][]uint64{
+		10: {1 * gwei, 2 * gwei, 0, 1 * gwei},
+		50: {2 * gwei, 3 * gwei, 0, 2 * gwei},
+		90: {5 * gwei, 8 * gwei, 0, 6 * gwei},
+	}
+
+	return rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		switch method {
+		case "eth_feeHistory":
+			var percentiles []float64
+
+			if err := json.Unmarshal(params[2], &percentiles); err != nil || len(percentiles) != 1 {
+				return nil, errors.New("expected a single reward percentile")
+			}
+
+			reward := [][]string{}
+
+			for _, tip := range rewards[percentiles[0]] {
+				reward = append(reward, []string{hexutil.EncodeUint64(tip)})
+			}
+
+			return map[string]any{
+				"oldestBlock":   "0x10",
+				"baseFeePerGas": []string{"0x1", "0x2", "0x3", "0x4", hexutil.EncodeUint64(10 * gwei)},
+				"gasUsedRatio":  []float64{0.5, 0.6, 0, 0.4},
+				"reward":        reward,
+			}, nil
+
+		case "eth_estimateGas":
+			return "0x5208", nil
+
+		case "eth_chainId":
+			return "0x5", nil
+
+		case "eth_getTransactionCount":
+			return "0x7", nil
+		}
+
+		return nil, errors.New("unexpected method " + method)
+	})
+}
+
+// rpcServer serves JSON-RPC requests, single or batched, with the handler
+// and returns an adapter pointed at it.
+func rpcServer(t *testing.T, handle func(method string, params []json.RawMessage) (any, error)) *Adapter {
+	t.Helper()
+
+	type request struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+		ID     uint64            `json:"id"`
+	}
+
+	respond := func(request request) map[string]any {
+		result, err := handle(request.Method, request.Params)
+
+		if err != nil {
+			return map[string]any{"jsonrpc": "2.0", "id": request.ID, "error": map[string]any{"code": -32601, "message": err.Error()}}
+		}
+
+		return map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": result}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		var batch []request
+
+		if json.Unmarshal(body, &batch) != nil {
+			var single request
+
+			_ = json.Unmarshal(body, &single)
+			_ = json.NewEncoder(w).Encode(respond(single))
+
+			return
+		}
+
+		responses := make([]map[string]any, len(batch))
+
+		for i, request := range batch {
+			responses[i] = respond(request)
+		}
+
+		_ = json.NewEncoder(w).Encode(responses)
+	}))
+
+	t.Cleanup(server.Close)
+
+	ad := NewAdapter(testNet, 20, nil)
+	ad.rpc = newRPC(server.URL)
+
+	return ad
+}
+
+func TestEstimateFeesBySpeed(t *testing.T) {
+	t.Parallel()
+
+	ad := feeHistoryServer(t)
+
+	// The empty block is skipped and the median of the other three taken;
+	// the max fee allows the next block's 10 gwei base fee to double.
+	cases := map[string]uint64{
+		"":                     2 * gwei,
+		adapter.FeeSpeedSlow:   1 * gwei,
+		adapter.FeeSpeedNormal: 2 * gwei,
+		adapter.FeeSpeedFast:   6 * gwei,
+	}
+
+	for speed, tip := range cases {
+		tx, err := ad.BuildTx(context.Background(), holder, recipient, big.NewInt(1), adapter.FeeHint{Speed: speed})
+
+		if err != nil {
+			t.Fatalf("%q: %v", speed, err)
+		}
+
+		if tx.PriorityFee != tip || tx.Fee != 20*gwei+tip {
+			t.Fatalf("%q: got fee=%d priority=%d want fee=%d priority=%d", speed, tx.Fee, tx.PriorityFee, 20*gwei+tip, tip)
+		}
+	}
+
+	if _, err := ad.BuildTx(context.Background(), holder, recipient, big.NewInt(1), adapter.FeeHint{Speed: "instant"}); err == nil {
+		t.Fatal("expected an unknown speed to be rejected")
+	}
+}
+
+func TestBuildTxHonorsFeeHint(t *testing.T) {
+	t.Parallel()
+
+	ad := feeHistoryServer(t)
+
+	cases := map[string]struct {
+		hint             adapter.FeeHint
+		fee, priorityFee uint64
+	}{
+		"both":         {adapter.FeeHint{MaxFeePerGas: 30 * gwei, MaxPriorityFee: 3 * gwei}, 30 * gwei, 3 * gwei},
+		"max fee":      {adapter.FeeHint{MaxFeePerGas: 30 * gwei, Speed: adapter.FeeSpeedFast}, 30 * gwei, 6 * gwei},
+		"low max fee":  {adapter.FeeHint{MaxFeePerGas: 1 * gwei}, 1 * gwei, 1 * gwei},
+		"priority fee": {adapter.FeeHint{MaxPriorityFee: 4 * gwei}, 24 * gwei, 4 * gwei},
+	}
+
+	for name, testCase := range cases {
+		tx, err := ad.BuildTx(context.Background(), holder, recipient, big.NewInt(1), testCase.hint)
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if tx.Fee != testCase.fee || tx.PriorityFee != testCase.priorityFee {
+			t.Fatalf("%s: got fee=%d priority=%d want fee=%d priority=%d", name, tx.Fee, tx.PriorityFee, testCase.fee, testCase.priorityFee)
+		}
+	}
+
+	if _, err := ad.BuildTx(context.Background(), holder, recipient, big.NewInt(1), adapter.FeeHint{MaxFeePerGas: gwei, MaxPriorityFee: 2 * gwei}); err == nil {
+		t.Fatal("expected a priority fee above the max fee to be rejected")
+	}
+}
+
+func TestFeeErrorsAreReturned(t *testing.T) {
+	t.Parallel()
+
+	failing := rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		return nil, errors.New("method not found")
+	})
+
+	if _, err := failing.BuildTx(context.Background(), holder, recipient, big.NewInt(1), adapter.FeeHint{}); err == nil {
+		t.Fatal("expected the eth_feeHistory error")
+	}
+
+	preLondon := rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		return map[string]any{"oldestBlock": "0x1", "baseFeePerGas": []string{}, "gasUsedRatio": []float64{}, "reward": [][]string{}}, nil
+	})
+
+	if _, err := preLondon.BuildTx(context.Background(), holder, recipient, big.NewInt(1), adapter.FeeHint{}); !errors.Is(err, ErrNoFeeHistory) {
+		t.Fatalf("got err=%v, want ErrNoFeeHistory", err)
+	}
+}
+
+func TestSignTxUsesTxFees(t *testing.T) {
+	t.Parallel()
+
+	ad := feeHistoryServer(t)
+	priv, _, from, _ := ad.NewKey([]byte("fees"))
+	cases := map[string]struct {
+		hint             adapter.FeeHint
+		fee, priorityFee uint64
+	}{
+		"hinted":    {adapter.FeeHint{MaxFeePerGas: 30 * gwei, MaxPriorityFee: 3 * gwei}, 30 * gwei, 3 * gwei},
+		"estimated": {adapter.FeeHint{}, 22 * gwei, 2 * gwei},
+	}
+
+	// The node's pending nonce stays 7, so each build is allocated the next.
+	nonce := uint64(7)
+
+	for name, testCase := range cases {
+		tx, err := ad.BuildTx(context.Background(), from, from, big.NewInt(1), testCase.hint)
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		signed, err := ad.SignTx(priv, tx)
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var decoded types.Transaction
+
+		if err := decoded.UnmarshalBinary(hexutil.MustDecode(signed.RawHex)); err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.GasFeeCap().Uint64() != testCase.fee || decoded.GasTipCap().Uint64() != testCase.priorityFee || decoded.Nonce() != nonce || decoded.ChainId().Uint64() != 5 {
+			t.Fatalf("%s: got fee=%s priority=%s nonce=%d chain=%s want nonce=%d", name, decoded.GasFeeCap(), decoded.GasTipCap(), decoded.Nonce(), decoded.ChainId(), nonce)
+		}
+
+		nonce++
+	}
+}
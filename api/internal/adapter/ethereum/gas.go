@@ -0,0 +1,76 @@
+package ethereum
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// estimateGasCall asks eth_estimateGas what the transaction uses, into
+// gasHex.
+func estimateGasCall(from, to string, amount *big.Int, data []byte, gasHex *string) *rpcCall {
+	call := map[string]string{
+		"from":  from,
+		"to":    to,
+		"value": hexutil.EncodeBig(amount),
+	}
+
+	if len(data) > 0 {
+		call["input"] = hexutil.Encode(data)
+	}
+
+	return &rpcCall{method: "eth_estimateGas", params: []any{call}, out: gasHex}
+}
+
+// gasLimit adds the adapter's safety margin to the gas eth_estimateGas
+// reported, since state can change before the transaction is mined. A plain
+// transfer always uses exactly 21,000 gas and gets no margin.
+func (ad *Adapter) gasLimit(gasHex string) (uint64, error) {
+	gas, err := parseUint64Quantity(gasHex)
+
+	if err != nil {
+		return 0, fmt.Errorf("eth_estimateGas: %w", err)
+	}
+
+	if gas <= params.TxGas {
+		return gas, nil
+	}
+
+	return gas + gas*ad.gasMargin/100, nil
+}
+
+// offlineGasLimit is the gas limit without a node: a plain transfer's, since
+// what call data uses cannot be known.
+func offlineGasLimit(data []byte) (uint64, error) {
+	if len(data) > 0 {
+		return 0, fmt.Errorf("%w: estimating gas for call data needs a node", ErrNoRPC)
+	}
+
+	return params.TxGas, nil
+}
+
+// maxCost is the most a transaction can take from the sender: the amount
+// plus every unit of gas at the max fee.
+func maxCost(amount *big.Int, gasLimit, maxFeePerGas uint64) *big.Int {
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), new(big.Int).SetUint64(maxFeePerGas))
+
+	return cost.Add(cost, amount)
+}
+
+// uint256Amount checks the amount fits the 256 bits Ethereum values and token
+// amounts have, with nil as zero.
+func uint256Amount(amount *big.Int) (*big.Int, error) {
+	if amount == nil {
+		return new(big.Int), nil
+	}
+
+	if amount.Sign() < 0 || amount.BitLen() > 256 {
+		return nil, fmt.Errorf("%w %s: out of range", adapter.ErrInvalidAmount, amount)
+	}
+
+	return amount, nil
+}
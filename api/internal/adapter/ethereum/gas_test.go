@@ -0,0 +1,147 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// gasServer estimates 21,000 gas for plain transfers and 50,000 for calls,
+// and reverts calls to 0xdead.
+func gasServer(t *testing.T) *Adapter {
+	t.Helper()
+
+	return rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		switch method {
+		case "eth_estimateGas":
+			var call map[string]string
+
+			if err := json.Unmarshal(params[0], &call); err != nil {
+				return nil, err
+			}
+
+			if strings.EqualFold(call["to"], "0x000000000000000000000000000000000000dead") {
+				return nil, errors.New("execution reverted")
+			}
+
+			if call["input"] == "" {
+				return "0x5208", nil
+			}
+
+			return "0xc350", nil
+
+		case "eth_chainId":
+			return "0x5", nil
+
+		case "eth_getTransactionCount":
+			return "0x0", nil
+		}
+
+		return nil, errors.New("unexpected method " + method)
+	})
+}
+
+func TestBuildTxWithDataEstimatesGas(t *testing.T) {
+	t.Parallel()
+
+	ad := gasServer(t)
+	ctx := context.Background()
+	hint := adapter.FeeHint{MaxFeePerGas: 30 * gwei, MaxPriorityFee: 2 * gwei}
+	to := "0x00000000000000000000000000000000000000aa"
+
+	// A call gets the 20% margin; a plain transfer's 21,000 gas does not.
+	cases := map[string]struct {
+		data []byte
+		gas  uint64
+	}{
+		"call":     {[]byte{0xa9, 0x05, 0x9c, 0xbb}, 60_000},
+		"transfer": {nil, 21_000},
+	}
+
+	for name, testCase := range cases {
+		tx, err := ad.BuildTxWithData(ctx, "0x00000000000000000000000000000000000000bb", to, big.NewInt(5), testCase.data, hint, adapter.TxParams{})
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if tx.GasLimit != testCase.gas || tx.MaxCost.Uint64() != 5+testCase.gas*30*gwei || len(tx.Data) != len(testCase.data) {
+			t.Fatalf("%s: got gas=%d maxCost=%d data=%x", name, tx.GasLimit, tx.MaxCost, tx.Data)
+		}
+	}
+
+	if _, err := ad.BuildTxWithData(ctx, to, "0x000000000000000000000000000000000000dead", nil, []byte{1}, hint, adapter.TxParams{}); err == nil {
+		t.Fatal("expected the eth_estimateGas error")
+	}
+}
+
+func TestSignTxCarriesDataAndGasLimit(t *testing.T) {
+	t.Parallel()
+
+	ad := gasServer(t)
+	priv, _, from, _ := ad.NewKey([]byte("gas"))
+	data := []byte{0xa9, 0x05, 0x9c, 0xbb}
+	cases := map[string]struct {
+		gasLimit, want uint64
+	}{
+		"pinned":    {75_000, 75_000},
+		"estimated": {0, 60_000},
+	}
+
+	for name, testCase := range cases {
+		tx, err := ad.BuildTxWithData(context.Background(), from, from, nil, data, adapter.FeeHint{MaxFeePerGas: 30 * gwei, MaxPriorityFee: gwei}, adapter.TxParams{GasLimit: testCase.gasLimit})
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		signed, err := ad.SignTx(priv, tx)
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var decoded types.Transaction
+
+		if err := decoded.UnmarshalBinary(hexutil.MustDecode(signed.RawHex)); err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.Gas() != testCase.want || hexutil.Encode(decoded.Data()) != hexutil.Encode(data) {
+			t.Fatalf("%s: got gas=%d data=%x", name, decoded.Gas(), decoded.Data())
+		}
+	}
+}
+
+func TestAmountsBeyondUint64(t *testing.T) {
+	t.Parallel()
+
+	ad := gasServer(t)
+	// 100 ether in wei does not fit a uint64.
+	amount, _ := new(big.Int).SetString("100000000000000000000", 10)
+	tx, err := ad.BuildTx(context.Background(), "0x00000000000000000000000000000000000000bb", "0x00000000000000000000000000000000000000aa", amount, adapter.FeeHint{MaxFeePerGas: 30 * gwei, MaxPriorityFee: gwei})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.MaxCost.String() != "100000630000000000000" {
+		t.Fatalf("got maxCost=%s", tx.MaxCost)
+	}
+
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	for _, invalid := range []*big.Int{tooLarge, big.NewInt(-1)} {
+		if _, err := ad.BuildTx(context.Background(), "0x00000000000000000000000000000000000000bb", "0x00000000000000000000000000000000000000aa", invalid, adapter.FeeHint{}); !errors.Is(err, adapter.ErrInvalidAmount) {
+			t.Fatalf("%s: got err=%v, want ErrInvalidAmount", invalid, err)
+		}
+	}
+}
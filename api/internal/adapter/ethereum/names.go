@@ -0,0 +1,233 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// ENSRegistry is the ENS registry on Ethereum mainnet and Sepolia.
+const ENSRegistry = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+
+var (
+	ErrInvalidAddress = errors.New("invalid ethereum address")
+	ErrZeroAddress    = errors.New("zero address")
+	ErrNameNotFound   = errors.New("name not found")
+)
+
+// ENS function selectors: the registry's resolver(bytes32) and the
+// resolver's addr(bytes32).
+var (
+	resolverSelector = []byte{0x01, 0x78, 0xb8, 0xbf}
+	addrSelector     = []byte{0x3b, 0x3b, 0x57, 0xde}
+)
+
+// NameResolver resolves a name, such as an ENS name, to the address it
+// points at.
+type NameResolver interface {
+	ResolveName(ctx context.Context, name string) (common.Address, error)
+}
+
+// ENSResolver resolves ENS names with eth_call against the registry for the
+// name's resolver and then against the resolver for its address.
+type ENSResolver struct {
+	rpc      *rpcClient
+	registry common.Address
+}
+
+// NewENSResolver resolves names through the adapter's nodes against the
+// registry at the address.
+func (ad *Adapter) NewENSResolver(registry string) (*ENSResolver, error) {
+	address, err := ParseAddress(registry)
+
+	if err != nil {
+		return nil, fmt.Errorf("ENS registry: %w", err)
+	}
+
+	return &ENSResolver{rpc: ad.rpc, registry: common.HexToAddress(address)}, nil
+}
+
+func (resolver *ENSResolver) ResolveName(ctx context.Context, name string) (common.Address, error) {
+	node, err := nameHash(name)
+
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	if resolver.rpc.offline() {
+		return common.Address{}, fmt.Errorf("%w: resolving %s needs a node", ErrNoRPC, name)
+	}
+
+	nameResolver, err := resolver.call(ctx, resolver.registry, resolverSelector, node)
+
+	if err != nil {
+		return common.Address{}, fmt.Errorf("ENS resolver of %s: %w", name, err)
+	}
+
+	if nameResolver == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%w: %s has no ENS resolver", ErrNameNotFound, name)
+	}
+
+	address, err := resolver.call(ctx, nameResolver, addrSelector, node)
+
+	if err != nil {
+		return common.Address{}, fmt.Errorf("ENS address of %s: %w", name, err)
+	}
+
+	if address == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%w: %s has no address", ErrNameNotFound, name)
+	}
+
+	return address, nil
+}
+
+// call makes a view call taking a node and returning an address.
+func (resolver *ENSResolver) call(ctx context.Context, contract common.Address, selector []byte, node common.Hash) (common.Address, error) {
+	call := map[string]string{
+		"to":    contract.Hex(),
+		"input": hexutil.Encode(append(append([]byte{}, selector...), node.Bytes()...)),
+	}
+
+	var resultHex string
+
+	if err := resolver.rpc.call(ctx, "eth_call", []any{call, "latest"}, &resultHex); err != nil {
+		return common.Address{}, err
+	}
+
+	result, err := hexutil.Decode(resultHex)
+
+	// A call to an address without code returns nothing.
+	if err == nil && len(result) == 0 {
+		return common.Address{}, nil
+	}
+
+	if err != nil || len(result) != 32 {
+		return common.Address{}, fmt.Errorf("unexpected result %q", resultHex)
+	}
+
+	return common.BytesToAddress(result), nil
+}
+
+// nameHash is the ENS namehash of the name, lowercased: the hash of each
+// label's hash with that of the labels to its right. Only labels that ENSIP-15
+// normalisation leaves as they are once lowercased are taken: letters, digits,
+// hyphens and leading underscores, without the "--" of punycode. Other names
+// would hash to a different node than the one registered for them.
+func nameHash(name string) (common.Hash, error) {
+	var node common.Hash
+	labels := strings.Split(strings.ToLower(name), ".")
+
+	for i := len(labels) - 1; i >= 0; i-- {
+		if err := checkLabel(labels[i]); err != nil {
+			return common.Hash{}, fmt.Errorf("%w: %q %v", ErrInvalidAddress, name, err)
+		}
+
+		node = gethcrypto.Keccak256Hash(node.Bytes(), gethcrypto.Keccak256([]byte(labels[i])))
+	}
+
+	return node, nil
+}
+
+func checkLabel(label string) error {
+	if label == "" {
+		return errors.New("has an empty label")
+	}
+
+	if len(label) >= 4 && label[2:4] == "--" {
+		return fmt.Errorf("label %q has a hyphen in the third and fourth places", label)
+	}
+
+	body := strings.TrimLeft(label, "_")
+
+	for _, char := range body {
+		if (char < 'a' || char > 'z') && (char < '0' || char > '9') && char != '-' {
+			return fmt.Errorf("label %q has %q, which is not a letter, digit or hyphen", label, char)
+		}
+	}
+
+	return nil
+}
+
+// isName reports whether the value is a name to resolve rather than a hex
+// address.
+func isName(value string) bool {
+	return strings.Contains(value, ".") && !strings.HasPrefix(strings.ToLower(value), "0x")
+}
+
+// ParseAddress checks that the value is 0x and 40 hex digits and, if it
+// mixes upper and lower case, that the case is its EIP-55 checksum. It
+// returns the address checksummed.
+func ParseAddress(value string) (string, error) {
+	digits, ok := strings.CutPrefix(value, "0x")
+
+	if !ok {
+		digits, ok = strings.CutPrefix(value, "0X")
+	}
+
+	if !ok || len(digits) != 2*common.AddressLength {
+		return "", fmt.Errorf("%w %q: expected 0x and 40 hex digits", ErrInvalidAddress, value)
+	}
+
+	if _, err := hexutil.Decode("0x" + digits); err != nil {
+		return "", fmt.Errorf("%w %q: not hex", ErrInvalidAddress, value)
+	}
+
+	checksummed := common.HexToAddress(digits).Hex()
+
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && digits != checksummed[2:] {
+		return "", fmt.Errorf("%w %q: bad EIP-55 checksum, expected %s", ErrInvalidAddress, value, checksummed)
+	}
+
+	return checksummed, nil
+}
+
+// SetNameResolver sets how names are resolved to addresses, replacing the
+// ENS resolver of networks with a registry. Nil turns names off.
+func (ad *Adapter) SetNameResolver(resolver NameResolver) {
+	ad.resolver = resolver
+}
+
+// ResolveAddress is ParseAddress that also takes a name, such as vitalik.eth,
+// resolved by the adapter's name resolver.
+func (ad *Adapter) ResolveAddress(ctx context.Context, value string) (string, error) {
+	if !isName(value) {
+		return ParseAddress(value)
+	}
+
+	if ad.resolver == nil {
+		return "", fmt.Errorf("%w %q: %s does not resolve names", ErrInvalidAddress, value, ad.network.Name)
+	}
+
+	address, err := ad.resolver.ResolveName(ctx, value)
+
+	if err != nil {
+		return "", err
+	}
+
+	return address.Hex(), nil
+}
+
+// recipientAddress resolves where a transaction is sent, which must not be
+// the zero address: a transfer there burns the coins.
+func (ad *Adapter) recipientAddress(ctx context.Context, value string) (string, error) {
+	address, err := ad.ResolveAddress(ctx, value)
+
+	if err != nil {
+		return "", err
+	}
+
+	if common.HexToAddress(address) == (common.Address{}) {
+		return "", fmt.Errorf("%w: sending to %s would burn it", ErrZeroAddress, address)
+	}
+
+	return address, nil
+}
+
+var _ adapter.NameResolvingAdapter = (*Adapter)(nil)
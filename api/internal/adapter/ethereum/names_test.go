@@ -0,0 +1,194 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+func TestParseAddress(t *testing.T) {
+	t.Parallel()
+
+	const checksummed = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	cases := map[string]struct {
+		value, want string
+		valid       bool
+	}{
+		"checksummed":     {checksummed, checksummed, true},
+		"lower case":      {strings.ToLower(checksummed), checksummed, true},
+		"upper case":      {"0x" + strings.ToUpper(checksummed[2:]), checksummed, true},
+		"upper case 0X":   {"0X" + checksummed[2:], checksummed, true},
+		"zero":            {"0x0000000000000000000000000000000000000000", "0x0000000000000000000000000000000000000000", true},
+		"bad checksum":    {"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "", false},
+		"not hex":         {"0xZZZZb6053F3E94C9b9A09f33669435E7Ef1BeAed", "", false},
+		"no prefix":       {checksummed[2:], "", false},
+		"short":           {checksummed[:41], "", false},
+		"long":            {checksummed + "0", "", false},
+		"empty":           {"", "", false},
+		"name":            {"vitalik.eth", "", false},
+		"prefixed spaces": {" " + checksummed, "", false},
+	}
+
+	for name, testCase := range cases {
+		got, err := NewAdapter(Mainnet, 20, nil).ParseAddress(testCase.value)
+
+		if testCase.valid != (err == nil) || got != testCase.want {
+			t.Fatalf("%s: got=%q err=%v want=%q", name, got, err, testCase.want)
+		}
+
+		if err != nil && !errors.Is(err, ErrInvalidAddress) {
+			t.Fatalf("%s: got err=%v, want ErrInvalidAddress", name, err)
+		}
+	}
+}
+
+func TestNameHash(t *testing.T) {
+	t.Parallel()
+
+	const vitalik = "0xee6c4522aab0003e8d14cd40a6af439055fd2577951148c14b6cea9a53475835"
+
+	for _, name := range []string{"vitalik.eth", "Vitalik.ETH"} {
+		if node, err := nameHash(name); err != nil || node.Hex() != vitalik {
+			t.Fatalf("%s: got=%s err=%v want=%s", name, node.Hex(), err, vitalik)
+		}
+	}
+
+	for _, name := range []string{"_dnslink.vitalik.eth", "my-name.eth", "123.eth"} {
+		if _, err := nameHash(name); err != nil {
+			t.Fatalf("%s: got err=%v", name, err)
+		}
+	}
+
+	// Names ENSIP-15 would map or refuse are not guessed at.
+	for _, name := range []string{"vitalik..eth", "vitalík.eth", "ⓥitalik.eth", "ＶＩＴＡＬＩＫ.eth", "vita lik.eth", "vi_talik.eth", "xn--vtalik-3va.eth", "vitalik.eth."} {
+		if _, err := nameHash(name); !errors.Is(err, ErrInvalidAddress) {
+			t.Fatalf("%s: got err=%v, want ErrInvalidAddress", name, err)
+		}
+	}
+}
+
+// ensServer serves a registry at ENSRegistry where vitalik.eth has a
+// resolver pointing it at owner and nobody.eth has none, and answers what
+// BuildTx asks.
+func ensServer(t *testing.T, owner common.Address) *Adapter {
+	t.Helper()
+
+	vitalik, _ := nameHash("vitalik.eth")
+	resolver := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	ad := rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		switch method {
+		case "eth_call":
+			var call map[string]string
+
+			if err := json.Unmarshal(params[0], &call); err != nil {
+				return nil, err
+			}
+
+			input, _ := hexutil.Decode(call["input"])
+			var result common.Address
+
+			switch {
+			case strings.EqualFold(call["to"], ENSRegistry) && common.BytesToHash(input[4:]) == vitalik:
+				result = resolver
+
+			case strings.EqualFold(call["to"], resolver.Hex()):
+				result = owner
+			}
+
+			return hexutil.Encode(common.LeftPadBytes(result.Bytes(), 32)), nil
+
+		case "eth_getTransactionCount":
+			return "0x0", nil
+
+		case "eth_estimateGas":
+			return "0x5208", nil
+		}
+
+		return nil, errors.New("unexpected " + method)
+	})
+
+	ens, err := ad.NewENSResolver(ENSRegistry)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ad.SetNameResolver(ens)
+
+	return ad
+}
+
+func TestResolveAddress(t *testing.T) {
+	t.Parallel()
+
+	owner := common.HexToAddress("0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045")
+	ad := ensServer(t, owner)
+	ctx := context.Background()
+
+	for _, value := range []string{"vitalik.eth", "VITALIK.eth", strings.ToLower(owner.Hex())} {
+		if got, err := ad.ResolveAddress(ctx, value); err != nil || got != owner.Hex() {
+			t.Fatalf("%s: got=%s err=%v want=%s", value, got, err, owner.Hex())
+		}
+	}
+
+	if _, err := ad.ResolveAddress(ctx, "nobody.eth"); !errors.Is(err, ErrNameNotFound) {
+		t.Fatalf("got err=%v, want ErrNameNotFound", err)
+	}
+
+	// A name resolves wherever a recipient is expected.
+	tx, err := ad.BuildTx(ctx, holder, "vitalik.eth", big.NewInt(1), adapter.FeeHint{MaxFeePerGas: gwei, MaxPriorityFee: gwei})
+
+	if err != nil || tx.To != owner.Hex() {
+		t.Fatalf("got to=%s err=%v want=%s", tx.To, err, owner.Hex())
+	}
+
+	// Networks without a registry take no names.
+	if _, err := NewAdapter(Polygon, 20, nil).ResolveAddress(ctx, "vitalik.eth"); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("polygon: got err=%v, want ErrInvalidAddress", err)
+	}
+
+	if _, err := NewAdapter(Mainnet, 20, nil).ResolveAddress(ctx, "vitalik.eth"); !errors.Is(err, ErrNoRPC) {
+		t.Fatalf("offline: got err=%v, want ErrNoRPC", err)
+	}
+}
+
+func TestBuildTxRejectsBadRecipients(t *testing.T) {
+	t.Parallel()
+
+	// A name pointing at the zero address has no address.
+	ad := ensServer(t, common.Address{})
+	usdc := MainnetTokens[0]
+	hint := adapter.FeeHint{MaxFeePerGas: gwei, MaxPriorityFee: gwei}
+	cases := map[string]struct {
+		recipient string
+		want      error
+	}{
+		"zero":         {"0x0000000000000000000000000000000000000000", ErrZeroAddress},
+		"bad checksum": {"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", ErrInvalidAddress},
+		"not hex":      {"0xto", ErrInvalidAddress},
+		"unresolved":   {"nobody.eth", ErrNameNotFound},
+		"zero name":    {"vitalik.eth", ErrNameNotFound},
+	}
+
+	for name, testCase := range cases {
+		if _, err := ad.BuildTx(context.Background(), holder, testCase.recipient, big.NewInt(1), hint); !errors.Is(err, testCase.want) {
+			t.Fatalf("%s: got err=%v want=%v", name, err, testCase.want)
+		}
+
+		if _, err := ad.BuildTokenTx(context.Background(), usdc, holder, testCase.recipient, big.NewInt(1), hint, adapter.TxParams{}); !errors.Is(err, testCase.want) {
+			t.Fatalf("%s token: got err=%v want=%v", name, err, testCase.want)
+		}
+	}
+
+	if _, err := ad.BuildTx(context.Background(), "0xfrom", recipient, big.NewInt(1), hint); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("bad sender: got err=%v, want ErrInvalidAddress", err)
+	}
+}
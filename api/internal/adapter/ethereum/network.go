@@ -0,0 +1,94 @@
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Network is the EVM chain an adapter works on. Name is the adapter registry
+// entry, ChainID what the node at RPCURLs must report and what transactions
+// are signed for, Symbol and ExplorerURL the native coin and the block
+// explorer for the chain, and ENSRegistry the ENS registry names are resolved
+// against, empty on chains without one.
+type Network struct {
+	Name        string
+	ChainID     uint64
+	RPCURLs     []string
+	Symbol      string
+	ExplorerURL string
+	ENSRegistry string
+}
+
+var networks = map[string]Network{
+	"ethereum": {Name: "ethereum", ChainID: 1, Symbol: "ETH", ExplorerURL: "https://etherscan.io", ENSRegistry: ENSRegistry},
+	"sepolia":  {Name: "sepolia", ChainID: 11155111, Symbol: "ETH", ExplorerURL: "https://sepolia.etherscan.io", ENSRegistry: ENSRegistry},
+	"polygon":  {Name: "polygon", ChainID: 137, Symbol: "POL", ExplorerURL: "https://polygonscan.com"},
+	"base":     {Name: "base", ChainID: 8453, Symbol: "ETH", ExplorerURL: "https://basescan.org"},
+}
+
+var (
+	Mainnet = networks["ethereum"]
+	Sepolia = networks["sepolia"]
+	Polygon = networks["polygon"]
+	Base    = networks["base"]
+)
+
+// NetworkNames lists the networks LookupNetwork knows, in name order.
+func NetworkNames() []string {
+	names := make([]string, 0, len(networks))
+
+	for name := range networks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupNetwork returns a known network, without RPC URLs. Any other EVM
+// chain can be used by filling in a Network.
+func LookupNetwork(name string) (Network, error) {
+	network, ok := networks[name]
+
+	if !ok {
+		return Network{}, fmt.Errorf("unknown EVM network %q, expected one of %v", name, NetworkNames())
+	}
+
+	return network, nil
+}
+
+// CheckChainID asks the node at each RPC URL for its chain ID and fails if
+// one reports another chain, so a misconfigured URL is caught before
+// anything is signed for, or failed over to, the wrong chain. A node that
+// cannot be reached is logged and marked unhealthy instead, so one that is
+// down does not keep the server from starting and requests fail over past
+// it. Without an RPC URL there is nothing to check.
+func (ad *Adapter) CheckChainID(ctx context.Context) error {
+	for _, endpoint := range ad.rpc.endpoints {
+		var chainIDHex string
+		probe := newRPC(endpoint.url)
+		probe.retries = 0
+
+		if err := probe.call(ctx, "eth_chainId", nil, &chainIDHex); err != nil {
+			log.Printf("%s: cannot check the chain ID of the node at %s: %v", ad.network.Name, endpoint.url, err)
+			ad.rpc.failed(endpoint)
+
+			continue
+		}
+
+		chainID, err := parseUint64Quantity(chainIDHex)
+
+		if err != nil {
+			return fmt.Errorf("%s eth_chainId: %w", ad.network.Name, err)
+		}
+
+		if chainID != ad.network.ChainID {
+			return fmt.Errorf("%s: node at %s reports chain ID %d, want %d", ad.network.Name, endpoint.url, chainID, ad.network.ChainID)
+		}
+	}
+
+	return nil
+}
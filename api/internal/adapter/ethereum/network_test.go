@@ -0,0 +1,67 @@
+package ethereum
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLookupNetwork(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range NetworkNames() {
+		network, err := LookupNetwork(name)
+
+		if err != nil || network.Name != name || network.ChainID == 0 || network.Symbol == "" {
+			t.Fatalf("%s: got=%+v err=%v", name, network, err)
+		}
+	}
+
+	if _, err := LookupNetwork("goerli"); err == nil {
+		t.Fatal("expected an unknown network to be rejected")
+	}
+
+	if ad := NewAdapter(Polygon, 20, nil); ad.Network() != "polygon" || ad.ChainID() != 137 || ad.Symbol() != "POL" {
+		t.Fatalf("got network=%s chain=%d symbol=%s", ad.Network(), ad.ChainID(), ad.Symbol())
+	}
+}
+
+func TestCheckChainID(t *testing.T) {
+	t.Parallel()
+
+	// The fee history server reports chain ID 5.
+	ad := feeHistoryServer(t)
+
+	if err := ad.CheckChainID(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ad.network = Sepolia
+
+	if err := ad.CheckChainID(context.Background()); err == nil {
+		t.Fatal("expected a node on another chain to be rejected")
+	}
+
+	// An unreachable backup URL does not stop the network from starting, but
+	// is passed over until it recovers.
+	ad.network = testNet
+	ad.rpc.endpoints = append(ad.rpc.endpoints, &endpoint{url: "http://127.0.0.1:0"})
+
+	if err := ad.CheckChainID(context.Background()); err != nil {
+		t.Fatalf("unreachable backup: %v", err)
+	}
+
+	if endpoints := ad.RPCEndpoints(); !endpoints[0].Healthy || endpoints[1].Healthy {
+		t.Fatalf("got endpoints %+v, want the backup unhealthy", endpoints)
+	}
+
+	// A node on another chain still is.
+	ad.network = Sepolia
+
+	if err := ad.CheckChainID(context.Background()); err == nil {
+		t.Fatal("expected a node on another chain to be rejected")
+	}
+
+	if err := NewAdapter(Sepolia, 20, nil).CheckChainID(context.Background()); err != nil {
+		t.Fatalf("without an RPC URL: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package ethereum
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/afrodynamic/gochain/api/internal/platform/jsonfile"
+)
+
+// nonceLease is how long an allocated nonce is held for its transaction to be
+// signed and broadcast. Once it lapses, a nonce the node still has no
+// transaction for is a gap and is handed out again.
+const nonceLease = 5 * time.Minute
+
+// NonceManager allocates the nonces of each sender locally, so concurrent
+// BuildTx calls for one address never get the same one. Every allocation is
+// reconciled with the node's pending nonce: a node that is ahead, because
+// the address sent from elsewhere, moves the next nonce up, and a node that
+// is behind has a gap where a transaction was never broadcast or was dropped,
+// which is filled first. The next nonce of each address is persisted to a
+// JSON file after every allocation so restarts do not reuse nonces still in
+// flight. An empty path keeps them in memory.
+type NonceManager struct {
+	mutex    sync.Mutex
+	path     string
+	now      func() time.Time
+	accounts map[common.Address]*nonceAccount
+}
+
+// nonceAccount is the next nonce of an address and the expiry of each lease
+// below it.
+type nonceAccount struct {
+	next   uint64
+	leases map[uint64]time.Time
+}
+
+func LoadNonceManager(path string) (*NonceManager, error) {
+	manager := &NonceManager{path: path, now: time.Now, accounts: make(map[common.Address]*nonceAccount)}
+
+	var next map[common.Address]uint64
+
+	if err := jsonfile.Load(path, &next); err != nil {
+		return nil, err
+	}
+
+	for address, nonce := range next {
+		manager.accounts[address] = &nonceAccount{next: nonce, leases: make(map[uint64]time.Time)}
+	}
+
+	return manager, nil
+}
+
+// Allocate leases the nonce for the sender's next transaction, given the
+// node's pending nonce for it.
+func (manager *NonceManager) Allocate(from common.Address, pending uint64) (uint64, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	account, ok := manager.accounts[from]
+
+	if !ok {
+		account = &nonceAccount{leases: make(map[uint64]time.Time)}
+		manager.accounts[from] = account
+	}
+
+	now := manager.now()
+
+	for nonce, expiry := range account.leases {
+		if nonce < pending || !now.Before(expiry) {
+			delete(account.leases, nonce)
+		}
+	}
+
+	_, leased := account.leases[pending]
+
+	switch {
+	case pending > account.next:
+		account.next = pending
+
+	case pending < account.next && !leased:
+		// The node has nothing at the pending nonce, though it was handed
+		// out and its lease has lapsed or been released.
+		account.leases[pending] = now.Add(nonceLease)
+
+		return pending, nil
+	}
+
+	nonce := account.next
+	previous := account.next
+	account.leases[nonce] = now.Add(nonceLease)
+	account.next = nonce + 1
+
+	if err := manager.saveLocked(); err != nil {
+		delete(account.leases, nonce)
+		account.next = previous
+
+		return 0, err
+	}
+
+	return nonce, nil
+}
+
+// Release gives up the lease of a nonce whose transaction was not broadcast.
+// The latest nonce is handed out again next; an earlier one as soon as the
+// node confirms it is free. The persisted next nonce is left as it is, since
+// a higher one is reconciled on the next allocation.
+func (manager *NonceManager) Release(from common.Address, nonce uint64) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	account, ok := manager.accounts[from]
+
+	if !ok {
+		return
+	}
+
+	delete(account.leases, nonce)
+
+	if nonce+1 == account.next {
+		account.next = nonce
+	}
+}
+
+// Next returns the nonce the sender's next allocation would take if the
+// node agreed, or zero for an address with none yet.
+func (manager *NonceManager) Next(from common.Address) uint64 {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	if account, ok := manager.accounts[from]; ok {
+		return account.next
+	}
+
+	return 0
+}
+
+func (manager *NonceManager) saveLocked() error {
+	next := make(map[common.Address]uint64, len(manager.accounts))
+
+	for address, account := range manager.accounts {
+		next[address] = account.next
+	}
+
+	return jsonfile.Save(manager.path, next)
+}
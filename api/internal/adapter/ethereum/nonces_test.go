@@ -0,0 +1,253 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+func TestNonceManagerConcurrentSenders(t *testing.T) {
+	t.Parallel()
+
+	nonces, _ := LoadNonceManager("")
+	senders := []common.Address{common.HexToAddress(holder), common.HexToAddress(recipient)}
+	const perSender = 50
+
+	var wait sync.WaitGroup
+	var mutex sync.Mutex
+	allocated := make(map[common.Address]map[uint64]bool)
+
+	for _, from := range senders {
+		allocated[from] = make(map[uint64]bool)
+
+		for range perSender {
+			wait.Add(1)
+
+			go func() {
+				defer wait.Done()
+
+				// Every sender asks the node at once and is told the same
+				// pending nonce.
+				nonce, err := nonces.Allocate(from, 7)
+
+				if err != nil {
+					t.Error(err)
+
+					return
+				}
+
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				if allocated[from][nonce] {
+					t.Errorf("%s: nonce %d allocated twice", from.Hex(), nonce)
+				}
+
+				allocated[from][nonce] = true
+			}()
+		}
+	}
+
+	wait.Wait()
+
+	for _, from := range senders {
+		for nonce := uint64(7); nonce < 7+perSender; nonce++ {
+			if !allocated[from][nonce] {
+				t.Fatalf("%s: nonce %d skipped", from.Hex(), nonce)
+			}
+		}
+	}
+}
+
+func TestNonceManagerReconciles(t *testing.T) {
+	t.Parallel()
+
+	nonces, _ := LoadNonceManager("")
+	now := time.Now()
+	nonces.now = func() time.Time { return now }
+	from := common.HexToAddress(holder)
+	allocate := func(pending, want uint64) {
+		t.Helper()
+
+		if nonce, err := nonces.Allocate(from, pending); err != nil || nonce != want {
+			t.Fatalf("pending %d: got nonce=%d err=%v want=%d", pending, nonce, err, want)
+		}
+	}
+
+	allocate(7, 7)
+	allocate(7, 8)
+
+	// The address sent from elsewhere, so the node is ahead.
+	allocate(12, 12)
+
+	// The latest nonce was not broadcast, so it is handed out again.
+	nonces.Release(from, 12)
+	allocate(12, 12)
+	allocate(12, 13)
+	allocate(12, 14)
+
+	// 13 was released but the node has not caught up to it, while 12 is
+	// still leased.
+	nonces.Release(from, 13)
+	allocate(12, 15)
+
+	// 12 never reached the node and its lease lapsed.
+	now = now.Add(nonceLease)
+	allocate(12, 12)
+
+	// The node has 12; 13 is the gap now.
+	allocate(13, 13)
+	allocate(16, 16)
+
+	if next := nonces.Next(from); next != 17 {
+		t.Fatalf("got next=%d want=17", next)
+	}
+}
+
+func TestNonceManagerPersists(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nonces", "sepolia.json")
+	nonces, err := LoadNonceManager(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := common.HexToAddress(holder)
+
+	for range 3 {
+		if _, err := nonces.Allocate(from, 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restarted, err := LoadNonceManager(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next := restarted.Next(from); next != 7 {
+		t.Fatalf("got next=%d want=7", next)
+	}
+
+	// Before the restart 4, 5 and 6 were in flight; the node has 4 and 5.
+	if nonce, err := restarted.Allocate(from, 6); err != nil || nonce != 6 {
+		t.Fatalf("got nonce=%d err=%v want=6", nonce, err)
+	}
+
+	if nonce, err := restarted.Allocate(from, 6); err != nil || nonce != 7 {
+		t.Fatalf("got nonce=%d err=%v want=7", nonce, err)
+	}
+}
+
+func TestBuildTxAllocatesNoncesConcurrently(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	reject := false
+	ad := rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		switch method {
+		case "eth_getTransactionCount":
+			return "0x7", nil
+
+		case "eth_estimateGas":
+			return "0x5208", nil
+
+		case "eth_sendRawTransaction":
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if reject {
+				return nil, errors.New("replacement transaction underpriced")
+			}
+
+			return "0x", nil
+		}
+
+		return nil, errors.New("unexpected " + method)
+	})
+
+	priv, _, from, _ := ad.NewKey([]byte("hot wallet"))
+	hint := adapter.FeeHint{MaxFeePerGas: 30 * gwei, MaxPriorityFee: gwei}
+	const senders = 20
+
+	var wait sync.WaitGroup
+	nonces := make(chan uint64, senders)
+
+	for range senders {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			tx, err := ad.BuildTx(context.Background(), from, recipient, big.NewInt(1), hint)
+
+			if err != nil {
+				t.Error(err)
+
+				return
+			}
+
+			signed, err := ad.SignTx(priv, tx)
+
+			if err != nil {
+				t.Error(err)
+
+				return
+			}
+
+			if _, err := ad.Broadcast(context.Background(), signed); err != nil {
+				t.Error(err)
+
+				return
+			}
+
+			nonces <- tx.Nonce
+		}()
+	}
+
+	wait.Wait()
+	close(nonces)
+
+	seen := make(map[uint64]bool)
+
+	for nonce := range nonces {
+		if seen[nonce] || nonce < 7 || nonce >= 7+senders {
+			t.Fatalf("nonce %d duplicated or out of range", nonce)
+		}
+
+		seen[nonce] = true
+	}
+
+	// A rejected transaction gives its nonce back for the next build.
+	mutex.Lock()
+	reject = true
+	mutex.Unlock()
+
+	tx, err := ad.BuildTx(context.Background(), from, recipient, big.NewInt(1), hint)
+
+	if err != nil || tx.Nonce != 7+senders {
+		t.Fatalf("got nonce=%d err=%v want=%d", tx.Nonce, err, 7+senders)
+	}
+
+	signed, _ := ad.SignTx(priv, tx)
+
+	if _, err := ad.Broadcast(context.Background(), signed); err == nil {
+		t.Fatal("expected the broadcast to be rejected")
+	}
+
+	if tx, err := ad.BuildTx(context.Background(), from, recipient, big.NewInt(1), hint); err != nil || tx.Nonce != 7+senders {
+		t.Fatalf("got nonce=%d err=%v want %d again", tx.Nonce, err, 7+senders)
+	}
+}
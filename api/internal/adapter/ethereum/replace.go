@@ -0,0 +1,218 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// replacementBump is the percentage by which a replacement must raise both
+// the max fee and the priority fee of a pending transaction for nodes to
+// accept it with the same nonce.
+const replacementBump = 10
+
+var ErrNotPending = errors.New("transaction is not pending")
+
+// pendingTx is a transaction in the node's mempool, as a replacement needs
+// it.
+type pendingTx struct {
+	from     string
+	to       *string
+	value    *big.Int
+	data     []byte
+	gasLimit uint64
+	nonce    uint64
+	fees     fees
+}
+
+// SpeedUpTx rebuilds a pending transaction with the same nonce, recipient,
+// value, data and gas limit, priced like BuildTx but at least
+// replacementBump percent above the original's fees.
+func (ad *Adapter) SpeedUpTx(ctx context.Context, txID string, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	original, replacementFees, err := ad.replacement(ctx, txID, feeHint)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	if original.to == nil {
+		return adapter.Tx{}, fmt.Errorf("%s creates a contract and can only be cancelled", txID)
+	}
+
+	return ad.replacementTx(original.from, *original.to, original.value, original.data, original.gasLimit, original.nonce, replacementFees), nil
+}
+
+// CancelTx replaces a pending transaction with a transfer of nothing from the
+// sender to itself with the same nonce, at fees at least replacementBump
+// percent above the original's.
+func (ad *Adapter) CancelTx(ctx context.Context, txID string, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	original, replacementFees, err := ad.replacement(ctx, txID, feeHint)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	return ad.replacementTx(original.from, original.from, new(big.Int), nil, params.TxGas, original.nonce, replacementFees), nil
+}
+
+func (ad *Adapter) replacementTx(from, to string, amount *big.Int, data []byte, gasLimit, nonce uint64, fees fees) adapter.Tx {
+	return adapter.Tx{
+		From:        from,
+		To:          to,
+		Amount:      amount,
+		Fee:         fees.maxFeePerGas,
+		PriorityFee: fees.maxPriorityFee,
+		Nonce:       nonce,
+		Data:        data,
+		GasLimit:    gasLimit,
+		MaxCost:     maxCost(amount, gasLimit, fees.maxFeePerGas),
+		ChainID:     ad.network.ChainID,
+	}
+}
+
+// replacement asks the node for the pending transaction and, unless the hint
+// sets both fees, the fee history in one batch, and prices its replacement.
+func (ad *Adapter) replacement(ctx context.Context, txID string, feeHint adapter.FeeHint) (pendingTx, fees, error) {
+	hash, err := hexutil.Decode(txID)
+
+	if err != nil || len(hash) != common.HashLength {
+		return pendingTx{}, fees{}, fmt.Errorf("invalid transaction hash %q", txID)
+	}
+
+	if ad.rpc.offline() {
+		return pendingTx{}, fees{}, fmt.Errorf("%w: replacing a transaction needs a node", ErrNoRPC)
+	}
+
+	var tx *rpcTransaction
+	calls := []*rpcCall{{method: "eth_getTransactionByHash", params: []any{txID}, out: &tx}}
+	var history *feeHistory
+
+	if ad.wantsFeeEstimate(feeHint) {
+		historyCall, estimate, err := feeHistoryCall(feeHint.Speed)
+
+		if err != nil {
+			return pendingTx{}, fees{}, err
+		}
+
+		calls, history = append(calls, historyCall), estimate
+	}
+
+	if err := ad.rpc.batch(ctx, calls...); err != nil {
+		return pendingTx{}, fees{}, err
+	}
+
+	for _, call := range calls {
+		if call.err != nil {
+			return pendingTx{}, fees{}, call.err
+		}
+	}
+
+	switch {
+	case tx == nil:
+		return pendingTx{}, fees{}, fmt.Errorf("%w: the node does not know %s", ErrNotPending, txID)
+
+	case tx.BlockNumber != nil:
+		return pendingTx{}, fees{}, fmt.Errorf("%w: %s is already in block %s", ErrNotPending, txID, *tx.BlockNumber)
+	}
+
+	original, err := parsePendingTx(*tx)
+
+	if err != nil {
+		return pendingTx{}, fees{}, fmt.Errorf("eth_getTransactionByHash: %w", err)
+	}
+
+	var estimated fees
+
+	if history != nil {
+		if estimated, err = history.fees(); err != nil {
+			return pendingTx{}, fees{}, err
+		}
+	}
+
+	resolved, err := ad.resolveFees(feeHint, estimated)
+
+	if err != nil {
+		return pendingTx{}, fees{}, err
+	}
+
+	replacementFees := fees{
+		maxFeePerGas:   max(resolved.maxFeePerGas, bumpFee(original.fees.maxFeePerGas)),
+		maxPriorityFee: max(resolved.maxPriorityFee, bumpFee(original.fees.maxPriorityFee)),
+	}
+	replacementFees.maxFeePerGas = max(replacementFees.maxFeePerGas, replacementFees.maxPriorityFee)
+
+	return original, replacementFees, nil
+}
+
+// bumpFee raises a fee by replacementBump percent, rounding up.
+func bumpFee(fee uint64) uint64 {
+	return fee + (fee*replacementBump+99)/100
+}
+
+func parsePendingTx(tx rpcTransaction) (pendingTx, error) {
+	value, err := parseQuantity(tx.Value)
+
+	if err != nil {
+		return pendingTx{}, err
+	}
+
+	data, err := hexutil.Decode(tx.Input)
+
+	if err != nil {
+		return pendingTx{}, fmt.Errorf("input: %w", err)
+	}
+
+	gasLimit, err := parseUint64Quantity(tx.Gas)
+
+	if err != nil {
+		return pendingTx{}, err
+	}
+
+	nonce, err := parseUint64Quantity(tx.Nonce)
+
+	if err != nil {
+		return pendingTx{}, err
+	}
+
+	// A legacy transaction pays its gas price as both fees.
+	maxFeeHex, priorityFeeHex := tx.MaxFeePerGas, tx.MaxPriorityFeePerGas
+
+	if maxFeeHex == "" {
+		maxFeeHex, priorityFeeHex = tx.GasPrice, tx.GasPrice
+	}
+
+	maxFeePerGas, err := parseUint64Quantity(maxFeeHex)
+
+	if err != nil {
+		return pendingTx{}, err
+	}
+
+	maxPriorityFee, err := parseUint64Quantity(priorityFeeHex)
+
+	if err != nil {
+		return pendingTx{}, err
+	}
+
+	if len(data) == 0 {
+		data = nil
+	}
+
+	return pendingTx{
+		from:     tx.From,
+		to:       tx.To,
+		value:    value,
+		data:     data,
+		gasLimit: gasLimit,
+		nonce:    nonce,
+		fees:     fees{maxFeePerGas: maxFeePerGas, maxPriorityFee: maxPriorityFee},
+	}, nil
+}
+
+var _ adapter.ReplacementAdapter = (*Adapter)(nil)
@@ -0,0 +1,114 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// pendingServer knows a pending transfer with call data at nonce 9, paying
+// 20 gwei with a 1 gwei tip, a legacy one at 10 gwei, and a mined one. Fees
+// are estimated at 22 gwei with a 2 gwei tip.
+func pendingServer(t *testing.T) (*Adapter, map[string]string) {
+	t.Helper()
+
+	hashes := map[string]string{
+		"pending": "0x" + strings.Repeat("1", 64),
+		"legacy":  "0x" + strings.Repeat("2", 64),
+		"mined":   "0x" + strings.Repeat("3", 64),
+	}
+	transactions := map[string]map[string]any{
+		hashes["pending"]: {"from": holder, "to": recipient, "input": "0xa9059cbb", "value": "0x64", "gas": "0xc350", "nonce": "0x9", "maxFeePerGas": "0x4a817c800", "maxPriorityFeePerGas": "0x3b9aca00", "blockNumber": nil},
+		hashes["legacy"]:  {"from": holder, "to": recipient, "input": "0x", "value": "0x1", "gas": "0x5208", "nonce": "0xa", "gasPrice": "0x2540be400", "blockNumber": nil},
+		hashes["mined"]:   {"from": holder, "to": recipient, "input": "0x", "value": "0x1", "gas": "0x5208", "nonce": "0x8", "gasPrice": "0x2540be400", "blockNumber": "0x10"},
+	}
+
+	ad := rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		switch method {
+		case "eth_getTransactionByHash":
+			if tx, ok := transactions[hashParam(params)]; ok {
+				return tx, nil
+			}
+
+			return nil, nil
+
+		case "eth_feeHistory":
+			return map[string]any{
+				"baseFeePerGas": []string{"0x2540be400"},
+				"gasUsedRatio":  []float64{0.5},
+				"reward":        [][]string{{"0x77359400"}},
+			}, nil
+		}
+
+		return nil, errors.New("unexpected " + method)
+	})
+
+	return ad, hashes
+}
+
+func TestSpeedUpTx(t *testing.T) {
+	t.Parallel()
+
+	ad, hashes := pendingServer(t)
+	cases := map[string]struct {
+		hint             adapter.FeeHint
+		fee, priorityFee uint64
+	}{
+		// The estimate's 22 gwei beats the 10% bump to 22 gwei; its tip of 2
+		// beats 1.1.
+		"estimated": {adapter.FeeHint{}, 22 * gwei, 2 * gwei},
+		// A hint below the bump is raised to it.
+		"hinted low": {adapter.FeeHint{MaxFeePerGas: 21 * gwei, MaxPriorityFee: gwei}, 22 * gwei, gwei + gwei/10},
+		"hinted":     {adapter.FeeHint{MaxFeePerGas: 50 * gwei, MaxPriorityFee: 5 * gwei}, 50 * gwei, 5 * gwei},
+	}
+
+	for name, testCase := range cases {
+		tx, err := ad.SpeedUpTx(context.Background(), hashes["pending"], testCase.hint)
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if tx.Fee != testCase.fee || tx.PriorityFee != testCase.priorityFee {
+			t.Fatalf("%s: got fee=%d priority=%d want=%d and %d", name, tx.Fee, tx.PriorityFee, testCase.fee, testCase.priorityFee)
+		}
+
+		if tx.Nonce != 9 || tx.GasLimit != 50_000 || tx.Amount.Int64() != 100 || tx.To != recipient || tx.From != holder || string(tx.Data) != "\xa9\x05\x9c\xbb" || tx.ChainID != 5 {
+			t.Fatalf("%s: got %+v, want the original with new fees", name, tx)
+		}
+	}
+
+	// A legacy transaction's gas price stands for both fees.
+	if tx, err := ad.SpeedUpTx(context.Background(), hashes["legacy"], adapter.FeeHint{MaxFeePerGas: gwei, MaxPriorityFee: gwei}); err != nil || tx.Fee != 11*gwei || tx.PriorityFee != 11*gwei || tx.Nonce != 10 {
+		t.Fatalf("legacy: got fee=%d priority=%d nonce=%d err=%v", tx.Fee, tx.PriorityFee, tx.Nonce, err)
+	}
+
+	for _, id := range []string{hashes["mined"], "0x" + strings.Repeat("4", 64)} {
+		if _, err := ad.SpeedUpTx(context.Background(), id, adapter.FeeHint{}); !errors.Is(err, ErrNotPending) {
+			t.Fatalf("%s: got err=%v, want ErrNotPending", id, err)
+		}
+	}
+
+	if _, err := NewAdapter(testNet, 20, nil).SpeedUpTx(context.Background(), hashes["pending"], adapter.FeeHint{MaxFeePerGas: gwei}); !errors.Is(err, ErrNoRPC) {
+		t.Fatalf("offline: got err=%v, want ErrNoRPC", err)
+	}
+}
+
+func TestCancelTx(t *testing.T) {
+	t.Parallel()
+
+	ad, hashes := pendingServer(t)
+	tx, err := ad.CancelTx(context.Background(), hashes["pending"], adapter.FeeHint{MaxFeePerGas: 21 * gwei, MaxPriorityFee: 3 * gwei})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.From != holder || tx.To != holder || tx.Amount.Sign() != 0 || tx.Data != nil || tx.GasLimit != 21_000 || tx.Nonce != 9 || tx.Fee != 22*gwei || tx.PriorityFee != 3*gwei {
+		t.Fatalf("got %+v, want a zero-value self-send at nonce 9", tx)
+	}
+}
@@ -4,29 +4,85 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+var ErrNoRPC = errors.New("no RPC URL set")
+
+const (
+	rpcTimeout = 15 * time.Second
+	// rpcRetries is how many times a request is retried after a transient
+	// failure: a network error, HTTP 429 or a 5xx.
+	rpcRetries = 3
+	// rpcBackoff doubles after each retry up to rpcMaxBackoff.
+	rpcBackoff    = 200 * time.Millisecond
+	rpcMaxBackoff = 5 * time.Second
+	// endpointCooldown doubles with each consecutive failure of an endpoint,
+	// up to maxEndpointCooldown, and the endpoint is passed over until it ends.
+	endpointCooldown    = time.Second
+	maxEndpointCooldown = time.Minute
 )
 
+// rpcClient sends JSON-RPC requests to the first healthy endpoint, retrying
+// transient failures with exponential backoff on the next one.
 type rpcClient struct {
-	url    string
-	client *http.Client
+	client     *http.Client
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	cooldown   time.Duration
+	nextID     atomic.Uint64
+
+	mutex     sync.Mutex
+	endpoints []*endpoint
+	metrics   map[string]*adapter.RPCMetrics
+}
+
+// endpoint is a node URL and its recent failures.
+type endpoint struct {
+	url       string
+	failures  int
+	downUntil time.Time
 }
 
-func newRPC() *rpcClient {
-	return &rpcClient{
-		url:    os.Getenv("ETH_RPC"),
-		client: &http.Client{Timeout: 15 * time.Second},
+func newRPC(urls ...string) *rpcClient {
+	client := &rpcClient{
+		client:     &http.Client{Timeout: rpcTimeout},
+		retries:    rpcRetries,
+		backoff:    rpcBackoff,
+		maxBackoff: rpcMaxBackoff,
+		cooldown:   endpointCooldown,
+		metrics:    make(map[string]*adapter.RPCMetrics),
 	}
+
+	for _, url := range urls {
+		if url != "" {
+			client.endpoints = append(client.endpoints, &endpoint{url: url})
+		}
+	}
+
+	return client
+}
+
+// offline reports whether there is no node to ask.
+func (c *rpcClient) offline() bool {
+	return len(c.endpoints) == 0
 }
 
 type rpcRequest struct {
 	JSONRPC string `json:"jsonrpc"`
 	Method  string `json:"method"`
 	Params  []any  `json:"params"`
-	ID      int    `json:"id"`
+	ID      uint64 `json:"id"`
 }
 
 type rpcError struct {
@@ -34,36 +90,157 @@ type rpcError struct {
 	Message string `json:"message"`
 }
 
+func (err *rpcError) Error() string {
+	return fmt.Sprintf("(%d) %s", err.Code, err.Message)
+}
+
 type rpcResponse struct {
 	Result json.RawMessage `json:"result"`
 	Error  *rpcError       `json:"error"`
-	ID     int             `json:"id"`
+	ID     uint64          `json:"id"`
 }
 
-func (c *rpcClient) call(ctx context.Context, method string, params []any, out interface{}) error {
-	if c.url == "" {
-		return fmt.Errorf("ETH_RPC not set")
+// rpcCall is one request in a batch. Its result is decoded into out, and err
+// is what went wrong with it alone. maybeDelivered is set when an attempt
+// that failed may still have reached a node, after a network error or a 5xx,
+// so a retry can be told apart from a first try.
+type rpcCall struct {
+	method         string
+	params         []any
+	out            any
+	err            error
+	maybeDelivered bool
+}
+
+// httpError is a response with a status other than 200.
+type httpError struct {
+	status int
+}
+
+func (err *httpError) Error() string {
+	return fmt.Sprintf("http %d", err.status)
+}
+
+func (c *rpcClient) call(ctx context.Context, method string, params []any, out any) error {
+	call := &rpcCall{method: method, params: params, out: out}
+
+	if err := c.batch(ctx, call); err != nil {
+		return err
+	}
+
+	return call.err
+}
+
+// batch sends the calls in one round trip, as a single request when there is
+// only one. The error is for the round trip; each call's own error is set on
+// it.
+func (c *rpcClient) batch(ctx context.Context, calls ...*rpcCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	if c.offline() {
+		return ErrNoRPC
 	}
 
-	if params == nil {
-		params = []any{}
+	requests := make([]rpcRequest, len(calls))
+
+	for i, call := range calls {
+		params := call.params
+
+		if params == nil {
+			params = []any{}
+		}
+
+		requests[i] = rpcRequest{JSONRPC: "2.0", Method: call.method, Params: params, ID: c.nextID.Add(1)}
 	}
 
-	reqBody, err := json.Marshal(rpcRequest{
-		JSONRPC: "2.0",
-		Method:  method,
-		Params:  params,
-		ID:      1,
-	})
+	var body []byte
+	var err error
+
+	if len(requests) == 1 {
+		body, err = json.Marshal(requests[0])
+	} else {
+		body, err = json.Marshal(requests)
+	}
 
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(reqBody))
+	started := time.Now()
+	responseBody, maybeDelivered, err := c.send(ctx, calls[0].method, body)
+
+	for _, call := range calls {
+		call.maybeDelivered = maybeDelivered
+	}
+
+	if err == nil {
+		err = decodeResponses(responseBody, requests, calls)
+	}
+
+	c.record(calls, time.Since(started), err)
+
+	return err
+}
+
+// send posts the body, retrying transient failures on the next healthy
+// endpoint after a backoff, and returns the response body and whether a
+// failed attempt may have reached a node anyway.
+func (c *rpcClient) send(ctx context.Context, method string, body []byte) ([]byte, bool, error) {
+	backoff := c.backoff
+	maybeDelivered := false
+
+	for attempt := 0; ; attempt++ {
+		target := c.pick()
+		responseBody, retryAfter, err := c.post(ctx, target.url, body)
+
+		if err == nil {
+			c.succeeded(target)
+
+			return responseBody, maybeDelivered, nil
+		}
+
+		// A node that answered 429 turned the request away before looking
+		// at it; anything else may have been processed.
+		var status *httpError
+
+		if !errors.As(err, &status) || status.status >= http.StatusInternalServerError {
+			maybeDelivered = true
+		}
+
+		if retryAfter < 0 || ctx.Err() != nil {
+			return nil, maybeDelivered, fmt.Errorf("rpc %s: %w", method, err)
+		}
+
+		c.failed(target)
+
+		if attempt == c.retries {
+			return nil, maybeDelivered, fmt.Errorf("rpc %s: %w", method, err)
+		}
+
+		// A node cannot hold the call up for longer than the backoff allows;
+		// the next attempt goes to another endpoint while this one cools down.
+		wait := min(max(backoff, retryAfter), c.maxBackoff)
+		backoff = min(backoff*2, c.maxBackoff)
+
+		select {
+		case <-ctx.Done():
+			return nil, maybeDelivered, fmt.Errorf("rpc %s: %w", method, ctx.Err())
+
+		case <-time.After(wait):
+		}
+	}
+}
+
+// post sends the body to the URL. A failure worth retrying comes back with a
+// zero or positive retryAfter, the wait the node asked for; any other
+// failure with a negative one.
+func (c *rpcClient) post(ctx context.Context, url string, body []byte) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 
 	if err != nil {
-		return err
+		return nil, -1, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -71,28 +248,167 @@ func (c *rpcClient) call(ctx context.Context, method string, params []any, out i
 	resp, err := c.client.Do(req)
 
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("rpc %s: http %d", method, resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+
+		return nil, time.Duration(seconds) * time.Second, &httpError{status: resp.StatusCode}
+
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, 0, &httpError{status: resp.StatusCode}
+
+	case resp.StatusCode != http.StatusOK:
+		return nil, -1, &httpError{status: resp.StatusCode}
 	}
 
-	var res rpcResponse
+	responseBody, err := io.ReadAll(resp.Body)
 
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return err
+	if err != nil {
+		return nil, 0, err
 	}
 
-	if res.Error != nil {
-		return fmt.Errorf("rpc %s: (%d) %s", method, res.Error.Code, res.Error.Message)
+	return responseBody, 0, nil
+}
+
+// decodeResponses matches the responses to the calls by ID and decodes each
+// result or error into its call.
+func decodeResponses(body []byte, requests []rpcRequest, calls []*rpcCall) error {
+	var responses []rpcResponse
+
+	if len(requests) == 1 {
+		var response rpcResponse
+
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("rpc %s: %w", calls[0].method, err)
+		}
+
+		// The only response answers the only request, whatever its ID.
+		response.ID = requests[0].ID
+		responses = []rpcResponse{response}
+	} else if err := json.Unmarshal(body, &responses); err != nil {
+		return fmt.Errorf("rpc batch: %w", err)
 	}
 
-	if out == nil {
-		return nil
+	byID := make(map[uint64]rpcResponse, len(responses))
+
+	for _, response := range responses {
+		byID[response.ID] = response
+	}
+
+	for i, call := range calls {
+		response, ok := byID[requests[i].ID]
+
+		switch {
+		case !ok:
+			call.err = fmt.Errorf("rpc %s: no response", call.method)
+
+		case response.Error != nil:
+			call.err = fmt.Errorf("rpc %s: %w", call.method, response.Error)
+
+		case call.out != nil:
+			if err := json.Unmarshal(response.Result, call.out); err != nil {
+				call.err = fmt.Errorf("rpc %s: %w", call.method, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// pick returns the first endpoint that is not cooling down after a failure,
+// or the one that recovers soonest if they all are.
+func (c *rpcClient) pick() *endpoint {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	soonest := c.endpoints[0]
+
+	for _, candidate := range c.endpoints {
+		if !candidate.downUntil.After(now) {
+			return candidate
+		}
+
+		if candidate.downUntil.Before(soonest.downUntil) {
+			soonest = candidate
+		}
 	}
 
-	return json.Unmarshal(res.Result, out)
+	return soonest
+}
+
+func (c *rpcClient) succeeded(target *endpoint) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	target.failures = 0
+	target.downUntil = time.Time{}
+}
+
+func (c *rpcClient) failed(target *endpoint) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	target.failures++
+	target.downUntil = time.Now().Add(min(c.cooldown<<(target.failures-1), maxEndpointCooldown))
+}
+
+// record adds the round trip to each call's method. A call fails if the
+// round trip or its own response did.
+func (c *rpcClient) record(calls []*rpcCall, latency time.Duration, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, call := range calls {
+		metrics, ok := c.metrics[call.method]
+
+		if !ok {
+			metrics = &adapter.RPCMetrics{}
+			c.metrics[call.method] = metrics
+		}
+
+		metrics.Calls++
+		metrics.Latency += latency
+
+		if err != nil || call.err != nil {
+			metrics.Errors++
+		}
+	}
 }
+
+// RPCMetrics returns the metrics per JSON-RPC method so far.
+func (ad *Adapter) RPCMetrics() map[string]adapter.RPCMetrics {
+	ad.rpc.mutex.Lock()
+	defer ad.rpc.mutex.Unlock()
+
+	snapshot := make(map[string]adapter.RPCMetrics, len(ad.rpc.metrics))
+
+	for method, metrics := range ad.rpc.metrics {
+		snapshot[method] = *metrics
+	}
+
+	return snapshot
+}
+
+// RPCEndpoints reports the health of the network's RPC URLs, in failover
+// order.
+func (ad *Adapter) RPCEndpoints() []adapter.RPCEndpoint {
+	ad.rpc.mutex.Lock()
+	defer ad.rpc.mutex.Unlock()
+
+	now := time.Now()
+	endpoints := make([]adapter.RPCEndpoint, len(ad.rpc.endpoints))
+
+	for i, endpoint := range ad.rpc.endpoints {
+		endpoints[i] = adapter.RPCEndpoint{URL: endpoint.url, Failures: endpoint.failures, Healthy: !endpoint.downUntil.After(now)}
+	}
+
+	return endpoints
+}
+
+var _ adapter.RPCStatsAdapter = (*Adapter)(nil)
@@ -3,22 +3,29 @@ package ethereum
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
 )
 
 func TestCall_NoRPCEnv(t *testing.T) {
 	t.Parallel()
 
-	client := newRPC()
-	client.url = ""
+	client := newRPC("")
 
 	var result any
 	err := client.call(context.Background(), "web3_clientVersion", nil, &result)
 
 	if err == nil {
-		t.Fatal("expected error when ETH_RPC not set")
+		t.Fatal("expected error without an RPC URL")
 	}
 }
 
@@ -35,8 +42,7 @@ func TestCall_Success(t *testing.T) {
 
 	defer server.Close()
 
-	client := newRPC()
-	client.url = server.URL
+	client := newRPC(server.URL)
 
 	var result string
 
@@ -62,8 +68,7 @@ func TestCall_RPCError(t *testing.T) {
 
 	defer server.Close()
 
-	client := newRPC()
-	client.url = server.URL
+	client := newRPC(server.URL)
 
 	var result any
 
@@ -71,3 +76,334 @@ func TestCall_RPCError(t *testing.T) {
 		t.Fatal("expected rpc error")
 	}
 }
+
+// fastRPC is a client for the URLs that backs off for a millisecond, so
+// retries do not slow the tests down.
+func fastRPC(urls ...string) *rpcClient {
+	client := newRPC(urls...)
+	client.backoff = time.Millisecond
+	client.maxBackoff = time.Millisecond
+
+	return client
+}
+
+func TestBatchMatchesResponsesByID(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []rpcRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil || len(requests) != 3 {
+			http.Error(w, "expected a batch of three", http.StatusBadRequest)
+
+			return
+		}
+
+		// Answer out of order, fail the second call and leave out the third.
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"jsonrpc": "2.0", "id": requests[1].ID, "error": map[string]any{"code": -32000, "message": "boom"}},
+			{"jsonrpc": "2.0", "id": requests[0].ID, "result": "0x5"},
+		})
+	}))
+
+	defer server.Close()
+
+	var chainID, nonce, gas string
+	calls := []*rpcCall{
+		{method: "eth_chainId", out: &chainID},
+		{method: "eth_getTransactionCount", params: []any{"0x0", "pending"}, out: &nonce},
+		{method: "eth_estimateGas", params: []any{map[string]string{}}, out: &gas},
+	}
+
+	if err := newRPC(server.URL).batch(context.Background(), calls...); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls[0].err != nil || chainID != "0x5" || calls[1].err == nil || calls[2].err == nil {
+		t.Fatalf("got chainID=%q errors=%v, %v, %v", chainID, calls[0].err, calls[1].err, calls[2].err)
+	}
+}
+
+func TestCallRetriesTransientFailures(t *testing.T) {
+	t.Parallel()
+
+	statuses := map[string]int{"rate limited": http.StatusTooManyRequests, "unavailable": http.StatusServiceUnavailable}
+
+	for name, status := range statuses {
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(status)
+
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+		}))
+
+		var result string
+
+		if err := fastRPC(server.URL).call(context.Background(), "eth_chainId", nil, &result); err != nil || result != "0x1" || attempts.Load() != 3 {
+			t.Fatalf("%s: got result=%q attempts=%d err=%v", name, result, attempts.Load(), err)
+		}
+
+		server.Close()
+	}
+}
+
+func TestCallCapsRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var result string
+
+	if err := fastRPC(server.URL).call(ctx, "eth_chainId", nil, &result); err != nil || result != "0x1" || attempts.Load() != 3 {
+		t.Fatalf("got result=%q attempts=%d err=%v, want the hour-long Retry-After capped", result, attempts.Load(), err)
+	}
+}
+
+func TestCallGivesUp(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+
+		if r.URL.Path == "/forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	defer server.Close()
+
+	if err := fastRPC(server.URL).call(context.Background(), "eth_chainId", nil, nil); err == nil || attempts.Load() != rpcRetries+1 {
+		t.Fatalf("got attempts=%d err=%v, want %d attempts", attempts.Load(), err, rpcRetries+1)
+	}
+
+	attempts.Store(0)
+
+	// A client error is not transient and is not retried.
+	if err := fastRPC(server.URL+"/forbidden").call(context.Background(), "eth_chainId", nil, nil); err == nil || attempts.Load() != 1 {
+		t.Fatalf("got attempts=%d err=%v, want 1 attempt", attempts.Load(), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := fastRPC(server.URL).call(ctx, "eth_chainId", nil, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got err=%v, want context.Canceled", err)
+	}
+}
+
+func TestCallFailsOverToHealthyEndpoint(t *testing.T) {
+	t.Parallel()
+
+	var primaryHits, backupHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupHits.Add(1)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+
+	defer primary.Close()
+	defer backup.Close()
+
+	ad := NewAdapter(Mainnet, 20, nil)
+	ad.rpc = fastRPC(primary.URL, backup.URL)
+
+	for range 3 {
+		if _, err := ad.Balance(context.Background(), holder); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The primary failed once and is passed over while it cools down.
+	if primaryHits.Load() != 1 || backupHits.Load() != 3 {
+		t.Fatalf("got primary=%d backup=%d", primaryHits.Load(), backupHits.Load())
+	}
+
+	endpoints := ad.RPCEndpoints()
+
+	if len(endpoints) != 2 || endpoints[0].Healthy || endpoints[0].Failures != 1 || !endpoints[1].Healthy {
+		t.Fatalf("got %+v", endpoints)
+	}
+
+	metrics := ad.RPCMetrics()["eth_getBalance"]
+
+	if metrics.Calls != 3 || metrics.Errors != 0 || metrics.Latency <= 0 || metrics.MeanLatency() > metrics.Latency {
+		t.Fatalf("got %+v", metrics)
+	}
+}
+
+func TestBuildTxAsksInOneRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ad := feeHistoryServer(t)
+	var roundTrips atomic.Int32
+	inner := ad.rpc.endpoints[0].url
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		roundTrips.Add(1)
+		http.Redirect(w, r, inner, http.StatusTemporaryRedirect)
+	}))
+
+	defer server.Close()
+
+	ad.rpc = newRPC(server.URL)
+
+	if _, err := ad.BuildTx(context.Background(), holder, recipient, big.NewInt(1), adapter.FeeHint{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if roundTrips.Load() != 1 {
+		t.Fatalf("got %d round trips, want 1", roundTrips.Load())
+	}
+
+	metrics := ad.RPCMetrics()
+
+	for _, method := range []string{"eth_feeHistory", "eth_getTransactionCount", "eth_estimateGas"} {
+		if metrics[method].Calls != 1 {
+			t.Fatalf("%s: got %+v", method, metrics[method])
+		}
+	}
+}
+
+func TestBroadcastRetryAfterAmbiguousFailure(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		// first is the status of the first attempt, which reaches the node
+		// when delivered is set; rejection answers the retry.
+		first     int
+		delivered bool
+		rejection string
+		sent      bool
+		next      uint64
+	}{
+		"already known":       {http.StatusBadGateway, true, "already known", true, 8},
+		"nonce used by other": {http.StatusBadGateway, false, "nonce too low", false, 8},
+		"rate limited":        {http.StatusTooManyRequests, false, "insufficient funds for gas * price + value", false, 7},
+	}
+
+	type request struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+		ID     uint64            `json:"id"`
+	}
+
+	for name, testCase := range cases {
+		var known atomic.Value
+		var attempts atomic.Int32
+
+		known.Store("")
+		answer := func(method string, params []json.RawMessage) (any, string) {
+			switch method {
+			case "eth_getTransactionCount":
+				return "0x7", ""
+
+			case "eth_estimateGas":
+				return "0x5208", ""
+
+			case "eth_getTransactionByHash":
+				var hash string
+
+				_ = json.Unmarshal(params[0], &hash)
+
+				if hash == known.Load() {
+					return map[string]any{"hash": hash}, ""
+				}
+
+				return nil, ""
+			}
+
+			return nil, testCase.rejection
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body json.RawMessage
+
+			_ = json.NewDecoder(r.Body).Decode(&body)
+
+			var batch []request
+
+			if json.Unmarshal(body, &batch) != nil {
+				batch = make([]request, 1)
+				_ = json.Unmarshal(body, &batch[0])
+			}
+
+			if batch[0].Method == "eth_sendRawTransaction" && attempts.Add(1) == 1 {
+				w.WriteHeader(testCase.first)
+
+				return
+			}
+
+			responses := make([]map[string]any, len(batch))
+
+			for i, request := range batch {
+				result, message := answer(request.Method, request.Params)
+				responses[i] = map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": result}
+
+				if message != "" {
+					responses[i] = map[string]any{"jsonrpc": "2.0", "id": request.ID, "error": map[string]any{"code": -32000, "message": message}}
+				}
+			}
+
+			if json.Unmarshal(body, &[]request{}) != nil {
+				_ = json.NewEncoder(w).Encode(responses[0])
+
+				return
+			}
+
+			_ = json.NewEncoder(w).Encode(responses)
+		}))
+
+		ad := NewAdapter(testNet, 20, nil)
+		ad.rpc = fastRPC(server.URL)
+		priv, _, from, _ := ad.NewKey([]byte("retry"))
+		tx, err := ad.BuildTx(context.Background(), from, recipient, big.NewInt(1), adapter.FeeHint{MaxFeePerGas: 30 * gwei, MaxPriorityFee: gwei})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed, _ := ad.SignTx(priv, tx)
+
+		if testCase.delivered {
+			known.Store(signed.TxID)
+		}
+
+		txID, err := ad.Broadcast(context.Background(), signed)
+
+		if testCase.sent != (err == nil) || (testCase.sent && txID != signed.TxID) {
+			t.Fatalf("%s: got txid=%s err=%v, want sent=%t", name, txID, err, testCase.sent)
+		}
+
+		if next := ad.nonces.Next(common.HexToAddress(from)); next != testCase.next {
+			t.Fatalf("%s: got next nonce %d want %d", name, next, testCase.next)
+		}
+
+		server.Close()
+	}
+}
@@ -0,0 +1,94 @@
+package ethereum
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/afrodynamic/gochain/api/internal/platform/jsonfile"
+)
+
+// SentTxs are the transactions the adapter broadcast by hash, with the sender
+// and nonce of each, so one the node no longer has can be reported replaced
+// or dropped. They are persisted to a JSON file after every change so a
+// restart keeps them. Once a transaction is confirmed its nonce is final, and
+// every transaction of the sender up to that nonce is forgotten. An empty
+// path keeps them in memory.
+type SentTxs struct {
+	mutex sync.Mutex
+	path  string
+	txs   map[common.Hash]sentTx
+}
+
+// sentTx is the sender and nonce of a transaction the adapter broadcast.
+type sentTx struct {
+	From  common.Address `json:"from"`
+	Nonce uint64         `json:"nonce"`
+}
+
+func LoadSentTxs(path string) (*SentTxs, error) {
+	sent := &SentTxs{path: path, txs: make(map[common.Hash]sentTx)}
+
+	if err := jsonfile.Load(path, &sent.txs); err != nil {
+		return nil, err
+	}
+
+	return sent, nil
+}
+
+func (sent *SentTxs) add(hash common.Hash, tx sentTx) error {
+	sent.mutex.Lock()
+	defer sent.mutex.Unlock()
+
+	sent.txs[hash] = tx
+
+	return sent.saveLocked()
+}
+
+// lookup returns the transaction and the others broadcast with the same
+// sender and nonce, such as its speed-ups.
+func (sent *SentTxs) lookup(hash common.Hash) (sentTx, []common.Hash, bool) {
+	sent.mutex.Lock()
+	defer sent.mutex.Unlock()
+
+	tx, ok := sent.txs[hash]
+
+	if !ok {
+		return sentTx{}, nil, false
+	}
+
+	var rivals []common.Hash
+
+	for other, otherTx := range sent.txs {
+		if other != hash && otherTx == tx {
+			rivals = append(rivals, other)
+		}
+	}
+
+	return tx, rivals, true
+}
+
+// settle forgets the transaction, if the adapter broadcast it, along with
+// every other one of its sender with the same nonce or a lower one.
+func (sent *SentTxs) settle(hash common.Hash) error {
+	sent.mutex.Lock()
+	defer sent.mutex.Unlock()
+
+	settled, ok := sent.txs[hash]
+
+	if !ok {
+		return nil
+	}
+
+	for other, tx := range sent.txs {
+		if tx.From == settled.From && tx.Nonce <= settled.Nonce {
+			delete(sent.txs, other)
+		}
+	}
+
+	return sent.saveLocked()
+}
+
+func (sent *SentTxs) saveLocked() error {
+	return jsonfile.Save(sent.path, sent.txs)
+}
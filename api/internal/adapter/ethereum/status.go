@@ -0,0 +1,253 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// confirmationDepth is how many blocks deep a transaction must be for
+// TxStatus to report it confirmed rather than included.
+const confirmationDepth = 12
+
+// receipt is the part of an eth_getTransactionReceipt result TxStatus reads.
+type receipt struct {
+	BlockNumber string `json:"blockNumber"`
+	BlockHash   string `json:"blockHash"`
+	Status      string `json:"status"`
+	GasUsed     string `json:"gasUsed"`
+}
+
+// rpcTransaction is the part of an eth_getTransactionByHash result TxStatus
+// and the replacements read. BlockNumber is nil while the transaction is
+// pending, and a legacy transaction has a GasPrice instead of EIP-1559 fees.
+type rpcTransaction struct {
+	From                 string  `json:"from"`
+	To                   *string `json:"to"`
+	Input                string  `json:"input"`
+	Value                string  `json:"value"`
+	Gas                  string  `json:"gas"`
+	Nonce                string  `json:"nonce"`
+	GasPrice             string  `json:"gasPrice"`
+	MaxFeePerGas         string  `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string  `json:"maxPriorityFeePerGas"`
+	BlockNumber          *string `json:"blockNumber"`
+}
+
+// decodeSignedTx decodes a raw signed transaction and recovers its sender.
+func decodeSignedTx(rawHex string) (*types.Transaction, common.Address, error) {
+	raw, err := hexutil.Decode(rawHex)
+
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+
+	var tx types.Transaction
+
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, common.Address{}, err
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), &tx)
+
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+
+	return &tx, from, nil
+}
+
+// remember records the sender and nonce of a broadcast transaction. The
+// transaction is out whether or not the record is saved, so a failure to
+// save is only logged.
+func (ad *Adapter) remember(tx *types.Transaction, from common.Address) {
+	if err := ad.sent.add(tx.Hash(), sentTx{From: from, Nonce: tx.Nonce()}); err != nil {
+		log.Printf("%s: cannot record broadcast transaction %s: %v", ad.network.Name, tx.Hash().Hex(), err)
+	}
+}
+
+// TxStatus asks the node for the transaction's receipt, the transaction and
+// the head block in one batch. A receipt means it was included, and failed if
+// it reverted; a transaction without one is pending. One the node does not
+// know is unknown, unless the adapter broadcast it: then it was replaced if
+// its nonce has since been used, and dropped if not. Once a transaction is
+// confirmed, the adapter forgets the ones it broadcast from the sender up to
+// its nonce, whose fate is settled.
+func (ad *Adapter) TxStatus(ctx context.Context, txID string) (adapter.TxStatus, error) {
+	hash, err := hexutil.Decode(txID)
+
+	if err != nil || len(hash) != common.HashLength {
+		return adapter.TxStatus{}, fmt.Errorf("invalid transaction hash %q", txID)
+	}
+
+	if ad.rpc.offline() {
+		return adapter.TxStatus{Status: adapter.StatusUnknown}, nil
+	}
+
+	var rcpt *receipt
+	var tx *rpcTransaction
+	var headHex string
+	calls := []*rpcCall{
+		{method: "eth_getTransactionReceipt", params: []any{txID}, out: &rcpt},
+		{method: "eth_getTransactionByHash", params: []any{txID}, out: &tx},
+		{method: "eth_blockNumber", out: &headHex},
+	}
+
+	if err := ad.rpc.batch(ctx, calls...); err != nil {
+		return adapter.TxStatus{}, err
+	}
+
+	for _, call := range calls {
+		if call.err != nil {
+			return adapter.TxStatus{}, call.err
+		}
+	}
+
+	switch {
+	case rcpt != nil && rcpt.BlockNumber != "":
+		status, err := ad.includedStatus(ctx, *rcpt, tx, headHex)
+
+		if err == nil && status.Confirmations >= confirmationDepth {
+			if err := ad.sent.settle(common.BytesToHash(hash)); err != nil {
+				log.Printf("%s: cannot forget settled transactions: %v", ad.network.Name, err)
+			}
+		}
+
+		return status, err
+
+	case tx != nil:
+		return adapter.TxStatus{Status: adapter.StatusPending}, nil
+	}
+
+	return ad.missingStatus(ctx, common.BytesToHash(hash))
+}
+
+func (ad *Adapter) includedStatus(ctx context.Context, rcpt receipt, tx *rpcTransaction, headHex string) (adapter.TxStatus, error) {
+	height, err := parseUint64Quantity(rcpt.BlockNumber)
+
+	if err != nil {
+		return adapter.TxStatus{}, fmt.Errorf("eth_getTransactionReceipt: %w", err)
+	}
+
+	head, err := parseUint64Quantity(headHex)
+
+	if err != nil {
+		return adapter.TxStatus{}, fmt.Errorf("eth_blockNumber: %w", err)
+	}
+
+	// A node behind the one that served the receipt can report an older head.
+	status := adapter.TxStatus{Confirmations: 1, BlockHeight: height, BlockHash: rcpt.BlockHash}
+
+	if head >= height {
+		status.Confirmations = head - height + 1
+	}
+
+	switch {
+	case rcpt.Status == "0x0":
+		status.Status = adapter.StatusFailed
+		status.Reason = ad.revertReason(ctx, rcpt, tx)
+
+	case status.Confirmations >= confirmationDepth:
+		status.Status = adapter.StatusConfirmed
+
+	default:
+		status.Status = adapter.StatusIncluded
+	}
+
+	return status, nil
+}
+
+// revertReason replays the transaction with eth_call at its block, where the
+// node reports why it reverts, e.g. "execution reverted: insufficient
+// balance". A transaction that used all its gas without a reason ran out of
+// it.
+func (ad *Adapter) revertReason(ctx context.Context, rcpt receipt, tx *rpcTransaction) string {
+	if tx == nil {
+		return "reverted"
+	}
+
+	call := map[string]string{"from": tx.From, "input": tx.Input, "value": tx.Value, "gas": tx.Gas}
+
+	if tx.To != nil {
+		call["to"] = *tx.To
+	}
+
+	var replayErr *rpcError
+
+	if err := ad.rpc.call(ctx, "eth_call", []any{call, rcpt.BlockNumber}, nil); errors.As(err, &replayErr) {
+		return replayErr.Message
+	}
+
+	if rcpt.GasUsed != "" && rcpt.GasUsed == tx.Gas {
+		return "out of gas"
+	}
+
+	return "reverted"
+}
+
+// missingStatus is the status of a transaction the node does not know. One
+// the adapter broadcast was replaced if its nonce has since been used, or if
+// the node has another the adapter broadcast with the same nonce, such as a
+// speed-up; otherwise it was dropped.
+func (ad *Adapter) missingStatus(ctx context.Context, hash common.Hash) (adapter.TxStatus, error) {
+	sent, rivals, ok := ad.sent.lookup(hash)
+
+	if !ok {
+		return adapter.TxStatus{Status: adapter.StatusUnknown}, nil
+	}
+
+	var nonceHex string
+	calls := []*rpcCall{{method: "eth_getTransactionCount", params: []any{sent.From.Hex(), "latest"}, out: &nonceHex}}
+	receipts := make([]*receipt, len(rivals))
+	transactions := make([]*rpcTransaction, len(rivals))
+
+	for i, rival := range rivals {
+		calls = append(calls,
+			&rpcCall{method: "eth_getTransactionReceipt", params: []any{rival.Hex()}, out: &receipts[i]},
+			&rpcCall{method: "eth_getTransactionByHash", params: []any{rival.Hex()}, out: &transactions[i]},
+		)
+	}
+
+	if err := ad.rpc.batch(ctx, calls...); err != nil {
+		return adapter.TxStatus{}, err
+	}
+
+	if calls[0].err != nil {
+		return adapter.TxStatus{}, calls[0].err
+	}
+
+	mined, err := parseUint64Quantity(nonceHex)
+
+	if err != nil {
+		return adapter.TxStatus{}, fmt.Errorf("eth_getTransactionCount: %w", err)
+	}
+
+	// A rival that was mined replaced it for good; failing that, one still
+	// pending has taken its place in the mempool.
+	replacedBy := ""
+
+	for i, rival := range rivals {
+		receiptCall, txCall := calls[1+2*i], calls[2+2*i]
+
+		switch {
+		case receiptCall.err == nil && receipts[i] != nil && receipts[i].BlockNumber != "":
+			return adapter.TxStatus{Status: adapter.StatusReplaced, ReplacedBy: rival.Hex()}, nil
+
+		case txCall.err == nil && transactions[i] != nil:
+			replacedBy = rival.Hex()
+		}
+	}
+
+	if mined > sent.Nonce || replacedBy != "" {
+		return adapter.TxStatus{Status: adapter.StatusReplaced, ReplacedBy: replacedBy}, nil
+	}
+
+	return adapter.TxStatus{Status: adapter.StatusDropped, Reason: "no longer in the node's mempool"}, nil
+}
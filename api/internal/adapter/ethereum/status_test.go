@@ -0,0 +1,195 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+func hashParam(params []json.RawMessage) string {
+	var hash string
+
+	_ = json.Unmarshal(params[0], &hash)
+
+	return strings.ToLower(hash)
+}
+
+func TestTxStatus(t *testing.T) {
+	t.Parallel()
+
+	hash := func(fill string) string { return "0x" + strings.Repeat(fill, 64) }
+	receipts := map[string]map[string]string{
+		hash("1"): {"blockNumber": "0x6e", "blockHash": hash("b"), "status": "0x1", "gasUsed": "0x5208"},
+		hash("2"): {"blockNumber": "0x64", "blockHash": hash("b"), "status": "0x1", "gasUsed": "0x5208"},
+		hash("3"): {"blockNumber": "0x6e", "blockHash": hash("b"), "status": "0x0", "gasUsed": "0x9c40"},
+		hash("4"): {"blockNumber": "0x6e", "blockHash": hash("b"), "status": "0x0", "gasUsed": "0x7530"},
+	}
+	transactions := map[string]map[string]any{
+		hash("3"): {"from": holder, "to": recipient, "input": "0xa9059cbb", "value": "0x0", "gas": "0x9c40", "blockNumber": "0x6e"},
+		hash("4"): {"from": holder, "to": recipient, "input": "0x", "value": "0x0", "gas": "0x7530", "blockNumber": "0x6e"},
+		hash("5"): {"from": holder, "to": recipient, "input": "0x", "value": "0x1", "gas": "0x5208", "blockNumber": nil},
+	}
+
+	ad := rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		switch method {
+		case "eth_getTransactionReceipt":
+			if receipt, ok := receipts[hashParam(params)]; ok {
+				return receipt, nil
+			}
+
+			return nil, nil
+
+		case "eth_getTransactionByHash":
+			if tx, ok := transactions[hashParam(params)]; ok {
+				return tx, nil
+			}
+
+			return nil, nil
+
+		case "eth_blockNumber":
+			return "0x6f", nil
+
+		case "eth_call":
+			var call map[string]string
+
+			_ = json.Unmarshal(params[0], &call)
+
+			if call["input"] == "0xa9059cbb" {
+				return nil, errors.New("execution reverted: ERC20: transfer amount exceeds balance")
+			}
+
+			return "0x", nil
+		}
+
+		return nil, errors.New("unexpected " + method)
+	})
+
+	cases := map[string]adapter.TxStatus{
+		hash("1"): {Status: adapter.StatusIncluded, Confirmations: 2, BlockHeight: 110, BlockHash: hash("b")},
+		hash("2"): {Status: adapter.StatusConfirmed, Confirmations: 12, BlockHeight: 100, BlockHash: hash("b")},
+		hash("3"): {Status: adapter.StatusFailed, Confirmations: 2, BlockHeight: 110, BlockHash: hash("b"), Reason: "execution reverted: ERC20: transfer amount exceeds balance"},
+		hash("4"): {Status: adapter.StatusFailed, Confirmations: 2, BlockHeight: 110, BlockHash: hash("b"), Reason: "out of gas"},
+		hash("5"): {Status: adapter.StatusPending},
+		hash("6"): {Status: adapter.StatusUnknown},
+	}
+
+	for id, want := range cases {
+		if got, err := ad.TxStatus(context.Background(), id); err != nil || got != want {
+			t.Fatalf("%s: got=%+v err=%v want=%+v", id, got, err, want)
+		}
+	}
+
+	if _, err := ad.TxStatus(context.Background(), "0x1234"); err == nil {
+		t.Fatal("expected a short hash to be rejected")
+	}
+
+	if got, err := NewAdapter(testNet, 20, nil).TxStatus(context.Background(), hash("1")); err != nil || got.Status != adapter.StatusUnknown {
+		t.Fatalf("offline: got=%+v err=%v, want unknown", got, err)
+	}
+}
+
+func TestTxStatusAfterBroadcast(t *testing.T) {
+	t.Parallel()
+
+	// The node forgets everything it is sent; what it reports is the sender's
+	// latest nonce and, once mined, the receipt of the transaction in mined.
+	latestNonce := "0x7"
+	mined := ""
+	head := "0x6f"
+	ad := rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		switch method {
+		case "eth_sendRawTransaction":
+			return "0x", nil
+
+		case "eth_estimateGas":
+			return "0x5208", nil
+
+		case "eth_getTransactionReceipt":
+			if mined != "" && hashParam(params) == mined {
+				return map[string]string{"blockNumber": "0x6f", "blockHash": "0x" + strings.Repeat("b", 64), "status": "0x1", "gasUsed": "0x5208"}, nil
+			}
+
+			return nil, nil
+
+		case "eth_getTransactionByHash":
+			return nil, nil
+
+		case "eth_blockNumber":
+			return head, nil
+
+		case "eth_getTransactionCount":
+			return latestNonce, nil
+		}
+
+		return nil, errors.New("unexpected " + method)
+	})
+
+	path := filepath.Join(t.TempDir(), "sent.json")
+	sent, _ := LoadSentTxs(path)
+	ad.SetSentTxs(sent)
+	priv, _, from, _ := ad.NewKey([]byte("status"))
+	nonce := uint64(7)
+	sign := func(maxFee uint64) adapter.SignedTx {
+		tx, err := ad.BuildTxWithData(context.Background(), from, recipient, big.NewInt(1), nil, adapter.FeeHint{MaxFeePerGas: maxFee, MaxPriorityFee: gwei}, adapter.TxParams{ChainID: testNet.ChainID, Nonce: &nonce})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed, err := ad.SignTx(priv, tx)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ad.Broadcast(context.Background(), signed); err != nil {
+			t.Fatal(err)
+		}
+
+		return signed
+	}
+
+	original := sign(20 * gwei)
+	bumped := sign(30 * gwei)
+
+	if got, err := ad.TxStatus(context.Background(), original.TxID); err != nil || got.Status != adapter.StatusDropped {
+		t.Fatalf("got=%+v err=%v, want dropped while the nonce is unused", got, err)
+	}
+
+	latestNonce = "0x8"
+	mined = strings.ToLower(bumped.TxID)
+
+	// The broadcasts are remembered across a restart.
+	sent, err := LoadSentTxs(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ad.SetSentTxs(sent)
+
+	if got, err := ad.TxStatus(context.Background(), original.TxID); err != nil || got.Status != adapter.StatusReplaced || !strings.EqualFold(got.ReplacedBy, bumped.TxID) {
+		t.Fatalf("got=%+v err=%v, want replaced by %s", got, err, bumped.TxID)
+	}
+
+	if got, err := ad.TxStatus(context.Background(), bumped.TxID); err != nil || got.Status != adapter.StatusIncluded {
+		t.Fatalf("got=%+v err=%v, want the replacement included", got, err)
+	}
+
+	// Once the replacement is confirmed both are forgotten.
+	head = "0x7a"
+
+	if got, err := ad.TxStatus(context.Background(), bumped.TxID); err != nil || got.Status != adapter.StatusConfirmed {
+		t.Fatalf("got=%+v err=%v, want the replacement confirmed", got, err)
+	}
+
+	if sent, err = LoadSentTxs(path); err != nil || len(sent.txs) != 0 {
+		t.Fatalf("got %d sent transactions err=%v, want none", len(sent.txs), err)
+	}
+}
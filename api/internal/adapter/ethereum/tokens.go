@@ -0,0 +1,174 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+// MainnetTokens are the ERC-20 tokens known out of the box.
+var MainnetTokens = []adapter.Token{
+	{Symbol: "USDC", Contract: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", Decimals: 6},
+	{Symbol: "USDT", Contract: "0xdAC17F958D2ee523a2206206994597C13D831ec7", Decimals: 6},
+	{Symbol: "DAI", Contract: "0x6B175474E89094C44Da98b954EedeAC495271d0F", Decimals: 18},
+	{Symbol: "WETH", Contract: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Decimals: 18},
+}
+
+var ErrUnknownToken = errors.New("unknown token")
+
+// ERC-20 function selectors, the first four bytes of the keccak256 hash of
+// each signature.
+var (
+	balanceOfSelector = []byte{0x70, 0xa0, 0x82, 0x31}
+	transferSelector  = []byte{0xa9, 0x05, 0x9c, 0xbb}
+)
+
+// ParseToken reads a token as SYMBOL:CONTRACT:DECIMALS, e.g.
+// USDC:0xA0b8...eB48:6.
+func ParseToken(spec string) (adapter.Token, error) {
+	parts := strings.Split(spec, ":")
+
+	if len(parts) != 3 {
+		return adapter.Token{}, fmt.Errorf("token %q: expected SYMBOL:CONTRACT:DECIMALS", spec)
+	}
+
+	if parts[0] == "" {
+		return adapter.Token{}, fmt.Errorf("token %q: empty symbol", spec)
+	}
+
+	if !common.IsHexAddress(parts[1]) {
+		return adapter.Token{}, fmt.Errorf("token %q: invalid contract address", spec)
+	}
+
+	decimals, err := strconv.ParseUint(parts[2], 10, 8)
+
+	if err != nil {
+		return adapter.Token{}, fmt.Errorf("token %q: invalid decimals", spec)
+	}
+
+	return adapter.Token{
+		Symbol:   strings.ToUpper(parts[0]),
+		Contract: common.HexToAddress(parts[1]).Hex(),
+		Decimals: uint32(decimals),
+	}, nil
+}
+
+// registerTokens keys the tokens by symbol. A later token replaces an
+// earlier one with the same symbol.
+func (ad *Adapter) registerTokens(tokens []adapter.Token) {
+	ad.tokens = make(map[string]adapter.Token)
+
+	for _, token := range tokens {
+		ad.tokens[strings.ToUpper(token.Symbol)] = token
+	}
+}
+
+// Tokens lists the registered tokens by symbol.
+func (ad *Adapter) Tokens() []adapter.Token {
+	tokens := make([]adapter.Token, 0, len(ad.tokens))
+
+	for _, token := range ad.tokens {
+		tokens = append(tokens, token)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Symbol < tokens[j].Symbol })
+
+	return tokens
+}
+
+// Token looks a registered token up by symbol or contract address.
+func (ad *Adapter) Token(symbolOrContract string) (adapter.Token, error) {
+	if token, ok := ad.tokens[strings.ToUpper(symbolOrContract)]; ok {
+		return token, nil
+	}
+
+	for _, token := range ad.tokens {
+		if strings.EqualFold(token.Contract, symbolOrContract) {
+			return token, nil
+		}
+	}
+
+	return adapter.Token{}, fmt.Errorf("%w %q", ErrUnknownToken, symbolOrContract)
+}
+
+func encodeAddress(address string) ([]byte, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid ethereum address %q", address)
+	}
+
+	return common.LeftPadBytes(common.HexToAddress(address).Bytes(), 32), nil
+}
+
+// TokenBalance calls balanceOf on the token's contract for the owner, in the
+// token's smallest unit.
+func (ad *Adapter) TokenBalance(ctx context.Context, token adapter.Token, owner string) (*big.Int, error) {
+	encodedOwner, err := encodeAddress(owner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ad.rpc.offline() {
+		return new(big.Int), nil
+	}
+
+	call := map[string]string{
+		"to":    token.Contract,
+		"input": hexutil.Encode(append(append([]byte{}, balanceOfSelector...), encodedOwner...)),
+	}
+
+	var resultHex string
+
+	if err := ad.rpc.call(ctx, "eth_call", []any{call, "latest"}, &resultHex); err != nil {
+		return nil, err
+	}
+
+	result, err := hexutil.Decode(resultHex)
+
+	if err != nil || len(result) != 32 {
+		return nil, fmt.Errorf("%s balanceOf: unexpected result %q", token.Symbol, resultHex)
+	}
+
+	return new(big.Int).SetBytes(result), nil
+}
+
+// BuildTokenTx builds a call to the token's transfer(to, amount), sending no
+// ether. The transaction is addressed to the contract; the recipient and the
+// amount in the token's smallest unit are in its call data. The recipient may
+// be a name, and must not be the zero address.
+func (ad *Adapter) BuildTokenTx(ctx context.Context, token adapter.Token, sender, recipient string, amount *big.Int, feeHint adapter.FeeHint, params adapter.TxParams) (adapter.Tx, error) {
+	recipient, err := ad.recipientAddress(ctx, recipient)
+
+	if err != nil {
+		return adapter.Tx{}, fmt.Errorf("recipient: %w", err)
+	}
+
+	encodedRecipient, err := encodeAddress(recipient)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	amount, err = uint256Amount(amount)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	data := append([]byte{}, transferSelector...)
+	data = append(data, encodedRecipient...)
+	data = append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+
+	return ad.BuildTxWithData(ctx, sender, token.Contract, new(big.Int), data, feeHint, params)
+}
+
+var _ adapter.TokenAdapter = (*Adapter)(nil)
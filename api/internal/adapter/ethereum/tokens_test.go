@@ -0,0 +1,142 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+)
+
+const (
+	holder    = "0x00000000000000000000000000000000000000bb"
+	recipient = "0x00000000000000000000000000000000000000cc"
+)
+
+// tokenServer answers balanceOf for holder with 1,500,000 and estimates
+// 50,000 gas for any call.
+func tokenServer(t *testing.T) *Adapter {
+	t.Helper()
+
+	ad := rpcServer(t, func(method string, params []json.RawMessage) (any, error) {
+		var call map[string]string
+
+		if len(params) > 0 {
+			_ = json.Unmarshal(params[0], &call)
+		}
+
+		switch method {
+		case "eth_call":
+			if !strings.EqualFold(call["to"], MainnetTokens[0].Contract) {
+				return nil, errors.New("no contract at " + call["to"])
+			}
+
+			if call["input"] != "0x70a08231"+strings.Repeat("0", 62)+"bb" {
+				return nil, errors.New("unexpected call data " + call["input"])
+			}
+
+			return "0x" + strings.Repeat("0", 58) + "16e360", nil
+
+		case "eth_estimateGas":
+			return "0xc350", nil
+
+		case "eth_getTransactionCount":
+			return "0x0", nil
+		}
+
+		return nil, errors.New("unexpected method " + method)
+	})
+
+	ad.registerTokens(MainnetTokens)
+
+	return ad
+}
+
+func TestParseToken(t *testing.T) {
+	t.Parallel()
+
+	token, err := ParseToken("usdc:0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48:6")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != MainnetTokens[0] {
+		t.Fatalf("got=%+v want=%+v", token, MainnetTokens[0])
+	}
+
+	for _, spec := range []string{"USDC", "USDC:0x1234:6", ":" + holder + ":6", "USDC:" + holder + ":-1", "USDC:" + holder + ":256"} {
+		if _, err := ParseToken(spec); err == nil {
+			t.Fatalf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestTokenRegistry(t *testing.T) {
+	t.Parallel()
+
+	override := adapter.Token{Symbol: "USDC", Contract: holder, Decimals: 6}
+	ad := NewAdapter(Mainnet, 20, append(MainnetTokens, override))
+
+	for _, key := range []string{"usdc", "USDC", strings.ToUpper(holder)} {
+		if token, err := ad.Token(key); err != nil || token != override {
+			t.Fatalf("%s: got=%+v err=%v", key, token, err)
+		}
+	}
+
+	if _, err := ad.Token(MainnetTokens[0].Contract); !errors.Is(err, ErrUnknownToken) {
+		t.Fatalf("replaced contract: got err=%v, want ErrUnknownToken", err)
+	}
+
+	if tokens := ad.Tokens(); len(tokens) != len(MainnetTokens) || tokens[0].Symbol != "DAI" {
+		t.Fatalf("got %+v", tokens)
+	}
+}
+
+func TestTokenBalance(t *testing.T) {
+	t.Parallel()
+
+	ad := tokenServer(t)
+	usdc, _ := ad.Token("USDC")
+
+	if balance, err := ad.TokenBalance(context.Background(), usdc, holder); err != nil || balance.Uint64() != 1_500_000 {
+		t.Fatalf("got=%d err=%v want=1500000", balance, err)
+	}
+
+	dai, _ := ad.Token("DAI")
+
+	if _, err := ad.TokenBalance(context.Background(), dai, holder); err == nil {
+		t.Fatal("expected the eth_call error")
+	}
+
+	if _, err := ad.TokenBalance(context.Background(), usdc, "0xbb"); err == nil {
+		t.Fatal("expected an invalid owner to be rejected")
+	}
+}
+
+func TestBuildTokenTxEncodesTransfer(t *testing.T) {
+	t.Parallel()
+
+	ad := tokenServer(t)
+	usdc, _ := ad.Token("USDC")
+	tx, err := ad.BuildTokenTx(context.Background(), usdc, holder, recipient, big.NewInt(2_500_000), adapter.FeeHint{MaxFeePerGas: 30 * gwei, MaxPriorityFee: gwei}, adapter.TxParams{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "0xa9059cbb" + strings.Repeat("0", 62) + "cc" + strings.Repeat("0", 58) + "2625a0"
+
+	if hexutil.Encode(tx.Data) != want {
+		t.Fatalf("got data=%x want=%s", tx.Data, want)
+	}
+
+	if tx.To != usdc.Contract || tx.Amount.Sign() != 0 || tx.GasLimit != 60_000 {
+		t.Fatalf("got to=%s amount=%d gas=%d", tx.To, tx.Amount, tx.GasLimit)
+	}
+}
@@ -1,28 +1,47 @@
 package gochain
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
+	"sync"
 
 	"github.com/afrodynamic/gochain/api/internal/adapter"
 	"github.com/afrodynamic/gochain/api/internal/core"
 )
 
+// confirmationDepth is how deep a block must be for its transactions to be
+// confirmed. Blocks are produced as transactions arrive rather than on a
+// schedule, so a deeper requirement could wait indefinitely.
+const confirmationDepth = 1
+
+// Adapter submits to the local chain. It remembers the transactions it
+// submitted, so one that leaves the mempool unmined is reported dropped.
 type Adapter struct {
 	chain core.Blockchain
+
+	mutex     sync.Mutex
+	submitted map[string]bool
 }
 
 func NewAdapter(chain core.Blockchain) *Adapter {
-	return &Adapter{chain: chain}
+	return &Adapter{chain: chain, submitted: make(map[string]bool)}
 }
 
 func (ad *Adapter) Network() string {
 	return "gochain"
 }
 
+// Decimals is zero: gochain amounts are whole coins.
+func (ad *Adapter) Decimals() uint32 {
+	return 0
+}
+
 func (ad *Adapter) NewKey(seed []byte) (privateKey, publicKey, address string, err error) {
 	if len(seed) == 0 {
 		seed = GenerateRandomSeed()
@@ -49,21 +68,31 @@ func (ad *Adapter) ParseAddress(address string) (string, error) {
 	return address, nil
 }
 
-func (ad *Adapter) Balance(ctx context.Context, address string) (uint64, error) {
+func (ad *Adapter) Balance(ctx context.Context, address string) (*big.Int, error) {
 	decoded, err := decodeAddress(address)
 
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return ad.chain.GetBalance(decoded)
+	balance, err := ad.chain.GetBalance(decoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetUint64(balance), nil
 }
 
-func (ad *Adapter) BuildTx(ctx context.Context, sender, recipient string, amount uint64, feeHint adapter.FeeHint) (adapter.Tx, error) {
+func (ad *Adapter) BuildTx(ctx context.Context, sender, recipient string, amount *big.Int, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	if _, err := adapter.Uint64Amount(amount); err != nil {
+		return adapter.Tx{}, err
+	}
+
 	fee := feeHint.MaxFeePerGas
 
 	if fee == 0 {
-		fee = 1
+		fee = max(1, ad.chain.NodeInfo().Rules.Rules.MinFee)
 	}
 
 	senderBytes, err := decodeAddress(sender)
@@ -103,10 +132,16 @@ func (ad *Adapter) Broadcast(ctx context.Context, signedTx adapter.SignedTx) (st
 		return "", err
 	}
 
+	amount, err := adapter.Uint64Amount(tx.Amount)
+
+	if err != nil {
+		return "", err
+	}
+
 	submitted, err := ad.chain.SubmitTx(core.Tx{
 		From:   fromBytes,
 		To:     toBytes,
-		Amount: tx.Amount,
+		Amount: amount,
 		Fee:    tx.Fee,
 		Data:   tx.Data,
 	})
@@ -115,11 +150,60 @@ func (ad *Adapter) Broadcast(ctx context.Context, signedTx adapter.SignedTx) (st
 		return "", err
 	}
 
-	return hex.EncodeToString(submitted.Hash), nil
+	txID := hex.EncodeToString(submitted.Hash)
+
+	ad.mutex.Lock()
+	ad.submitted[txID] = true
+	ad.mutex.Unlock()
+
+	return txID, nil
 }
 
-func (ad *Adapter) TxStatus(ctx context.Context, txID string) (adapter.Status, error) {
-	return adapter.StatusMined, nil
+// TxStatus looks the transaction up in the chain and then the mempool.
+func (ad *Adapter) TxStatus(ctx context.Context, txID string) (adapter.TxStatus, error) {
+	hash, err := hex.DecodeString(txID)
+
+	if err != nil {
+		return adapter.TxStatus{}, fmt.Errorf("invalid transaction id: %w", err)
+	}
+
+	tx, _, err := ad.chain.TransactionProof(hash)
+
+	switch {
+	case err == nil:
+		confirmations := ad.chain.NodeInfo().Height - tx.BlockHeight + 1
+		status := adapter.StatusIncluded
+
+		if confirmations >= confirmationDepth {
+			status = adapter.StatusConfirmed
+		}
+
+		return adapter.TxStatus{
+			Status:        status,
+			Confirmations: confirmations,
+			BlockHeight:   tx.BlockHeight,
+			BlockHash:     hex.EncodeToString(tx.BlockHash),
+		}, nil
+
+	case !errors.Is(err, core.ErrUnknownTransaction):
+		return adapter.TxStatus{}, err
+	}
+
+	for _, pending := range ad.chain.PendingTransactions() {
+		if bytes.Equal(pending.Hash, hash) {
+			return adapter.TxStatus{Status: adapter.StatusPending}, nil
+		}
+	}
+
+	ad.mutex.Lock()
+	submitted := ad.submitted[hex.EncodeToString(hash)]
+	ad.mutex.Unlock()
+
+	if submitted {
+		return adapter.TxStatus{Status: adapter.StatusDropped, Reason: "no longer valid against the chain state"}, nil
+	}
+
+	return adapter.TxStatus{Status: adapter.StatusUnknown}, nil
 }
 
 var _ adapter.ChainAdapter = (*Adapter)(nil)
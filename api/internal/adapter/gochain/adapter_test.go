@@ -0,0 +1,156 @@
+package gochain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+// fakeChain is a chain at a fixed height whose mined and pending
+// transactions the test sets. Submitted transactions join the mempool.
+type fakeChain struct {
+	core.Blockchain
+	height    uint64
+	mined     []core.Transaction
+	pending   []core.Transaction
+	submitted []core.Tx
+	broken    error
+}
+
+func (chain *fakeChain) NodeInfo() core.NodeInfo {
+	return core.NodeInfo{Height: chain.height}
+}
+
+func (chain *fakeChain) CurrentNonce(address []byte) uint64 {
+	return 0
+}
+
+func (chain *fakeChain) SubmitTx(tx core.Tx) (core.Transaction, error) {
+	chain.submitted = append(chain.submitted, tx)
+	transaction := core.Transaction{Hash: []byte{byte(len(chain.submitted))}, From: tx.From, To: tx.To, Amount: tx.Amount, Fee: tx.Fee}
+	chain.pending = append(chain.pending, transaction)
+
+	return transaction, nil
+}
+
+func (chain *fakeChain) TransactionProof(hash []byte) (core.Transaction, core.MerkleProof, error) {
+	if chain.broken != nil {
+		return core.Transaction{}, core.MerkleProof{}, chain.broken
+	}
+
+	for _, tx := range chain.mined {
+		if bytes.Equal(tx.Hash, hash) {
+			return tx, core.MerkleProof{}, nil
+		}
+	}
+
+	return core.Transaction{}, core.MerkleProof{}, core.ErrUnknownTransaction
+}
+
+func (chain *fakeChain) PendingTransactions() []core.Transaction {
+	return chain.pending
+}
+
+func TestTxStatus(t *testing.T) {
+	t.Parallel()
+
+	chain := &fakeChain{
+		height: 5,
+		mined:  []core.Transaction{{Hash: []byte{0xaa}, BlockHeight: 3, BlockHash: []byte{0xbb}}},
+	}
+	ad := NewAdapter(chain)
+	_, _, from := NewKey([]byte("from"))
+	_, _, to := NewKey([]byte("to"))
+	send := func() string {
+		tx, err := ad.BuildTx(context.Background(), from, to, big.NewInt(1), adapter.FeeHint{MaxFeePerGas: 1})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed, _ := ad.SignTx("", tx)
+		txID, err := ad.Broadcast(context.Background(), signed)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return txID
+	}
+
+	pending := send()
+	dropped := send()
+
+	// The second transaction left the mempool without being mined.
+	chain.pending = chain.pending[:1]
+
+	cases := map[string]adapter.TxStatus{
+		"aa":    {Status: adapter.StatusConfirmed, Confirmations: 3, BlockHeight: 3, BlockHash: "bb"},
+		pending: {Status: adapter.StatusPending},
+		dropped: {Status: adapter.StatusDropped, Reason: "no longer valid against the chain state"},
+		"cc":    {Status: adapter.StatusUnknown},
+	}
+
+	for id, want := range cases {
+		if got, err := ad.TxStatus(context.Background(), id); err != nil || got != want {
+			t.Fatalf("%s: got=%+v err=%v want=%+v", id, got, err, want)
+		}
+	}
+
+	if _, err := ad.TxStatus(context.Background(), "not hex"); err == nil {
+		t.Fatal("expected an invalid id to be rejected")
+	}
+
+	chain.broken = errors.New("store closed")
+
+	if _, err := ad.TxStatus(context.Background(), "aa"); !errors.Is(err, chain.broken) {
+		t.Fatalf("got err=%v, want the chain's error", err)
+	}
+}
+
+func TestAmountsBeyondUint64(t *testing.T) {
+	t.Parallel()
+
+	chain := &fakeChain{}
+	ad := NewAdapter(chain)
+	_, _, from := NewKey([]byte("from"))
+	_, _, to := NewKey([]byte("to"))
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), 64)
+
+	for _, amount := range []*big.Int{tooLarge, big.NewInt(-1)} {
+		if _, err := ad.BuildTx(context.Background(), from, to, amount, adapter.FeeHint{}); !errors.Is(err, adapter.ErrInvalidAmount) {
+			t.Fatalf("build %s: got err=%v, want ErrInvalidAmount", amount, err)
+		}
+	}
+
+	// A transaction built elsewhere is checked again when it is broadcast.
+	signed, _ := ad.SignTx("", adapter.Tx{From: from, To: to, Amount: tooLarge, Fee: 1})
+
+	if _, err := ad.Broadcast(context.Background(), signed); !errors.Is(err, adapter.ErrInvalidAmount) || len(chain.submitted) != 0 {
+		t.Fatalf("broadcast: got err=%v submitted=%d, want ErrInvalidAmount and nothing submitted", err, len(chain.submitted))
+	}
+
+	largest := new(big.Int).SetUint64(math.MaxUint64)
+	tx, err := ad.BuildTx(context.Background(), from, to, largest, adapter.FeeHint{MaxFeePerGas: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, _ = ad.SignTx("", tx)
+
+	if _, err := ad.Broadcast(context.Background(), signed); err != nil || chain.submitted[0].Amount != math.MaxUint64 {
+		t.Fatalf("got err=%v submitted=%+v, want the largest amount submitted", err, chain.submitted)
+	}
+
+	if got := hex.EncodeToString(chain.submitted[0].To); got != to {
+		t.Fatalf("got to=%s want=%s", got, to)
+	}
+}
@@ -1,5 +1,7 @@
 package adapter
 
+import "sort"
+
 type Registry struct {
 	adapters map[string]ChainAdapter
 }
@@ -19,3 +21,16 @@ func (r *Registry) Get(id string) (ChainAdapter, bool) {
 
 	return adapter, exists
 }
+
+// Chains lists the chains with an adapter, in name order.
+func (r *Registry) Chains() []string {
+	chains := make([]string, 0, len(r.adapters))
+
+	for chain := range r.adapters {
+		chains = append(chains, chain)
+	}
+
+	sort.Strings(chains)
+
+	return chains
+}
@@ -1,12 +1,37 @@
 package adapter
 
+import (
+	"math/big"
+	"time"
+)
+
+// Tx is an unsigned transaction. VSize and FeeRate are the estimated size and
+// the fee rate it was priced at, where the network reports them. On EIP-1559
+// networks Fee is the max fee per gas and PriorityFee the max priority fee,
+// MaxCost is the amount plus GasLimit gas at the max fee, and ChainID is the
+// chain the transaction is signed for.
 type Tx struct {
-	From   string
-	To     string
-	Amount uint64
-	Fee    uint64
-	Nonce  uint64
-	Data   []byte
+	From        string
+	To          string
+	Amount      *big.Int
+	Fee         uint64
+	PriorityFee uint64
+	Nonce       uint64
+	Data        []byte
+	VSize       uint64
+	FeeRate     uint64
+	GasLimit    uint64
+	MaxCost     *big.Int
+	ChainID     uint64
+}
+
+// TxParams are what a transaction would otherwise be built with from a node,
+// given up front so it can be built and signed without one. Zero values and
+// a nil Nonce are looked up.
+type TxParams struct {
+	ChainID  uint64
+	Nonce    *uint64
+	GasLimit uint64
 }
 
 type SignedTx struct {
@@ -14,14 +39,99 @@ type SignedTx struct {
 	TxID   string
 }
 
+// Token is a fungible token issued by a contract, such as an ERC-20. Amounts
+// of it are counted in its smallest unit, 10^-Decimals of a token.
+type Token struct {
+	Symbol   string
+	Contract string
+	Decimals uint32
+}
+
+// FeeHint overrides the estimated fees. Speed picks how fast the estimate
+// aims to confirm on networks that price from recent blocks.
 type FeeHint struct {
 	MaxFeePerGas   uint64
 	MaxPriorityFee uint64
+	Speed          string
+	FeeRate        uint64
 }
 
+const (
+	FeeSpeedSlow   = "slow"
+	FeeSpeedNormal = "normal"
+	FeeSpeedFast   = "fast"
+)
+
+// Status is where a transaction stands on its network.
 type Status string
 
 const (
+	// StatusUnknown is a transaction the network has no record of, such as
+	// one that has not propagated yet.
+	StatusUnknown Status = "unknown"
+	// StatusPending is waiting in the mempool.
 	StatusPending Status = "pending"
-	StatusMined   Status = "mined"
+	// StatusIncluded is in a block, but not yet as deep as the network
+	// considers final.
+	StatusIncluded Status = "included"
+	// StatusConfirmed is in a block at least as deep as the network considers
+	// final.
+	StatusConfirmed Status = "confirmed"
+	// StatusFailed is in a block but did not apply, such as a reverted
+	// contract call.
+	StatusFailed Status = "failed"
+	// StatusDropped left the mempool without being included.
+	StatusDropped Status = "dropped"
+	// StatusReplaced will never be included because a transaction spending
+	// the same nonce or inputs was.
+	StatusReplaced Status = "replaced"
 )
+
+// Final reports whether the status can no longer change.
+func (status Status) Final() bool {
+	switch status {
+	case StatusConfirmed, StatusFailed, StatusDropped, StatusReplaced:
+		return true
+	}
+
+	return false
+}
+
+// TxStatus is a transaction's status with what the network reports about
+// it. Confirmations counts the block it is in and those on top of it, Reason
+// says why a failed transaction failed, and ReplacedBy is the transaction
+// that replaced it, where known.
+type TxStatus struct {
+	Status        Status
+	Confirmations uint64
+	BlockHeight   uint64
+	BlockHash     string
+	Reason        string
+	ReplacedBy    string
+}
+
+// RPCEndpoint is the health of one of a network's RPC URLs: how many times
+// in a row it has failed, and whether it is being tried.
+type RPCEndpoint struct {
+	URL      string
+	Failures int
+	Healthy  bool
+}
+
+// RPCMetrics count the requests for one JSON-RPC method, including those
+// sent in batches, the ones that failed, and the time they took with
+// retries.
+type RPCMetrics struct {
+	Calls   uint64
+	Errors  uint64
+	Latency time.Duration
+}
+
+// MeanLatency is the average time a call took.
+func (metrics RPCMetrics) MeanLatency() time.Duration {
+	if metrics.Calls == 0 {
+		return 0
+	}
+
+	return metrics.Latency / time.Duration(metrics.Calls)
+}
@@ -0,0 +1,216 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+	"github.com/afrodynamic/gochain/api/internal/p2p"
+	adminv1 "github.com/afrodynamic/gochain/api/proto/admin/v1"
+)
+
+type AdminServer struct {
+	adminv1.UnimplementedAdminServer
+	network  *p2p.Server
+	adapters *adapter.Registry
+	token    string
+}
+
+// NewAdmin serves peer management for the network and the RPC stats of the
+// registry's adapters. Every call must carry "authorization: Bearer <token>";
+// an empty token disables the service.
+func NewAdmin(network *p2p.Server, adapters *adapter.Registry, token string) *AdminServer {
+	return &AdminServer{network: network, adapters: adapters, token: token}
+}
+
+func (server *AdminServer) ListPeers(ctx context.Context, request *adminv1.ListPeersRequest) (*adminv1.ListPeersResponse, error) {
+	if err := server.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	peers := server.network.Peers()
+	response := &adminv1.ListPeersResponse{Peers: make([]*adminv1.Peer, 0, len(peers))}
+
+	for _, peer := range peers {
+		response.Peers = append(response.Peers, &adminv1.Peer{
+			Id:            peer.ID,
+			Address:       peer.Address,
+			ListenAddress: peer.ListenAddress,
+			Inbound:       peer.Inbound,
+			Height:        peer.Height,
+			Score:         peer.Score,
+		})
+	}
+
+	return response, nil
+}
+
+func (server *AdminServer) ListBans(ctx context.Context, request *adminv1.ListBansRequest) (*adminv1.ListBansResponse, error) {
+	if err := server.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	bans := server.network.Bans()
+	response := &adminv1.ListBansResponse{Bans: make([]*adminv1.Ban, 0, len(bans))}
+
+	for _, ban := range bans {
+		response.Bans = append(response.Bans, convertBan(ban))
+	}
+
+	return response, nil
+}
+
+func (server *AdminServer) BanPeer(ctx context.Context, request *adminv1.BanPeerRequest) (*adminv1.BanPeerResponse, error) {
+	if err := server.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	reason := request.Reason
+
+	if reason == "" {
+		reason = "banned by operator"
+	}
+
+	ban, err := server.network.Ban(request.Target, reason, time.Duration(request.DurationSeconds)*time.Second)
+
+	if err != nil {
+		return nil, banError(err)
+	}
+
+	return &adminv1.BanPeerResponse{Ban: convertBan(ban)}, nil
+}
+
+func (server *AdminServer) UnbanPeer(ctx context.Context, request *adminv1.UnbanPeerRequest) (*adminv1.UnbanPeerResponse, error) {
+	if err := server.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	removed, err := server.network.Unban(request.Target)
+
+	if err != nil {
+		return nil, banError(err)
+	}
+
+	return &adminv1.UnbanPeerResponse{Removed: removed}, nil
+}
+
+// banError reports a target that is not a node ID or IP address as the
+// caller's mistake, and anything else, such as failing to save the ban list,
+// as the node's.
+func banError(err error) error {
+	if errors.Is(err, p2p.ErrInvalidBanTarget) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+// GetRPCStats reports the node URLs and per-method metrics of every adapter
+// that talks JSON-RPC. URLs are cut down to their host, since providers put
+// API keys in the path or query.
+func (server *AdminServer) GetRPCStats(ctx context.Context, request *adminv1.GetRPCStatsRequest) (*adminv1.GetRPCStatsResponse, error) {
+	if err := server.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	response := &adminv1.GetRPCStatsResponse{}
+
+	if server.adapters == nil {
+		return response, nil
+	}
+
+	for _, chain := range server.adapters.Chains() {
+		chainAdapter, _ := server.adapters.Get(chain)
+		stats, ok := chainAdapter.(adapter.RPCStatsAdapter)
+
+		if !ok {
+			continue
+		}
+
+		network := &adminv1.RPCNetwork{Chain: chain}
+
+		for _, endpoint := range stats.RPCEndpoints() {
+			network.Endpoints = append(network.Endpoints, &adminv1.RPCEndpoint{Host: endpointHost(endpoint.URL), Failures: uint32(endpoint.Failures), Healthy: endpoint.Healthy})
+		}
+
+		metrics := stats.RPCMetrics()
+		methods := make([]string, 0, len(metrics))
+
+		for method := range metrics {
+			methods = append(methods, method)
+		}
+
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			network.Methods = append(network.Methods, &adminv1.RPCMethod{
+				Method:        method,
+				Calls:         metrics[method].Calls,
+				Errors:        metrics[method].Errors,
+				MeanLatencyMs: float64(metrics[method].MeanLatency()) / float64(time.Millisecond),
+			})
+		}
+
+		response.Networks = append(response.Networks, network)
+	}
+
+	return response, nil
+}
+
+func endpointHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+
+	if err != nil || parsed.Host == "" {
+		return "invalid URL"
+	}
+
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// authorize lets through the calls with the admin token that manage peers,
+// which needs networking.
+func (server *AdminServer) authorize(ctx context.Context) error {
+	if err := server.authenticate(ctx); err != nil {
+		return err
+	}
+
+	if server.network == nil {
+		return status.Error(codes.FailedPrecondition, "p2p networking is disabled; set GOCHAIN_P2P_ADDR")
+	}
+
+	return nil
+}
+
+func (server *AdminServer) authenticate(ctx context.Context) error {
+	if server.token == "" {
+		return status.Error(codes.PermissionDenied, "admin API is disabled; set GOCHAIN_ADMIN_TOKEN")
+	}
+
+	incoming, _ := metadata.FromIncomingContext(ctx)
+	expected := []byte("Bearer " + server.token)
+	authorized := false
+
+	for _, value := range incoming.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(value), expected) == 1 {
+			authorized = true
+		}
+	}
+
+	if !authorized {
+		return status.Error(codes.Unauthenticated, "missing or invalid admin token")
+	}
+
+	return nil
+}
+
+func convertBan(ban p2p.Ban) *adminv1.Ban {
+	return &adminv1.Ban{Target: ban.Target, Reason: ban.Reason, UntilUnix: ban.Until.Unix()}
+}
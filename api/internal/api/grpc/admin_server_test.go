@@ -0,0 +1,118 @@
+package grpcapi_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter"
+	"github.com/afrodynamic/gochain/api/internal/adapter/bitcoin"
+	"github.com/afrodynamic/gochain/api/internal/adapter/ethereum"
+	grpcapi "github.com/afrodynamic/gochain/api/internal/api/grpc"
+	"github.com/afrodynamic/gochain/api/internal/p2p"
+	adminv1 "github.com/afrodynamic/gochain/api/proto/admin/v1"
+)
+
+func TestAdminRequiresToken(t *testing.T) {
+	t.Parallel()
+
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		ctx   context.Context
+		want  codes.Code
+	}{
+		{name: "disabled", token: "", ctx: withToken(""), want: codes.PermissionDenied},
+		{name: "missing", token: "secret", ctx: context.Background(), want: codes.Unauthenticated},
+		{name: "wrong", token: "secret", ctx: withToken("guess"), want: codes.Unauthenticated},
+		{name: "no network", token: "secret", ctx: withToken("secret"), want: codes.FailedPrecondition},
+	}
+
+	for _, testCase := range cases {
+		server := grpcapi.NewAdmin(nil, nil, testCase.token)
+		_, err := server.ListBans(testCase.ctx, &adminv1.ListBansRequest{})
+
+		if got := status.Code(err); got != testCase.want {
+			t.Fatalf("%s: got=%s want=%s", testCase.name, got, testCase.want)
+		}
+	}
+}
+
+func TestAdminRejectsInvalidBanTargets(t *testing.T) {
+	t.Parallel()
+
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	network, err := p2p.New(p2p.Config{PrivateKey: privateKey}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpcapi.NewAdmin(network, nil, "secret")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+
+	if _, err := server.BanPeer(ctx, &adminv1.BanPeerRequest{Target: "not-a-peer"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("ban: got=%v want=%s", err, codes.InvalidArgument)
+	}
+
+	if _, err := server.UnbanPeer(ctx, &adminv1.UnbanPeerRequest{Target: "not-a-peer"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unban: got=%v want=%s", err, codes.InvalidArgument)
+	}
+
+	if response, err := server.UnbanPeer(ctx, &adminv1.UnbanPeerRequest{Target: "10.0.0.1"}); err != nil || response.Removed {
+		t.Fatalf("unban unknown: got=%+v err=%v", response, err)
+	}
+}
+
+func TestAdminReportsRPCStats(t *testing.T) {
+	t.Parallel()
+
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x5"}`))
+	}))
+
+	defer node.Close()
+
+	evmAdapter := ethereum.NewAdapter(ethereum.Network{Name: "anvil", ChainID: 31337, Symbol: "ETH", RPCURLs: []string{node.URL + "/v3/secret-key"}}, 20, nil)
+
+	if _, err := evmAdapter.Balance(context.Background(), "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := adapter.NewRegistry()
+	registry.Register("anvil", evmAdapter)
+	registry.Register("bitcoin", bitcoin.NewAdapter(bitcoin.MainNet, nil))
+
+	// RPC stats need no p2p networking.
+	server := grpcapi.NewAdmin(nil, registry, "secret")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	response, err := server.GetRPCStats(ctx, &adminv1.GetRPCStatsRequest{})
+
+	if err != nil || len(response.Networks) != 1 {
+		t.Fatalf("got=%v err=%v, want the one EVM network", response, err)
+	}
+
+	network := response.Networks[0]
+
+	if network.Chain != "anvil" || len(network.Endpoints) != 1 || network.Endpoints[0].Host != node.URL || !network.Endpoints[0].Healthy {
+		t.Fatalf("got=%v, want the node's host without its key", network)
+	}
+
+	if len(network.Methods) != 1 || network.Methods[0].Method != "eth_getBalance" || network.Methods[0].Calls != 1 || network.Methods[0].Errors != 0 {
+		t.Fatalf("got methods=%v, want one eth_getBalance call", network.Methods)
+	}
+
+	if _, err := server.GetRPCStats(context.Background(), &adminv1.GetRPCStatsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got=%v want=%s", err, codes.Unauthenticated)
+	}
+}
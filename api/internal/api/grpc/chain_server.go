@@ -7,13 +7,18 @@ import (
 	chainv1 "github.com/afrodynamic/gochain/api/proto/chain/v1"
 )
 
+const maxHeadersPerRequest = 512
+
 type ChainServer struct {
 	chainv1.UnimplementedChainServer
-	blockchain core.Blockchain
+	blockchain   core.Blockchain
+	syncReporter core.SyncReporter
 }
 
-func NewChain(blockchain core.Blockchain) *ChainServer {
-	return &ChainServer{blockchain: blockchain}
+// NewChain serves the blockchain. The sync reporter is optional; without one
+// the node reports itself as caught up.
+func NewChain(blockchain core.Blockchain, syncReporter core.SyncReporter) *ChainServer {
+	return &ChainServer{blockchain: blockchain, syncReporter: syncReporter}
 }
 
 func (server *ChainServer) GetBlock(ctx context.Context, request *chainv1.GetBlockRequest) (*chainv1.GetBlockResponse, error) {
@@ -35,6 +40,7 @@ func (server *ChainServer) SubmitTx(ctx context.Context, request *chainv1.Submit
 		From:   request.From,
 		To:     request.To,
 		Amount: request.Amount,
+		Fee:    request.Fee,
 		Data:   request.Data,
 	})
 
@@ -54,3 +60,148 @@ func (server *ChainServer) GetBalance(ctx context.Context, request *chainv1.GetB
 
 	return &chainv1.GetBalanceResponse{Balance: balance}, nil
 }
+
+func (server *ChainServer) GetNodeInfo(ctx context.Context, request *chainv1.GetNodeInfoRequest) (*chainv1.GetNodeInfoResponse, error) {
+	info := server.blockchain.NodeInfo()
+	forks := make([]*chainv1.Fork, 0, len(info.Forks))
+
+	for _, fork := range info.Forks {
+		forks = append(forks, convertFork(fork, fork.Name == info.Rules.Name))
+	}
+
+	return &chainv1.GetNodeInfoResponse{
+		ChainId:     info.ChainID,
+		GenesisHash: info.GenesisHash,
+		Consensus:   info.Consensus,
+		Height:      info.Height,
+		Current:     convertFork(info.Rules, true),
+		Forks:       forks,
+	}, nil
+}
+
+func (server *ChainServer) GetSyncStatus(ctx context.Context, request *chainv1.GetSyncStatusRequest) (*chainv1.GetSyncStatusResponse, error) {
+	status := server.syncStatus()
+
+	return &chainv1.GetSyncStatusResponse{
+		Syncing:       status.Syncing,
+		StartHeight:   status.StartHeight,
+		CurrentHeight: status.CurrentHeight,
+		TargetHeight:  status.TargetHeight,
+		Peers:         uint32(status.Peers),
+		EtaSeconds:    uint64(status.ETA.Seconds()),
+	}, nil
+}
+
+func (server *ChainServer) GetHeaders(ctx context.Context, request *chainv1.GetHeadersRequest) (*chainv1.GetHeadersResponse, error) {
+	height := server.blockchain.NodeInfo().Height
+	count := min(uint64(request.Count), maxHeadersPerRequest)
+
+	if count == 0 {
+		count = maxHeadersPerRequest
+	}
+
+	response := &chainv1.GetHeadersResponse{Height: height}
+
+	for current := request.From; current <= height && current-request.From < count; current++ {
+		block, err := server.blockchain.GetBlock(current)
+
+		if err != nil {
+			break
+		}
+
+		response.Headers = append(response.Headers, convertHeader(block))
+	}
+
+	return response, nil
+}
+
+func (server *ChainServer) GetAccountProof(ctx context.Context, request *chainv1.GetAccountProofRequest) (*chainv1.GetAccountProofResponse, error) {
+	height, proof, err := server.blockchain.AccountProof(request.Address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response := &chainv1.GetAccountProofResponse{Height: height, Address: proof.Address}
+
+	for i, account := range proof.Accounts {
+		response.Accounts = append(response.Accounts, &chainv1.Account{Address: account.Address, Balance: account.Balance, Nonce: account.Nonce})
+		response.Proofs = append(response.Proofs, convertMerkleProof(proof.Proofs[i]))
+	}
+
+	return response, nil
+}
+
+func (server *ChainServer) GetTransactionProof(ctx context.Context, request *chainv1.GetTransactionProofRequest) (*chainv1.GetTransactionProofResponse, error) {
+	tx, proof, err := server.blockchain.TransactionProof(request.Hash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &chainv1.GetTransactionProofResponse{
+		Transaction: &chainv1.Transaction{
+			Hash:              tx.Hash,
+			From:              tx.From,
+			To:                tx.To,
+			Amount:            tx.Amount,
+			Fee:               tx.Fee,
+			Nonce:             tx.Nonce,
+			TimestampUnixNano: tx.Timestamp.UnixNano(),
+			BlockHeight:       tx.BlockHeight,
+			Data:              tx.Data,
+		},
+		Proof: convertMerkleProof(proof),
+	}, nil
+}
+
+func (server *ChainServer) syncStatus() core.SyncStatus {
+	if server.syncReporter != nil {
+		return server.syncReporter.SyncStatus()
+	}
+
+	height := server.blockchain.NodeInfo().Height
+
+	return core.SyncStatus{StartHeight: height, CurrentHeight: height, TargetHeight: height}
+}
+
+func convertHeader(block core.Block) *chainv1.BlockHeader {
+	header := &chainv1.BlockHeader{
+		Hash:              block.Hash,
+		Height:            block.Height,
+		PrevHash:          block.PrevHash,
+		TimestampUnixNano: block.Timestamp.UnixNano(),
+		TxRoot:            block.TxRoot,
+		StateRoot:         block.StateRoot,
+		Nonce:             block.Nonce,
+		Proposer:          block.Proposer,
+	}
+
+	if block.Commit != nil {
+		header.Commit = &chainv1.Commit{Round: block.Commit.Round}
+
+		for _, signature := range block.Commit.Signatures {
+			header.Commit.Signatures = append(header.Commit.Signatures, &chainv1.CommitSignature{Signer: signature.Signer, Signature: signature.Signature})
+		}
+	}
+
+	return header
+}
+
+func convertMerkleProof(proof core.MerkleProof) *chainv1.MerkleProof {
+	return &chainv1.MerkleProof{Index: proof.Index, Size: proof.Size, Hashes: proof.Hashes}
+}
+
+func convertFork(fork core.Fork, active bool) *chainv1.Fork {
+	return &chainv1.Fork{
+		Name:   fork.Name,
+		Height: fork.Height,
+		Rules: &chainv1.Rules{
+			MinFee:       fork.Rules.MinFee,
+			MaxDataBytes: fork.Rules.MaxDataBytes,
+			Difficulty:   uint32(fork.Rules.Difficulty),
+			MaxMint:      fork.Rules.MaxMint,
+		},
+		Active: active,
+	}
+}
@@ -2,8 +2,13 @@ package grpcapi
 
 import (
 	"context"
+	"math/big"
+	"slices"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/afrodynamic/gochain/api/internal/adapter"
 	walletv1 "github.com/afrodynamic/gochain/api/proto/wallet/v1"
 )
@@ -26,6 +31,10 @@ func (server *WalletServer) NewKey(ctx context.Context, req *walletv1.NewKeyRequ
 		return &walletv1.NewKeyResponse{Priv: "priv", Pub: "pub", Addr: "addr"}, nil
 	}
 
+	if req.AddressType != "" {
+		return server.newKeyWithAddressType(req.Seed, req.AddressType)
+	}
+
 	privateKey, publicKey, address, err := server.adapter.NewKey(req.Seed)
 
 	if err != nil {
@@ -35,12 +44,39 @@ func (server *WalletServer) NewKey(ctx context.Context, req *walletv1.NewKeyRequ
 	return &walletv1.NewKeyResponse{Priv: privateKey, Pub: publicKey, Addr: address}, nil
 }
 
+func (server *WalletServer) newKeyWithAddressType(seed []byte, addressType string) (*walletv1.NewKeyResponse, error) {
+	typed, ok := server.adapter.(adapter.AddressTypeAdapter)
+
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "%s does not support address types", server.adapter.Network())
+	}
+
+	if !slices.Contains(typed.AddressTypes(), addressType) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown address type %q, expected one of %v", addressType, typed.AddressTypes())
+	}
+
+	privateKey, publicKey, address, err := typed.NewKeyWithAddressType(seed, addressType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &walletv1.NewKeyResponse{Priv: privateKey, Pub: publicKey, Addr: address}, nil
+}
+
 func (server *WalletServer) ParseAddress(ctx context.Context, request *walletv1.ParseAddressRequest) (*walletv1.ParseAddressResponse, error) {
 	if server.adapter == nil {
 		return &walletv1.ParseAddressResponse{Addr: request.Value}, nil
 	}
 
-	address, err := server.adapter.ParseAddress(request.Value)
+	var address string
+	var err error
+
+	if resolver, ok := server.adapter.(adapter.NameResolvingAdapter); ok {
+		address, err = resolver.ResolveAddress(ctx, request.Value)
+	} else {
+		address, err = server.adapter.ParseAddress(request.Value)
+	}
 
 	if err != nil {
 		return nil, err
@@ -51,7 +87,11 @@ func (server *WalletServer) ParseAddress(ctx context.Context, request *walletv1.
 
 func (server *WalletServer) Balance(ctx context.Context, req *walletv1.BalanceRequest) (*walletv1.BalanceResponse, error) {
 	if server.adapter == nil {
-		return &walletv1.BalanceResponse{Balance: 0}, nil
+		return &walletv1.BalanceResponse{BalanceDecimal: "0"}, nil
+	}
+
+	if req.Token != "" {
+		return server.tokenBalance(ctx, req)
 	}
 
 	balance, err := server.adapter.Balance(ctx, req.Addr)
@@ -60,33 +100,303 @@ func (server *WalletServer) Balance(ctx context.Context, req *walletv1.BalanceRe
 		return nil, err
 	}
 
-	return &walletv1.BalanceResponse{Balance: balance}, nil
+	return &walletv1.BalanceResponse{BalanceDecimal: adapter.FormatAmount(balance), Decimals: server.adapter.Decimals()}, nil
+}
+
+func (server *WalletServer) tokens() (adapter.TokenAdapter, error) {
+	tokens, ok := server.adapter.(adapter.TokenAdapter)
+
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "%s does not support tokens", server.adapter.Network())
+	}
+
+	return tokens, nil
+}
+
+func (server *WalletServer) token(symbolOrContract string) (adapter.TokenAdapter, adapter.Token, error) {
+	tokens, err := server.tokens()
+
+	if err != nil {
+		return nil, adapter.Token{}, err
+	}
+
+	token, err := tokens.Token(symbolOrContract)
+
+	if err != nil {
+		return nil, adapter.Token{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return tokens, token, nil
+}
+
+func tokenMessage(token adapter.Token) *walletv1.Token {
+	return &walletv1.Token{Symbol: token.Symbol, Contract: token.Contract, Decimals: token.Decimals}
+}
+
+func (server *WalletServer) tokenBalance(ctx context.Context, req *walletv1.BalanceRequest) (*walletv1.BalanceResponse, error) {
+	tokens, token, err := server.token(req.Token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := tokens.TokenBalance(ctx, token, req.Addr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &walletv1.BalanceResponse{BalanceDecimal: adapter.FormatAmount(balance), Token: tokenMessage(token), Decimals: token.Decimals}, nil
+}
+
+func (server *WalletServer) ListTokens(ctx context.Context, request *walletv1.ListTokensRequest) (*walletv1.ListTokensResponse, error) {
+	if server.adapter == nil {
+		return &walletv1.ListTokensResponse{}, nil
+	}
+
+	tokens, err := server.tokens()
+
+	if err != nil {
+		return nil, err
+	}
+
+	response := &walletv1.ListTokensResponse{}
+
+	for _, token := range tokens.Tokens() {
+		response.Tokens = append(response.Tokens, tokenMessage(token))
+	}
+
+	return response, nil
 }
 
 func (server *WalletServer) BuildTx(ctx context.Context, request *walletv1.BuildTxRequest) (*walletv1.BuildTxResponse, error) {
 	if server.adapter == nil {
-		return &walletv1.BuildTxResponse{Tx: &walletv1.Tx{From: request.From, To: request.To, Amount: request.Amount}}, nil
+		return &walletv1.BuildTxResponse{Tx: &walletv1.Tx{From: request.From, To: request.To, AmountDecimal: request.AmountDecimal}}, nil
 	}
 
-	tx, err := server.adapter.BuildTx(ctx, request.From, request.To, request.Amount, adapter.FeeHint{
-		MaxFeePerGas:   request.FeeHint.MaxFeePerGas,
-		MaxPriorityFee: request.FeeHint.MaxPriorityFee,
-	})
+	amount, err := parseAmount(request.AmountDecimal)
+
+	if err != nil {
+		return nil, err
+	}
+
+	feeHint := feeHintFrom(request.FeeHint)
+
+	var tx adapter.Tx
+	var token *walletv1.Token
+	decimals := server.adapter.Decimals()
+
+	switch {
+	case request.Token != "":
+		tx, token, err = server.buildTokenTx(ctx, request, amount, feeHint)
+		decimals = token.GetDecimals()
+
+	case request.FromDescriptor != "":
+		tx, err = server.buildDescriptorTx(ctx, request, amount, feeHint)
+
+	case len(request.Data) > 0 || request.ChainId != 0 || request.Nonce != nil || request.GasLimit != 0:
+		tx, err = server.buildTxWithData(ctx, request, amount, feeHint)
+
+	case request.CoinSelection != "":
+		tx, err = server.buildTxWithCoinSelection(ctx, request, amount, feeHint)
+
+	default:
+		tx, err = server.adapter.BuildTx(ctx, request.From, request.To, amount, feeHint)
+	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	return buildTxResponse(tx, token, decimals), nil
+}
+
+func feeHintFrom(hint *walletv1.FeeHint) adapter.FeeHint {
+	return adapter.FeeHint{
+		MaxFeePerGas:   hint.GetMaxFeePerGas(),
+		MaxPriorityFee: hint.GetMaxPriorityFee(),
+		Speed:          hint.GetSpeed(),
+		FeeRate:        hint.GetFeeRate(),
+	}
+}
+
+func buildTxResponse(tx adapter.Tx, token *walletv1.Token, decimals uint32) *walletv1.BuildTxResponse {
+	var maxCost string
+
+	if tx.MaxCost != nil {
+		maxCost = adapter.FormatAmount(tx.MaxCost)
+	}
+
 	return &walletv1.BuildTxResponse{
 		Tx: &walletv1.Tx{
-			From:   tx.From,
-			To:     tx.To,
-			Amount: tx.Amount,
-			Fee:    tx.Fee,
-			Nonce:  tx.Nonce,
-			Data:   tx.Data,
+			From:           tx.From,
+			To:             tx.To,
+			AmountDecimal:  adapter.FormatAmount(tx.Amount),
+			Fee:            tx.Fee,
+			Nonce:          tx.Nonce,
+			Data:           tx.Data,
+			MaxPriorityFee: tx.PriorityFee,
+			GasLimit:       tx.GasLimit,
+			ChainId:        tx.ChainID,
 		},
-	}, nil
+		EstimatedVsize: tx.VSize,
+		FeeRate:        tx.FeeRate,
+		GasLimit:       tx.GasLimit,
+		MaxCostDecimal: maxCost,
+		Token:          token,
+		Decimals:       decimals,
+	}
+}
+
+// parseAmount reads a decimal amount in the smallest unit from a request.
+func parseAmount(value string) (*big.Int, error) {
+	amount, err := adapter.ParseAmount(value)
+
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return amount, nil
+}
+
+func (server *WalletServer) buildTokenTx(ctx context.Context, request *walletv1.BuildTxRequest, amount *big.Int, feeHint adapter.FeeHint) (adapter.Tx, *walletv1.Token, error) {
+	if len(request.Data) > 0 {
+		return adapter.Tx{}, nil, status.Error(codes.InvalidArgument, "token transfers carry their own call data")
+	}
+
+	tokens, token, err := server.token(request.Token)
+
+	if err != nil {
+		return adapter.Tx{}, nil, err
+	}
+
+	tx, err := tokens.BuildTokenTx(ctx, token, request.From, request.To, amount, feeHint, txParams(request))
+
+	return tx, tokenMessage(token), err
+}
+
+func (server *WalletServer) buildTxWithData(ctx context.Context, request *walletv1.BuildTxRequest, amount *big.Int, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	calling, ok := server.adapter.(adapter.CallDataAdapter)
+
+	if !ok {
+		return adapter.Tx{}, status.Errorf(codes.InvalidArgument, "%s does not support call data or explicit transaction params", server.adapter.Network())
+	}
+
+	return calling.BuildTxWithData(ctx, request.From, request.To, amount, request.Data, feeHint, txParams(request))
+}
+
+func txParams(request *walletv1.BuildTxRequest) adapter.TxParams {
+	return adapter.TxParams{ChainID: request.ChainId, Nonce: request.Nonce, GasLimit: request.GasLimit}
+}
+
+func (server *WalletServer) coinSelection(strategy string) (adapter.CoinSelectionAdapter, error) {
+	selecting, ok := server.adapter.(adapter.CoinSelectionAdapter)
+
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "%s does not support coin selection", server.adapter.Network())
+	}
+
+	if !slices.Contains(selecting.CoinSelections(), strategy) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown coin selection %q, expected one of %v", strategy, selecting.CoinSelections())
+	}
+
+	return selecting, nil
+}
+
+func (server *WalletServer) buildTxWithCoinSelection(ctx context.Context, request *walletv1.BuildTxRequest, amount *big.Int, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	selecting, err := server.coinSelection(request.CoinSelection)
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	return selecting.BuildTxWithCoinSelection(ctx, request.From, request.To, amount, feeHint, request.CoinSelection)
+}
+
+func (server *WalletServer) buildDescriptorTx(ctx context.Context, request *walletv1.BuildTxRequest, amount *big.Int, feeHint adapter.FeeHint) (adapter.Tx, error) {
+	descriptors, err := server.descriptors()
+
+	if err != nil {
+		return adapter.Tx{}, err
+	}
+
+	if request.CoinSelection != "" {
+		if _, err := server.coinSelection(request.CoinSelection); err != nil {
+			return adapter.Tx{}, err
+		}
+	}
+
+	return descriptors.BuildDescriptorTx(ctx, request.FromDescriptor, request.To, amount, feeHint, request.CoinSelection)
+}
+
+func (server *WalletServer) descriptors() (adapter.DescriptorAdapter, error) {
+	descriptors, ok := server.adapter.(adapter.DescriptorAdapter)
+
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "%s does not support output descriptors", server.adapter.Network())
+	}
+
+	return descriptors, nil
+}
+
+func (server *WalletServer) ImportDescriptor(ctx context.Context, request *walletv1.ImportDescriptorRequest) (*walletv1.ImportDescriptorResponse, error) {
+	if server.adapter == nil {
+		return &walletv1.ImportDescriptorResponse{Descriptor_: request.Descriptor_}, nil
+	}
+
+	descriptors, err := server.descriptors()
+
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := descriptors.ImportDescriptor(request.Descriptor_)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &walletv1.ImportDescriptorResponse{Descriptor_: descriptor}, nil
+}
+
+func (server *WalletServer) DescriptorAddresses(ctx context.Context, request *walletv1.DescriptorAddressesRequest) (*walletv1.DescriptorAddressesResponse, error) {
+	if server.adapter == nil {
+		return &walletv1.DescriptorAddressesResponse{}, nil
+	}
+
+	descriptors, err := server.descriptors()
+
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := descriptors.DescriptorAddresses(request.Descriptor_, request.Change, request.Start, request.Count)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &walletv1.DescriptorAddressesResponse{Addresses: addresses}, nil
+}
+
+func (server *WalletServer) DescriptorBalance(ctx context.Context, request *walletv1.DescriptorBalanceRequest) (*walletv1.BalanceResponse, error) {
+	if server.adapter == nil {
+		return &walletv1.BalanceResponse{BalanceDecimal: "0"}, nil
+	}
+
+	descriptors, err := server.descriptors()
+
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := descriptors.DescriptorBalance(ctx, request.Descriptor_)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &walletv1.BalanceResponse{BalanceDecimal: adapter.FormatAmount(balance), Decimals: server.adapter.Decimals()}, nil
 }
 
 func (server *WalletServer) SignTx(ctx context.Context, request *walletv1.SignTxRequest) (*walletv1.SignTxResponse, error) {
@@ -94,13 +404,22 @@ func (server *WalletServer) SignTx(ctx context.Context, request *walletv1.SignTx
 		return &walletv1.SignTxResponse{Signed: &walletv1.SignedTx{RawHex: "0x", TxId: "id"}}, nil
 	}
 
+	amount, err := parseAmount(request.Tx.AmountDecimal)
+
+	if err != nil {
+		return nil, err
+	}
+
 	signed, err := server.adapter.SignTx(request.Priv, adapter.Tx{
-		From:   request.Tx.From,
-		To:     request.Tx.To,
-		Amount: request.Tx.Amount,
-		Fee:    request.Tx.Fee,
-		Nonce:  request.Tx.Nonce,
-		Data:   request.Tx.Data,
+		From:        request.Tx.From,
+		To:          request.Tx.To,
+		Amount:      amount,
+		Fee:         request.Tx.Fee,
+		PriorityFee: request.Tx.MaxPriorityFee,
+		Nonce:       request.Tx.Nonce,
+		Data:        request.Tx.Data,
+		GasLimit:    request.Tx.GasLimit,
+		ChainID:     request.Tx.ChainId,
 	})
 
 	if err != nil {
@@ -132,9 +451,59 @@ func (server *WalletServer) Broadcast(ctx context.Context, request *walletv1.Bro
 	return &walletv1.BroadcastResponse{TxId: txID}, nil
 }
 
+func (server *WalletServer) replacements() (adapter.ReplacementAdapter, error) {
+	replacements, ok := server.adapter.(adapter.ReplacementAdapter)
+
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "%s does not support replacing transactions", server.adapter.Network())
+	}
+
+	return replacements, nil
+}
+
+func (server *WalletServer) SpeedUpTx(ctx context.Context, request *walletv1.SpeedUpTxRequest) (*walletv1.BuildTxResponse, error) {
+	if server.adapter == nil {
+		return &walletv1.BuildTxResponse{Tx: &walletv1.Tx{}}, nil
+	}
+
+	replacements, err := server.replacements()
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := replacements.SpeedUpTx(ctx, request.TxId, feeHintFrom(request.FeeHint))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buildTxResponse(tx, nil, server.adapter.Decimals()), nil
+}
+
+func (server *WalletServer) CancelTx(ctx context.Context, request *walletv1.CancelTxRequest) (*walletv1.BuildTxResponse, error) {
+	if server.adapter == nil {
+		return &walletv1.BuildTxResponse{Tx: &walletv1.Tx{}}, nil
+	}
+
+	replacements, err := server.replacements()
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := replacements.CancelTx(ctx, request.TxId, feeHintFrom(request.FeeHint))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buildTxResponse(tx, nil, server.adapter.Decimals()), nil
+}
+
 func (server *WalletServer) TxStatus(ctx context.Context, request *walletv1.TxStatusRequest) (*walletv1.TxStatusResponse, error) {
 	if server.adapter == nil {
-		return &walletv1.TxStatusResponse{Status: "pending"}, nil
+		return &walletv1.TxStatusResponse{Status: string(adapter.StatusPending)}, nil
 	}
 
 	status, err := server.adapter.TxStatus(ctx, request.TxId)
@@ -143,16 +512,26 @@ func (server *WalletServer) TxStatus(ctx context.Context, request *walletv1.TxSt
 		return nil, err
 	}
 
-	return &walletv1.TxStatusResponse{Status: string(status)}, nil
+	return &walletv1.TxStatusResponse{
+		Status:        string(status.Status),
+		Confirmations: status.Confirmations,
+		BlockHeight:   status.BlockHeight,
+		BlockHash:     status.BlockHash,
+		Reason:        status.Reason,
+		ReplacedBy:    status.ReplacedBy,
+	}, nil
 }
 
+// SubscribeTx polls the transaction's status, sending an event whenever it or
+// the confirmation count changes, until the status is final.
 func (server *WalletServer) SubscribeTx(request *walletv1.SubscribeTxRequest, stream walletv1.Wallet_SubscribeTxServer) error {
 	context := stream.Context()
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
 	transactionID := request.GetId()
-	var lastStatus string
+	var last adapter.TxStatus
+	sent := false
 
 	for {
 		select {
@@ -161,7 +540,7 @@ func (server *WalletServer) SubscribeTx(request *walletv1.SubscribeTxRequest, st
 
 		case <-ticker.C:
 			if server.adapter == nil {
-				_ = stream.Send(&walletv1.TxEvent{Id: transactionID, Status: "mined"})
+				_ = stream.Send(&walletv1.TxEvent{Id: transactionID, Status: string(adapter.StatusConfirmed)})
 				return nil
 			}
 
@@ -171,15 +550,26 @@ func (server *WalletServer) SubscribeTx(request *walletv1.SubscribeTxRequest, st
 				continue
 			}
 
-			currentStatus := string(status)
+			if sent && status.Status == last.Status && status.Confirmations == last.Confirmations {
+				continue
+			}
 
-			if currentStatus != lastStatus {
-				lastStatus = currentStatus
-				_ = stream.Send(&walletv1.TxEvent{Id: transactionID, Status: lastStatus})
+			last, sent = status, true
+
+			if err := stream.Send(&walletv1.TxEvent{
+				Id:            transactionID,
+				Status:        string(status.Status),
+				Confirmations: status.Confirmations,
+				BlockHeight:   status.BlockHeight,
+				BlockHash:     status.BlockHash,
+				Reason:        status.Reason,
+				ReplacedBy:    status.ReplacedBy,
+			}); err != nil {
+				return err
+			}
 
-				if lastStatus == "mined" {
-					return nil
-				}
+			if status.Status.Final() {
+				return nil
 			}
 		}
 	}
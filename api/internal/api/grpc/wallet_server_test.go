@@ -0,0 +1,304 @@
+package grpcapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/afrodynamic/gochain/api/internal/adapter/bitcoin"
+	"github.com/afrodynamic/gochain/api/internal/adapter/ethereum"
+	grpcapi "github.com/afrodynamic/gochain/api/internal/api/grpc"
+	walletv1 "github.com/afrodynamic/gochain/api/proto/wallet/v1"
+)
+
+func TestNewKeyAddressTypes(t *testing.T) {
+	t.Parallel()
+
+	server := grpcapi.NewWallet(bitcoin.NewAdapter(bitcoin.MainNet, nil))
+	prefixes := map[string]string{"": "bc1q", "p2pkh": "1", "p2sh-p2wpkh": "3", "p2wpkh": "bc1q", "p2tr": "bc1p"}
+
+	for addressType, prefix := range prefixes {
+		response, err := server.NewKey(context.Background(), &walletv1.NewKeyRequest{AddressType: addressType})
+
+		if err != nil {
+			t.Fatalf("%q: %v", addressType, err)
+		}
+
+		if !strings.HasPrefix(response.Addr, prefix) {
+			t.Fatalf("%q: got=%s want prefix %s", addressType, response.Addr, prefix)
+		}
+	}
+
+	_, err := server.NewKey(context.Background(), &walletv1.NewKeyRequest{AddressType: "p2wsh"})
+
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("unknown type: got=%s want=%s", got, codes.InvalidArgument)
+	}
+
+	_, err = grpcapi.NewWallet(ethereum.NewAdapter(ethereum.Mainnet, 20, nil)).NewKey(context.Background(), &walletv1.NewKeyRequest{AddressType: "p2tr"})
+
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("unsupported network: got=%s want=%s", got, codes.InvalidArgument)
+	}
+}
+
+// relaySource funds every address with one confirmed output and accepts any
+// transaction.
+type relaySource struct{}
+
+func (relaySource) UTXOs(ctx context.Context, address string) ([]bitcoin.UTXO, error) {
+	return []bitcoin.UTXO{{TxID: strings.Repeat("ab", 32), Vout: 0, Value: 100_000, Height: 1}}, nil
+}
+
+func (relaySource) FeeRate(ctx context.Context, targetBlocks int) (uint64, error) {
+	return 4, nil
+}
+
+func (relaySource) Broadcast(ctx context.Context, rawTx []byte) (string, error) {
+	msgTx := wire.NewMsgTx(0)
+
+	if err := msgTx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		return "", err
+	}
+
+	return msgTx.TxHash().String(), nil
+}
+
+func TestBitcoinPSBTRoundTripsThroughWallet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	online := grpcapi.NewWallet(bitcoin.NewAdapter(bitcoin.RegTest, relaySource{}))
+	coldStorage := grpcapi.NewWallet(bitcoin.NewAdapter(bitcoin.RegTest, nil))
+
+	key, err := coldStorage.NewKey(ctx, &walletv1.NewKeyRequest{Seed: []byte("cold"), AddressType: "p2tr"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	built, err := online.BuildTx(ctx, &walletv1.BuildTxRequest{From: key.Addr, To: key.Addr, AmountDecimal: "25000", FeeHint: &walletv1.FeeHint{}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := coldStorage.SignTx(ctx, &walletv1.SignTxRequest{Priv: key.Priv, Tx: built.Tx})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broadcast, err := online.Broadcast(ctx, &walletv1.BroadcastRequest{Signed: signed.Signed})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if broadcast.TxId != signed.Signed.TxId {
+		t.Fatalf("got txid=%s want=%s", broadcast.TxId, signed.Signed.TxId)
+	}
+}
+
+func TestSpeedUpTxThroughWallet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	wallet := grpcapi.NewWallet(bitcoin.NewAdapter(bitcoin.RegTest, relaySource{}))
+	key, _ := wallet.NewKey(ctx, &walletv1.NewKeyRequest{Seed: []byte("impatient")})
+	recipient, _ := wallet.NewKey(ctx, &walletv1.NewKeyRequest{Seed: []byte("recipient")})
+
+	built, err := wallet.BuildTx(ctx, &walletv1.BuildTxRequest{From: key.Addr, To: recipient.Addr, AmountDecimal: "25000"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, _ := wallet.SignTx(ctx, &walletv1.SignTxRequest{Priv: key.Priv, Tx: built.Tx})
+	broadcast, err := wallet.Broadcast(ctx, &walletv1.BroadcastRequest{Signed: signed.Signed})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replacement, err := wallet.SpeedUpTx(ctx, &walletv1.SpeedUpTxRequest{TxId: broadcast.TxId, FeeHint: &walletv1.FeeHint{FeeRate: 20}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replacement.FeeRate != 20 || replacement.Tx.Fee <= built.Tx.Fee || replacement.Tx.AmountDecimal != "25000" || replacement.Tx.To != recipient.Addr {
+		t.Fatalf("got rate=%d fee=%d amount=%s to=%s", replacement.FeeRate, replacement.Tx.Fee, replacement.Tx.AmountDecimal, replacement.Tx.To)
+	}
+
+	signed, err = wallet.SignTx(ctx, &walletv1.SignTxRequest{Priv: key.Priv, Tx: replacement.Tx})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wallet.Broadcast(ctx, &walletv1.BroadcastRequest{Signed: signed.Signed}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wallet.CancelTx(ctx, &walletv1.CancelTxRequest{TxId: strings.Repeat("cd", 32)}); !errors.Is(err, bitcoin.ErrNotReplaceable) {
+		t.Fatalf("unknown transaction: got=%v want=%v", err, bitcoin.ErrNotReplaceable)
+	}
+}
+
+func TestBuildTxCoinSelection(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	wallet := grpcapi.NewWallet(bitcoin.NewAdapter(bitcoin.RegTest, relaySource{}))
+	key, _ := wallet.NewKey(ctx, &walletv1.NewKeyRequest{Seed: []byte("selector")})
+	request := &walletv1.BuildTxRequest{From: key.Addr, To: key.Addr, AmountDecimal: "25000", FeeHint: &walletv1.FeeHint{}, CoinSelection: bitcoin.CoinSelectionLargestFirst}
+
+	built, err := wallet.BuildTx(ctx, request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One P2WPKH input and two P2WPKH outputs weigh 562 WU, 141 vB.
+	if built.FeeRate != 4 || built.EstimatedVsize != 141 || built.Tx.Fee != 141*4 {
+		t.Fatalf("got rate=%d vsize=%d fee=%d", built.FeeRate, built.EstimatedVsize, built.Tx.Fee)
+	}
+
+	request.CoinSelection = "smallest-first"
+
+	if _, err := wallet.BuildTx(ctx, request); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unknown strategy: got=%v want=%s", err, codes.InvalidArgument)
+	}
+
+	request.CoinSelection = bitcoin.CoinSelectionKnapsack
+
+	if _, err := grpcapi.NewWallet(ethereum.NewAdapter(ethereum.Mainnet, 20, nil)).BuildTx(ctx, request); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unsupported network: got=%v want=%s", err, codes.InvalidArgument)
+	}
+}
+
+func TestBuildTxGasAndCallData(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	wallet := grpcapi.NewWallet(ethereum.NewAdapter(ethereum.Mainnet, 20, nil))
+	nonce := uint64(0)
+	request := &walletv1.BuildTxRequest{From: "0x00000000000000000000000000000000000000bb", To: "0x00000000000000000000000000000000000000cc", AmountDecimal: "1000", FeeHint: &walletv1.FeeHint{MaxFeePerGas: 30, MaxPriorityFee: 2}, ChainId: 1, Nonce: &nonce}
+
+	built, err := wallet.BuildTx(ctx, request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if built.GasLimit != 21_000 || built.Tx.GasLimit != 21_000 || built.MaxCostDecimal != "631000" || built.Decimals != 18 {
+		t.Fatalf("got gas=%d maxCost=%s decimals=%d", built.GasLimit, built.MaxCostDecimal, built.Decimals)
+	}
+
+	request.AmountDecimal = "1.5"
+
+	if _, err := wallet.BuildTx(ctx, request); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("fractional amount: got=%v want=%s", err, codes.InvalidArgument)
+	}
+
+	request.AmountDecimal = "1000"
+	request.Data = []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+	// Without a node there is nothing to estimate the call's gas with.
+	if _, err := wallet.BuildTx(ctx, request); !errors.Is(err, ethereum.ErrNoRPC) {
+		t.Fatalf("got err=%v, want ErrNoRPC", err)
+	}
+
+	if _, err := grpcapi.NewWallet(bitcoin.NewAdapter(bitcoin.RegTest, relaySource{})).BuildTx(ctx, request); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unsupported network: got=%v want=%s", err, codes.InvalidArgument)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	wallet := grpcapi.NewWallet(ethereum.NewAdapter(ethereum.Mainnet, 20, ethereum.MainnetTokens))
+	listed, err := wallet.ListTokens(ctx, &walletv1.ListTokensRequest{})
+
+	if err != nil || len(listed.Tokens) != len(ethereum.MainnetTokens) {
+		t.Fatalf("got=%v err=%v", listed, err)
+	}
+
+	holder := "0x00000000000000000000000000000000000000bb"
+	balance, err := wallet.Balance(ctx, &walletv1.BalanceRequest{Addr: holder, Token: "usdc"})
+
+	if err != nil || balance.Token.GetSymbol() != "USDC" || balance.Token.GetDecimals() != 6 {
+		t.Fatalf("got=%v err=%v", balance, err)
+	}
+
+	request := &walletv1.BuildTxRequest{From: holder, To: holder, AmountDecimal: "1", FeeHint: &walletv1.FeeHint{}, Token: "NOPE"}
+
+	if _, err := wallet.BuildTx(ctx, request); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unknown token: got=%v want=%s", err, codes.InvalidArgument)
+	}
+
+	if _, err := grpcapi.NewWallet(bitcoin.NewAdapter(bitcoin.RegTest, nil)).ListTokens(ctx, &walletv1.ListTokensRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unsupported network: got=%v want=%s", err, codes.InvalidArgument)
+	}
+}
+
+func TestDescriptorWallet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	wallet := grpcapi.NewWallet(bitcoin.NewAdapter(bitcoin.RegTest, relaySource{}))
+	first, _ := wallet.NewKey(ctx, &walletv1.NewKeyRequest{Seed: []byte("first")})
+	second, _ := wallet.NewKey(ctx, &walletv1.NewKeyRequest{Seed: []byte("second")})
+
+	imported, err := wallet.ImportDescriptor(ctx, &walletv1.ImportDescriptorRequest{Descriptor_: "wsh(sortedmulti(1," + first.Pub + "," + second.Pub + "))"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(imported.Descriptor_, "#") {
+		t.Fatalf("expected the checksum to be added, got %s", imported.Descriptor_)
+	}
+
+	addresses, err := wallet.DescriptorAddresses(ctx, &walletv1.DescriptorAddressesRequest{Descriptor_: imported.Descriptor_, Count: 1})
+
+	if err != nil || len(addresses.Addresses) != 1 || !strings.HasPrefix(addresses.Addresses[0], "bcrt1q") {
+		t.Fatalf("got addresses=%v err=%v", addresses, err)
+	}
+
+	balance, err := wallet.DescriptorBalance(ctx, &walletv1.DescriptorBalanceRequest{Descriptor_: imported.Descriptor_})
+
+	if err != nil || balance.BalanceDecimal != "100000" || balance.Decimals != 8 {
+		t.Fatalf("got balance=%v err=%v", balance, err)
+	}
+
+	built, err := wallet.BuildTx(ctx, &walletv1.BuildTxRequest{FromDescriptor: imported.Descriptor_, To: first.Addr, AmountDecimal: "30000", FeeHint: &walletv1.FeeHint{}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := wallet.SignTx(ctx, &walletv1.SignTxRequest{Priv: second.Priv, Tx: built.Tx})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wallet.Broadcast(ctx, &walletv1.BroadcastRequest{Signed: signed.Signed}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = grpcapi.NewWallet(ethereum.NewAdapter(ethereum.Mainnet, 20, nil)).ImportDescriptor(ctx, &walletv1.ImportDescriptorRequest{Descriptor_: imported.Descriptor_})
+
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("unsupported network: got=%s want=%s", got, codes.InvalidArgument)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
+	adminv1 "github.com/afrodynamic/gochain/api/proto/admin/v1"
 	chainv1 "github.com/afrodynamic/gochain/api/proto/chain/v1"
 	walletv1 "github.com/afrodynamic/gochain/api/proto/wallet/v1"
 )
@@ -65,6 +66,10 @@ func NewMux(grpcAddress string) (http.Handler, error) {
 		return nil, err
 	}
 
+	if err := adminv1.RegisterAdminHandlerFromEndpoint(baseContext, gatewayMux, grpcAddress, grpcOptions); err != nil {
+		return nil, err
+	}
+
 	mainMux := http.NewServeMux()
 	mainMux.Handle("/", gatewayMux)
 	mainMux.Handle("/health", newHealthHandler(grpcAddress))
@@ -15,6 +15,8 @@ import (
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/afrodynamic/gochain/api/internal/core"
+	adminv1 "github.com/afrodynamic/gochain/api/proto/admin/v1"
 	chainv1 "github.com/afrodynamic/gochain/api/proto/chain/v1"
 	walletv1 "github.com/afrodynamic/gochain/api/proto/wallet/v1"
 )
@@ -48,22 +50,35 @@ func enableCORS(next http.Handler) http.Handler {
 	})
 }
 
-func healthHandler() http.HandlerFunc {
+func healthHandler(syncReporter core.SyncReporter) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, _ *http.Request) {
 		responseWriter.Header().Set("Content-Type", "application/json")
 
-		_ = json.NewEncoder(responseWriter).Encode(map[string]any{
+		body := map[string]any{
 			"status":    "ok",
 			"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
-		})
+		}
+
+		if syncReporter != nil {
+			if status := syncReporter.SyncStatus(); status.Syncing {
+				body["status"] = "syncing"
+				body["currentHeight"] = status.CurrentHeight
+				body["targetHeight"] = status.TargetHeight
+			}
+		}
+
+		_ = json.NewEncoder(responseWriter).Encode(body)
 	}
 }
 
-func NewHandler(chainService chainv1.ChainServer, walletService walletv1.WalletServer) (http.Handler, *grpc.Server, error) {
+// NewHandler serves the APIs on a single handler. When a sync reporter is
+// given, /health reports "syncing" until the node has caught up.
+func NewHandler(chainService chainv1.ChainServer, walletService walletv1.WalletServer, adminService adminv1.AdminServer, syncReporter core.SyncReporter) (http.Handler, *grpc.Server, error) {
 	grpcServer := grpc.NewServer()
 
 	chainv1.RegisterChainServer(grpcServer, chainService)
 	walletv1.RegisterWalletServer(grpcServer, walletService)
+	adminv1.RegisterAdminServer(grpcServer, adminService)
 
 	healthServer := health.NewServer()
 	healthpb.RegisterHealthServer(grpcServer, healthServer)
@@ -82,8 +97,12 @@ func NewHandler(chainService chainv1.ChainServer, walletService walletv1.WalletS
 		return nil, nil, err
 	}
 
+	if err := adminv1.RegisterAdminHandlerServer(baseContext, gatewayMux, adminService); err != nil {
+		return nil, nil, err
+	}
+
 	rootMux := http.NewServeMux()
-	rootMux.Handle("/health", healthHandler())
+	rootMux.Handle("/health", healthHandler(syncReporter))
 	rootMux.Handle("/", http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
 		contentType := request.Header.Get("Content-Type")
 
@@ -0,0 +1,628 @@
+package blocksync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/core"
+	"github.com/afrodynamic/gochain/api/internal/p2p"
+)
+
+// Chain is the part of the blockchain the sync manager reads headers and
+// bodies from and imports downloaded blocks into.
+type Chain interface {
+	GetBlock(height uint64) (core.Block, error)
+	ImportBlock(block core.Block) error
+	NodeInfo() core.NodeInfo
+}
+
+type Config struct {
+	HeaderBatch    int
+	BodyBatch      int
+	MaxParallel    int
+	RequestTimeout time.Duration
+	Interval       time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		HeaderBatch:    256,
+		BodyBatch:      32,
+		MaxParallel:    4,
+		RequestTimeout: 10 * time.Second,
+		Interval:       2 * time.Second,
+	}
+}
+
+var errRequestTimeout = errors.New("request timed out")
+
+type request struct {
+	peer     string
+	response chan json.RawMessage
+}
+
+// Manager brings the chain up to the best height advertised by peers. Each
+// round first downloads and validates a window of headers from the best peer,
+// then fetches the matching bodies from every peer that has them in parallel
+// and imports the blocks in order.
+type Manager struct {
+	config      Config
+	chain       Chain
+	engine      consensus.Engine
+	network     *p2p.Server
+	mutex       sync.Mutex
+	syncing     bool
+	startHeight uint64
+	target      uint64
+	startedAt   time.Time
+	nextRequest uint64
+	requests    map[uint64]request
+	stopped     chan struct{}
+	stopOnce    sync.Once
+	wait        sync.WaitGroup
+}
+
+// New registers the sync protocol on the network, so it must be called before
+// the network is started.
+func New(config Config, chain Chain, engine consensus.Engine, network *p2p.Server) *Manager {
+	defaults := DefaultConfig()
+
+	if config.HeaderBatch <= 0 {
+		config.HeaderBatch = defaults.HeaderBatch
+	}
+
+	if config.BodyBatch <= 0 {
+		config.BodyBatch = defaults.BodyBatch
+	}
+
+	if config.MaxParallel <= 0 {
+		config.MaxParallel = defaults.MaxParallel
+	}
+
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = defaults.RequestTimeout
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+
+	manager := &Manager{
+		config:   config,
+		chain:    chain,
+		engine:   engine,
+		network:  network,
+		requests: make(map[uint64]request),
+		stopped:  make(chan struct{}),
+	}
+
+	network.Handle(MessageGetHeaders, manager.handleGetHeaders)
+	network.Handle(MessageHeaders, manager.handleResponse)
+	network.Handle(MessageGetBodies, manager.handleGetBodies)
+	network.Handle(MessageBodies, manager.handleResponse)
+
+	return manager
+}
+
+func (manager *Manager) Start() error {
+	manager.wait.Add(1)
+
+	go manager.loop()
+
+	return nil
+}
+
+func (manager *Manager) Stop() error {
+	manager.stopOnce.Do(func() { close(manager.stopped) })
+	manager.wait.Wait()
+
+	return nil
+}
+
+func (manager *Manager) SyncStatus() core.SyncStatus {
+	current := manager.chain.NodeInfo().Height
+	peers := len(manager.network.Peers())
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	if !manager.syncing || current >= manager.target {
+		return core.SyncStatus{StartHeight: current, CurrentHeight: current, TargetHeight: current, Peers: peers}
+	}
+
+	status := core.SyncStatus{
+		Syncing:       true,
+		StartHeight:   manager.startHeight,
+		CurrentHeight: current,
+		TargetHeight:  manager.target,
+		Peers:         peers,
+	}
+
+	if imported := current - min(current, manager.startHeight); imported > 0 {
+		elapsed := time.Since(manager.startedAt)
+		status.ETA = time.Duration(float64(elapsed) / float64(imported) * float64(manager.target-current))
+	}
+
+	return status
+}
+
+func (manager *Manager) loop() {
+	defer manager.wait.Done()
+
+	ticker := time.NewTicker(manager.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := manager.syncOnce(); err != nil {
+			log.Printf("sync: %v", err)
+		}
+
+		select {
+		case <-manager.stopped:
+			return
+
+		case <-ticker.C:
+		}
+	}
+}
+
+func (manager *Manager) syncOnce() error {
+	local := manager.chain.NodeInfo().Height
+	best, exists := manager.bestPeer()
+
+	if !exists || best.Height <= local {
+		manager.finish()
+
+		return nil
+	}
+
+	manager.begin(local, best.Height)
+
+	headers, err := manager.fetchHeaders(best, local)
+
+	if err != nil {
+		return err
+	}
+
+	return manager.fetchBodies(headers)
+}
+
+func (manager *Manager) bestPeer() (p2p.PeerInfo, bool) {
+	var best p2p.PeerInfo
+	exists := false
+
+	for _, peer := range manager.network.Peers() {
+		if !exists || peer.Height > best.Height {
+			best = peer
+			exists = true
+		}
+	}
+
+	return best, exists
+}
+
+func (manager *Manager) begin(local, target uint64) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	if !manager.syncing {
+		manager.syncing = true
+		manager.startHeight = local
+		manager.startedAt = time.Now()
+	}
+
+	manager.target = max(manager.target, target)
+}
+
+func (manager *Manager) finish() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.syncing = false
+	manager.target = 0
+}
+
+func (manager *Manager) raiseTarget(target uint64) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.target = max(manager.target, target)
+}
+
+// fetchHeaders downloads a window of headers above the last block the local
+// chain shares with the best peer and checks that they link up and carry a
+// valid seal.
+func (manager *Manager) fetchHeaders(best p2p.PeerInfo, local uint64) ([]core.Block, error) {
+	previous, err := manager.commonAncestor(best.ID, local)
+
+	if err != nil {
+		manager.penalizeFailure(best.ID, err)
+
+		return nil, fmt.Errorf("headers from peer %.12s: %w", best.ID, err)
+	}
+
+	target := best.Height
+	window := manager.config.HeaderBatch * manager.config.MaxParallel
+	headers := make([]core.Block, 0, min(uint64(window), target-previous.Height))
+
+	for previous.Height < target && len(headers) < window {
+		count := min(uint64(manager.config.HeaderBatch), target-previous.Height)
+		response, err := manager.requestHeaders(best.ID, previous.Height+1, count)
+
+		if err != nil {
+			manager.penalizeFailure(best.ID, err)
+
+			return nil, fmt.Errorf("headers from peer %.12s: %w", best.ID, err)
+		}
+
+		if peer, exists := manager.network.Peer(best.ID); exists {
+			peer.ObserveHeight(response.Height)
+		}
+
+		if len(response.Headers) == 0 {
+			break
+		}
+
+		for _, header := range response.Headers {
+			if err := manager.checkHeader(previous, header); err != nil {
+				err = fmt.Errorf("header %d: %w", header.Height, err)
+				manager.network.Penalize(best.ID, p2p.OffenceInvalidBlock, err)
+
+				return nil, fmt.Errorf("peer %.12s: %w", best.ID, err)
+			}
+
+			headers = append(headers, header)
+			previous = header
+		}
+
+		target = max(target, response.Height)
+		manager.raiseTarget(target)
+	}
+
+	return headers, nil
+}
+
+// commonAncestor finds a local block the peer's chain also has, stepping back
+// from the head in growing steps, so a peer on another branch is synced from
+// where the branches split. Peers share the genesis block.
+func (manager *Manager) commonAncestor(peerID string, local uint64) (core.Block, error) {
+	for step := uint64(0); ; step = max(1, step*2) {
+		height := local - min(step, local)
+		ours, err := manager.chain.GetBlock(height)
+
+		if err != nil || height == 0 {
+			return ours, err
+		}
+
+		response, err := manager.requestHeaders(peerID, height, 1)
+
+		if err != nil {
+			return core.Block{}, err
+		}
+
+		if len(response.Headers) == 1 && bytes.Equal(response.Headers[0].Hash, ours.Hash) {
+			return ours, nil
+		}
+	}
+}
+
+func (manager *Manager) requestHeaders(peerID string, from, count uint64) (headersMessage, error) {
+	requestID, responses := manager.register(peerID)
+	response := headersMessage{}
+
+	if err := manager.send(peerID, MessageGetHeaders, getHeadersMessage{RequestID: requestID, From: from, Count: uint32(count)}); err != nil {
+		manager.unregister(requestID)
+
+		return response, err
+	}
+
+	err := manager.await(requestID, responses, &response)
+
+	return response, err
+}
+
+func (manager *Manager) checkHeader(previous, header core.Block) error {
+	if header.Height != previous.Height+1 {
+		return fmt.Errorf("expected height %d", previous.Height+1)
+	}
+
+	if !bytes.Equal(header.PrevHash, previous.Hash) {
+		return errors.New("does not link to the previous header")
+	}
+
+	if len(header.Transactions) != 0 {
+		return errors.New("header carries transactions")
+	}
+
+	return manager.engine.Validate(header)
+}
+
+type bodiesResult struct {
+	index  int
+	bodies [][]core.Transaction
+	err    error
+}
+
+// fetchBodies downloads the bodies for the headers in chunks spread across the
+// peers that have them, and imports each chunk as soon as every chunk before
+// it has been imported.
+func (manager *Manager) fetchBodies(headers []core.Block) error {
+	chunks := make([][]core.Block, 0, len(headers)/manager.config.BodyBatch+1)
+
+	for start := 0; start < len(headers); start += manager.config.BodyBatch {
+		chunks = append(chunks, headers[start:min(start+manager.config.BodyBatch, len(headers))])
+	}
+
+	results := make(chan bodiesResult, len(chunks))
+	slots := make(chan struct{}, manager.config.MaxParallel)
+
+	for index, chunk := range chunks {
+		go func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			bodies, err := manager.fetchChunk(index, chunk)
+			results <- bodiesResult{index: index, bodies: bodies, err: err}
+		}()
+	}
+
+	ready := make(map[int][][]core.Transaction)
+	next := 0
+	var firstErr error
+
+	for range chunks {
+		result := <-results
+
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+			}
+
+			continue
+		}
+
+		ready[result.index] = result.bodies
+
+		for firstErr == nil {
+			bodies, exists := ready[next]
+
+			if !exists {
+				break
+			}
+
+			delete(ready, next)
+			firstErr = manager.importChunk(chunks[next], bodies)
+			next++
+		}
+	}
+
+	return firstErr
+}
+
+// fetchChunk asks the peers that have the chunk in turn, starting at a
+// different peer for each chunk so downloads are spread across the network.
+func (manager *Manager) fetchChunk(index int, chunk []core.Block) ([][]core.Transaction, error) {
+	last := chunk[len(chunk)-1].Height
+	candidates := make([]p2p.PeerInfo, 0)
+
+	for _, peer := range manager.network.Peers() {
+		if peer.Height >= last {
+			candidates = append(candidates, peer)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no peer has block %d", last)
+	}
+
+	var lastErr error
+
+	for attempt := range candidates {
+		peer := candidates[(index+attempt)%len(candidates)]
+		bodies, err := manager.requestBodies(peer.ID, chunk)
+
+		if err == nil {
+			return bodies, nil
+		}
+
+		manager.penalizeFailure(peer.ID, err)
+
+		lastErr = fmt.Errorf("bodies from peer %.12s: %w", peer.ID, err)
+	}
+
+	return nil, lastErr
+}
+
+func (manager *Manager) requestBodies(peerID string, chunk []core.Block) ([][]core.Transaction, error) {
+	heights := make([]uint64, 0, len(chunk))
+
+	for _, header := range chunk {
+		heights = append(heights, header.Height)
+	}
+
+	requestID, responses := manager.register(peerID)
+	response := bodiesMessage{}
+
+	if err := manager.send(peerID, MessageGetBodies, getBodiesMessage{RequestID: requestID, Heights: heights}); err != nil {
+		manager.unregister(requestID)
+
+		return nil, err
+	}
+
+	if err := manager.await(requestID, responses, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Bodies) != len(chunk) {
+		return nil, p2p.Misbehaving(p2p.OffenceInvalidBlock, fmt.Errorf("got %d bodies for %d headers", len(response.Bodies), len(chunk)))
+	}
+
+	for i, body := range response.Bodies {
+		if !bytes.Equal(core.TxRoot(body), chunk[i].TxRoot) {
+			return nil, p2p.Misbehaving(p2p.OffenceInvalidBlock, fmt.Errorf("body %d does not match its header", chunk[i].Height))
+		}
+	}
+
+	return response.Bodies, nil
+}
+
+// penalizeFailure holds timeouts and invalid responses against the peer that
+// served them. A peer that simply disconnected is not penalised.
+func (manager *Manager) penalizeFailure(peerID string, err error) {
+	if errors.Is(err, errRequestTimeout) {
+		manager.network.Penalize(peerID, p2p.OffenceTimeout, err)
+
+		return
+	}
+
+	if offence, misbehaved := p2p.OffenceOf(err); misbehaved {
+		manager.network.Penalize(peerID, offence, err)
+	}
+}
+
+// importChunk imports the blocks in order. The chain moves an engine that
+// tracks the height being decided past each.
+func (manager *Manager) importChunk(chunk []core.Block, bodies [][]core.Transaction) error {
+	for i, header := range chunk {
+		block := header
+		block.Transactions = bodies[i]
+
+		if err := manager.chain.ImportBlock(block); err != nil && !errors.Is(err, gochain.ErrKnownBlock) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (manager *Manager) register(peerID string) (uint64, chan json.RawMessage) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.nextRequest++
+	responses := make(chan json.RawMessage, 1)
+	manager.requests[manager.nextRequest] = request{peer: peerID, response: responses}
+
+	return manager.nextRequest, responses
+}
+
+func (manager *Manager) unregister(requestID uint64) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	delete(manager.requests, requestID)
+}
+
+func (manager *Manager) send(peerID, messageType string, payload any) error {
+	peer, exists := manager.network.Peer(peerID)
+
+	if !exists {
+		return errors.New("peer disconnected")
+	}
+
+	return peer.Send(messageType, payload)
+}
+
+func (manager *Manager) await(requestID uint64, responses chan json.RawMessage, response any) error {
+	defer manager.unregister(requestID)
+
+	timer := time.NewTimer(manager.config.RequestTimeout)
+	defer timer.Stop()
+
+	select {
+	case payload := <-responses:
+		if err := json.Unmarshal(payload, response); err != nil {
+			return p2p.Misbehaving(p2p.OffenceInvalidMessage, err)
+		}
+
+		return nil
+
+	case <-timer.C:
+		return errRequestTimeout
+
+	case <-manager.stopped:
+		return errors.New("sync stopped")
+	}
+}
+
+func (manager *Manager) handleResponse(peer *p2p.Peer, payload json.RawMessage) error {
+	var envelope struct {
+		RequestID uint64 `json:"requestId"`
+	}
+
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return p2p.Misbehaving(p2p.OffenceInvalidMessage, err)
+	}
+
+	manager.mutex.Lock()
+	pending, exists := manager.requests[envelope.RequestID]
+	manager.mutex.Unlock()
+
+	if !exists || pending.peer != peer.ID() {
+		return errors.New("unsolicited response")
+	}
+
+	select {
+	case pending.response <- payload:
+	default:
+	}
+
+	return nil
+}
+
+func (manager *Manager) handleGetHeaders(peer *p2p.Peer, payload json.RawMessage) error {
+	var message getHeadersMessage
+
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return p2p.Misbehaving(p2p.OffenceInvalidMessage, err)
+	}
+
+	height := manager.chain.NodeInfo().Height
+	count := min(uint64(message.Count), maxServedHeaders)
+	headers := make([]core.Block, 0, count)
+
+	for current := message.From; current < message.From+count && current <= height; current++ {
+		block, err := manager.chain.GetBlock(current)
+
+		if err != nil {
+			break
+		}
+
+		headers = append(headers, block.Header())
+	}
+
+	return peer.Send(MessageHeaders, headersMessage{RequestID: message.RequestID, Height: height, Headers: headers})
+}
+
+func (manager *Manager) handleGetBodies(peer *p2p.Peer, payload json.RawMessage) error {
+	var message getBodiesMessage
+
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return p2p.Misbehaving(p2p.OffenceInvalidMessage, err)
+	}
+
+	if len(message.Heights) > maxServedBodies {
+		return p2p.Misbehaving(p2p.OffenceInvalidMessage, fmt.Errorf("requested %d bodies, limit is %d", len(message.Heights), maxServedBodies))
+	}
+
+	bodies := make([][]core.Transaction, 0, len(message.Heights))
+
+	for _, height := range message.Heights {
+		block, err := manager.chain.GetBlock(height)
+
+		if err != nil {
+			break
+		}
+
+		bodies = append(bodies, block.Transactions)
+	}
+
+	return peer.Send(MessageBodies, bodiesMessage{RequestID: message.RequestID, Bodies: bodies})
+}
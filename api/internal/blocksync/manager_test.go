@@ -0,0 +1,218 @@
+package blocksync_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/blocksync"
+	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
+	"github.com/afrodynamic/gochain/api/internal/consensus/pow"
+	"github.com/afrodynamic/gochain/api/internal/p2p"
+	"github.com/afrodynamic/gochain/api/internal/testutil"
+)
+
+type testNode struct {
+	chain   *gochain.Chain
+	server  *p2p.Server
+	manager *blocksync.Manager
+}
+
+var testGenesis = testutil.Genesis("gochain-sync")
+
+var testConfig = blocksync.Config{
+	HeaderBatch:    8,
+	BodyBatch:      4,
+	MaxParallel:    3,
+	RequestTimeout: time.Second,
+	Interval:       20 * time.Millisecond,
+}
+
+func newChain(t *testing.T) *gochain.Chain {
+	t.Helper()
+
+	return testutil.NewChain(t, testGenesis)
+}
+
+func startNode(t *testing.T, label string, chain *gochain.Chain, staticPeers []string, handlers map[string]p2p.Handler) *testNode {
+	t.Helper()
+
+	seed := sha256.Sum256([]byte(label))
+	server, err := p2p.New(p2p.Config{
+		PrivateKey:    ed25519.NewKeyFromSeed(seed[:]),
+		ListenAddress: "127.0.0.1:0",
+		StaticPeers:   staticPeers,
+		DialInterval:  20 * time.Millisecond,
+	}, chain)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := blocksync.New(testConfig, chain, pow.New(testGenesis.Consensus.Difficulty), server)
+
+	for messageType, handler := range handlers {
+		server.Handle(messageType, handler)
+	}
+
+	chain.Subscribe(server)
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		manager.Stop()
+		server.Stop()
+	})
+
+	return &testNode{chain: chain, server: server, manager: manager}
+}
+
+func TestSyncFromMultiplePeers(t *testing.T) {
+	t.Parallel()
+
+	sourceChain := newChain(t)
+
+	for i := 0; i < 60; i++ {
+		sourceChain.Credit([]byte(fmt.Sprintf("account-%d", i%7)), uint64(i+1))
+	}
+
+	source := startNode(t, "source", sourceChain, nil, nil)
+	relay := startNode(t, "relay", newChain(t), []string{source.server.Addr()}, nil)
+
+	testutil.Eventually(t, "relay to sync", func() bool {
+		return relay.chain.NodeInfo().Height == 60
+	})
+
+	fresh := startNode(t, "fresh", newChain(t), []string{source.server.Addr(), relay.server.Addr()}, nil)
+
+	testutil.Eventually(t, "fresh node to sync", func() bool {
+		return fresh.chain.NodeInfo().Height == 60
+	})
+
+	for i := 0; i < 7; i++ {
+		address := []byte(fmt.Sprintf("account-%d", i))
+		want, _ := sourceChain.GetBalance(address)
+		got, _ := fresh.chain.GetBalance(address)
+
+		if got != want {
+			t.Fatalf("account %d: got=%d want=%d", i, got, want)
+		}
+	}
+
+	sourceHead, _ := sourceChain.GetBlock(60)
+	freshHead, _ := fresh.chain.GetBlock(60)
+
+	if !bytes.Equal(sourceHead.Hash, freshHead.Hash) {
+		t.Fatalf("got head %x, want %x", freshHead.Hash, sourceHead.Hash)
+	}
+
+	testutil.Eventually(t, "sync status to settle", func() bool {
+		status := fresh.manager.SyncStatus()
+
+		return !status.Syncing && status.CurrentHeight == 60 && status.TargetHeight == 60 && status.Peers == 2
+	})
+}
+
+func TestSyncSwitchesToTheLongerBranch(t *testing.T) {
+	t.Parallel()
+
+	// The chains share 3 blocks; the local one then seals 2 of its own while
+	// the peer seals 6 on another branch.
+	sourceChain, localChain := newChain(t), newChain(t)
+
+	for i := 0; i < 3; i++ {
+		sourceChain.Credit([]byte("shared"), 10)
+		block, _ := sourceChain.GetBlock(uint64(i + 1))
+
+		if err := localChain.ImportBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		sourceChain.Credit([]byte("source"), 10)
+	}
+
+	for i := 0; i < 2; i++ {
+		localChain.Credit([]byte("local"), 10)
+	}
+
+	source := startNode(t, "source", sourceChain, nil, nil)
+	local := startNode(t, "local", localChain, []string{source.server.Addr()}, nil)
+
+	sourceHead, _ := sourceChain.GetBlock(9)
+
+	testutil.Eventually(t, "local node to switch branches", func() bool {
+		head, err := local.chain.GetBlock(9)
+
+		return err == nil && bytes.Equal(head.Hash, sourceHead.Hash)
+	})
+
+	if balance, _ := local.chain.GetBalance([]byte("local")); balance != 0 {
+		t.Fatalf("got balance=%d from the abandoned branch, want=0", balance)
+	}
+
+	if bans := local.server.Bans(); len(bans) != 0 {
+		t.Fatalf("got bans %+v, want none", bans)
+	}
+}
+
+func TestSyncRejectsInvalidHeaders(t *testing.T) {
+	t.Parallel()
+
+	sourceChain := newChain(t)
+
+	for i := 0; i < 5; i++ {
+		sourceChain.Credit([]byte("account"), 10)
+	}
+
+	// The dishonest node advertises its real height but serves headers whose
+	// contents no longer match their proof of work.
+	forgeHeaders := func(peer *p2p.Peer, payload json.RawMessage) error {
+		var request struct {
+			RequestID uint64 `json:"requestId"`
+			From      uint64 `json:"from"`
+		}
+
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return err
+		}
+
+		headers := make([]any, 0)
+		previous, _ := sourceChain.GetBlock(request.From - 1)
+
+		for height := request.From; height <= 5; height++ {
+			block, _ := sourceChain.GetBlock(height)
+			header := block.Header()
+			header.Timestamp = header.Timestamp.Add(time.Hour)
+			header.PrevHash = previous.Hash
+			headers = append(headers, header)
+			previous = header
+		}
+
+		return peer.Send(blocksync.MessageHeaders, map[string]any{"requestId": request.RequestID, "height": 5, "headers": headers})
+	}
+
+	dishonest := startNode(t, "dishonest", sourceChain, nil, map[string]p2p.Handler{blocksync.MessageGetHeaders: forgeHeaders})
+	victim := startNode(t, "victim", newChain(t), []string{dishonest.server.Addr()}, nil)
+
+	testutil.Eventually(t, "victim to ban the dishonest node", func() bool {
+		bans := victim.server.Bans()
+
+		return len(bans) == 1 && bans[0].Target == dishonest.server.ID() && len(victim.server.Peers()) == 0
+	})
+
+	if height := victim.chain.NodeInfo().Height; height != 0 {
+		t.Fatalf("got height=%d, want 0 after forged headers", height)
+	}
+}
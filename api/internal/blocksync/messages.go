@@ -0,0 +1,39 @@
+package blocksync
+
+import "github.com/afrodynamic/gochain/api/internal/core"
+
+const (
+	MessageGetHeaders = "get_headers"
+	MessageHeaders    = "headers"
+	MessageGetBodies  = "get_bodies"
+	MessageBodies     = "bodies"
+)
+
+const (
+	maxServedHeaders = 512
+	maxServedBodies  = 128
+)
+
+type getHeadersMessage struct {
+	RequestID uint64 `json:"requestId"`
+	From      uint64 `json:"from"`
+	Count     uint32 `json:"count"`
+}
+
+// headersMessage also reports the responder's height, which keeps the sync
+// target current while headers are being fetched.
+type headersMessage struct {
+	RequestID uint64       `json:"requestId"`
+	Height    uint64       `json:"height"`
+	Headers   []core.Block `json:"headers"`
+}
+
+type getBodiesMessage struct {
+	RequestID uint64   `json:"requestId"`
+	Heights   []uint64 `json:"heights"`
+}
+
+type bodiesMessage struct {
+	RequestID uint64               `json:"requestId"`
+	Bodies    [][]core.Transaction `json:"bodies"`
+}
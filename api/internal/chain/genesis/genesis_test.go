@@ -0,0 +1,67 @@
+package genesis
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashIsStableAcrossSaveAndLoad(t *testing.T) {
+	t.Parallel()
+
+	original := Default("bft", bytes.Repeat([]byte{1}, 32))
+	original.Alloc = map[string]uint64{"aa": 5, "bb": 7}
+	path := filepath.Join(t.TempDir(), "genesis.json")
+
+	if err := original.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(original.Hash(), loaded.Hash()) {
+		t.Fatalf("hash changed after round trip: %x != %x", original.Hash(), loaded.Hash())
+	}
+
+	if time.Duration(loaded.Consensus.BlockTime) != 5*time.Second {
+		t.Fatalf("unexpected block time: %s", time.Duration(loaded.Consensus.BlockTime))
+	}
+}
+
+func TestHashCommitsToEngine(t *testing.T) {
+	t.Parallel()
+
+	if bytes.Equal(Default("pow", nil).Hash(), Default("pos", nil).Hash()) {
+		t.Fatal("expected different engines to produce different genesis hashes")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		genesis Genesis
+		wantErr bool
+	}{
+		{name: "default", genesis: Default("pow", nil)},
+		{name: "missing chain id", genesis: Genesis{Consensus: Consensus{Engine: "pow"}}, wantErr: true},
+		{name: "missing engine", genesis: Genesis{ChainID: "test"}, wantErr: true},
+		{name: "bad validator key", genesis: Genesis{ChainID: "test", Consensus: Consensus{Engine: "bft", Validators: []Validator{{PublicKey: "zz", Power: 1}}}}, wantErr: true},
+		{name: "zero power", genesis: Genesis{ChainID: "test", Consensus: Consensus{Engine: "bft", Validators: []Validator{{PublicKey: "0101010101010101010101010101010101010101010101010101010101010101", Power: 0}}}}, wantErr: true},
+		{name: "bad alloc", genesis: Genesis{ChainID: "test", Consensus: Consensus{Engine: "pow"}, Alloc: map[string]uint64{"not-hex": 1}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.genesis.Validate()
+
+		if (err != nil) != test.wantErr {
+			t.Fatalf("%s: got error %v, wantErr %v", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package gochain
 
 import (
-	"crypto/sha256"
-	"encoding/binary"
+	"bytes"
 	"errors"
+	"fmt"
 	"log"
+	"math/big"
 	"sync"
 	"time"
 
@@ -13,22 +14,65 @@ import (
 	"github.com/afrodynamic/gochain/api/internal/storage/pebble"
 )
 
+const (
+	maxPendingTransactions = 10000
+
+	// Side branches forking more than maxReorgDepth blocks below the head are
+	// forgotten, and at most maxSideBlocks blocks are kept off the main chain.
+	maxReorgDepth = 64
+	maxSideBlocks = 1024
+)
+
+var (
+	ErrKnownBlock         = errors.New("block already known")
+	ErrUnknownParent      = errors.New("block does not extend the local head")
+	ErrConflictingBlock   = errors.New("block conflicts with the local chain")
+	ErrKnownTransaction   = errors.New("transaction already known")
+	ErrUnknownTransaction = core.ErrUnknownTransaction
+)
+
+// Listener is notified after a block or pending transaction has been accepted,
+// whether it was produced locally or received from a peer. Listeners run on
+// the caller's goroutine and must not block.
+type Listener interface {
+	BlockAdded(block core.Block)
+	TransactionAdded(tx core.Transaction)
+}
+
 type Chain struct {
-	mutex  sync.RWMutex
-	store  *pebble.Store
-	engine consensus.Engine
+	mutex     sync.RWMutex
+	sealMutex sync.Mutex
+	store     *pebble.Store
+	engine    consensus.Engine
+	schedule  core.Schedule
+	pending   []core.Transaction
+	side      map[string]core.Block
+	listeners []Listener
 }
 
-func New(engine consensus.Engine, store *pebble.Store) *Chain {
-	return &Chain{store: store, engine: engine}
+func New(engine consensus.Engine, store *pebble.Store) (*Chain, error) {
+	schedule, err := store.Genesis.Schedule()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chain{store: store, engine: engine, schedule: schedule, side: make(map[string]core.Block)}, nil
 }
 
 func (chain *Chain) Start() error {
-	return nil
+	return chain.engine.Start()
 }
 
 func (chain *Chain) Stop() error {
-	return nil
+	return chain.engine.Stop()
+}
+
+func (chain *Chain) Subscribe(listener Listener) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+
+	chain.listeners = append(chain.listeners, listener)
 }
 
 func (chain *Chain) GetBlock(height uint64) (core.Block, error) {
@@ -62,101 +106,131 @@ func (chain *Chain) ListBlocks(limit uint64) ([]core.Block, error) {
 	return result, nil
 }
 
+// SubmitTx adds the transaction to the mempool, announces it and then produces
+// a block with every pending transaction. If the block could not be produced
+// the transaction stays pending, so another node may still include it.
 func (chain *Chain) SubmitTx(tx core.Tx) (core.Transaction, error) {
+	if tx.Amount == 0 {
+		return core.Transaction{}, errors.New("amount must be positive")
+	}
+
+	transaction := core.Transaction{
+		From:      append([]byte(nil), tx.From...),
+		To:        append([]byte(nil), tx.To...),
+		Amount:    tx.Amount,
+		Fee:       tx.Fee,
+		Data:      append([]byte(nil), tx.Data...),
+		Timestamp: time.Now().UTC(),
+		Status:    core.TxStatusPending,
+	}
+
 	chain.mutex.Lock()
-	defer chain.mutex.Unlock()
 
 	if len(chain.store.Blocks) == 0 {
+		chain.mutex.Unlock()
+
 		return core.Transaction{}, errors.New("chain not initialised")
 	}
 
-	if tx.Amount == 0 {
-		return core.Transaction{}, errors.New("amount must be positive")
+	height := chain.head().Height + 1
+	fork := chain.schedule.At(height)
+
+	if err := fork.Rules.CheckTx(tx); err != nil {
+		chain.mutex.Unlock()
+
+		return core.Transaction{}, fmt.Errorf("rules %q at height %d: %w", fork.Name, height, err)
 	}
 
-	fromKey := string(tx.From)
-	toKey := string(tx.To)
-	totalDebit := tx.Amount + tx.Fee
+	transaction.Nonce = chain.pendingState().nonce(string(tx.From))
+	transaction.Hash = transaction.ComputeHash()
 
-	balance := chain.store.Balances[fromKey]
+	err := chain.addPending(transaction)
+	listeners := chain.listeners
+	chain.mutex.Unlock()
 
-	if balance < totalDebit {
-		return core.Transaction{}, errors.New("insufficient balance")
+	if err != nil {
+		return core.Transaction{}, err
 	}
 
-	chain.store.Balances[fromKey] = balance - totalDebit
-	chain.store.Balances[toKey] += tx.Amount
+	for _, listener := range listeners {
+		listener.TransactionAdded(transaction)
+	}
 
-	nonce := chain.store.Nonces[fromKey]
-	chain.store.Nonces[fromKey] = nonce + 1
+	block, err := chain.produceBlock()
 
-	previousBlock := chain.store.Blocks[len(chain.store.Blocks)-1]
-	height := previousBlock.Height + 1
-	timestamp := time.Now().UTC()
+	if err != nil {
+		return core.Transaction{}, err
+	}
 
-	txHashInput := make([]byte, 0, len(tx.From)+len(tx.To)+32)
-	txHashInput = append(txHashInput, tx.From...)
-	txHashInput = append(txHashInput, tx.To...)
+	for _, included := range block.Transactions {
+		if bytes.Equal(included.Hash, transaction.Hash) {
+			return included, nil
+		}
+	}
 
-	amountBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(amountBytes, tx.Amount)
-	txHashInput = append(txHashInput, amountBytes...)
+	return transaction, nil
+}
 
-	feeBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(feeBytes, tx.Fee)
-	txHashInput = append(txHashInput, feeBytes...)
+// ImportBlock validates a block received from a peer with the consensus engine
+// and the fork rules, and appends it when it extends the local head. Unless
+// the engine's blocks are final, a block on another branch is kept and the
+// chain switches to that branch once it outweighs the local one.
+func (chain *Chain) ImportBlock(block core.Block) error {
+	if err := chain.engine.Validate(block); err != nil {
+		return fmt.Errorf("block %d: %w", block.Height, err)
+	}
 
-	nonceBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(nonceBytes, nonce)
-	txHashInput = append(txHashInput, nonceBytes...)
+	chain.mutex.Lock()
+	added, err := chain.importBlock(block)
+	listeners := chain.listeners
+	chain.mutex.Unlock()
 
-	tsBytes := []byte(timestamp.Format(time.RFC3339Nano))
-	txHashInput = append(txHashInput, tsBytes...)
+	if err != nil {
+		return err
+	}
 
-	txHash := sha256.Sum256(txHashInput)
+	advancer, advances := chain.engine.(consensus.Advancer)
 
-	blockSeed := make([]byte, 0, len(previousBlock.Hash)+len(txHash)+len(tsBytes))
-	blockSeed = append(blockSeed, previousBlock.Hash...)
-	blockSeed = append(blockSeed, txHash[:]...)
-	blockSeed = append(blockSeed, tsBytes...)
+	for _, appended := range added {
+		// An engine deciding heights itself moves past a block committed
+		// without it, as deciding it would have, so it votes on the next one.
+		if advances {
+			advancer.Advance(appended)
+		}
 
-	newBlock := core.Block{
-		Hash:         blockSeed,
-		Height:       height,
-		PrevHash:     previousBlock.Hash,
-		Timestamp:    timestamp,
-		Transactions: nil,
+		for _, listener := range listeners {
+			listener.BlockAdded(appended)
+		}
 	}
 
-	recordedTx := core.Transaction{
-		Hash:        txHash[:],
-		From:        append([]byte(nil), tx.From...),
-		To:          append([]byte(nil), tx.To...),
-		Amount:      tx.Amount,
-		Fee:         tx.Fee,
-		Nonce:       nonce,
-		BlockHeight: height,
-		Timestamp:   timestamp,
-		Status:      core.TxStatusMined,
-	}
+	return nil
+}
 
-	sealedBlock, err := chain.engine.Seal(newBlock)
+// ValidateBlock checks a proposed block against the local head, the fork
+// rules and the state without checking its seal or appending it. The BFT
+// engine prevotes nil for a proposal that fails it.
+func (chain *Chain) ValidateBlock(block core.Block) error {
+	chain.mutex.RLock()
+	defer chain.mutex.RUnlock()
 
-	if err != nil {
-		return core.Transaction{}, err
+	if len(chain.store.Blocks) == 0 {
+		return errors.New("chain not initialised")
 	}
 
-	recordedTx.BlockHash = append([]byte(nil), sealedBlock.Hash...)
-	sealedBlock.Transactions = []core.Transaction{recordedTx}
+	if !bytes.Equal(block.Hash, block.SealHash()) {
+		return fmt.Errorf("block %d: hash does not match its contents", block.Height)
+	}
 
-	chain.store.Blocks = append(chain.store.Blocks, sealedBlock)
-	chain.store.Transactions = append(chain.store.Transactions, recordedTx)
+	_, _, err := chain.checkBlock(block)
 
-	if err := chain.store.Save(); err != nil {
-		return core.Transaction{}, err
-	}
+	return err
+}
 
-	return recordedTx, nil
+func (chain *Chain) PendingTransactions() []core.Transaction {
+	chain.mutex.RLock()
+	defer chain.mutex.RUnlock()
+
+	return append([]core.Transaction(nil), chain.pending...)
 }
 
 func (chain *Chain) ListTransactions(limit uint64) ([]core.Transaction, error) {
@@ -186,18 +260,93 @@ func (chain *Chain) GetBalance(address []byte) (uint64, error) {
 	return chain.store.Balances[string(address)], nil
 }
 
+// AccountProof proves the address's account against the state root of the
+// head block, whose height it returns alongside the proof.
+func (chain *Chain) AccountProof(address []byte) (uint64, core.StateProof, error) {
+	chain.mutex.RLock()
+	defer chain.mutex.RUnlock()
+
+	proof, err := core.BuildStateProof(newState(chain.store).accounts(), address)
+
+	return chain.head().Height, proof, err
+}
+
+// TransactionProof finds a mined transaction and proves its inclusion against
+// the transaction root of its block.
+func (chain *Chain) TransactionProof(hash []byte) (core.Transaction, core.MerkleProof, error) {
+	chain.mutex.RLock()
+	defer chain.mutex.RUnlock()
+
+	for i := len(chain.store.Transactions) - 1; i >= 0; i-- {
+		tx := chain.store.Transactions[i]
+
+		if !bytes.Equal(tx.Hash, hash) {
+			continue
+		}
+
+		block := chain.store.Blocks[tx.BlockHeight]
+		leaves := make([][]byte, 0, len(block.Transactions))
+		index := -1
+
+		for position, included := range block.Transactions {
+			leaves = append(leaves, included.Hash)
+
+			if bytes.Equal(included.Hash, hash) {
+				index = position
+			}
+		}
+
+		proof, err := core.BuildMerkleProof(leaves, index)
+
+		return tx, proof, err
+	}
+
+	return core.Transaction{}, core.MerkleProof{}, ErrUnknownTransaction
+}
+
+// Credit mints funds in a block of its own so that every node on the network
+// applies the same faucet credit. The credit must fit the mint allowance of
+// the rules, which every node checks.
 func (chain *Chain) Credit(address []byte, amount uint64) {
 	if amount == 0 {
 		return
 	}
 
-	chain.mutex.Lock()
-	defer chain.mutex.Unlock()
+	chain.mutex.RLock()
+	rules := chain.schedule.At(chain.head().Height + 1).Rules
+	chain.mutex.RUnlock()
 
-	chain.store.Balances[string(address)] += amount
+	if err := rules.CheckMint(0, amount); err != nil {
+		log.Printf("failed to credit %x: %v", address, err)
 
-	if err := chain.store.Save(); err != nil {
-		log.Printf("failed to persist credit operation: %v", err)
+		return
+	}
+
+	mint := core.Transaction{
+		To:        append([]byte(nil), address...),
+		Amount:    amount,
+		Timestamp: time.Now().UTC(),
+	}
+	mint.Hash = mint.ComputeHash()
+
+	if _, err := chain.produceBlock(mint); err != nil {
+		log.Printf("failed to credit %x: %v", address, err)
+	}
+}
+
+func (chain *Chain) NodeInfo() core.NodeInfo {
+	chain.mutex.RLock()
+	defer chain.mutex.RUnlock()
+
+	height := chain.head().Height
+
+	return core.NodeInfo{
+		ChainID:     chain.store.Genesis.ChainID,
+		GenesisHash: chain.store.Genesis.Hash(),
+		Consensus:   chain.engine.Name(),
+		Height:      height,
+		Rules:       chain.schedule.At(height + 1),
+		Forks:       chain.schedule.Forks(),
 	}
 }
 
@@ -207,3 +356,386 @@ func (chain *Chain) CurrentNonce(address []byte) uint64 {
 
 	return chain.store.Nonces[string(address)]
 }
+
+func (chain *Chain) head() core.Block {
+	return chain.store.Blocks[len(chain.store.Blocks)-1]
+}
+
+// produceBlock seals a block on top of the local head holding the extra
+// transactions followed by every pending transaction that is still valid.
+// Sealing runs without the chain lock so that reads and imports continue while
+// a block is being mined or agreed on.
+func (chain *Chain) produceBlock(extra ...core.Transaction) (core.Block, error) {
+	chain.sealMutex.Lock()
+	defer chain.sealMutex.Unlock()
+
+	chain.mutex.Lock()
+
+	if len(chain.store.Blocks) == 0 {
+		chain.mutex.Unlock()
+
+		return core.Block{}, errors.New("chain not initialised")
+	}
+
+	tip := chain.head()
+	candidate := core.Block{
+		Height:    tip.Height + 1,
+		PrevHash:  tip.Hash,
+		Timestamp: time.Now().UTC(),
+	}
+
+	rules := chain.schedule.At(candidate.Height).Rules
+	state := newState(chain.store)
+
+	for _, tx := range append(extra, chain.pending...) {
+		if err := state.apply(tx, rules); err == nil {
+			candidate.Transactions = append(candidate.Transactions, tx)
+		}
+	}
+
+	candidate.StateRoot = core.StateRoot(state.accounts())
+	chain.mutex.Unlock()
+
+	candidate.TxRoot = core.TxRoot(candidate.Transactions)
+	candidate.Hash = candidate.SealHash()
+	sealed, err := chain.engine.Seal(candidate)
+
+	if err != nil {
+		return core.Block{}, err
+	}
+
+	chain.mutex.Lock()
+	added, err := chain.importBlock(sealed)
+	listeners := chain.listeners
+	chain.mutex.Unlock()
+
+	if errors.Is(err, ErrKnownBlock) && len(added) == 1 {
+		return added[0], nil
+	}
+
+	if err != nil {
+		return core.Block{}, err
+	}
+
+	if len(added) == 0 {
+		return core.Block{}, fmt.Errorf("block %d was sealed on a branch that is not the heaviest", sealed.Height)
+	}
+
+	for _, appended := range added {
+		for _, listener := range listeners {
+			listener.BlockAdded(appended)
+		}
+	}
+
+	return added[len(added)-1], nil
+}
+
+// importBlock appends a sealed block to the head or hands it to fork choice,
+// and returns the blocks added to the main chain. The caller holds the write
+// lock and has already checked the seal.
+func (chain *Chain) importBlock(block core.Block) ([]core.Block, error) {
+	appended, err := chain.appendBlock(block)
+
+	switch {
+	case err == nil:
+		return []core.Block{appended}, nil
+
+	case errors.Is(err, ErrKnownBlock):
+		return []core.Block{appended}, err
+
+	case chain.engine.Finality() == consensus.FinalityInstant:
+		return nil, err
+
+	case errors.Is(err, ErrConflictingBlock), errors.Is(err, ErrUnknownParent):
+		return chain.chooseFork(block, err)
+	}
+
+	return nil, err
+}
+
+// chooseFork keeps a block that does not extend the head on a side branch
+// and reorganises onto the branch once it outweighs the main chain above
+// their common block. A block whose parent is unknown is refused with cause,
+// so sync can fetch the blocks before it.
+func (chain *Chain) chooseFork(block core.Block, cause error) ([]core.Block, error) {
+	if _, exists := chain.side[string(block.Hash)]; exists {
+		return nil, ErrKnownBlock
+	}
+
+	branch := []core.Block{block}
+
+	for {
+		first := branch[0]
+
+		if first.Height == 0 {
+			return nil, cause
+		}
+
+		if first.Height-1 < uint64(len(chain.store.Blocks)) && bytes.Equal(chain.store.Blocks[first.Height-1].Hash, first.PrevHash) {
+			break
+		}
+
+		parent, exists := chain.side[string(first.PrevHash)]
+
+		if !exists || parent.Height != first.Height-1 {
+			return nil, cause
+		}
+
+		branch = append([]core.Block{parent}, branch...)
+	}
+
+	fork := branch[0].Height - 1
+
+	if fork+maxReorgDepth < chain.head().Height || !chain.keepSide(block) {
+		return nil, cause
+	}
+
+	if chain.weight(branch).Cmp(chain.weight(chain.store.Blocks[fork+1:])) <= 0 {
+		return nil, nil
+	}
+
+	return chain.reorg(fork, branch)
+}
+
+// keepSide remembers a block off the main chain, first forgetting the ones
+// too deep below the head to reorganise onto.
+func (chain *Chain) keepSide(block core.Block) bool {
+	head := chain.head().Height
+
+	for hash, side := range chain.side {
+		if side.Height+maxReorgDepth < head {
+			delete(chain.side, hash)
+		}
+	}
+
+	if len(chain.side) >= maxSideBlocks {
+		return false
+	}
+
+	chain.side[string(block.Hash)] = block
+
+	return true
+}
+
+func (chain *Chain) weight(blocks []core.Block) *big.Int {
+	total := new(big.Int)
+	weigher, weighs := chain.engine.(consensus.Weigher)
+
+	for _, block := range blocks {
+		if weighs {
+			total.Add(total, weigher.Weight(block))
+		} else {
+			total.Add(total, big.NewInt(1))
+		}
+	}
+
+	return total
+}
+
+// reorg replaces the blocks above fork with the branch. The state is replayed
+// from genesis, which is simple rather than fast, and the transactions of the
+// abandoned blocks return to the mempool. If a branch block does not apply,
+// it and the blocks after it are forgotten and the chain is left as it was.
+func (chain *Chain) reorg(fork uint64, branch []core.Block) ([]core.Block, error) {
+	store := chain.store
+	blocks, transactions, balances, nonces := store.Blocks, store.Transactions, store.Balances, store.Nonces
+	pending := append([]core.Transaction(nil), chain.pending...)
+	abandoned := blocks[fork+1:]
+	kept := len(transactions)
+
+	for kept > 0 && transactions[kept-1].BlockHeight > fork {
+		kept--
+	}
+
+	store.Blocks = append([]core.Block(nil), blocks[:fork+1]...)
+	store.Transactions = append([]core.Transaction(nil), transactions[:kept]...)
+	store.Balances = make(map[string]uint64)
+	store.Nonces = make(map[string]uint64)
+
+	for _, account := range store.Genesis.Accounts() {
+		store.Balances[string(account.Address)] = account.Balance
+	}
+
+	for _, block := range store.Blocks[1:] {
+		replayed := newState(store)
+		rules := chain.schedule.At(block.Height).Rules
+
+		for _, tx := range block.Transactions {
+			replayed.apply(tx, rules)
+		}
+
+		replayed.commit()
+	}
+
+	added := make([]core.Block, 0, len(branch))
+
+	for i, block := range branch {
+		appended, err := chain.appendBlock(block)
+
+		if err != nil {
+			for _, invalid := range branch[i:] {
+				delete(chain.side, string(invalid.Hash))
+			}
+
+			store.Blocks, store.Transactions, store.Balances, store.Nonces, chain.pending = blocks, transactions, balances, nonces, pending
+
+			if saveErr := store.Save(); saveErr != nil {
+				return nil, errors.Join(err, saveErr)
+			}
+
+			return nil, err
+		}
+
+		delete(chain.side, string(block.Hash))
+		added = append(added, appended)
+	}
+
+	chain.pending = nil
+
+	for _, block := range abandoned {
+		chain.side[string(block.Hash)] = block
+
+		for _, tx := range block.Transactions {
+			if len(tx.From) == 0 {
+				continue
+			}
+
+			tx.BlockHash = nil
+			tx.BlockHeight = 0
+			tx.Status = core.TxStatusPending
+			chain.addPending(tx)
+		}
+	}
+
+	for _, tx := range pending {
+		chain.addPending(tx)
+	}
+
+	log.Printf("reorganised from block %d %x to %d %x", len(blocks)-1, blocks[len(blocks)-1].Hash, chain.head().Height, chain.head().Hash)
+
+	return added, nil
+}
+
+// appendBlock applies a sealed block on top of the head. The caller holds the
+// write lock and has already checked the seal.
+func (chain *Chain) appendBlock(block core.Block) (core.Block, error) {
+	tip := chain.head()
+
+	if block.Height <= tip.Height {
+		if bytes.Equal(chain.store.Blocks[block.Height].Hash, block.Hash) {
+			return chain.store.Blocks[block.Height], ErrKnownBlock
+		}
+
+		return core.Block{}, fmt.Errorf("%w: block %d", ErrConflictingBlock, block.Height)
+	}
+
+	state, recorded, err := chain.checkBlock(block)
+
+	if err != nil {
+		return core.Block{}, err
+	}
+
+	block.Transactions = recorded
+	state.commit()
+
+	chain.store.Blocks = append(chain.store.Blocks, block)
+	chain.store.Transactions = append(chain.store.Transactions, recorded...)
+	chain.prunePending()
+
+	if err := chain.store.Save(); err != nil {
+		return core.Block{}, err
+	}
+
+	return block, nil
+}
+
+// checkBlock checks that the block extends the head and that its
+// transactions apply under the fork rules to the state root it commits to. It
+// returns the resulting state, uncommitted, and the transactions as they are
+// recorded. The caller holds the lock.
+func (chain *Chain) checkBlock(block core.Block) (*state, []core.Transaction, error) {
+	tip := chain.head()
+
+	if block.Height != tip.Height+1 || !bytes.Equal(block.PrevHash, tip.Hash) {
+		return nil, nil, fmt.Errorf("%w: block %d, local height %d", ErrUnknownParent, block.Height, tip.Height)
+	}
+
+	if !bytes.Equal(block.TxRoot, core.TxRoot(block.Transactions)) {
+		return nil, nil, fmt.Errorf("block %d: transactions do not match the transaction root", block.Height)
+	}
+
+	fork := chain.schedule.At(block.Height)
+	state := newState(chain.store)
+	recorded := make([]core.Transaction, 0, len(block.Transactions))
+
+	for _, tx := range block.Transactions {
+		if !bytes.Equal(tx.Hash, tx.ComputeHash()) {
+			return nil, nil, fmt.Errorf("block %d: transaction %x hash does not match its contents", block.Height, tx.Hash)
+		}
+
+		if err := state.apply(tx, fork.Rules); err != nil {
+			return nil, nil, fmt.Errorf("block %d: transaction %x: rules %q: %w", block.Height, tx.Hash, fork.Name, err)
+		}
+
+		tx.BlockHash = append([]byte(nil), block.Hash...)
+		tx.BlockHeight = block.Height
+		tx.Status = core.TxStatusMined
+		recorded = append(recorded, tx)
+	}
+
+	if !bytes.Equal(block.StateRoot, core.StateRoot(state.accounts())) {
+		return nil, nil, fmt.Errorf("block %d: resulting state does not match the state root", block.Height)
+	}
+
+	return state, recorded, nil
+}
+
+func (chain *Chain) addPending(tx core.Transaction) error {
+	for _, existing := range chain.pending {
+		if bytes.Equal(existing.Hash, tx.Hash) {
+			return ErrKnownTransaction
+		}
+	}
+
+	if len(chain.pending) >= maxPendingTransactions {
+		return errors.New("mempool is full")
+	}
+
+	fork := chain.schedule.At(chain.head().Height + 1)
+
+	if err := chain.pendingState().apply(tx, fork.Rules); err != nil {
+		return fmt.Errorf("rules %q: %w", fork.Name, err)
+	}
+
+	chain.pending = append(chain.pending, tx)
+
+	return nil
+}
+
+// pendingState is the committed state with every pending transaction applied,
+// which is what a new transaction is checked against.
+func (chain *Chain) pendingState() *state {
+	current := newState(chain.store)
+	rules := chain.schedule.At(chain.head().Height + 1).Rules
+
+	for _, tx := range chain.pending {
+		current.apply(tx, rules)
+	}
+
+	return current
+}
+
+// prunePending drops transactions that were included or can no longer apply.
+func (chain *Chain) prunePending() {
+	current := newState(chain.store)
+	rules := chain.schedule.At(chain.head().Height + 1).Rules
+	kept := chain.pending[:0]
+
+	for _, tx := range chain.pending {
+		if err := current.apply(tx, rules); err == nil {
+			kept = append(kept, tx)
+		}
+	}
+
+	chain.pending = kept
+}
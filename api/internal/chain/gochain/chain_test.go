@@ -0,0 +1,246 @@
+package gochain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/consensus/pow"
+	"github.com/afrodynamic/gochain/api/internal/core"
+	"github.com/afrodynamic/gochain/api/internal/storage/pebble"
+)
+
+// finalEngine is proof of work whose blocks are final once sealed.
+type finalEngine struct {
+	consensus.Engine
+}
+
+func (engine finalEngine) Finality() consensus.Finality {
+	return consensus.FinalityInstant
+}
+
+// newTestChain is testutil.NewChain, which imports this package, with the
+// engine chosen by the test.
+func newTestChain(t *testing.T, engine consensus.Engine) *Chain {
+	t.Helper()
+
+	config := genesis.Default("pow", nil)
+	config.ChainID = "gochain-test"
+	config.Consensus.Difficulty = 1
+	store, err := pebble.New(t.TempDir(), config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { store.Close() })
+
+	chain, err := New(engine, store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chain
+}
+
+// mintBlock seals a block on the chain's head minting the amounts to address.
+func mintBlock(t *testing.T, chain *Chain, address []byte, amounts ...uint64) core.Block {
+	t.Helper()
+
+	chain.mutex.RLock()
+	tip := chain.head()
+	state := newState(chain.store)
+	rules := chain.schedule.At(tip.Height + 1).Rules
+	chain.mutex.RUnlock()
+
+	block := core.Block{Height: tip.Height + 1, PrevHash: tip.Hash, Timestamp: time.Now().UTC()}
+
+	for _, amount := range amounts {
+		mint := core.Transaction{To: address, Amount: amount, Timestamp: block.Timestamp}
+		mint.Hash = mint.ComputeHash()
+		state.apply(mint, rules)
+		block.Transactions = append(block.Transactions, mint)
+	}
+
+	block.TxRoot = core.TxRoot(block.Transactions)
+	block.StateRoot = core.StateRoot(state.accounts())
+	sealed, err := chain.engine.Seal(block)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sealed
+}
+
+func TestReorgOntoHeavierBranch(t *testing.T) {
+	t.Parallel()
+
+	local := newTestChain(t, pow.New(1))
+	remote := newTestChain(t, pow.New(1))
+	sender := []byte("sender")
+	recipient := []byte("recipient")
+
+	local.Credit(sender, 100)
+	shared, _ := local.GetBlock(1)
+
+	if err := remote.ImportBlock(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := local.SubmitTx(core.Tx{From: sender, To: recipient, Amount: 40, Fee: 1})
+
+	if err != nil || transaction.BlockHeight != 2 {
+		t.Fatalf("got transaction=%+v err=%v, want it mined in block 2", transaction, err)
+	}
+
+	remote.Credit([]byte("miner"), 1)
+	remote.Credit([]byte("miner"), 2)
+
+	for height := uint64(2); height <= 3; height++ {
+		block, _ := remote.GetBlock(height)
+
+		if err := local.ImportBlock(block); err != nil {
+			t.Fatalf("block %d: %v", height, err)
+		}
+	}
+
+	head, _ := local.GetBlock(3)
+	remoteHead, _ := remote.GetBlock(3)
+
+	if info := local.NodeInfo(); info.Height != 3 || !bytes.Equal(head.Hash, remoteHead.Hash) {
+		t.Fatalf("got height=%d head=%x, want the remote branch at 3", info.Height, head.Hash)
+	}
+
+	if balance, _ := local.GetBalance(recipient); balance != 0 {
+		t.Fatalf("got recipient=%d, want the orphaned transfer undone", balance)
+	}
+
+	pending := local.PendingTransactions()
+
+	if len(pending) != 1 || !bytes.Equal(pending[0].Hash, transaction.Hash) || pending[0].Status != core.TxStatusPending {
+		t.Fatalf("got pending=%+v, want the orphaned transfer back in the mempool", pending)
+	}
+
+	if _, _, err := local.TransactionProof(transaction.Hash); !errors.Is(err, ErrUnknownTransaction) {
+		t.Fatalf("got err=%v, want the orphaned transfer no longer mined", err)
+	}
+}
+
+func TestImportRefusesToReorgPastFinalBlocks(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		engine consensus.Engine
+		local  int
+		want   error
+	}{
+		{name: "probabilistic within reach", engine: pow.New(1), local: 2},
+		{name: "instant finality", engine: finalEngine{pow.New(1)}, local: 2, want: ErrConflictingBlock},
+		{name: "beyond the reorg depth", engine: pow.New(1), local: maxReorgDepth + 1, want: ErrConflictingBlock},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			local := newTestChain(t, tc.engine)
+			remote := newTestChain(t, tc.engine)
+
+			for i := 0; i < tc.local; i++ {
+				local.Credit([]byte("local"), 1)
+			}
+
+			remote.Credit([]byte("remote"), 1)
+			block, _ := remote.GetBlock(1)
+			head, _ := local.GetBlock(uint64(tc.local))
+
+			if err := local.ImportBlock(block); !errors.Is(err, tc.want) {
+				t.Fatalf("got err=%v want=%v", err, tc.want)
+			}
+
+			if kept, _ := local.GetBlock(uint64(tc.local)); local.NodeInfo().Height != uint64(tc.local) || !bytes.Equal(kept.Hash, head.Hash) {
+				t.Fatalf("got height=%d, want the local chain kept", local.NodeInfo().Height)
+			}
+		})
+	}
+}
+
+func TestMintAllowance(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		amounts []uint64
+		valid   bool
+	}{
+		{name: "at the allowance", amounts: []uint64{genesis.DefaultMaxMint}, valid: true},
+		{name: "split within the allowance", amounts: []uint64{60, 40}, valid: true},
+		{name: "over the allowance", amounts: []uint64{genesis.DefaultMaxMint + 1}},
+		{name: "split over the allowance", amounts: []uint64{60, 41}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			chain := newTestChain(t, pow.New(1))
+			block := mintBlock(t, chain, []byte("account"), tc.amounts...)
+
+			if err := chain.ImportBlock(block); (err == nil) != tc.valid {
+				t.Fatalf("got err=%v valid=%v", err, tc.valid)
+			}
+		})
+	}
+
+	chain := newTestChain(t, pow.New(1))
+	chain.Credit([]byte("account"), genesis.DefaultMaxMint+1)
+
+	if info := chain.NodeInfo(); info.Height != 0 {
+		t.Fatalf("got height=%d, want a credit over the allowance refused", info.Height)
+	}
+}
+
+func TestValidateBlock(t *testing.T) {
+	t.Parallel()
+
+	chain := newTestChain(t, pow.New(1))
+	chain.Credit([]byte("account"), 1)
+	parent, _ := chain.GetBlock(0)
+
+	cases := []struct {
+		name   string
+		modify func(block *core.Block)
+		want   error
+	}{
+		{name: "extends the head", modify: func(block *core.Block) {}},
+		{name: "height already taken", modify: func(block *core.Block) { block.Height = 1 }, want: ErrUnknownParent},
+		{name: "height past the head", modify: func(block *core.Block) { block.Height = 3 }, want: ErrUnknownParent},
+		{name: "parent off the head", modify: func(block *core.Block) { block.PrevHash = parent.Hash }, want: ErrUnknownParent},
+	}
+
+	for _, tc := range cases {
+		block := mintBlock(t, chain, []byte("account"), 1)
+		tc.modify(&block)
+		block.Hash = block.SealHash()
+
+		if err := chain.ValidateBlock(block); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: got err=%v want=%v", tc.name, err, tc.want)
+		}
+	}
+
+	block := mintBlock(t, chain, []byte("account"), 1)
+
+	if err := chain.ValidateBlock(block); err == nil {
+		t.Fatal("expected a block whose hash is not its seal hash to be refused")
+	}
+
+	if info := chain.NodeInfo(); info.Height != 1 {
+		t.Fatalf("got height=%d, want validation to leave the chain as it was", info.Height)
+	}
+}
@@ -0,0 +1,142 @@
+package gochain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/afrodynamic/gochain/api/internal/core"
+	"github.com/afrodynamic/gochain/api/internal/storage/pebble"
+)
+
+// state overlays balance and nonce changes on the store so that a block or the
+// mempool can be checked transaction by transaction and only committed once
+// every transaction applied. minted totals the mints applied, which the rules
+// cap per block.
+type state struct {
+	store    *pebble.Store
+	balances map[string]uint64
+	nonces   map[string]uint64
+	minted   uint64
+}
+
+func newState(store *pebble.Store) *state {
+	return &state{store: store, balances: make(map[string]uint64), nonces: make(map[string]uint64)}
+}
+
+func (current *state) balance(address string) uint64 {
+	if balance, exists := current.balances[address]; exists {
+		return balance
+	}
+
+	return current.store.Balances[address]
+}
+
+func (current *state) nonce(address string) uint64 {
+	if nonce, exists := current.nonces[address]; exists {
+		return nonce
+	}
+
+	return current.store.Nonces[address]
+}
+
+// apply checks a transaction against the rules and the overlaid state and
+// applies it. Transactions without a sender mint faucet credits, up to the
+// rules' allowance.
+func (current *state) apply(tx core.Transaction, rules core.Rules) error {
+	if tx.Amount == 0 {
+		return errors.New("amount must be positive")
+	}
+
+	toKey := string(tx.To)
+
+	if len(tx.From) == 0 {
+		if err := rules.CheckMint(current.minted, tx.Amount); err != nil {
+			return err
+		}
+
+		if current.balance(toKey) > math.MaxUint64-tx.Amount {
+			return errors.New("balance overflow")
+		}
+
+		current.balances[toKey] = current.balance(toKey) + tx.Amount
+		current.minted += tx.Amount
+
+		return nil
+	}
+
+	if err := rules.CheckTx(core.Tx{From: tx.From, To: tx.To, Amount: tx.Amount, Fee: tx.Fee, Data: tx.Data}); err != nil {
+		return err
+	}
+
+	fromKey := string(tx.From)
+
+	if expected := current.nonce(fromKey); tx.Nonce != expected {
+		return fmt.Errorf("nonce %d, expected %d", tx.Nonce, expected)
+	}
+
+	if tx.Fee > math.MaxUint64-tx.Amount {
+		return errors.New("amount plus fee overflows")
+	}
+
+	totalDebit := tx.Amount + tx.Fee
+	balance := current.balance(fromKey)
+
+	if balance < totalDebit {
+		return errors.New("insufficient balance")
+	}
+
+	if fromKey != toKey && current.balance(toKey) > math.MaxUint64-tx.Amount {
+		return errors.New("balance overflow")
+	}
+
+	current.balances[fromKey] = balance - totalDebit
+	current.balances[toKey] = current.balance(toKey) + tx.Amount
+	current.nonces[fromKey] = current.nonce(fromKey) + 1
+
+	return nil
+}
+
+func (current *state) commit() {
+	for address, balance := range current.balances {
+		current.store.Balances[address] = balance
+	}
+
+	for address, nonce := range current.nonces {
+		current.store.Nonces[address] = nonce
+	}
+}
+
+// accounts lists the overlaid state in address order, as the state root
+// commits to it.
+func (current *state) accounts() []core.Account {
+	merged := make(map[string]*core.Account, len(current.store.Balances))
+	account := func(address string) *core.Account {
+		if existing, exists := merged[address]; exists {
+			return existing
+		}
+
+		created := &core.Account{Address: []byte(address), Balance: current.balance(address), Nonce: current.nonce(address)}
+		merged[address] = created
+
+		return created
+	}
+
+	for _, addresses := range []map[string]uint64{current.store.Balances, current.store.Nonces, current.balances, current.nonces} {
+		for address := range addresses {
+			account(address)
+		}
+	}
+
+	accounts := make([]core.Account, 0, len(merged))
+
+	for _, account := range merged {
+		if !account.Empty() {
+			accounts = append(accounts, *account)
+		}
+	}
+
+	core.SortAccounts(accounts)
+
+	return accounts
+}
@@ -0,0 +1,225 @@
+package bft
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+type Config struct {
+	Validators []Validator
+	PrivateKey ed25519.PrivateKey
+	Height     uint64
+	PrevHash   []byte
+	Timeouts   Timeouts
+	Transport  Transport
+	Validate   func(block core.Block) error
+}
+
+// Engine runs a Node against the wall clock and exposes it as a
+// consensus.Engine. Seal blocks until the height is decided, which gives the
+// chain instant finality.
+type Engine struct {
+	mutex      sync.Mutex
+	node       *Node
+	validators *ValidatorSet
+	waiters    map[uint64][]chan core.Block
+	stopped    chan struct{}
+	stopOnce   sync.Once
+}
+
+func New(config Config) (*Engine, error) {
+	validators, err := NewValidatorSet(config.Validators)
+
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &Engine{
+		validators: validators,
+		waiters:    make(map[uint64][]chan core.Block),
+		stopped:    make(chan struct{}),
+	}
+
+	node, err := NewNode(NodeConfig{
+		Validators: validators,
+		PrivateKey: config.PrivateKey,
+		Height:     config.Height,
+		PrevHash:   config.PrevHash,
+		Timeouts:   config.Timeouts,
+		Clock:      &lockedClock{mutex: &engine.mutex},
+		Transport:  config.Transport,
+		Validate:   config.Validate,
+		OnCommit:   engine.onCommit,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	engine.node = node
+
+	return engine, nil
+}
+
+func Factory(params consensus.Params) (consensus.Engine, error) {
+	validators := make([]Validator, 0, len(params.Genesis.Validators))
+
+	for i, validator := range params.Genesis.Validators {
+		publicKey, err := validator.Key()
+
+		if err != nil {
+			return nil, fmt.Errorf("validator %d: %w", i, err)
+		}
+
+		validators = append(validators, Validator{PublicKey: publicKey, Power: validator.Power})
+	}
+
+	timeouts := DefaultTimeouts()
+
+	if params.Genesis.BlockTime > 0 {
+		timeouts.Propose = time.Duration(params.Genesis.BlockTime)
+	}
+
+	engine, err := New(Config{
+		Validators: validators,
+		PrivateKey: params.PrivateKey,
+		Height:     params.Height,
+		PrevHash:   params.PrevHash,
+		Timeouts:   timeouts,
+		Validate:   params.Validate,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+func (engine *Engine) Start() error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.node.Start()
+
+	return nil
+}
+
+func (engine *Engine) Stop() error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.node.Stop()
+	engine.stopOnce.Do(func() { close(engine.stopped) })
+
+	return nil
+}
+
+// Seal offers the block for its height and waits for the validators to decide.
+// The returned block is the decided one, which may be another validator's
+// proposal when this node was not the proposer.
+func (engine *Engine) Seal(block core.Block) (core.Block, error) {
+	engine.mutex.Lock()
+
+	if decided, exists := engine.node.Decision(block.Height); exists {
+		engine.mutex.Unlock()
+
+		return decided, nil
+	}
+
+	if block.Height < engine.node.Height() {
+		engine.mutex.Unlock()
+
+		return core.Block{}, errors.New("height already decided")
+	}
+
+	waiter := make(chan core.Block, 1)
+	engine.waiters[block.Height] = append(engine.waiters[block.Height], waiter)
+	engine.node.Offer(block)
+	engine.mutex.Unlock()
+
+	select {
+	case decided := <-waiter:
+		return decided, nil
+
+	case <-engine.stopped:
+		return core.Block{}, errors.New("consensus engine stopped")
+	}
+}
+
+func (engine *Engine) Validate(block core.Block) error {
+	if !bytes.Equal(block.Hash, block.SealHash()) {
+		return errors.New("block hash does not match its contents")
+	}
+
+	return engine.validators.VerifyCommit(block)
+}
+
+func (engine *Engine) Finality() consensus.Finality {
+	return consensus.FinalityInstant
+}
+
+func (engine *Engine) Name() string {
+	return "bft"
+}
+
+// HandleMessage feeds a message received from the network into the node.
+func (engine *Engine) HandleMessage(message Message) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.node.Receive(message)
+}
+
+// Advance moves consensus past a block imported from a peer, so the node
+// votes on the height after it.
+func (engine *Engine) Advance(block core.Block) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.node.Advance(block)
+}
+
+func (engine *Engine) SetTransport(transport Transport) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.node.SetTransport(transport)
+}
+
+func (engine *Engine) Validators() *ValidatorSet {
+	return engine.validators
+}
+
+func (engine *Engine) onCommit(block core.Block) {
+	for _, waiter := range engine.waiters[block.Height] {
+		waiter <- block
+	}
+
+	delete(engine.waiters, block.Height)
+}
+
+type lockedClock struct {
+	mutex *sync.Mutex
+}
+
+func (clock *lockedClock) AfterFunc(delay time.Duration, callback func()) func() {
+	timer := time.AfterFunc(delay, func() {
+		clock.mutex.Lock()
+		defer clock.mutex.Unlock()
+
+		callback()
+	})
+
+	return func() { timer.Stop() }
+}
+
+var _ consensus.Engine = (*Engine)(nil)
+var _ consensus.Advancer = (*Engine)(nil)
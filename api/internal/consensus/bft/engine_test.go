@@ -0,0 +1,239 @@
+package bft
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+func newTestKey(label string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte(label))
+
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func TestEngineSealSingleValidator(t *testing.T) {
+	t.Parallel()
+
+	privateKey := newTestKey("validator")
+
+	engine, err := New(Config{
+		Validators: []Validator{{PublicKey: privateKey.Public().(ed25519.PublicKey), Power: 10}},
+		PrivateKey: privateKey,
+		Height:     1,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer engine.Stop()
+
+	block := core.Block{Height: 1, PrevHash: []byte("genesis")}
+	block.Hash = block.SealHash()
+	sealed, err := engine.Seal(block)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sealed.Commit == nil || len(sealed.Commit.Signatures) != 1 {
+		t.Fatalf("expected a single commit signature, got %+v", sealed.Commit)
+	}
+
+	if err := engine.Validate(sealed); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := sealed
+	tampered.Hash = []byte("other")
+
+	if err := engine.Validate(tampered); err == nil {
+		t.Fatal("expected tampered block to fail validation")
+	}
+
+	unsigned := sealed
+	unsigned.Commit = nil
+
+	if err := engine.Validate(unsigned); err == nil {
+		t.Fatal("expected block without commit to fail validation")
+	}
+}
+
+func TestVerifyCommitRequiresQuorum(t *testing.T) {
+	t.Parallel()
+
+	keys := []ed25519.PrivateKey{newTestKey("a"), newTestKey("b"), newTestKey("c"), newTestKey("d")}
+	validators := make([]Validator, 0, len(keys))
+
+	for _, key := range keys {
+		validators = append(validators, Validator{PublicKey: key.Public().(ed25519.PublicKey), Power: 1})
+	}
+
+	set, err := NewValidatorSet(validators)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := core.Block{Hash: []byte("hash"), Height: 7, Commit: &core.Commit{Round: 2}}
+
+	for _, key := range keys[:2] {
+		vote := Message{Type: MessagePrecommit, Height: 7, Round: 2, BlockHash: block.Hash}
+		vote.Sign(key)
+		block.Commit.Signatures = append(block.Commit.Signatures, core.Signature{Signer: vote.Validator, Signature: vote.Signature})
+	}
+
+	if err := set.VerifyCommit(block); err == nil {
+		t.Fatal("expected two of four signatures to fall short of quorum")
+	}
+
+	vote := Message{Type: MessagePrecommit, Height: 7, Round: 2, BlockHash: block.Hash}
+	vote.Sign(keys[2])
+	block.Commit.Signatures = append(block.Commit.Signatures, core.Signature{Signer: vote.Validator, Signature: vote.Signature})
+
+	if err := set.VerifyCommit(block); err != nil {
+		t.Fatal(err)
+	}
+
+	block.Commit.Signatures = append(block.Commit.Signatures, block.Commit.Signatures[0])
+
+	if err := set.VerifyCommit(block); err == nil {
+		t.Fatal("expected duplicate signer to be rejected")
+	}
+}
+
+func TestEngineAdvancesPastImportedBlocks(t *testing.T) {
+	t.Parallel()
+
+	keys := []ed25519.PrivateKey{newTestKey("a"), newTestKey("b"), newTestKey("c"), newTestKey("d")}
+	validators := make([]Validator, 0, len(keys))
+
+	for _, key := range keys {
+		validators = append(validators, Validator{PublicKey: key.Public().(ed25519.PublicKey), Power: 1})
+	}
+
+	engine, err := New(Config{Validators: validators, PrivateKey: keys[0], Height: 1, PrevHash: []byte("genesis")})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer engine.Stop()
+
+	// The other validators committed block 1 while this one was away, and
+	// sync imports it.
+	block := core.Block{Height: 1, PrevHash: []byte("genesis"), Commit: &core.Commit{}}
+	block.Hash = block.SealHash()
+
+	for _, key := range keys[1:] {
+		vote := Message{Type: MessagePrecommit, Height: 1, BlockHash: block.Hash}
+		vote.Sign(key)
+		block.Commit.Signatures = append(block.Commit.Signatures, core.Signature{Signer: vote.Validator, Signature: vote.Signature})
+	}
+
+	engine.Advance(block)
+
+	engine.mutex.Lock()
+	height := engine.node.Height()
+	engine.mutex.Unlock()
+
+	if height != 2 {
+		t.Fatalf("got height=%d want=2", height)
+	}
+
+	if decided, err := engine.Seal(core.Block{Height: 1}); err != nil || !bytes.Equal(decided.Hash, block.Hash) {
+		t.Fatalf("got hash=%x err=%v, want the imported block", decided.Hash, err)
+	}
+
+	// An older block does not move the engine back.
+	engine.Advance(core.Block{Height: 0})
+
+	engine.mutex.Lock()
+	height = engine.node.Height()
+	engine.mutex.Unlock()
+
+	if height != 2 {
+		t.Fatalf("got height=%d want=2", height)
+	}
+}
+
+type engineTransport struct {
+	peers []*Engine
+}
+
+func (transport *engineTransport) Broadcast(message Message) {
+	for _, peer := range transport.peers {
+		go peer.HandleMessage(message)
+	}
+}
+
+func TestEngineSealWhenProposerHasNoBlock(t *testing.T) {
+	t.Parallel()
+
+	keys := []ed25519.PrivateKey{newTestKey("a"), newTestKey("b"), newTestKey("c")}
+	validators := make([]Validator, 0, len(keys))
+
+	for _, key := range keys {
+		validators = append(validators, Validator{PublicKey: key.Public().(ed25519.PublicKey), Power: 1})
+	}
+
+	timeouts := Timeouts{Propose: 50 * time.Millisecond, Prevote: 50 * time.Millisecond, Precommit: 50 * time.Millisecond, Delta: 10 * time.Millisecond, Gossip: 100 * time.Millisecond}
+	engines := make([]*Engine, 0, len(keys))
+
+	for _, key := range keys {
+		engine, err := New(Config{Validators: validators, PrivateKey: key, Height: 1, Timeouts: timeouts})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := engine.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		defer engine.Stop()
+
+		engines = append(engines, engine)
+	}
+
+	// Transports are attached after start, as a node joining its network does.
+	for i, engine := range engines {
+		others := append(append([]*Engine(nil), engines[:i]...), engines[i+1:]...)
+		engine.SetTransport(&engineTransport{peers: others})
+	}
+
+	proposer := engines[0].Validators().Proposer(1, 0)
+	var sealer *Engine
+
+	for i, key := range keys {
+		if !key.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(proposer.PublicKey)) {
+			sealer = engines[i]
+
+			break
+		}
+	}
+
+	hash := sha256.Sum256([]byte("block-1"))
+	sealed, err := sealer.Seal(core.Block{Hash: hash[:], Height: 1, PrevHash: []byte("genesis")})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sealed.Commit == nil || sealed.Commit.Round == 0 {
+		t.Fatalf("expected the block to be decided after the idle proposer's round, got %+v", sealed.Commit)
+	}
+}
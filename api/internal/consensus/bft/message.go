@@ -0,0 +1,79 @@
+package bft
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+type MessageType uint8
+
+const (
+	MessageProposal MessageType = iota + 1
+	MessagePrevote
+	MessagePrecommit
+	MessageCommit
+)
+
+func (messageType MessageType) String() string {
+	switch messageType {
+	case MessageProposal:
+		return "proposal"
+	case MessagePrevote:
+		return "prevote"
+	case MessagePrecommit:
+		return "precommit"
+	case MessageCommit:
+		return "commit"
+	default:
+		return "unknown"
+	}
+}
+
+// Message is the single wire type exchanged between validators. Votes with an
+// empty BlockHash are nil votes. Commit messages are unsigned and carry a
+// decided block whose certificate is checked instead.
+type Message struct {
+	Type      MessageType
+	Height    uint64
+	Round     int32
+	POLRound  int32
+	BlockHash []byte
+	Block     *core.Block
+	Validator []byte
+	Signature []byte
+}
+
+const signDomain = "gochain/bft/v1"
+
+func (message *Message) signBytes() []byte {
+	buffer := make([]byte, 0, len(signDomain)+1+8+4+4+len(message.BlockHash))
+	buffer = append(buffer, signDomain...)
+	buffer = append(buffer, byte(message.Type))
+	buffer = binary.BigEndian.AppendUint64(buffer, message.Height)
+	buffer = binary.BigEndian.AppendUint32(buffer, uint32(message.Round))
+
+	if message.Type == MessageProposal {
+		buffer = binary.BigEndian.AppendUint32(buffer, uint32(message.POLRound))
+	}
+
+	return append(buffer, message.BlockHash...)
+}
+
+func (message *Message) Sign(privateKey ed25519.PrivateKey) {
+	message.Validator = append([]byte(nil), privateKey.Public().(ed25519.PublicKey)...)
+	message.Signature = ed25519.Sign(privateKey, message.signBytes())
+}
+
+func (message *Message) Verify() bool {
+	if len(message.Validator) != ed25519.PublicKeySize {
+		return false
+	}
+
+	return ed25519.Verify(message.Validator, message.signBytes(), message.Signature)
+}
+
+func (message *Message) isNilVote() bool {
+	return len(message.BlockHash) == 0
+}
@@ -0,0 +1,828 @@
+package bft
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+type Clock interface {
+	AfterFunc(delay time.Duration, callback func()) (cancel func())
+}
+
+// Transport delivers a message to every other validator. Implementations must
+// not call back into the node synchronously.
+type Transport interface {
+	Broadcast(message Message)
+}
+
+type Timeouts struct {
+	Propose   time.Duration
+	Prevote   time.Duration
+	Precommit time.Duration
+	Delta     time.Duration
+	Gossip    time.Duration
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Propose:   3 * time.Second,
+		Prevote:   time.Second,
+		Precommit: time.Second,
+		Delta:     500 * time.Millisecond,
+		Gossip:    time.Second,
+	}
+}
+
+type NodeConfig struct {
+	Validators *ValidatorSet
+	PrivateKey ed25519.PrivateKey
+	Height     uint64
+	PrevHash   []byte
+	Timeouts   Timeouts
+	Clock      Clock
+	Transport  Transport
+	Validate   func(block core.Block) error
+	OnCommit   func(block core.Block)
+}
+
+type step uint8
+
+const (
+	stepPropose step = iota
+	stepPrevote
+	stepPrecommit
+)
+
+const (
+	maxBufferedAhead = 64
+	maxRoundsAhead   = 64
+	maxDecisions     = 128
+)
+
+type voteSet struct {
+	votes  map[string]Message
+	power  map[string]uint64
+	total  uint64
+	quorum uint64
+}
+
+func newVoteSet(quorum uint64) *voteSet {
+	return &voteSet{
+		votes:  make(map[string]Message),
+		power:  make(map[string]uint64),
+		quorum: quorum,
+	}
+}
+
+// add records the first vote of each validator; later conflicting votes are
+// equivocation and are ignored rather than counted twice.
+func (set *voteSet) add(vote Message, power uint64) bool {
+	if _, exists := set.votes[string(vote.Validator)]; exists {
+		return false
+	}
+
+	set.votes[string(vote.Validator)] = vote
+	set.power[string(vote.BlockHash)] += power
+	set.total += power
+
+	return true
+}
+
+func (set *voteSet) hasQuorumFor(blockHash []byte) bool {
+	return set.power[string(blockHash)] >= set.quorum
+}
+
+func (set *voteSet) hasQuorumAny() bool {
+	return set.total >= set.quorum
+}
+
+func (set *voteSet) signaturesFor(blockHash []byte, order []Validator) []core.Signature {
+	signatures := make([]core.Signature, 0, len(set.votes))
+
+	for _, validator := range order {
+		vote, exists := set.votes[string(validator.PublicKey)]
+
+		if exists && bytes.Equal(vote.BlockHash, blockHash) {
+			signatures = append(signatures, core.Signature{Signer: vote.Validator, Signature: vote.Signature})
+		}
+	}
+
+	return signatures
+}
+
+type roundState struct {
+	proposal          *Message
+	prevotes          *voteSet
+	precommits        *voteSet
+	participants      map[string]uint64
+	proposed          bool
+	prevoteTimerSet   bool
+	precommitTimerSet bool
+	polApplied        bool
+}
+
+// Node is the deterministic Tendermint state machine for a single validator.
+// It is not safe for concurrent use; Engine serialises access to it and the
+// simulator drives it from a single goroutine.
+type Node struct {
+	config     NodeConfig
+	validators *ValidatorSet
+	self       []byte
+
+	height      uint64
+	round       int32
+	step        step
+	prevHash    []byte
+	lockedBlock *core.Block
+	lockedRound int32
+	validBlock  *core.Block
+	validRound  int32
+	rounds      map[int32]*roundState
+
+	pending       map[uint64]core.Block
+	decisions     map[uint64]core.Block
+	futureCommits map[uint64]Message
+	catchupSent   map[uint64]bool
+	sent          []Message
+
+	timers   []func()
+	gossip   func()
+	inbox    []Message
+	draining bool
+	started  bool
+	stopped  bool
+}
+
+func NewNode(config NodeConfig) (*Node, error) {
+	if config.Validators == nil {
+		return nil, errors.New("validator set is required")
+	}
+
+	if config.Clock == nil {
+		return nil, errors.New("clock is required")
+	}
+
+	if config.Timeouts == (Timeouts{}) {
+		config.Timeouts = DefaultTimeouts()
+	}
+
+	node := &Node{
+		config:        config,
+		validators:    config.Validators,
+		height:        config.Height,
+		prevHash:      config.PrevHash,
+		pending:       make(map[uint64]core.Block),
+		decisions:     make(map[uint64]core.Block),
+		futureCommits: make(map[uint64]Message),
+		catchupSent:   make(map[uint64]bool),
+	}
+
+	if config.PrivateKey != nil {
+		self := config.PrivateKey.Public().(ed25519.PublicKey)
+
+		if _, exists := config.Validators.Power(self); !exists {
+			return nil, errors.New("private key does not belong to a validator")
+		}
+
+		node.self = self
+	}
+
+	return node, nil
+}
+
+func (node *Node) Height() uint64 {
+	return node.height
+}
+
+func (node *Node) Round() int32 {
+	return node.round
+}
+
+func (node *Node) Decision(height uint64) (core.Block, bool) {
+	block, exists := node.decisions[height]
+
+	return block, exists
+}
+
+func (node *Node) Start() {
+	if node.started {
+		return
+	}
+
+	node.started = true
+	node.resetHeight()
+	node.startRound(0)
+	node.scheduleGossip()
+	node.drain()
+}
+
+func (node *Node) Stop() {
+	node.stopped = true
+	node.cancelTimers()
+
+	if node.gossip != nil {
+		node.gossip()
+		node.gossip = nil
+	}
+}
+
+// SetTransport connects a node that was created before its network existed.
+func (node *Node) SetTransport(transport Transport) {
+	node.config.Transport = transport
+
+	if node.started && !node.stopped && node.gossip == nil {
+		node.scheduleGossip()
+	}
+}
+
+// Offer supplies the block this node proposes when it is the proposer for the
+// block's height.
+func (node *Node) Offer(block core.Block) {
+	if block.Height < node.height {
+		return
+	}
+
+	node.pending[block.Height] = block
+
+	if node.started && !node.stopped && block.Height == node.height {
+		node.tryPropose()
+		node.drain()
+	}
+}
+
+// Advance moves the node past a block committed without it, such as one
+// imported by sync, as deciding it would. The block's commit must already be
+// verified; blocks below the node's height are ignored.
+func (node *Node) Advance(block core.Block) {
+	if block.Height < node.height || node.stopped {
+		return
+	}
+
+	if !node.started {
+		node.decisions[block.Height] = block
+		node.height = block.Height + 1
+		node.prevHash = block.Hash
+
+		return
+	}
+
+	node.decide(block)
+	node.drain()
+}
+
+func (node *Node) Receive(message Message) {
+	if node.stopped {
+		return
+	}
+
+	node.inbox = append(node.inbox, message)
+	node.drain()
+}
+
+func (node *Node) drain() {
+	if node.draining {
+		return
+	}
+
+	node.draining = true
+
+	for len(node.inbox) > 0 && !node.stopped {
+		message := node.inbox[0]
+		node.inbox = node.inbox[1:]
+		node.handle(message)
+	}
+
+	node.draining = false
+}
+
+func (node *Node) handle(message Message) {
+	if message.Type == MessageCommit {
+		node.handleCommit(message)
+
+		return
+	}
+
+	if !node.started {
+		return
+	}
+
+	power, exists := node.validators.Power(message.Validator)
+
+	if !exists || node.isDuplicate(message) || !message.Verify() {
+		return
+	}
+
+	if message.Height < node.height {
+		node.sendCatchup(message.Height)
+
+		return
+	}
+
+	if message.Height > node.height || message.Round < 0 || message.Round > node.round+maxRoundsAhead {
+		return
+	}
+
+	state := node.roundState(message.Round)
+
+	switch message.Type {
+	case MessageProposal:
+		proposer := node.validators.Proposer(message.Height, message.Round)
+
+		if !bytes.Equal(proposer.PublicKey, message.Validator) || state.proposal != nil {
+			return
+		}
+
+		if message.Block == nil || !bytes.Equal(message.Block.Hash, message.BlockHash) || message.POLRound < -1 || message.POLRound >= message.Round {
+			return
+		}
+
+		proposal := message
+		state.proposal = &proposal
+
+	case MessagePrevote:
+		if !state.prevotes.add(message, power) {
+			return
+		}
+
+	case MessagePrecommit:
+		if !state.precommits.add(message, power) {
+			return
+		}
+
+	default:
+		return
+	}
+
+	state.participants[string(message.Validator)] = power
+	node.evaluate()
+}
+
+// isDuplicate spots re-gossiped messages before their signature is checked
+// again, which is by far the most expensive part of handling a message.
+func (node *Node) isDuplicate(message Message) bool {
+	if message.Height != node.height {
+		return false
+	}
+
+	state, exists := node.rounds[message.Round]
+
+	if !exists {
+		return false
+	}
+
+	var seen *Message
+
+	switch message.Type {
+	case MessageProposal:
+		seen = state.proposal
+
+	case MessagePrevote:
+		if vote, exists := state.prevotes.votes[string(message.Validator)]; exists {
+			seen = &vote
+		}
+
+	case MessagePrecommit:
+		if vote, exists := state.precommits.votes[string(message.Validator)]; exists {
+			seen = &vote
+		}
+	}
+
+	return seen != nil && bytes.Equal(seen.Signature, message.Signature)
+}
+
+func (node *Node) handleCommit(message Message) {
+	if message.Block == nil || message.Height != message.Block.Height {
+		return
+	}
+
+	if message.Height < node.height {
+		return
+	}
+
+	if err := node.validators.VerifyCommit(*message.Block); err != nil {
+		return
+	}
+
+	if message.Height > node.height {
+		if message.Height-node.height <= maxBufferedAhead {
+			node.futureCommits[message.Height] = message
+		}
+
+		return
+	}
+
+	if !node.isValid(*message.Block) {
+		return
+	}
+
+	node.decide(*message.Block)
+	node.evaluate()
+}
+
+func (node *Node) sendCatchup(height uint64) {
+	if node.catchupSent[height] || node.config.Transport == nil {
+		return
+	}
+
+	decision, exists := node.decisions[height]
+
+	if !exists {
+		return
+	}
+
+	node.catchupSent[height] = true
+	node.config.Transport.Broadcast(Message{Type: MessageCommit, Height: height, Block: &decision})
+}
+
+func (node *Node) roundState(round int32) *roundState {
+	state, exists := node.rounds[round]
+
+	if !exists {
+		state = &roundState{
+			prevotes:     newVoteSet(node.validators.QuorumPower()),
+			precommits:   newVoteSet(node.validators.QuorumPower()),
+			participants: make(map[string]uint64),
+		}
+		node.rounds[round] = state
+	}
+
+	return state
+}
+
+func (node *Node) resetHeight() {
+	node.round = 0
+	node.step = stepPropose
+	node.lockedBlock = nil
+	node.lockedRound = -1
+	node.validBlock = nil
+	node.validRound = -1
+	node.rounds = make(map[int32]*roundState)
+	node.sent = nil
+	node.cancelTimers()
+}
+
+func (node *Node) startRound(round int32) {
+	node.round = round
+	node.step = stepPropose
+
+	// The proposer arms the timeout too: without a block of its own to offer
+	// it must still prevote nil so the round can move on to the next proposer.
+	height := node.height
+	node.schedule(node.timeout(node.config.Timeouts.Propose, round), func() {
+		node.onTimeoutPropose(height, round)
+	})
+
+	if node.isProposer() {
+		node.tryPropose()
+	}
+}
+
+func (node *Node) isProposer() bool {
+	if node.self == nil {
+		return false
+	}
+
+	proposer := node.validators.Proposer(node.height, node.round)
+
+	return bytes.Equal(proposer.PublicKey, node.self)
+}
+
+func (node *Node) tryPropose() {
+	if node.step != stepPropose || !node.isProposer() {
+		return
+	}
+
+	state := node.roundState(node.round)
+
+	if state.proposed {
+		return
+	}
+
+	var block core.Block
+	polRound := int32(-1)
+
+	if node.validBlock != nil {
+		block = *node.validBlock
+		polRound = node.validRound
+	} else {
+		pending, exists := node.pending[node.height]
+
+		if !exists {
+			return
+		}
+
+		block = pending
+	}
+
+	state.proposed = true
+	node.broadcast(Message{
+		Type:      MessageProposal,
+		Height:    node.height,
+		Round:     node.round,
+		POLRound:  polRound,
+		BlockHash: block.Hash,
+		Block:     &block,
+	})
+}
+
+func (node *Node) isValid(block core.Block) bool {
+	if block.Height != node.height || len(block.Hash) == 0 {
+		return false
+	}
+
+	if node.prevHash != nil && !bytes.Equal(block.PrevHash, node.prevHash) {
+		return false
+	}
+
+	if node.config.Validate != nil {
+		return node.config.Validate(block) == nil
+	}
+
+	return true
+}
+
+func (node *Node) evaluate() {
+	for !node.stopped && node.applyRule() {
+	}
+}
+
+// applyRule fires at most one rule of the Tendermint algorithm (Buchman,
+// Kwon and Milosevic, 2018) and reports whether the state changed. Line
+// numbers refer to Algorithm 1 of that paper.
+func (node *Node) applyRule() bool {
+	state := node.roundState(node.round)
+	proposal := state.proposal
+
+	// Lines 22 and 28: prevote on the current proposal.
+	if node.step == stepPropose && proposal != nil {
+		polRound := proposal.POLRound
+
+		if polRound == -1 {
+			vote := []byte(nil)
+
+			if node.isValid(*proposal.Block) && (node.lockedRound == -1 || bytes.Equal(node.lockedBlock.Hash, proposal.BlockHash)) {
+				vote = proposal.BlockHash
+			}
+
+			node.step = stepPrevote
+			node.vote(MessagePrevote, vote)
+
+			return true
+		}
+
+		if polState, exists := node.rounds[polRound]; exists && polState.prevotes.hasQuorumFor(proposal.BlockHash) {
+			vote := []byte(nil)
+
+			if node.isValid(*proposal.Block) && (node.lockedRound <= polRound || bytes.Equal(node.lockedBlock.Hash, proposal.BlockHash)) {
+				vote = proposal.BlockHash
+			}
+
+			node.step = stepPrevote
+			node.vote(MessagePrevote, vote)
+
+			return true
+		}
+	}
+
+	// Line 34: any 2f+1 prevotes start the prevote timeout.
+	if node.step == stepPrevote && !state.prevoteTimerSet && state.prevotes.hasQuorumAny() {
+		state.prevoteTimerSet = true
+		height, round := node.height, node.round
+		node.schedule(node.timeout(node.config.Timeouts.Prevote, round), func() {
+			node.onTimeoutPrevote(height, round)
+		})
+
+		return true
+	}
+
+	// Line 36: a polka for the proposal locks it and records it as valid.
+	if node.step >= stepPrevote && !state.polApplied && proposal != nil && state.prevotes.hasQuorumFor(proposal.BlockHash) && node.isValid(*proposal.Block) {
+		state.polApplied = true
+		block := *proposal.Block
+
+		if node.step == stepPrevote {
+			node.lockedBlock = &block
+			node.lockedRound = node.round
+			node.step = stepPrecommit
+			node.vote(MessagePrecommit, block.Hash)
+		}
+
+		node.validBlock = &block
+		node.validRound = node.round
+
+		return true
+	}
+
+	// Line 44: a polka for nil precommits nil.
+	if node.step == stepPrevote && state.prevotes.hasQuorumFor(nil) {
+		node.step = stepPrecommit
+		node.vote(MessagePrecommit, nil)
+
+		return true
+	}
+
+	// Line 47: any 2f+1 precommits start the precommit timeout.
+	if !state.precommitTimerSet && state.precommits.hasQuorumAny() {
+		state.precommitTimerSet = true
+		height, round := node.height, node.round
+		node.schedule(node.timeout(node.config.Timeouts.Precommit, round), func() {
+			node.onTimeoutPrecommit(height, round)
+		})
+
+		return true
+	}
+
+	rounds := node.sortedRounds()
+
+	// Line 49: 2f+1 precommits for a proposal in any round decide it.
+	for _, round := range rounds {
+		roundState := node.rounds[round]
+		roundProposal := roundState.proposal
+
+		if roundProposal == nil || !roundState.precommits.hasQuorumFor(roundProposal.BlockHash) || !node.isValid(*roundProposal.Block) {
+			continue
+		}
+
+		block := *roundProposal.Block
+		block.Proposer = append([]byte(nil), roundProposal.Validator...)
+		block.Commit = &core.Commit{
+			Round:      round,
+			Signatures: roundState.precommits.signaturesFor(block.Hash, node.validators.validators),
+		}
+
+		node.decide(block)
+
+		return true
+	}
+
+	// Line 55: f+1 participants in a later round skip ahead to it.
+	for _, round := range rounds {
+		if round <= node.round {
+			continue
+		}
+
+		var power uint64
+
+		for _, participantPower := range node.rounds[round].participants {
+			power += participantPower
+		}
+
+		if power >= node.validators.FaultPower() {
+			node.startRound(round)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func (node *Node) sortedRounds() []int32 {
+	rounds := make([]int32, 0, len(node.rounds))
+
+	for round := range node.rounds {
+		rounds = append(rounds, round)
+	}
+
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i] < rounds[j] })
+
+	return rounds
+}
+
+func (node *Node) onTimeoutPropose(height uint64, round int32) {
+	if node.stopped || height != node.height || round != node.round || node.step != stepPropose {
+		return
+	}
+
+	node.step = stepPrevote
+	node.vote(MessagePrevote, nil)
+	node.evaluate()
+	node.drain()
+}
+
+func (node *Node) onTimeoutPrevote(height uint64, round int32) {
+	if node.stopped || height != node.height || round != node.round || node.step != stepPrevote {
+		return
+	}
+
+	node.step = stepPrecommit
+	node.vote(MessagePrecommit, nil)
+	node.evaluate()
+	node.drain()
+}
+
+func (node *Node) onTimeoutPrecommit(height uint64, round int32) {
+	if node.stopped || height != node.height || round != node.round {
+		return
+	}
+
+	node.startRound(round + 1)
+	node.evaluate()
+	node.drain()
+}
+
+func (node *Node) decide(block core.Block) {
+	node.decisions[block.Height] = block
+	delete(node.pending, block.Height)
+
+	if block.Height >= maxDecisions {
+		delete(node.decisions, block.Height-maxDecisions)
+	}
+
+	if node.config.Transport != nil {
+		node.config.Transport.Broadcast(Message{Type: MessageCommit, Height: block.Height, Block: &block})
+	}
+
+	if node.config.OnCommit != nil {
+		node.config.OnCommit(block)
+	}
+
+	node.height = block.Height + 1
+	node.prevHash = block.Hash
+	node.resetHeight()
+	node.startRound(0)
+
+	if next, exists := node.futureCommits[node.height]; exists {
+		delete(node.futureCommits, node.height)
+		node.inbox = append(node.inbox, next)
+	}
+
+	for height := range node.futureCommits {
+		if height < node.height {
+			delete(node.futureCommits, height)
+		}
+	}
+}
+
+func (node *Node) vote(messageType MessageType, blockHash []byte) {
+	if node.self == nil {
+		return
+	}
+
+	node.broadcast(Message{
+		Type:      messageType,
+		Height:    node.height,
+		Round:     node.round,
+		BlockHash: blockHash,
+	})
+}
+
+func (node *Node) broadcast(message Message) {
+	message.Sign(node.config.PrivateKey)
+	node.sent = append(node.sent, message)
+
+	if node.config.Transport != nil {
+		node.config.Transport.Broadcast(message)
+	}
+
+	node.inbox = append(node.inbox, message)
+}
+
+func (node *Node) timeout(base time.Duration, round int32) time.Duration {
+	return base + time.Duration(round)*node.config.Timeouts.Delta
+}
+
+func (node *Node) schedule(delay time.Duration, callback func()) {
+	node.timers = append(node.timers, node.config.Clock.AfterFunc(delay, callback))
+}
+
+func (node *Node) cancelTimers() {
+	for _, cancel := range node.timers {
+		cancel()
+	}
+
+	node.timers = nil
+}
+
+// scheduleGossip periodically re-sends this node's messages for the current
+// height and its latest decision, so dropped messages are eventually delivered
+// and lagging validators can catch up. Older rounds are included because a
+// proof-of-lock from an earlier round may be needed to unlock peers.
+func (node *Node) scheduleGossip() {
+	if node.config.Transport == nil {
+		return
+	}
+
+	node.gossip = node.config.Clock.AfterFunc(node.config.Timeouts.Gossip, func() {
+		if node.stopped {
+			return
+		}
+
+		node.catchupSent = make(map[uint64]bool)
+
+		for _, message := range node.sent {
+			node.config.Transport.Broadcast(message)
+		}
+
+		if decision, exists := node.decisions[node.height-1]; exists {
+			node.config.Transport.Broadcast(Message{Type: MessageCommit, Height: decision.Height, Block: &decision})
+		}
+
+		node.scheduleGossip()
+	})
+}
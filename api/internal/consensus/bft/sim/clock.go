@@ -0,0 +1,97 @@
+package sim
+
+import (
+	"container/heap"
+	"time"
+)
+
+type event struct {
+	at       time.Duration
+	sequence uint64
+	callback func()
+	canceled bool
+}
+
+type eventQueue []*event
+
+func (queue eventQueue) Len() int {
+	return len(queue)
+}
+
+func (queue eventQueue) Less(i, j int) bool {
+	if queue[i].at != queue[j].at {
+		return queue[i].at < queue[j].at
+	}
+
+	return queue[i].sequence < queue[j].sequence
+}
+
+func (queue eventQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+}
+
+func (queue *eventQueue) Push(value any) {
+	*queue = append(*queue, value.(*event))
+}
+
+func (queue *eventQueue) Pop() any {
+	old := *queue
+	last := old[len(old)-1]
+	*queue = old[:len(old)-1]
+
+	return last
+}
+
+// Clock is a fake clock that only advances when the next scheduled event runs.
+// Events at the same instant run in scheduling order, which keeps a
+// simulation fully deterministic for a given seed.
+type Clock struct {
+	now      time.Duration
+	sequence uint64
+	events   eventQueue
+}
+
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+func (clock *Clock) Now() time.Duration {
+	return clock.now
+}
+
+func (clock *Clock) AfterFunc(delay time.Duration, callback func()) func() {
+	if delay < 0 {
+		delay = 0
+	}
+
+	clock.sequence++
+	scheduled := &event{at: clock.now + delay, sequence: clock.sequence, callback: callback}
+	heap.Push(&clock.events, scheduled)
+
+	return func() { scheduled.canceled = true }
+}
+
+// Step runs the next pending event and reports whether one was found before
+// the deadline.
+func (clock *Clock) Step(deadline time.Duration) bool {
+	for clock.events.Len() > 0 {
+		next := clock.events[0]
+
+		if next.at > deadline {
+			return false
+		}
+
+		heap.Pop(&clock.events)
+
+		if next.canceled {
+			continue
+		}
+
+		clock.now = next.at
+		next.callback()
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,384 @@
+package sim
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/consensus/bft"
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+type Behaviour string
+
+const (
+	BehaviourHonest    Behaviour = "honest"
+	BehaviourSilent    Behaviour = "silent"
+	BehaviourByzantine Behaviour = "byzantine"
+	BehaviourInvalid   Behaviour = "invalid"
+)
+
+// Config describes a simulated network. The first Byzantine validators
+// equivocate on every proposal and vote, the next Silent validators never
+// start and the next Invalid validators vote honestly but propose blocks that
+// fail validation; the rest are honest. Each validator offers its next block BlockTime
+// after deciding the previous one.
+type Config struct {
+	Validators int
+	Byzantine  int
+	Silent     int
+	Invalid    int
+	Seed       int64
+	MinDelay   time.Duration
+	MaxDelay   time.Duration
+	DropRate   float64
+	BlockTime  time.Duration
+	Timeouts   bft.Timeouts
+}
+
+type simNode struct {
+	index      int
+	behaviour  Behaviour
+	privateKey ed25519.PrivateKey
+	node       *bft.Node
+	decisions  []core.Block
+}
+
+type Simulation struct {
+	config           Config
+	random           *rand.Rand
+	clock            *Clock
+	validators       *bft.ValidatorSet
+	nodes            []*simNode
+	delivered        uint64
+	dropped          uint64
+	invalidProposals uint64
+	invalidPrevotes  uint64
+	invalidHashes    map[string]bool
+}
+
+var genesisHash = []byte("genesis")
+
+// invalidRoot marks the blocks invalid validators propose, which every
+// validator's validation rejects.
+var invalidRoot = []byte("invalid")
+
+var errInvalidBlock = errors.New("block fails validation")
+
+func DefaultTimeouts() bft.Timeouts {
+	return bft.Timeouts{
+		Propose:   300 * time.Millisecond,
+		Prevote:   100 * time.Millisecond,
+		Precommit: 100 * time.Millisecond,
+		Delta:     50 * time.Millisecond,
+		Gossip:    200 * time.Millisecond,
+	}
+}
+
+func New(config Config) (*Simulation, error) {
+	if config.Validators <= 0 {
+		return nil, errors.New("at least one validator is required")
+	}
+
+	if config.Byzantine+config.Silent+config.Invalid > config.Validators {
+		return nil, errors.New("more faulty validators than validators")
+	}
+
+	if config.MaxDelay < config.MinDelay {
+		return nil, errors.New("max delay below min delay")
+	}
+
+	if config.Timeouts == (bft.Timeouts{}) {
+		config.Timeouts = DefaultTimeouts()
+	}
+
+	if config.BlockTime == 0 {
+		config.BlockTime = 10 * time.Millisecond
+	}
+
+	simulation := &Simulation{
+		config:        config,
+		random:        rand.New(rand.NewSource(config.Seed)),
+		clock:         NewClock(),
+		invalidHashes: make(map[string]bool),
+	}
+
+	validators := make([]bft.Validator, 0, config.Validators)
+
+	for i := 0; i < config.Validators; i++ {
+		seed := sha256.Sum256(binary.BigEndian.AppendUint64([]byte("validator"), uint64(config.Seed)+uint64(i)))
+		privateKey := ed25519.NewKeyFromSeed(seed[:])
+
+		behaviour := BehaviourHonest
+
+		switch {
+		case i < config.Byzantine:
+			behaviour = BehaviourByzantine
+		case i < config.Byzantine+config.Silent:
+			behaviour = BehaviourSilent
+		case i < config.Byzantine+config.Silent+config.Invalid:
+			behaviour = BehaviourInvalid
+		}
+
+		simulation.nodes = append(simulation.nodes, &simNode{index: i, behaviour: behaviour, privateKey: privateKey})
+		validators = append(validators, bft.Validator{PublicKey: privateKey.Public().(ed25519.PublicKey), Power: 1})
+	}
+
+	validatorSet, err := bft.NewValidatorSet(validators)
+
+	if err != nil {
+		return nil, err
+	}
+
+	simulation.validators = validatorSet
+
+	for _, current := range simulation.nodes {
+		node, err := bft.NewNode(bft.NodeConfig{
+			Validators: validatorSet,
+			PrivateKey: current.privateKey,
+			Height:     1,
+			PrevHash:   genesisHash,
+			Timeouts:   config.Timeouts,
+			Clock:      simulation.clock,
+			Transport:  &transport{simulation: simulation, from: current},
+			Validate:   validate,
+			OnCommit: func(block core.Block) {
+				current.decisions = append(current.decisions, block)
+				next := simulation.makeBlock(current, block.Height+1, block.Hash)
+
+				simulation.clock.AfterFunc(config.BlockTime, func() {
+					current.node.Offer(next)
+				})
+			},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		current.node = node
+	}
+
+	for _, current := range simulation.nodes {
+		if current.behaviour == BehaviourSilent {
+			continue
+		}
+
+		current.node.Offer(simulation.makeBlock(current, 1, genesisHash))
+		current.node.Start()
+	}
+
+	return simulation, nil
+}
+
+func (simulation *Simulation) Now() time.Duration {
+	return simulation.clock.Now()
+}
+
+func (simulation *Simulation) Validators() *bft.ValidatorSet {
+	return simulation.validators
+}
+
+func (simulation *Simulation) Stats() (delivered, dropped uint64) {
+	return simulation.delivered, simulation.dropped
+}
+
+// InvalidVotes reports how many invalid proposals were broadcast and how many
+// prevotes for them honest validators cast.
+func (simulation *Simulation) InvalidVotes() (proposals, prevotes uint64) {
+	return simulation.invalidProposals, simulation.invalidPrevotes
+}
+
+func (simulation *Simulation) Decisions(index int) []core.Block {
+	return simulation.nodes[index].decisions
+}
+
+func (simulation *Simulation) Behaviour(index int) Behaviour {
+	return simulation.nodes[index].behaviour
+}
+
+// Run advances simulated time until every honest validator has decided the
+// target height, and fails if that does not happen before the limit.
+func (simulation *Simulation) Run(targetHeight uint64, limit time.Duration) error {
+	for !simulation.reached(targetHeight) {
+		if !simulation.clock.Step(limit) {
+			return simulation.livenessError(targetHeight)
+		}
+	}
+
+	return nil
+}
+
+func (simulation *Simulation) reached(targetHeight uint64) bool {
+	for _, current := range simulation.nodes {
+		if current.behaviour == BehaviourHonest && uint64(len(current.decisions)) < targetHeight {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (simulation *Simulation) livenessError(targetHeight uint64) error {
+	for _, current := range simulation.nodes {
+		if current.behaviour == BehaviourHonest && uint64(len(current.decisions)) < targetHeight {
+			return fmt.Errorf("liveness: validator %d decided %d of %d heights after %s", current.index, len(current.decisions), targetHeight, simulation.clock.Now())
+		}
+	}
+
+	return fmt.Errorf("liveness: target height %d not reached after %s", targetHeight, simulation.clock.Now())
+}
+
+// CheckSafety verifies that honest validators never decided different blocks
+// at the same height and that every decision carries a valid commit.
+func (simulation *Simulation) CheckSafety() error {
+	decided := make(map[uint64][]byte)
+
+	for _, current := range simulation.nodes {
+		if current.behaviour != BehaviourHonest {
+			continue
+		}
+
+		for i, block := range current.decisions {
+			if block.Height != uint64(i)+1 {
+				return fmt.Errorf("safety: validator %d decided height %d out of order", current.index, block.Height)
+			}
+
+			if err := simulation.validators.VerifyCommit(block); err != nil {
+				return fmt.Errorf("safety: validator %d height %d: %w", current.index, block.Height, err)
+			}
+
+			if existing, exists := decided[block.Height]; exists && !bytes.Equal(existing, block.Hash) {
+				return fmt.Errorf("safety: conflicting decisions at height %d: %x and %x", block.Height, existing, block.Hash)
+			}
+
+			decided[block.Height] = block.Hash
+		}
+	}
+
+	return nil
+}
+
+func (simulation *Simulation) deliver(to *simNode, message bft.Message) {
+	if to.behaviour == BehaviourSilent {
+		return
+	}
+
+	if simulation.config.DropRate > 0 && simulation.random.Float64() < simulation.config.DropRate {
+		simulation.dropped++
+
+		return
+	}
+
+	delay := simulation.config.MinDelay
+
+	if spread := simulation.config.MaxDelay - simulation.config.MinDelay; spread > 0 {
+		delay += time.Duration(simulation.random.Int63n(int64(spread) + 1))
+	}
+
+	simulation.clock.AfterFunc(delay, func() {
+		simulation.delivered++
+		to.node.Receive(message)
+	})
+}
+
+type transport struct {
+	simulation *Simulation
+	from       *simNode
+}
+
+func (transport *transport) Broadcast(message bft.Message) {
+	simulation := transport.simulation
+
+	switch {
+	case message.Type == bft.MessageProposal && validate(*message.Block) != nil:
+		simulation.invalidProposals++
+
+	case message.Type == bft.MessagePrevote && transport.from.behaviour == BehaviourHonest && simulation.invalidHashes[string(message.BlockHash)]:
+		simulation.invalidPrevotes++
+	}
+
+	if transport.from.behaviour == BehaviourByzantine {
+		transport.equivocate(message)
+
+		return
+	}
+
+	for _, peer := range simulation.nodes {
+		if peer != transport.from {
+			simulation.deliver(peer, message)
+		}
+	}
+}
+
+// equivocate sends the genuine message to half of the peers and a conflicting,
+// correctly signed message for the same height and round to the other half.
+func (transport *transport) equivocate(message bft.Message) {
+	simulation := transport.simulation
+	conflicting := message
+
+	switch message.Type {
+	case bft.MessageProposal:
+		block := *message.Block
+		hash := sha256.Sum256(append(append([]byte(nil), block.Hash...), "equivocation"...))
+		block.Hash = hash[:]
+		conflicting.Block = &block
+		conflicting.BlockHash = block.Hash
+		conflicting.Sign(transport.from.privateKey)
+
+	case bft.MessagePrevote, bft.MessagePrecommit:
+		hash := sha256.Sum256(binary.BigEndian.AppendUint64([]byte("equivocation"), simulation.random.Uint64()))
+		conflicting.BlockHash = hash[:]
+		conflicting.Sign(transport.from.privateKey)
+	}
+
+	for i, peer := range simulation.nodes {
+		if peer == transport.from {
+			continue
+		}
+
+		if i%2 == 0 {
+			simulation.deliver(peer, message)
+		} else {
+			simulation.deliver(peer, conflicting)
+		}
+	}
+}
+
+func (simulation *Simulation) makeBlock(proposer *simNode, height uint64, prevHash []byte) core.Block {
+	input := binary.BigEndian.AppendUint64(nil, uint64(proposer.index))
+	input = binary.BigEndian.AppendUint64(input, height)
+	input = append(input, prevHash...)
+	block := core.Block{
+		Height:   height,
+		PrevHash: append([]byte(nil), prevHash...),
+	}
+
+	if proposer.behaviour == BehaviourInvalid {
+		block.StateRoot = invalidRoot
+		input = append(input, invalidRoot...)
+	}
+
+	hash := sha256.Sum256(input)
+	block.Hash = hash[:]
+
+	if proposer.behaviour == BehaviourInvalid {
+		simulation.invalidHashes[string(block.Hash)] = true
+	}
+
+	return block
+}
+
+// validate stands in for the chain's validation of a proposal.
+func validate(block core.Block) error {
+	if bytes.Equal(block.StateRoot, invalidRoot) {
+		return errInvalidBlock
+	}
+
+	return nil
+}
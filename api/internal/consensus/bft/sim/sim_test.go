@@ -0,0 +1,144 @@
+package sim
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func run(t *testing.T, config Config, targetHeight uint64, limit time.Duration) *Simulation {
+	t.Helper()
+
+	simulation, err := New(config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := simulation.Run(targetHeight, limit); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := simulation.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+
+	return simulation
+}
+
+func TestHonestValidators(t *testing.T) {
+	t.Parallel()
+
+	run(t, Config{Validators: 4, Seed: 1, MinDelay: 5 * time.Millisecond, MaxDelay: 20 * time.Millisecond}, 20, time.Minute)
+}
+
+func TestSingleValidator(t *testing.T) {
+	t.Parallel()
+
+	run(t, Config{Validators: 1, Seed: 2}, 10, time.Second)
+}
+
+func TestDelaysAndDrops(t *testing.T) {
+	t.Parallel()
+
+	simulation := run(t, Config{
+		Validators: 7,
+		Seed:       3,
+		MinDelay:   10 * time.Millisecond,
+		MaxDelay:   250 * time.Millisecond,
+		DropRate:   0.2,
+	}, 15, 10*time.Minute)
+
+	if _, dropped := simulation.Stats(); dropped == 0 {
+		t.Fatal("expected the network to drop messages")
+	}
+}
+
+func TestSilentValidators(t *testing.T) {
+	t.Parallel()
+
+	run(t, Config{Validators: 7, Silent: 2, Seed: 4, MinDelay: 5 * time.Millisecond, MaxDelay: 50 * time.Millisecond}, 15, 5*time.Minute)
+}
+
+func TestByzantineEquivocation(t *testing.T) {
+	t.Parallel()
+
+	for seed := int64(0); seed < 5; seed++ {
+		run(t, Config{Validators: 4, Byzantine: 1, Seed: seed, MinDelay: 5 * time.Millisecond, MaxDelay: 80 * time.Millisecond, DropRate: 0.05}, 10, 5*time.Minute)
+	}
+}
+
+func TestByzantineAndSilent(t *testing.T) {
+	t.Parallel()
+
+	run(t, Config{Validators: 10, Byzantine: 2, Silent: 1, Seed: 5, MinDelay: 5 * time.Millisecond, MaxDelay: 100 * time.Millisecond, DropRate: 0.1}, 5, 10*time.Minute)
+}
+
+func TestInvalidProposals(t *testing.T) {
+	t.Parallel()
+
+	simulation := run(t, Config{Validators: 4, Invalid: 1, Seed: 8, MinDelay: 5 * time.Millisecond, MaxDelay: 50 * time.Millisecond}, 10, 5*time.Minute)
+	proposals, prevotes := simulation.InvalidVotes()
+
+	if proposals == 0 {
+		t.Fatal("expected the invalid validator to propose")
+	}
+
+	// Honest validators prevote nil for every invalid proposal, so its rounds
+	// move on to the next proposer.
+	if prevotes != 0 {
+		t.Fatalf("honest validators cast %d prevotes for invalid proposals", prevotes)
+	}
+
+	for index := 1; index < 4; index++ {
+		for _, block := range simulation.Decisions(index) {
+			if validate(block) != nil {
+				t.Fatalf("validator %d decided invalid block %d", index, block.Height)
+			}
+		}
+	}
+}
+
+func TestNoLivenessWithoutQuorum(t *testing.T) {
+	t.Parallel()
+
+	simulation, err := New(Config{Validators: 4, Silent: 2, Seed: 6, MinDelay: 5 * time.Millisecond, MaxDelay: 20 * time.Millisecond})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := simulation.Run(1, time.Minute); err == nil {
+		t.Fatal("expected no progress with half of the validators offline")
+	}
+
+	if err := simulation.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	t.Parallel()
+
+	config := Config{Validators: 4, Byzantine: 1, Seed: 7, MinDelay: 5 * time.Millisecond, MaxDelay: 100 * time.Millisecond, DropRate: 0.1}
+	first := run(t, config, 10, 5*time.Minute)
+	second := run(t, config, 10, 5*time.Minute)
+
+	if first.Now() != second.Now() {
+		t.Fatalf("simulations finished at %s and %s", first.Now(), second.Now())
+	}
+
+	for index := 0; index < config.Validators; index++ {
+		firstDecisions, secondDecisions := first.Decisions(index), second.Decisions(index)
+
+		if len(firstDecisions) != len(secondDecisions) {
+			t.Fatalf("validator %d decided %d and %d blocks", index, len(firstDecisions), len(secondDecisions))
+		}
+
+		for i := range firstDecisions {
+			if !bytes.Equal(firstDecisions[i].Hash, secondDecisions[i].Hash) {
+				t.Fatalf("validator %d diverged at height %d", index, firstDecisions[i].Height)
+			}
+		}
+	}
+}
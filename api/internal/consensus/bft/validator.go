@@ -0,0 +1,152 @@
+package bft
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+type Validator struct {
+	PublicKey ed25519.PublicKey
+	Power     uint64
+}
+
+type ValidatorSet struct {
+	validators []Validator
+	index      map[string]int
+	totalPower uint64
+}
+
+func NewValidatorSet(validators []Validator) (*ValidatorSet, error) {
+	if len(validators) == 0 {
+		return nil, errors.New("validator set is empty")
+	}
+
+	sorted := make([]Validator, len(validators))
+	copy(sorted, validators)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].PublicKey, sorted[j].PublicKey) < 0
+	})
+
+	set := &ValidatorSet{
+		validators: sorted,
+		index:      make(map[string]int, len(sorted)),
+	}
+
+	for i, validator := range sorted {
+		if len(validator.PublicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("validator %d: invalid public key", i)
+		}
+
+		if validator.Power == 0 {
+			return nil, fmt.Errorf("validator %x: power must be positive", []byte(validator.PublicKey))
+		}
+
+		key := string(validator.PublicKey)
+
+		if _, exists := set.index[key]; exists {
+			return nil, fmt.Errorf("validator %x: duplicate public key", []byte(validator.PublicKey))
+		}
+
+		set.index[key] = i
+		set.totalPower += validator.Power
+	}
+
+	return set, nil
+}
+
+func (set *ValidatorSet) Size() int {
+	return len(set.validators)
+}
+
+func (set *ValidatorSet) Validators() []Validator {
+	result := make([]Validator, len(set.validators))
+	copy(result, set.validators)
+
+	return result
+}
+
+func (set *ValidatorSet) TotalPower() uint64 {
+	return set.totalPower
+}
+
+// QuorumPower is the smallest voting power strictly greater than two thirds
+// of the total, i.e. the 2f+1 threshold for prevotes and precommits.
+func (set *ValidatorSet) QuorumPower() uint64 {
+	return set.totalPower*2/3 + 1
+}
+
+// FaultPower is the smallest voting power strictly greater than one third of
+// the total, i.e. the f+1 threshold that guarantees one honest participant.
+func (set *ValidatorSet) FaultPower() uint64 {
+	return set.totalPower/3 + 1
+}
+
+func (set *ValidatorSet) Power(publicKey []byte) (uint64, bool) {
+	i, exists := set.index[string(publicKey)]
+
+	if !exists {
+		return 0, false
+	}
+
+	return set.validators[i].Power, true
+}
+
+// Proposer rotates through the validators in key order, advancing by one for
+// every height and every round so a silent proposer only stalls one round.
+func (set *ValidatorSet) Proposer(height uint64, round int32) Validator {
+	offset := (height + uint64(round)) % uint64(len(set.validators))
+
+	return set.validators[offset]
+}
+
+// VerifyCommit checks that the block carries precommits from more than two
+// thirds of the voting power for its hash at the recorded commit round.
+func (set *ValidatorSet) VerifyCommit(block core.Block) error {
+	if block.Commit == nil {
+		return errors.New("missing commit")
+	}
+
+	seen := make(map[string]bool, len(block.Commit.Signatures))
+	var power uint64
+
+	for _, signature := range block.Commit.Signatures {
+		signerPower, exists := set.Power(signature.Signer)
+
+		if !exists {
+			return fmt.Errorf("commit signed by unknown validator %x", signature.Signer)
+		}
+
+		if seen[string(signature.Signer)] {
+			return fmt.Errorf("commit signed twice by validator %x", signature.Signer)
+		}
+
+		seen[string(signature.Signer)] = true
+
+		vote := Message{
+			Type:      MessagePrecommit,
+			Height:    block.Height,
+			Round:     block.Commit.Round,
+			BlockHash: block.Hash,
+			Validator: signature.Signer,
+			Signature: signature.Signature,
+		}
+
+		if !vote.Verify() {
+			return fmt.Errorf("invalid commit signature from validator %x", signature.Signer)
+		}
+
+		power += signerPower
+	}
+
+	if power < set.QuorumPower() {
+		return fmt.Errorf("commit power %d below quorum %d", power, set.QuorumPower())
+	}
+
+	return nil
+}
@@ -1,9 +1,36 @@
 package consensus
 
-import "github.com/afrodynamic/gochain/api/internal/core"
+import (
+	"math/big"
+
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+type Finality string
+
+const (
+	FinalityProbabilistic Finality = "probabilistic"
+	FinalityInstant       Finality = "instant"
+)
 
 type Engine interface {
+	Start() error
+	Stop() error
 	Seal(block core.Block) (core.Block, error)
 	Validate(block core.Block) error
+	Finality() Finality
 	Name() string
 }
+
+// Weigher is implemented by engines whose fork choice weighs blocks, such as
+// proof of work by the work behind each. Without it every block weighs the
+// same and the longest chain wins.
+type Weigher interface {
+	Weight(block core.Block) *big.Int
+}
+
+// Advancer is implemented by engines that track the height being decided and
+// must be moved past blocks the chain imports from peers.
+type Advancer interface {
+	Advance(block core.Block)
+}
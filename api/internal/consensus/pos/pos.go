@@ -1,6 +1,7 @@
 package pos
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"errors"
 
@@ -16,10 +17,38 @@ type Engine struct {
 	stakeReader StakeReader
 }
 
+type FixedStake uint64
+
+func (stake FixedStake) TotalStake() uint64 {
+	return uint64(stake)
+}
+
 func New(stakeReader StakeReader) consensus.Engine {
 	return &Engine{stakeReader: stakeReader}
 }
 
+func Factory(params consensus.Params) (consensus.Engine, error) {
+	var totalStake uint64
+
+	for _, validator := range params.Genesis.Validators {
+		totalStake += validator.Power
+	}
+
+	if totalStake == 0 {
+		return nil, errors.New("proof of stake requires at least one validator")
+	}
+
+	return New(FixedStake(totalStake)), nil
+}
+
+func (engine *Engine) Start() error {
+	return nil
+}
+
+func (engine *Engine) Stop() error {
+	return nil
+}
+
 func (engine *Engine) Seal(block core.Block) (core.Block, error) {
 	totalStake := engine.stakeReader.TotalStake()
 
@@ -27,20 +56,29 @@ func (engine *Engine) Seal(block core.Block) (core.Block, error) {
 		return core.Block{}, errors.New("no stake")
 	}
 
-	hash := sha256.Sum256(append(block.Hash, byte(totalStake%255)))
-	block.Hash = hash[:]
+	block.Hash = engine.stakeHash(block)
 
 	return block, nil
 }
 
 func (engine *Engine) Validate(block core.Block) error {
-	if len(block.Hash) == 0 {
+	if len(block.Hash) == 0 || !bytes.Equal(block.Hash, engine.stakeHash(block)) {
 		return errors.New("invalid proof of stake")
 	}
 
 	return nil
 }
 
+func (engine *Engine) stakeHash(block core.Block) []byte {
+	hash := sha256.Sum256(append(block.SealHash(), byte(engine.stakeReader.TotalStake()%255)))
+
+	return hash[:]
+}
+
+func (engine *Engine) Finality() consensus.Finality {
+	return consensus.FinalityProbabilistic
+}
+
 func (engine *Engine) Name() string {
 	return "proof_of_stake"
 }
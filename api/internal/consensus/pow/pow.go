@@ -1,29 +1,61 @@
 package pow
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
+	"math/big"
 
 	"github.com/afrodynamic/gochain/api/internal/consensus"
 	"github.com/afrodynamic/gochain/api/internal/core"
 )
 
 type Engine struct {
-	difficulty uint8
+	difficultyAt func(height uint64) uint8
 }
 
 func New(difficulty uint8) consensus.Engine {
-	return &Engine{difficulty: difficulty}
+	return &Engine{difficultyAt: func(uint64) uint8 { return difficulty }}
+}
+
+// NewWithSchedule takes the difficulty for each block from the rules active at
+// its height, so forks can retarget the chain.
+func NewWithSchedule(schedule core.Schedule) consensus.Engine {
+	return &Engine{difficultyAt: func(height uint64) uint8 { return schedule.At(height).Rules.Difficulty }}
+}
+
+func Factory(params consensus.Params) (consensus.Engine, error) {
+	if params.Genesis.Difficulty == 0 {
+		return nil, errors.New("proof of work difficulty must be positive")
+	}
+
+	if len(params.Schedule.Forks()) == 0 {
+		return New(params.Genesis.Difficulty), nil
+	}
+
+	return NewWithSchedule(params.Schedule), nil
+}
+
+func (engine *Engine) Start() error {
+	return nil
+}
+
+func (engine *Engine) Stop() error {
+	return nil
 }
 
 func (engine *Engine) Seal(block core.Block) (core.Block, error) {
 	var nonce uint64
+	sealHash := block.SealHash()
+	difficulty := engine.difficultyAt(block.Height)
 
 	for {
-		hash := sha256.Sum256(append(block.Hash, byte(nonce)))
+		hash := powHash(sealHash, nonce)
 
-		if countLeadingZeroBits(hash[:]) >= int(engine.difficulty) {
-			block.Hash = hash[:]
+		if countLeadingZeroBits(hash) >= int(difficulty) {
+			block.Nonce = nonce
+			block.Hash = hash
 			return block, nil
 		}
 
@@ -36,19 +68,39 @@ func (engine *Engine) Seal(block core.Block) (core.Block, error) {
 }
 
 func (engine *Engine) Validate(block core.Block) error {
-	hash := sha256.Sum256(block.Hash)
+	hash := powHash(block.SealHash(), block.Nonce)
+
+	if !bytes.Equal(hash, block.Hash) {
+		return errors.New("block hash does not match its contents")
+	}
 
-	if countLeadingZeroBits(hash[:]) < int(engine.difficulty) {
+	if countLeadingZeroBits(hash) < int(engine.difficultyAt(block.Height)) {
 		return errors.New("invalid proof of work")
 	}
 
 	return nil
 }
 
+// Weight is the expected number of hashes behind the block, so fork choice
+// follows the chain with the most work.
+func (engine *Engine) Weight(block core.Block) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(engine.difficultyAt(block.Height)))
+}
+
+func (engine *Engine) Finality() consensus.Finality {
+	return consensus.FinalityProbabilistic
+}
+
 func (engine *Engine) Name() string {
 	return "proof_of_work"
 }
 
+func powHash(sealHash []byte, nonce uint64) []byte {
+	hash := sha256.Sum256(binary.BigEndian.AppendUint64(append([]byte(nil), sealHash...), nonce))
+
+	return hash[:]
+}
+
 func countLeadingZeroBits(bytes []byte) int {
 	count := 0
 
@@ -69,3 +121,5 @@ func countLeadingZeroBits(bytes []byte) int {
 
 	return count
 }
+
+var _ consensus.Weigher = (*Engine)(nil)
@@ -0,0 +1,36 @@
+package pow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+func TestSealedBlockValidates(t *testing.T) {
+	t.Parallel()
+
+	engine := New(8)
+	block := core.Block{Height: 1, PrevHash: []byte("genesis"), Timestamp: time.Unix(1700000000, 0).UTC()}
+
+	sealed, err := engine.Seal(block)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := engine.Validate(sealed); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := sealed
+	tampered.Timestamp = tampered.Timestamp.Add(time.Second)
+
+	if err := engine.Validate(tampered); err == nil {
+		t.Fatal("expected a block whose contents changed after sealing to fail validation")
+	}
+
+	if err := New(255).Validate(sealed); err == nil {
+		t.Fatal("expected insufficient work to fail validation")
+	}
+}
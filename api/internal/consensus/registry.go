@@ -0,0 +1,59 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sort"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+type Params struct {
+	Genesis    genesis.Consensus
+	Schedule   core.Schedule
+	Height     uint64
+	PrevHash   []byte
+	PrivateKey ed25519.PrivateKey
+	// Validate checks a proposed block against the chain's state. Engines that
+	// vote on proposals reject the ones it fails; nil accepts every block.
+	Validate func(block core.Block) error
+}
+
+type Factory func(params Params) (Engine, error)
+
+type Registry struct {
+	factories map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]Factory),
+	}
+}
+
+func (r *Registry) Register(id string, factory Factory) {
+	r.factories[id] = factory
+}
+
+func (r *Registry) New(id string, params Params) (Engine, error) {
+	factory, exists := r.factories[id]
+
+	if !exists {
+		return nil, fmt.Errorf("unknown consensus engine %q (available: %v)", id, r.Names())
+	}
+
+	return factory(params)
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.factories))
+
+	for name := range r.factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
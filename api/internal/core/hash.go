@@ -0,0 +1,52 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// ComputeHash returns the transaction hash over its signed-off content, so
+// every node derives the same hash for a transaction received from a peer.
+// Data is length-prefixed after the rest, and only when there is any, so
+// transactions without data keep the hashes they had before it was carried.
+func (tx Transaction) ComputeHash() []byte {
+	input := make([]byte, 0, len(tx.From)+len(tx.To)+32+len(time.RFC3339Nano)+len(tx.Data))
+	input = append(input, tx.From...)
+	input = append(input, tx.To...)
+	input = binary.BigEndian.AppendUint64(input, tx.Amount)
+	input = binary.BigEndian.AppendUint64(input, tx.Fee)
+	input = binary.BigEndian.AppendUint64(input, tx.Nonce)
+	input = append(input, tx.Timestamp.UTC().Format(time.RFC3339Nano)...)
+
+	if len(tx.Data) > 0 {
+		input = binary.BigEndian.AppendUint64(input, uint64(len(tx.Data)))
+		input = append(input, tx.Data...)
+	}
+
+	hash := sha256.Sum256(input)
+
+	return hash[:]
+}
+
+// SealHash commits to the block header that consensus engines seal over. It
+// excludes the block hash itself and the seal (nonce, proposer and commit);
+// the body is covered through TxRoot and the resulting state through
+// StateRoot, so headers validate without either.
+func (block Block) SealHash() []byte {
+	hasher := sha256.New()
+	hasher.Write(binary.BigEndian.AppendUint64(nil, block.Height))
+	hasher.Write(block.PrevHash)
+	hasher.Write([]byte(block.Timestamp.UTC().Format(time.RFC3339Nano)))
+	hasher.Write(block.TxRoot)
+	hasher.Write(block.StateRoot)
+
+	return hasher.Sum(nil)
+}
+
+// Header is the block without its transactions.
+func (block Block) Header() Block {
+	block.Transactions = nil
+
+	return block
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleRoot builds the RFC 6962 tree hash over the leaves. Leaves and inner
+// nodes are hashed with different prefixes so an inner node can never be
+// passed off as a leaf.
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		empty := sha256.Sum256(nil)
+
+		return empty[:]
+	}
+
+	if len(leaves) == 1 {
+		return merkleLeaf(leaves[0])
+	}
+
+	split := merkleSplit(len(leaves))
+
+	return merkleNode(MerkleRoot(leaves[:split]), MerkleRoot(leaves[split:]))
+}
+
+// TxRoot is the Merkle root over the transaction hashes of a block body.
+func TxRoot(txs []Transaction) []byte {
+	leaves := make([][]byte, 0, len(txs))
+
+	for _, tx := range txs {
+		leaves = append(leaves, tx.Hash)
+	}
+
+	return MerkleRoot(leaves)
+}
+
+func merkleLeaf(leaf []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf...))
+
+	return hash[:]
+}
+
+func merkleNode(left, right []byte) []byte {
+	input := make([]byte, 0, 1+len(left)+len(right))
+	input = append(input, merkleNodePrefix)
+	input = append(input, left...)
+	input = append(input, right...)
+	hash := sha256.Sum256(input)
+
+	return hash[:]
+}
+
+// merkleSplit is the largest power of two smaller than size.
+func merkleSplit(size int) int {
+	split := 1
+
+	for split*2 < size {
+		split *= 2
+	}
+
+	return split
+}
+
+// MerkleProof is the RFC 6962 audit path for the leaf at Index in a tree of
+// Size leaves, ordered from the leaf up to the root.
+type MerkleProof struct {
+	Index  uint64
+	Size   uint64
+	Hashes [][]byte
+}
+
+func BuildMerkleProof(leaves [][]byte, index int) (MerkleProof, error) {
+	if index < 0 || index >= len(leaves) {
+		return MerkleProof{}, fmt.Errorf("leaf %d is outside a tree of %d leaves", index, len(leaves))
+	}
+
+	return MerkleProof{Index: uint64(index), Size: uint64(len(leaves)), Hashes: merklePath(leaves, index)}, nil
+}
+
+func merklePath(leaves [][]byte, index int) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+
+	split := merkleSplit(len(leaves))
+
+	if index < split {
+		return append(merklePath(leaves[:split], index), MerkleRoot(leaves[split:]))
+	}
+
+	return append(merklePath(leaves[split:], index-split), MerkleRoot(leaves[:split]))
+}
+
+// VerifyMerkleProof reports whether the leaf sits at the proof's index in the
+// tree with the given root.
+func VerifyMerkleProof(root, leaf []byte, proof MerkleProof) bool {
+	if proof.Index >= proof.Size {
+		return false
+	}
+
+	hash, ok := merkleProofRoot(merkleLeaf(leaf), proof.Index, proof.Size, proof.Hashes)
+
+	return ok && bytes.Equal(hash, root)
+}
+
+func merkleProofRoot(hash []byte, index, size uint64, path [][]byte) ([]byte, bool) {
+	if size == 1 {
+		return hash, len(path) == 0
+	}
+
+	if len(path) == 0 {
+		return nil, false
+	}
+
+	sibling := path[len(path)-1]
+	split := uint64(merkleSplit(int(size)))
+
+	if index < split {
+		left, ok := merkleProofRoot(hash, index, split, path[:len(path)-1])
+
+		return merkleNode(left, sibling), ok
+	}
+
+	right, ok := merkleProofRoot(hash, index-split, size-split, path[:len(path)-1])
+
+	return merkleNode(sibling, right), ok
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Rules are the protocol parameters in force at a height. Zero fields in a
+// fork inherit the value of the previous rule set. MaxMint is the most a
+// block may credit with mint transactions, which have no sender.
+type Rules struct {
+	MinFee       uint64 `json:"minFee,omitempty"`
+	MaxDataBytes uint32 `json:"maxDataBytes,omitempty"`
+	Difficulty   uint8  `json:"difficulty,omitempty"`
+	MaxMint      uint64 `json:"maxMint,omitempty"`
+}
+
+type Fork struct {
+	Name   string `json:"name"`
+	Height uint64 `json:"height"`
+	Rules  Rules  `json:"rules"`
+}
+
+const GenesisForkName = "genesis"
+
+type Schedule struct {
+	forks []Fork
+}
+
+func NewSchedule(base Rules, forks []Fork) (Schedule, error) {
+	sorted := make([]Fork, len(forks))
+	copy(sorted, forks)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Height < sorted[j].Height
+	})
+
+	resolved := []Fork{{Name: GenesisForkName, Height: 0, Rules: base}}
+	names := map[string]bool{GenesisForkName: true}
+
+	for _, fork := range sorted {
+		if fork.Name == "" {
+			return Schedule{}, errors.New("fork name is required")
+		}
+
+		if names[fork.Name] {
+			return Schedule{}, fmt.Errorf("fork %q defined twice", fork.Name)
+		}
+
+		previous := resolved[len(resolved)-1]
+
+		if fork.Height == 0 || fork.Height == previous.Height {
+			return Schedule{}, fmt.Errorf("fork %q: activation height %d must be positive and unique", fork.Name, fork.Height)
+		}
+
+		names[fork.Name] = true
+		fork.Rules = fork.Rules.inherit(previous.Rules)
+		resolved = append(resolved, fork)
+	}
+
+	return Schedule{forks: resolved}, nil
+}
+
+func (rules Rules) inherit(previous Rules) Rules {
+	if rules.MinFee == 0 {
+		rules.MinFee = previous.MinFee
+	}
+
+	if rules.MaxDataBytes == 0 {
+		rules.MaxDataBytes = previous.MaxDataBytes
+	}
+
+	if rules.Difficulty == 0 {
+		rules.Difficulty = previous.Difficulty
+	}
+
+	if rules.MaxMint == 0 {
+		rules.MaxMint = previous.MaxMint
+	}
+
+	return rules
+}
+
+func (schedule Schedule) At(height uint64) Fork {
+	if len(schedule.forks) == 0 {
+		return Fork{Name: GenesisForkName}
+	}
+
+	index := sort.Search(len(schedule.forks), func(i int) bool {
+		return schedule.forks[i].Height > height
+	})
+
+	return schedule.forks[index-1]
+}
+
+func (schedule Schedule) Forks() []Fork {
+	result := make([]Fork, len(schedule.forks))
+	copy(result, schedule.forks)
+
+	return result
+}
+
+func (rules Rules) CheckTx(tx Tx) error {
+	if tx.Fee < rules.MinFee {
+		return fmt.Errorf("fee %d below minimum %d", tx.Fee, rules.MinFee)
+	}
+
+	if rules.MaxDataBytes > 0 && len(tx.Data) > int(rules.MaxDataBytes) {
+		return fmt.Errorf("data size %d exceeds maximum %d", len(tx.Data), rules.MaxDataBytes)
+	}
+
+	return nil
+}
+
+// CheckMint checks a mint of amount in a block that has already minted
+// minted.
+func (rules Rules) CheckMint(minted, amount uint64) error {
+	if minted > rules.MaxMint || amount > rules.MaxMint-minted {
+		return fmt.Errorf("mint of %d exceeds the block allowance of %d", amount, rules.MaxMint)
+	}
+
+	return nil
+}
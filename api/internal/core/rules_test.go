@@ -0,0 +1,86 @@
+package core
+
+import "testing"
+
+func TestScheduleAt(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := NewSchedule(Rules{Difficulty: 8}, []Fork{
+		{Name: "data-limit", Height: 200, Rules: Rules{MaxDataBytes: 256}},
+		{Name: "fee-floor", Height: 100, Rules: Rules{MinFee: 5}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		height uint64
+		want   Fork
+	}{
+		{height: 0, want: Fork{Name: GenesisForkName, Height: 0, Rules: Rules{Difficulty: 8}}},
+		{height: 99, want: Fork{Name: GenesisForkName, Height: 0, Rules: Rules{Difficulty: 8}}},
+		{height: 100, want: Fork{Name: "fee-floor", Height: 100, Rules: Rules{MinFee: 5, Difficulty: 8}}},
+		{height: 250, want: Fork{Name: "data-limit", Height: 200, Rules: Rules{MinFee: 5, MaxDataBytes: 256, Difficulty: 8}}},
+	}
+
+	for _, test := range tests {
+		if got := schedule.At(test.height); got != test.want {
+			t.Fatalf("At(%d) = %+v, want %+v", test.height, got, test.want)
+		}
+	}
+}
+
+func TestNewScheduleRejectsInvalidForks(t *testing.T) {
+	t.Parallel()
+
+	invalid := [][]Fork{
+		{{Name: "", Height: 10}},
+		{{Name: "zero", Height: 0}},
+		{{Name: "a", Height: 10}, {Name: "b", Height: 10}},
+		{{Name: "a", Height: 10}, {Name: "a", Height: 20}},
+		{{Name: GenesisForkName, Height: 10}},
+	}
+
+	for _, forks := range invalid {
+		if _, err := NewSchedule(Rules{}, forks); err == nil {
+			t.Fatalf("expected %+v to be rejected", forks)
+		}
+	}
+}
+
+func TestRulesCheckTx(t *testing.T) {
+	t.Parallel()
+
+	rules := Rules{MinFee: 2, MaxDataBytes: 4}
+
+	if err := rules.CheckTx(Tx{Fee: 1}); err == nil {
+		t.Fatal("expected fee below minimum to be rejected")
+	}
+
+	if err := rules.CheckTx(Tx{Fee: 2, Data: []byte("12345")}); err == nil {
+		t.Fatal("expected oversized data to be rejected")
+	}
+
+	if err := rules.CheckTx(Tx{Fee: 2, Data: []byte("1234")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRulesCheckMint(t *testing.T) {
+	t.Parallel()
+
+	if err := (Rules{}).CheckMint(0, 1); err == nil {
+		t.Fatal("expected a mint without an allowance to be rejected")
+	}
+
+	rules := Rules{MaxMint: 100}
+
+	if err := rules.CheckMint(60, 40); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rules.CheckMint(60, 41); err == nil {
+		t.Fatal("expected a mint over the allowance to be rejected")
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// Account is the state of one address. Addresses without a balance or nonce
+// have no account and are left out of the state tree.
+type Account struct {
+	Address []byte
+	Balance uint64
+	Nonce   uint64
+}
+
+func (account Account) Empty() bool {
+	return account.Balance == 0 && account.Nonce == 0
+}
+
+// Leaf is the state tree leaf for the account. The address is length-prefixed
+// so no two accounts encode to the same leaf.
+func (account Account) Leaf() []byte {
+	leaf := make([]byte, 0, 4+len(account.Address)+16)
+	leaf = binary.BigEndian.AppendUint32(leaf, uint32(len(account.Address)))
+	leaf = append(leaf, account.Address...)
+	leaf = binary.BigEndian.AppendUint64(leaf, account.Balance)
+
+	return binary.BigEndian.AppendUint64(leaf, account.Nonce)
+}
+
+// SortAccounts orders accounts by address, the order the state tree uses.
+func SortAccounts(accounts []Account) {
+	sort.Slice(accounts, func(i, j int) bool { return bytes.Compare(accounts[i].Address, accounts[j].Address) < 0 })
+}
+
+// StateRoot is the Merkle root over the accounts in address order.
+func StateRoot(accounts []Account) []byte {
+	return MerkleRoot(accountLeaves(accounts))
+}
+
+func accountLeaves(accounts []Account) [][]byte {
+	leaves := make([][]byte, 0, len(accounts))
+
+	for _, account := range accounts {
+		leaves = append(leaves, account.Leaf())
+	}
+
+	return leaves
+}
+
+// StateProof proves the state of Address against a state root. When the
+// address has an account, Accounts holds just that account. Otherwise it holds
+// the accounts on either side of where the address would sort, which proves
+// that nothing lies between them; at either end of the tree there is only one
+// neighbour, and an empty tree needs none.
+type StateProof struct {
+	Address  []byte
+	Accounts []Account
+	Proofs   []MerkleProof
+}
+
+// BuildStateProof proves the address against the tree over the given
+// accounts, which must be sorted by address.
+func BuildStateProof(accounts []Account, address []byte) (StateProof, error) {
+	leaves := accountLeaves(accounts)
+	position := sort.Search(len(accounts), func(i int) bool { return bytes.Compare(accounts[i].Address, address) >= 0 })
+	proof := StateProof{Address: address}
+	indexes := make([]int, 0, 2)
+
+	switch {
+	case position < len(accounts) && bytes.Equal(accounts[position].Address, address):
+		indexes = append(indexes, position)
+
+	default:
+		if position > 0 {
+			indexes = append(indexes, position-1)
+		}
+
+		if position < len(accounts) {
+			indexes = append(indexes, position)
+		}
+	}
+
+	for _, index := range indexes {
+		merkleProof, err := BuildMerkleProof(leaves, index)
+
+		if err != nil {
+			return StateProof{}, err
+		}
+
+		proof.Accounts = append(proof.Accounts, accounts[index])
+		proof.Proofs = append(proof.Proofs, merkleProof)
+	}
+
+	return proof, nil
+}
+
+var ErrInvalidStateProof = errors.New("invalid state proof")
+
+// VerifyStateProof checks the proof against the state root and returns the
+// proven account, which is empty when the proof shows the address has none.
+func VerifyStateProof(root []byte, proof StateProof) (Account, error) {
+	if len(proof.Accounts) != len(proof.Proofs) {
+		return Account{}, ErrInvalidStateProof
+	}
+
+	for i, account := range proof.Accounts {
+		if !VerifyMerkleProof(root, account.Leaf(), proof.Proofs[i]) {
+			return Account{}, ErrInvalidStateProof
+		}
+	}
+
+	absent := Account{Address: proof.Address}
+
+	switch len(proof.Accounts) {
+	case 0:
+		if !bytes.Equal(root, MerkleRoot(nil)) {
+			return Account{}, ErrInvalidStateProof
+		}
+
+		return absent, nil
+
+	case 1:
+		account, merkleProof := proof.Accounts[0], proof.Proofs[0]
+		order := bytes.Compare(account.Address, proof.Address)
+
+		switch {
+		case order == 0:
+			return account, nil
+
+		// The address sorts before the first account or after the last one.
+		case order > 0 && merkleProof.Index == 0, order < 0 && merkleProof.Index == merkleProof.Size-1:
+			return absent, nil
+		}
+
+	case 2:
+		below, above := proof.Accounts[0], proof.Accounts[1]
+		adjacent := proof.Proofs[0].Size == proof.Proofs[1].Size && proof.Proofs[0].Index+1 == proof.Proofs[1].Index
+
+		if adjacent && bytes.Compare(below.Address, proof.Address) < 0 && bytes.Compare(proof.Address, above.Address) < 0 {
+			return absent, nil
+		}
+	}
+
+	return Account{}, ErrInvalidStateProof
+}
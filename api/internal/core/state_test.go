@@ -0,0 +1,114 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	t.Parallel()
+
+	for size := 1; size <= 9; size++ {
+		leaves := make([][]byte, size)
+
+		for i := range leaves {
+			leaves[i] = []byte(fmt.Sprintf("leaf-%d", i))
+		}
+
+		root := MerkleRoot(leaves)
+
+		for index := range leaves {
+			proof, err := BuildMerkleProof(leaves, index)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !VerifyMerkleProof(root, leaves[index], proof) {
+				t.Fatalf("size=%d index=%d: valid proof rejected", size, index)
+			}
+
+			if VerifyMerkleProof(root, []byte("forged"), proof) {
+				t.Fatalf("size=%d index=%d: forged leaf accepted", size, index)
+			}
+		}
+	}
+
+	if _, err := BuildMerkleProof([][]byte{[]byte("a")}, 1); err == nil {
+		t.Fatal("expected an error for an index out of range")
+	}
+}
+
+func TestStateProofs(t *testing.T) {
+	t.Parallel()
+
+	accounts := []Account{
+		{Address: []byte("carol"), Balance: 30, Nonce: 1},
+		{Address: []byte("alice"), Balance: 10},
+		{Address: []byte("erin"), Balance: 50, Nonce: 4},
+	}
+	SortAccounts(accounts)
+	root := StateRoot(accounts)
+
+	tests := map[string]uint64{"alice": 10, "carol": 30, "erin": 50, "aaron": 0, "bob": 0, "dave": 0, "zed": 0}
+
+	for address, want := range tests {
+		proof, err := BuildStateProof(accounts, []byte(address))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		account, err := VerifyStateProof(root, proof)
+
+		if err != nil {
+			t.Fatalf("%s: %v", address, err)
+		}
+
+		if account.Balance != want || string(account.Address) != address {
+			t.Fatalf("%s: got=%+v want balance=%d", address, account, want)
+		}
+	}
+
+	empty, err := BuildStateProof(nil, []byte("alice"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyStateProof(StateRoot(nil), empty); err != nil {
+		t.Fatalf("empty state: %v", err)
+	}
+}
+
+func TestStateProofsRejectForgeries(t *testing.T) {
+	t.Parallel()
+
+	accounts := []Account{
+		{Address: []byte("alice"), Balance: 10},
+		{Address: []byte("bob"), Balance: 20},
+		{Address: []byte("carol"), Balance: 30},
+	}
+	root := StateRoot(accounts)
+
+	inflated, _ := BuildStateProof(accounts, []byte("bob"))
+	inflated.Accounts[0].Balance = 1000
+
+	// Claims bob has no account by presenting non-adjacent neighbours.
+	skipped, _ := BuildStateProof(accounts, []byte("alice"))
+	carol, _ := BuildStateProof(accounts, []byte("carol"))
+	skipped.Address = []byte("bob")
+	skipped.Accounts = append(skipped.Accounts, carol.Accounts...)
+	skipped.Proofs = append(skipped.Proofs, carol.Proofs...)
+
+	// Claims bob has no account by presenting only alice, who is not last.
+	truncated, _ := BuildStateProof(accounts, []byte("alice"))
+	truncated.Address = []byte("bob")
+
+	for name, proof := range map[string]StateProof{"inflated": inflated, "skipped": skipped, "truncated": truncated, "empty": {Address: []byte("bob")}} {
+		if _, err := VerifyStateProof(root, proof); !errors.Is(err, ErrInvalidStateProof) {
+			t.Fatalf("%s: got err=%v, want ErrInvalidStateProof", name, err)
+		}
+	}
+}
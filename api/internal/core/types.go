@@ -1,13 +1,35 @@
 package core
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnknownTransaction is returned for a transaction that is not in the
+// chain.
+var ErrUnknownTransaction = errors.New("transaction not found")
 
 type Block struct {
 	Hash         []byte
 	Height       uint64
 	PrevHash     []byte
 	Timestamp    time.Time
+	TxRoot       []byte
+	StateRoot    []byte
 	Transactions []Transaction
+	Nonce        uint64
+	Proposer     []byte
+	Commit       *Commit
+}
+
+type Signature struct {
+	Signer    []byte
+	Signature []byte
+}
+
+type Commit struct {
+	Round      int32
+	Signatures []Signature
 }
 
 type Tx struct {
@@ -32,12 +54,35 @@ type Transaction struct {
 	Amount      uint64
 	Fee         uint64
 	Nonce       uint64
+	Data        []byte
 	BlockHash   []byte
 	BlockHeight uint64
 	Timestamp   time.Time
 	Status      TxStatus
 }
 
+type NodeInfo struct {
+	ChainID     string
+	GenesisHash []byte
+	Consensus   string
+	Height      uint64
+	Rules       Fork
+	Forks       []Fork
+}
+
+type SyncStatus struct {
+	Syncing       bool
+	StartHeight   uint64
+	CurrentHeight uint64
+	TargetHeight  uint64
+	Peers         int
+	ETA           time.Duration
+}
+
+type SyncReporter interface {
+	SyncStatus() SyncStatus
+}
+
 type Blockchain interface {
 	Start() error
 	Stop() error
@@ -48,4 +93,8 @@ type Blockchain interface {
 	GetBalance(address []byte) (uint64, error)
 	Credit(address []byte, amount uint64)
 	CurrentNonce(address []byte) uint64
+	NodeInfo() NodeInfo
+	AccountProof(address []byte) (uint64, StateProof, error)
+	TransactionProof(hash []byte) (Transaction, MerkleProof, error)
+	PendingTransactions() []Transaction
 }
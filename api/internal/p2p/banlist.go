@@ -0,0 +1,115 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/platform/jsonfile"
+)
+
+// Ban keeps a node ID or an IP address off the node until it expires.
+type Ban struct {
+	Target string    `json:"target"`
+	Reason string    `json:"reason"`
+	Until  time.Time `json:"until"`
+}
+
+// BanList is persisted to a JSON file after every change so bans survive
+// restarts. An empty path keeps the list in memory.
+type BanList struct {
+	mutex sync.Mutex
+	path  string
+	bans  map[string]Ban
+}
+
+func LoadBanList(path string) (*BanList, error) {
+	list := &BanList{path: path, bans: make(map[string]Ban)}
+
+	var bans []Ban
+
+	if err := jsonfile.Load(path, &bans); err != nil {
+		return nil, err
+	}
+
+	for _, ban := range bans {
+		list.bans[ban.Target] = ban
+	}
+
+	return list, nil
+}
+
+func (list *BanList) Ban(target, reason string, duration time.Duration) (Ban, error) {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+
+	ban := Ban{Target: target, Reason: reason, Until: time.Now().Add(duration).UTC()}
+	list.bans[target] = ban
+
+	return ban, list.saveLocked()
+}
+
+// Unban lifts a ban and reports whether there was one.
+func (list *BanList) Unban(target string) (bool, error) {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+
+	if _, exists := list.bans[target]; !exists {
+		return false, nil
+	}
+
+	delete(list.bans, target)
+
+	return true, list.saveLocked()
+}
+
+func (list *BanList) Banned(target string) bool {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+
+	ban, exists := list.bans[target]
+
+	return exists && time.Now().Before(ban.Until)
+}
+
+// List returns the bans that are still in force, soonest expiry first.
+func (list *BanList) List() []Ban {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+
+	now := time.Now()
+	bans := make([]Ban, 0, len(list.bans))
+
+	for _, ban := range list.bans {
+		if now.Before(ban.Until) {
+			bans = append(bans, ban)
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+
+	return bans
+}
+
+func (list *BanList) saveLocked() error {
+	if list.path == "" {
+		return nil
+	}
+
+	now := time.Now()
+	bans := make([]Ban, 0, len(list.bans))
+
+	for target, ban := range list.bans {
+		if !now.Before(ban.Until) {
+			delete(list.bans, target)
+
+			continue
+		}
+
+		bans = append(bans, ban)
+	}
+
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Target < bans[j].Target })
+
+	return jsonfile.Save(list.path, bans)
+}
@@ -0,0 +1,182 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	protocolVersion = 1
+	handshakeDomain = "gochain/p2p/v1"
+	nonceSize       = 32
+)
+
+type hello struct {
+	Version       uint32 `json:"version"`
+	ChainID       string `json:"chainId"`
+	GenesisHash   []byte `json:"genesisHash"`
+	PublicKey     []byte `json:"publicKey"`
+	ListenAddress string `json:"listenAddress"`
+	Height        uint64 `json:"height"`
+	Nonce         []byte `json:"nonce"`
+}
+
+type auth struct {
+	Signature []byte `json:"signature"`
+}
+
+// handshakeResult is what a completed handshake established about the remote
+// node.
+type handshakeResult struct {
+	publicKey     ed25519.PublicKey
+	listenAddress string
+	height        uint64
+}
+
+// handshake exchanges hellos and then proves possession of the node key by
+// signing the challenge nonce sent by the other side. The signature also
+// covers the chain ID and genesis hash, so a node can only authenticate to
+// peers on the same network. Traffic is authenticated at connection setup but
+// not encrypted.
+func handshake(conn net.Conn, local hello, privateKey ed25519.PrivateKey, timeout time.Duration) (handshakeResult, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return handshakeResult{}, err
+	}
+
+	defer conn.SetDeadline(time.Time{})
+
+	local.Version = protocolVersion
+	local.PublicKey = privateKey.Public().(ed25519.PublicKey)
+	local.Nonce = make([]byte, nonceSize)
+
+	if _, err := rand.Read(local.Nonce); err != nil {
+		return handshakeResult{}, err
+	}
+
+	if err := send(conn, MessageHello, local); err != nil {
+		return handshakeResult{}, err
+	}
+
+	var remote hello
+
+	if err := receive(conn, MessageHello, &remote); err != nil {
+		return handshakeResult{}, err
+	}
+
+	if err := checkHello(local, remote); err != nil {
+		return handshakeResult{}, err
+	}
+
+	signature := ed25519.Sign(privateKey, authBytes(remote.Nonce, local.ChainID, local.GenesisHash))
+
+	if err := send(conn, MessageAuth, auth{Signature: signature}); err != nil {
+		return handshakeResult{}, err
+	}
+
+	var remoteAuth auth
+
+	if err := receive(conn, MessageAuth, &remoteAuth); err != nil {
+		return handshakeResult{}, err
+	}
+
+	if !ed25519.Verify(remote.PublicKey, authBytes(local.Nonce, local.ChainID, local.GenesisHash), remoteAuth.Signature) {
+		return handshakeResult{}, errors.New("handshake: invalid authentication signature")
+	}
+
+	return handshakeResult{
+		publicKey:     ed25519.PublicKey(remote.PublicKey),
+		listenAddress: resolveListenAddress(remote.ListenAddress, conn.RemoteAddr()),
+		height:        remote.Height,
+	}, nil
+}
+
+func checkHello(local, remote hello) error {
+	if remote.Version != protocolVersion {
+		return fmt.Errorf("handshake: protocol version %d, want %d", remote.Version, protocolVersion)
+	}
+
+	if remote.ChainID != local.ChainID {
+		return fmt.Errorf("handshake: chain ID %q, want %q", remote.ChainID, local.ChainID)
+	}
+
+	if !bytes.Equal(remote.GenesisHash, local.GenesisHash) {
+		return fmt.Errorf("handshake: genesis %x, want %x", remote.GenesisHash, local.GenesisHash)
+	}
+
+	if len(remote.PublicKey) != ed25519.PublicKeySize {
+		return errors.New("handshake: invalid public key")
+	}
+
+	if bytes.Equal(remote.PublicKey, local.PublicKey) {
+		return errSelfConnection
+	}
+
+	if len(remote.Nonce) != nonceSize {
+		return errors.New("handshake: invalid nonce")
+	}
+
+	return nil
+}
+
+var errSelfConnection = errors.New("handshake: connected to self")
+
+func authBytes(nonce []byte, chainID string, genesisHash []byte) []byte {
+	buffer := make([]byte, 0, len(handshakeDomain)+len(nonce)+len(chainID)+len(genesisHash))
+	buffer = append(buffer, handshakeDomain...)
+	buffer = append(buffer, nonce...)
+	buffer = append(buffer, chainID...)
+
+	return append(buffer, genesisHash...)
+}
+
+func send(conn net.Conn, messageType string, payload any) error {
+	message, err := newEnvelope(messageType, payload)
+
+	if err != nil {
+		return err
+	}
+
+	return writeFrame(conn, message)
+}
+
+func receive(conn net.Conn, messageType string, payload any) error {
+	message, err := readFrame(conn)
+
+	if err != nil {
+		return err
+	}
+
+	if message.Type != messageType {
+		return fmt.Errorf("handshake: expected %s, got %s", messageType, message.Type)
+	}
+
+	return json.Unmarshal(message.Payload, payload)
+}
+
+// resolveListenAddress fills in the remote IP when a peer advertises a
+// wildcard listen address such as ":30303".
+func resolveListenAddress(advertised string, remote net.Addr) string {
+	host, port, err := net.SplitHostPort(advertised)
+
+	if err != nil || port == "" || port == "0" {
+		return ""
+	}
+
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return advertised
+	}
+
+	remoteHost, _, err := net.SplitHostPort(remote.String())
+
+	if err != nil {
+		return ""
+	}
+
+	return net.JoinHostPort(remoteHost, port)
+}
@@ -0,0 +1,54 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NodeID is the hex encoded ed25519 public key a node authenticates with.
+func NodeID(publicKey ed25519.PublicKey) string {
+	return hex.EncodeToString(publicKey)
+}
+
+// LoadOrCreateKey reads the node identity key from path, generating and
+// persisting a new one on first start so the node keeps its identity across
+// restarts.
+func LoadOrCreateKey(path string) (ed25519.PrivateKey, error) {
+	contents, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		seed := make([]byte, ed25519.SeedSize)
+
+		if _, err := rand.Read(seed); err != nil {
+			return nil, err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(seed)+"\n"), 0o600); err != nil {
+			return nil, err
+		}
+
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := hex.DecodeString(strings.TrimSpace(string(contents)))
+
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("node key %s must hold a hex encoded %d byte seed", path, ed25519.SeedSize)
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
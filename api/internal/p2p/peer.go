@@ -0,0 +1,207 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	sendQueueSize = 256
+	knownItems    = 4096
+)
+
+type PeerInfo struct {
+	ID            string
+	Address       string
+	ListenAddress string
+	Inbound       bool
+	Height        uint64
+	Score         float64
+}
+
+type Peer struct {
+	id            string
+	publicKey     ed25519.PublicKey
+	conn          net.Conn
+	listenAddress string
+	inbound       bool
+	send          chan envelope
+	closed        chan struct{}
+	closeOnce     sync.Once
+	mutex         sync.Mutex
+	height        uint64
+	score         score
+	limiter       *rateLimiter
+	known         *hashSet
+}
+
+func newPeer(conn net.Conn, result handshakeResult, inbound bool, limiter *rateLimiter) *Peer {
+	return &Peer{
+		id:            NodeID(result.publicKey),
+		publicKey:     result.publicKey,
+		conn:          conn,
+		listenAddress: result.listenAddress,
+		inbound:       inbound,
+		send:          make(chan envelope, sendQueueSize),
+		closed:        make(chan struct{}),
+		height:        result.height,
+		limiter:       limiter,
+		known:         newHashSet(knownItems),
+	}
+}
+
+func (peer *Peer) ID() string {
+	return peer.id
+}
+
+func (peer *Peer) Info() PeerInfo {
+	return PeerInfo{
+		ID:            peer.id,
+		Address:       peer.conn.RemoteAddr().String(),
+		ListenAddress: peer.listenAddress,
+		Inbound:       peer.inbound,
+		Height:        peer.Height(),
+		Score:         peer.Score(),
+	}
+}
+
+func (peer *Peer) Score() float64 {
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	return peer.score.at(time.Now())
+}
+
+func (peer *Peer) penalize(penalty float64) float64 {
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	return peer.score.add(-penalty, time.Now())
+}
+
+func (peer *Peer) allow() bool {
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	return peer.limiter.allow(time.Now())
+}
+
+func (peer *Peer) remoteHost() string {
+	host, _, err := net.SplitHostPort(peer.conn.RemoteAddr().String())
+
+	if err != nil {
+		return ""
+	}
+
+	return host
+}
+
+func (peer *Peer) Height() uint64 {
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	return peer.height
+}
+
+func (peer *Peer) ObserveHeight(height uint64) {
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	peer.height = max(peer.height, height)
+}
+
+// Send queues a message for the peer without blocking. A peer that does not
+// drain its queue loses messages rather than stalling the node.
+func (peer *Peer) Send(messageType string, payload any) error {
+	message, err := newEnvelope(messageType, payload)
+
+	if err != nil {
+		return err
+	}
+
+	return peer.enqueue(message)
+}
+
+func (peer *Peer) enqueue(message envelope) error {
+	select {
+	case <-peer.closed:
+		return errors.New("peer disconnected")
+	default:
+	}
+
+	select {
+	case peer.send <- message:
+		return nil
+	default:
+		return errors.New("peer send queue is full")
+	}
+}
+
+func (peer *Peer) Close() {
+	peer.closeOnce.Do(func() {
+		close(peer.closed)
+		peer.conn.Close()
+	})
+}
+
+func (peer *Peer) writeLoop() {
+	for {
+		select {
+		case message := <-peer.send:
+			if err := writeFrame(peer.conn, message); err != nil {
+				peer.Close()
+
+				return
+			}
+
+		case <-peer.closed:
+			return
+		}
+	}
+}
+
+// hashSet remembers a bounded number of hashes, forgetting the oldest first.
+type hashSet struct {
+	mutex    sync.Mutex
+	capacity int
+	items    map[string]struct{}
+	order    []string
+}
+
+func newHashSet(capacity int) *hashSet {
+	return &hashSet{capacity: capacity, items: make(map[string]struct{}, capacity)}
+}
+
+// add records the hash and reports whether it was new.
+func (set *hashSet) add(hash []byte) bool {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	key := string(hash)
+
+	if _, exists := set.items[key]; exists {
+		return false
+	}
+
+	if len(set.order) >= set.capacity {
+		delete(set.items, set.order[0])
+		set.order = set.order[1:]
+	}
+
+	set.items[key] = struct{}{}
+	set.order = append(set.order, key)
+
+	return true
+}
+
+func (set *hashSet) has(hash []byte) bool {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	_, exists := set.items[string(hash)]
+
+	return exists
+}
@@ -0,0 +1,111 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+type Offence string
+
+const (
+	OffenceInvalidBlock   Offence = "invalid_block"
+	OffenceInvalidMessage Offence = "invalid_message"
+	OffenceRateLimit      Offence = "rate_limit"
+	OffenceTimeout        Offence = "timeout"
+)
+
+var offencePenalties = map[Offence]float64{
+	OffenceInvalidBlock:   40,
+	OffenceInvalidMessage: 10,
+	OffenceRateLimit:      2,
+	OffenceTimeout:        5,
+}
+
+const (
+	banThreshold  = -100
+	scoreHalfLife = 10 * time.Minute
+)
+
+type misbehaviourError struct {
+	offence Offence
+	err     error
+}
+
+func (misbehaviour *misbehaviourError) Error() string {
+	return fmt.Sprintf("%s: %v", misbehaviour.offence, misbehaviour.err)
+}
+
+func (misbehaviour *misbehaviourError) Unwrap() error {
+	return misbehaviour.err
+}
+
+// Misbehaving marks a handler error as the peer's fault, so the server
+// penalises the peer for it instead of only logging it.
+func Misbehaving(offence Offence, err error) error {
+	return &misbehaviourError{offence: offence, err: err}
+}
+
+// OffenceOf reports the offence a handler error was marked with.
+func OffenceOf(err error) (Offence, bool) {
+	var misbehaviour *misbehaviourError
+
+	if errors.As(err, &misbehaviour) {
+		return misbehaviour.offence, true
+	}
+
+	return "", false
+}
+
+// score starts at zero and drops with every offence. Penalties decay with a
+// fixed half-life, so only sustained misbehaviour reaches the ban threshold.
+type score struct {
+	value   float64
+	updated time.Time
+}
+
+func (current *score) at(now time.Time) float64 {
+	if current.updated.IsZero() {
+		return current.value
+	}
+
+	elapsed := now.Sub(current.updated)
+
+	return current.value * math.Pow(0.5, float64(elapsed)/float64(scoreHalfLife))
+}
+
+func (current *score) add(delta float64, now time.Time) float64 {
+	current.value = current.at(now) + delta
+	current.updated = now
+
+	return current.value
+}
+
+// rateLimiter is a token bucket refilled at rate tokens per second.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (limiter *rateLimiter) allow(now time.Time) bool {
+	if !limiter.last.IsZero() {
+		limiter.tokens = min(limiter.burst, limiter.tokens+now.Sub(limiter.last).Seconds()*limiter.rate)
+	}
+
+	limiter.last = now
+
+	if limiter.tokens < 1 {
+		return false
+	}
+
+	limiter.tokens--
+
+	return true
+}
@@ -0,0 +1,801 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
+	"github.com/afrodynamic/gochain/api/internal/core"
+)
+
+const (
+	defaultMaxPeers         = 25
+	defaultDialInterval     = 5 * time.Second
+	defaultHandshakeTimeout = 5 * time.Second
+	maxAddressBook          = 1024
+	maxAdvertisedAddresses  = 32
+	maxDialFailures         = 5
+	maxDialBackoff          = 5 * time.Minute
+	defaultBanDuration      = time.Hour
+	defaultMessageRate      = 100
+	defaultMessageBurst     = 200
+)
+
+// Chain is the part of the blockchain the network feeds gossip into.
+type Chain interface {
+	ImportBlock(block core.Block) error
+	NodeInfo() core.NodeInfo
+}
+
+// Handler processes one message type received from a peer. Handlers run on
+// the peer's read goroutine, one message at a time.
+type Handler func(peer *Peer, payload json.RawMessage) error
+
+// Config describes how the node joins the network. Static peers are always
+// kept connected; bootstrap peers only seed the address book, which then grows
+// through peer exchange. Each peer may send MessageRate messages per second
+// with bursts of MessageBurst, and a peer whose score falls to the ban
+// threshold is banned for BanDuration.
+type Config struct {
+	PrivateKey       ed25519.PrivateKey
+	ListenAddress    string
+	StaticPeers      []string
+	BootstrapPeers   []string
+	MaxPeers         int
+	DialInterval     time.Duration
+	HandshakeTimeout time.Duration
+	BanListPath      string
+	BanDuration      time.Duration
+	MessageRate      float64
+	MessageBurst     int
+}
+
+type addressEntry struct {
+	static      bool
+	failures    int
+	nextAttempt time.Time
+}
+
+type Server struct {
+	config   Config
+	chain    Chain
+	id       string
+	listener net.Listener
+	mutex    sync.Mutex
+	peers    map[string]*Peer
+	dialing  map[string]bool
+	book     map[string]*addressEntry
+	handlers map[string]Handler
+	bans     *BanList
+	seen     *hashSet
+	stopped  chan struct{}
+	stopOnce sync.Once
+	wait     sync.WaitGroup
+}
+
+func New(config Config, chain Chain) (*Server, error) {
+	if len(config.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("p2p: node private key is required")
+	}
+
+	if config.MaxPeers <= 0 {
+		config.MaxPeers = defaultMaxPeers
+	}
+
+	if config.DialInterval <= 0 {
+		config.DialInterval = defaultDialInterval
+	}
+
+	if config.HandshakeTimeout <= 0 {
+		config.HandshakeTimeout = defaultHandshakeTimeout
+	}
+
+	if config.BanDuration <= 0 {
+		config.BanDuration = defaultBanDuration
+	}
+
+	if config.MessageRate <= 0 {
+		config.MessageRate = defaultMessageRate
+	}
+
+	if config.MessageBurst <= 0 {
+		config.MessageBurst = defaultMessageBurst
+	}
+
+	bans, err := LoadBanList(config.BanListPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		config:   config,
+		chain:    chain,
+		id:       NodeID(config.PrivateKey.Public().(ed25519.PublicKey)),
+		peers:    make(map[string]*Peer),
+		dialing:  make(map[string]bool),
+		book:     make(map[string]*addressEntry),
+		handlers: make(map[string]Handler),
+		bans:     bans,
+		seen:     newHashSet(knownItems),
+		stopped:  make(chan struct{}),
+	}
+
+	server.Handle(MessageGetPeers, server.handleGetPeers)
+	server.Handle(MessagePeers, server.handlePeers)
+	server.Handle(MessageTransaction, server.handleTransaction)
+	server.Handle(MessageBlock, server.handleBlock)
+
+	return server, nil
+}
+
+func (server *Server) ID() string {
+	return server.id
+}
+
+// Handle registers the handler for a message type. It must be called before
+// Start.
+func (server *Server) Handle(messageType string, handler Handler) {
+	server.handlers[messageType] = handler
+}
+
+func (server *Server) Start() error {
+	listener, err := net.Listen("tcp", server.config.ListenAddress)
+
+	if err != nil {
+		return err
+	}
+
+	server.mutex.Lock()
+	server.listener = listener
+
+	for _, address := range server.config.BootstrapPeers {
+		server.book[address] = &addressEntry{}
+	}
+
+	for _, address := range server.config.StaticPeers {
+		server.book[address] = &addressEntry{static: true}
+	}
+
+	server.mutex.Unlock()
+
+	server.wait.Add(2)
+	go server.acceptLoop()
+	go server.dialLoop()
+
+	return nil
+}
+
+func (server *Server) Stop() error {
+	server.stopOnce.Do(func() {
+		close(server.stopped)
+
+		server.mutex.Lock()
+
+		if server.listener != nil {
+			server.listener.Close()
+		}
+
+		for _, peer := range server.peers {
+			peer.Close()
+		}
+
+		server.mutex.Unlock()
+	})
+
+	server.wait.Wait()
+
+	return nil
+}
+
+// Addr is the address the node listens on, which differs from the configured
+// one when that used port 0.
+func (server *Server) Addr() string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.listener == nil {
+		return server.config.ListenAddress
+	}
+
+	return server.listener.Addr().String()
+}
+
+func (server *Server) Peers() []PeerInfo {
+	server.mutex.Lock()
+	peers := make([]PeerInfo, 0, len(server.peers))
+
+	for _, peer := range server.peers {
+		peers = append(peers, peer.Info())
+	}
+
+	server.mutex.Unlock()
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+
+	return peers
+}
+
+func (server *Server) Peer(id string) (*Peer, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	peer, exists := server.peers[id]
+
+	return peer, exists
+}
+
+// Connect dials the address and completes the handshake before returning.
+func (server *Server) Connect(address string) error {
+	server.mutex.Lock()
+
+	if _, exists := server.book[address]; !exists {
+		server.book[address] = &addressEntry{}
+	}
+
+	server.mutex.Unlock()
+
+	return server.dial(address)
+}
+
+// Broadcast sends the message to every connected peer.
+func (server *Server) Broadcast(messageType string, payload any) error {
+	message, err := newEnvelope(messageType, payload)
+
+	if err != nil {
+		return err
+	}
+
+	for _, peer := range server.connectedPeers() {
+		peer.enqueue(message)
+	}
+
+	return nil
+}
+
+// Penalize lowers the peer's score for the offence and bans the peer once the
+// score reaches the ban threshold.
+func (server *Server) Penalize(peerID string, offence Offence, err error) {
+	if peer, exists := server.Peer(peerID); exists {
+		server.penalize(peer, offence, err)
+	}
+}
+
+func (server *Server) penalize(peer *Peer, offence Offence, err error) {
+	value := peer.penalize(offencePenalties[offence])
+	log.Printf("p2p: peer %.12s: %s: %v (score %.0f)", peer.id, offence, err, value)
+
+	if value > banThreshold {
+		return
+	}
+
+	if _, err := server.Ban(peer.id, fmt.Sprintf("score %.0f after %s", value, offence), server.config.BanDuration); err != nil {
+		log.Printf("p2p: ban peer %.12s: %v", peer.id, err)
+	}
+}
+
+var ErrInvalidBanTarget = errors.New("ban target is neither a node ID nor an IP address")
+
+func checkBanTarget(target string) error {
+	if !isNodeID(target) && net.ParseIP(target) == nil {
+		return fmt.Errorf("%w: %q", ErrInvalidBanTarget, target)
+	}
+
+	return nil
+}
+
+// Ban disconnects and refuses a node ID or IP address until the ban expires.
+func (server *Server) Ban(target, reason string, duration time.Duration) (Ban, error) {
+	if err := checkBanTarget(target); err != nil {
+		return Ban{}, err
+	}
+
+	if duration <= 0 {
+		duration = server.config.BanDuration
+	}
+
+	ban, err := server.bans.Ban(target, reason, duration)
+
+	for _, peer := range server.connectedPeers() {
+		if peer.id == target || peer.remoteHost() == target {
+			peer.Close()
+		}
+	}
+
+	return ban, err
+}
+
+func (server *Server) Unban(target string) (bool, error) {
+	if err := checkBanTarget(target); err != nil {
+		return false, err
+	}
+
+	return server.bans.Unban(target)
+}
+
+func (server *Server) Bans() []Ban {
+	return server.bans.List()
+}
+
+func (server *Server) banned(peerID, host string) bool {
+	return server.bans.Banned(peerID) || server.bans.Banned(host)
+}
+
+func isNodeID(target string) bool {
+	decoded, err := hex.DecodeString(target)
+
+	return err == nil && len(decoded) == ed25519.PublicKeySize
+}
+
+// BlockAdded and TransactionAdded make the server a gochain.Listener, so
+// every block the chain accepts is relayed to the peers that have not seen it
+// yet.
+func (server *Server) BlockAdded(block core.Block) {
+	server.gossip(MessageBlock, block.Hash, block)
+}
+
+// TransactionAdded relays nothing: transactions carry no signature, so peers
+// cannot tell who sent them, and they reach other nodes inside blocks.
+func (server *Server) TransactionAdded(tx core.Transaction) {}
+
+func (server *Server) gossip(messageType string, hash []byte, payload any) {
+	server.seen.add(hash)
+	message, err := newEnvelope(messageType, payload)
+
+	if err != nil {
+		log.Printf("p2p: encode %s: %v", messageType, err)
+
+		return
+	}
+
+	for _, peer := range server.connectedPeers() {
+		if peer.known.add(hash) {
+			peer.enqueue(message)
+		}
+	}
+}
+
+func (server *Server) connectedPeers() []*Peer {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	peers := make([]*Peer, 0, len(server.peers))
+
+	for _, peer := range server.peers {
+		peers = append(peers, peer)
+	}
+
+	return peers
+}
+
+func (server *Server) localHello() hello {
+	info := server.chain.NodeInfo()
+
+	return hello{
+		ChainID:       info.ChainID,
+		GenesisHash:   info.GenesisHash,
+		ListenAddress: server.Addr(),
+		Height:        info.Height,
+	}
+}
+
+func (server *Server) acceptLoop() {
+	defer server.wait.Done()
+
+	for {
+		conn, err := server.listener.Accept()
+
+		if err != nil {
+			select {
+			case <-server.stopped:
+				return
+			default:
+			}
+
+			log.Printf("p2p: accept: %v", err)
+			time.Sleep(100 * time.Millisecond)
+
+			continue
+		}
+
+		server.wait.Add(1)
+
+		go func() {
+			defer server.wait.Done()
+
+			if err := server.accept(conn); err != nil {
+				conn.Close()
+			}
+		}()
+	}
+}
+
+func (server *Server) accept(conn net.Conn) error {
+	server.mutex.Lock()
+	full := len(server.peers) >= server.config.MaxPeers
+	server.mutex.Unlock()
+
+	if full {
+		return errors.New("too many peers")
+	}
+
+	if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil && server.bans.Banned(host) {
+		return errors.New("banned")
+	}
+
+	result, err := handshake(conn, server.localHello(), server.config.PrivateKey, server.config.HandshakeTimeout)
+
+	if err != nil {
+		return err
+	}
+
+	return server.startPeer(conn, result, true)
+}
+
+func (server *Server) dial(address string) error {
+	server.mutex.Lock()
+
+	if server.dialing[address] || server.connectedToLocked(address) {
+		server.mutex.Unlock()
+
+		return nil
+	}
+
+	server.dialing[address] = true
+	server.mutex.Unlock()
+
+	defer func() {
+		server.mutex.Lock()
+		delete(server.dialing, address)
+		server.mutex.Unlock()
+	}()
+
+	conn, err := net.DialTimeout("tcp", address, server.config.HandshakeTimeout)
+
+	if err != nil {
+		server.dialFailed(address)
+
+		return err
+	}
+
+	result, err := handshake(conn, server.localHello(), server.config.PrivateKey, server.config.HandshakeTimeout)
+
+	if err != nil {
+		conn.Close()
+
+		if errors.Is(err, errSelfConnection) {
+			server.forget(address)
+		} else {
+			server.dialFailed(address)
+		}
+
+		return fmt.Errorf("%s: %w", address, err)
+	}
+
+	result.listenAddress = address
+
+	if err := server.startPeer(conn, result, false); err != nil {
+		conn.Close()
+
+		return fmt.Errorf("%s: %w", address, err)
+	}
+
+	server.mutex.Lock()
+
+	if entry, exists := server.book[address]; exists {
+		entry.failures = 0
+	}
+
+	server.mutex.Unlock()
+
+	return nil
+}
+
+func (server *Server) connectedToLocked(address string) bool {
+	for _, peer := range server.peers {
+		if peer.listenAddress == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (server *Server) dialFailed(address string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	entry, exists := server.book[address]
+
+	if !exists {
+		return
+	}
+
+	entry.failures++
+
+	if !entry.static && entry.failures > maxDialFailures {
+		delete(server.book, address)
+
+		return
+	}
+
+	backoff := min(server.config.DialInterval<<entry.failures, maxDialBackoff)
+	entry.nextAttempt = time.Now().Add(backoff)
+}
+
+func (server *Server) forget(address string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	delete(server.book, address)
+}
+
+func (server *Server) startPeer(conn net.Conn, result handshakeResult, inbound bool) error {
+	peer := newPeer(conn, result, inbound, newRateLimiter(server.config.MessageRate, server.config.MessageBurst))
+
+	if server.banned(peer.id, peer.remoteHost()) {
+		return errors.New("banned")
+	}
+
+	server.mutex.Lock()
+
+	select {
+	case <-server.stopped:
+		server.mutex.Unlock()
+
+		return errors.New("server stopped")
+	default:
+	}
+
+	if _, exists := server.peers[peer.id]; exists {
+		server.mutex.Unlock()
+
+		return errors.New("already connected")
+	}
+
+	if len(server.peers) >= server.config.MaxPeers {
+		server.mutex.Unlock()
+
+		return errors.New("too many peers")
+	}
+
+	server.peers[peer.id] = peer
+
+	if peer.listenAddress != "" {
+		if _, exists := server.book[peer.listenAddress]; !exists && len(server.book) < maxAddressBook {
+			server.book[peer.listenAddress] = &addressEntry{}
+		}
+	}
+
+	server.mutex.Unlock()
+
+	server.wait.Add(2)
+
+	go func() {
+		defer server.wait.Done()
+
+		peer.writeLoop()
+	}()
+
+	go server.readLoop(peer)
+
+	peer.Send(MessageGetPeers, struct{}{})
+
+	return nil
+}
+
+func (server *Server) readLoop(peer *Peer) {
+	defer server.wait.Done()
+
+	defer func() {
+		peer.Close()
+
+		server.mutex.Lock()
+
+		if server.peers[peer.id] == peer {
+			delete(server.peers, peer.id)
+		}
+
+		server.mutex.Unlock()
+	}()
+
+	for {
+		message, err := readFrame(peer.conn)
+
+		if err != nil {
+			return
+		}
+
+		if !peer.allow() {
+			server.penalize(peer, OffenceRateLimit, fmt.Errorf("dropped %s: message rate exceeded", message.Type))
+
+			continue
+		}
+
+		handler, exists := server.handlers[message.Type]
+
+		if !exists {
+			continue
+		}
+
+		err = handler(peer, message.Payload)
+
+		if offence, misbehaved := OffenceOf(err); misbehaved {
+			server.penalize(peer, offence, err)
+		} else if err != nil {
+			log.Printf("p2p: peer %.12s: %s: %v", peer.id, message.Type, err)
+		}
+	}
+}
+
+func (server *Server) dialLoop() {
+	defer server.wait.Done()
+
+	ticker := time.NewTicker(server.config.DialInterval)
+	defer ticker.Stop()
+
+	for {
+		server.dialPeers()
+
+		select {
+		case <-server.stopped:
+			return
+
+		case <-ticker.C:
+		}
+	}
+}
+
+// dialPeers keeps static peers connected, dials known addresses while below
+// the peer limit and asks a random peer for more addresses.
+func (server *Server) dialPeers() {
+	now := time.Now()
+
+	server.mutex.Lock()
+
+	slots := server.config.MaxPeers - len(server.peers)
+	candidates := make([]string, 0)
+
+	for address, entry := range server.book {
+		if server.dialing[address] || server.connectedToLocked(address) || now.Before(entry.nextAttempt) {
+			continue
+		}
+
+		if host, _, err := net.SplitHostPort(address); err == nil && server.bans.Banned(host) {
+			continue
+		}
+
+		if entry.static || slots > 0 {
+			candidates = append(candidates, address)
+			slots--
+		}
+	}
+
+	var asked *Peer
+
+	if len(server.peers) > 0 && len(server.peers) < server.config.MaxPeers {
+		index := rand.Intn(len(server.peers))
+
+		for _, peer := range server.peers {
+			if index == 0 {
+				asked = peer
+			}
+
+			index--
+		}
+	}
+
+	server.mutex.Unlock()
+
+	for _, address := range candidates {
+		server.wait.Add(1)
+
+		go func() {
+			defer server.wait.Done()
+
+			server.dial(address)
+		}()
+	}
+
+	if asked != nil {
+		asked.Send(MessageGetPeers, struct{}{})
+	}
+}
+
+func (server *Server) handleGetPeers(peer *Peer, payload json.RawMessage) error {
+	server.mutex.Lock()
+	addresses := make([]string, 0, maxAdvertisedAddresses)
+
+	for _, other := range server.peers {
+		if other != peer && other.listenAddress != "" && len(addresses) < maxAdvertisedAddresses {
+			addresses = append(addresses, other.listenAddress)
+		}
+	}
+
+	server.mutex.Unlock()
+
+	return peer.Send(MessagePeers, peersMessage{Addresses: addresses})
+}
+
+func (server *Server) handlePeers(peer *Peer, payload json.RawMessage) error {
+	var message peersMessage
+
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return Misbehaving(OffenceInvalidMessage, err)
+	}
+
+	if len(message.Addresses) > maxAdvertisedAddresses {
+		return Misbehaving(OffenceInvalidMessage, fmt.Errorf("%d addresses exceed the limit of %d", len(message.Addresses), maxAdvertisedAddresses))
+	}
+
+	own := server.Addr()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for _, address := range message.Addresses {
+		if _, _, err := net.SplitHostPort(address); err != nil || address == own {
+			continue
+		}
+
+		if _, exists := server.book[address]; !exists && len(server.book) < maxAddressBook {
+			server.book[address] = &addressEntry{}
+		}
+	}
+
+	return nil
+}
+
+// handleTransaction ignores transactions from peers. Without a signature
+// nothing shows that the sender's owner made one, so accepting them would let
+// any peer spend from any address.
+func (server *Server) handleTransaction(peer *Peer, payload json.RawMessage) error {
+	return nil
+}
+
+func (server *Server) handleBlock(peer *Peer, payload json.RawMessage) error {
+	var block core.Block
+
+	if err := json.Unmarshal(payload, &block); err != nil {
+		return Misbehaving(OffenceInvalidMessage, err)
+	}
+
+	peer.known.add(block.Hash)
+
+	// A block is only marked seen once it is in the chain: a body that fails
+	// to import may be a forgery carrying a real block's hash.
+	if server.seen.has(block.Hash) {
+		return nil
+	}
+
+	err := server.chain.ImportBlock(block)
+
+	switch {
+	case err == nil, errors.Is(err, gochain.ErrKnownBlock):
+		server.seen.add(block.Hash)
+		peer.ObserveHeight(block.Height)
+
+		return nil
+
+	case errors.Is(err, gochain.ErrConflictingBlock):
+		return nil
+
+	case errors.Is(err, gochain.ErrUnknownParent):
+		// The peer has at least the next block, which starts a sync; how far
+		// ahead it is, sync learns from the headers it serves.
+		peer.ObserveHeight(server.chain.NodeInfo().Height + 1)
+
+		return nil
+	}
+
+	return Misbehaving(OffenceInvalidBlock, err)
+}
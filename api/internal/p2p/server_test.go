@@ -0,0 +1,607 @@
+package p2p_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/consensus/bft"
+	"github.com/afrodynamic/gochain/api/internal/consensus/pow"
+	"github.com/afrodynamic/gochain/api/internal/core"
+	"github.com/afrodynamic/gochain/api/internal/p2p"
+	"github.com/afrodynamic/gochain/api/internal/testutil"
+)
+
+type testNode struct {
+	chain  *gochain.Chain
+	server *p2p.Server
+}
+
+func newTestNode(t *testing.T, label string, genesisConfig genesis.Genesis, config p2p.Config) *testNode {
+	t.Helper()
+
+	return newTestNodeWithEngine(t, label, genesisConfig, config, pow.New(genesisConfig.Consensus.Difficulty))
+}
+
+func newTestNodeWithEngine(t *testing.T, label string, genesisConfig genesis.Genesis, config p2p.Config, engine consensus.Engine) *testNode {
+	t.Helper()
+
+	chain := testutil.NewChainWithEngine(t, genesisConfig, engine)
+	seed := sha256.Sum256([]byte(label))
+	config.PrivateKey = ed25519.NewKeyFromSeed(seed[:])
+	config.ListenAddress = "127.0.0.1:0"
+
+	if config.DialInterval == 0 {
+		config.DialInterval = 50 * time.Millisecond
+	}
+
+	server, err := p2p.New(config, chain)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain.Subscribe(server)
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { server.Stop() })
+
+	return &testNode{chain: chain, server: server}
+}
+
+func TestHandshakeRejectsOtherNetworks(t *testing.T) {
+	t.Parallel()
+
+	local := newTestNode(t, "local", testutil.Genesis("gochain-a"), p2p.Config{})
+	otherChain := newTestNode(t, "other-chain", testutil.Genesis("gochain-b"), p2p.Config{})
+
+	if err := otherChain.server.Connect(local.server.Addr()); err == nil {
+		t.Fatal("expected a different chain ID to be rejected")
+	}
+
+	otherGenesis := testutil.Genesis("gochain-a")
+	otherGenesis.Alloc = map[string]uint64{"abcd": 1}
+	otherFork := newTestNode(t, "other-genesis", otherGenesis, p2p.Config{})
+
+	if err := otherFork.server.Connect(local.server.Addr()); err == nil {
+		t.Fatal("expected a different genesis hash to be rejected")
+	}
+
+	sameNetwork := newTestNode(t, "same-network", testutil.Genesis("gochain-a"), p2p.Config{})
+
+	if err := sameNetwork.server.Connect(local.server.Addr()); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Eventually(t, "authenticated peers", func() bool {
+		peers := local.server.Peers()
+
+		return len(peers) == 1 && peers[0].ID == sameNetwork.server.ID()
+	})
+}
+
+func TestDiscoveryThroughBootstrapPeer(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-discovery")
+	first := newTestNode(t, "first", config, p2p.Config{})
+	second := newTestNode(t, "second", config, p2p.Config{StaticPeers: []string{first.server.Addr()}})
+	third := newTestNode(t, "third", config, p2p.Config{BootstrapPeers: []string{second.server.Addr()}})
+
+	testutil.Eventually(t, "third node to discover the first", func() bool {
+		for _, peer := range third.server.Peers() {
+			if peer.ID == first.server.ID() {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+func TestGossipBlocksAndTransactions(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-gossip")
+	first := newTestNode(t, "first", config, p2p.Config{MaxPeers: 1})
+	second := newTestNode(t, "second", config, p2p.Config{StaticPeers: []string{first.server.Addr()}, MaxPeers: 2})
+	third := newTestNode(t, "third", config, p2p.Config{StaticPeers: []string{second.server.Addr()}, MaxPeers: 1})
+
+	testutil.Eventually(t, "peers to connect", func() bool {
+		return len(first.server.Peers()) == 1 && len(third.server.Peers()) == 1
+	})
+
+	sender := []byte("sender")
+	recipient := []byte("recipient")
+	first.chain.Credit(sender, 100)
+
+	if _, err := first.chain.SubmitTx(core.Tx{From: sender, To: recipient, Amount: 40, Fee: 1, Data: []byte("memo")}); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Eventually(t, "blocks to reach the third node", func() bool {
+		return third.chain.NodeInfo().Height == 2
+	})
+
+	for _, node := range []*testNode{second, third} {
+		balance, _ := node.chain.GetBalance(recipient)
+		senderBalance, _ := node.chain.GetBalance(sender)
+
+		if balance != 40 || senderBalance != 59 {
+			t.Fatalf("got recipient=%d sender=%d, want 40 and 59", balance, senderBalance)
+		}
+	}
+
+	head, _ := first.chain.GetBlock(2)
+	relayed, _ := third.chain.GetBlock(2)
+
+	if string(head.Hash) != string(relayed.Hash) {
+		t.Fatalf("got head %x, want %x", relayed.Hash, head.Hash)
+	}
+
+	if data := relayed.Transactions[0].Data; string(data) != "memo" {
+		t.Fatalf("got data %q, want the transaction's data carried in the block", data)
+	}
+}
+
+func TestGossipRejectsTamperedBlocks(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-tampered")
+	first := newTestNode(t, "first", config, p2p.Config{})
+	second := newTestNode(t, "second", config, p2p.Config{StaticPeers: []string{first.server.Addr()}})
+
+	testutil.Eventually(t, "peers to connect", func() bool {
+		return len(first.server.Peers()) == 1
+	})
+
+	first.chain.Credit([]byte("sender"), 100)
+
+	testutil.Eventually(t, "credit block to reach the second node", func() bool {
+		return second.chain.NodeInfo().Height == 1
+	})
+
+	genuine, _ := first.chain.GetBlock(1)
+	tampered := genuine
+	tampered.Height = 2
+	tampered.PrevHash = genuine.Hash
+	tampered.Transactions = []core.Transaction{genuine.Transactions[0]}
+	tampered.Transactions[0].Amount = 1000
+
+	if err := second.chain.ImportBlock(tampered); err == nil {
+		t.Fatal("expected a tampered block to be rejected")
+	}
+}
+
+func TestImportRejectsMintsOverTheAllowance(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-mint")
+	node := newTestNode(t, "node", config, p2p.Config{})
+	genesisBlock, _ := node.chain.GetBlock(0)
+
+	// A correctly sealed block from a peer that credits itself more than the
+	// rules allow.
+	mint := core.Transaction{To: []byte("attacker"), Amount: genesis.DefaultMaxMint + 1, Timestamp: time.Now().UTC()}
+	mint.Hash = mint.ComputeHash()
+	block := core.Block{
+		Height:       1,
+		PrevHash:     genesisBlock.Hash,
+		Timestamp:    time.Now().UTC(),
+		Transactions: []core.Transaction{mint},
+		TxRoot:       core.TxRoot([]core.Transaction{mint}),
+		StateRoot:    core.StateRoot([]core.Account{{Address: []byte("attacker"), Balance: mint.Amount}}),
+	}
+	sealed, err := pow.New(config.Consensus.Difficulty).Seal(block)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.chain.ImportBlock(sealed); err == nil || !strings.Contains(err.Error(), "allowance") {
+		t.Fatalf("got err=%v, want the mint rejected", err)
+	}
+
+	if balance, _ := node.chain.GetBalance([]byte("attacker")); balance != 0 {
+		t.Fatalf("got balance=%d want=0", balance)
+	}
+}
+
+func TestCompetingBlocksConverge(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-fork")
+	first := newTestNode(t, "first", config, p2p.Config{})
+	second := newTestNode(t, "second", config, p2p.Config{})
+
+	// Apart, each node seals its own block 1.
+	first.chain.Credit([]byte("first"), 10)
+	second.chain.Credit([]byte("second"), 10)
+
+	firstBlock, _ := first.chain.GetBlock(1)
+	secondBlock, _ := second.chain.GetBlock(1)
+
+	if err := first.chain.ImportBlock(secondBlock); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := second.chain.ImportBlock(firstBlock); err != nil {
+		t.Fatal(err)
+	}
+
+	// The branches weigh the same, so each node keeps its own.
+	if head, _ := second.chain.GetBlock(1); !bytes.Equal(head.Hash, secondBlock.Hash) {
+		t.Fatalf("got head %x, want %x kept", head.Hash, secondBlock.Hash)
+	}
+
+	if err := second.server.Connect(first.server.Addr()); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Eventually(t, "peers to connect", func() bool {
+		return len(first.server.Peers()) == 1
+	})
+
+	// The first node's branch grows heavier and the second node follows it.
+	first.chain.Credit([]byte("first"), 10)
+	firstHead, _ := first.chain.GetBlock(2)
+
+	testutil.Eventually(t, "the second node to reorganise onto the first node's branch", func() bool {
+		head, err := second.chain.GetBlock(2)
+
+		return err == nil && bytes.Equal(head.Hash, firstHead.Hash)
+	})
+
+	if block, _ := second.chain.GetBlock(1); !bytes.Equal(block.Hash, firstBlock.Hash) {
+		t.Fatalf("got block 1 %x, want %x", block.Hash, firstBlock.Hash)
+	}
+
+	if abandoned, _ := second.chain.GetBalance([]byte("second")); abandoned != 0 {
+		t.Fatalf("got balance=%d from the abandoned block, want=0", abandoned)
+	}
+
+	if balance, _ := second.chain.GetBalance([]byte("first")); balance != 20 {
+		t.Fatalf("got balance=%d want=20", balance)
+	}
+}
+
+func TestPeerTransactionsAreIgnored(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-peer-tx")
+	config.Alloc = map[string]uint64{"76696374696d": 100}
+	honest := newTestNode(t, "honest", config, p2p.Config{})
+	attacker := newTestNode(t, "attacker", config, p2p.Config{StaticPeers: []string{honest.server.Addr()}})
+
+	testutil.Eventually(t, "peers to connect", func() bool {
+		return len(honest.server.Peers()) == 1
+	})
+
+	// Nothing shows the victim made this transfer, though its hash is right.
+	theft := core.Transaction{From: []byte("victim"), To: []byte("attacker"), Amount: 90, Fee: 1, Timestamp: time.Now().UTC()}
+	theft.Hash = theft.ComputeHash()
+	peer, _ := attacker.server.Peer(honest.server.ID())
+
+	if err := peer.Send(p2p.MessageTransaction, theft); err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages from a peer are handled in order, so once the block arrives
+	// the transaction has been too.
+	attacker.chain.Credit([]byte("attacker"), 1)
+
+	testutil.Eventually(t, "credit block to reach the honest node", func() bool {
+		return honest.chain.NodeInfo().Height == 1
+	})
+
+	if pending := honest.chain.PendingTransactions(); len(pending) != 0 {
+		t.Fatalf("got %d pending transactions, want the peer's ignored", len(pending))
+	}
+
+	if balance, _ := honest.chain.GetBalance([]byte("victim")); balance != 100 {
+		t.Fatalf("got victim balance=%d want=100", balance)
+	}
+}
+
+func TestInvalidBlocksDoNotRaisePeerHeight(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-height")
+	honest := newTestNode(t, "honest", config, p2p.Config{})
+	liar := newTestNode(t, "liar", config, p2p.Config{StaticPeers: []string{honest.server.Addr()}})
+
+	testutil.Eventually(t, "peers to connect", func() bool {
+		return len(honest.server.Peers()) == 1
+	})
+
+	junk := core.Block{Height: 1_000_000, Hash: []byte("junk"), Timestamp: time.Now().UTC()}
+	peer, _ := liar.server.Peer(honest.server.ID())
+
+	if err := peer.Send(p2p.MessageBlock, junk); err != nil {
+		t.Fatal(err)
+	}
+
+	liar.chain.Credit([]byte("liar"), 1)
+
+	testutil.Eventually(t, "credit block to reach the honest node", func() bool {
+		return honest.chain.NodeInfo().Height == 1
+	})
+
+	if peers := honest.server.Peers(); len(peers) != 1 || peers[0].Height != 1 {
+		t.Fatalf("got peers=%+v, want the liar at height 1", peers)
+	}
+}
+
+// voteRecorder records the consensus messages a validator broadcasts.
+type voteRecorder struct {
+	mutex    sync.Mutex
+	messages []bft.Message
+}
+
+func (recorder *voteRecorder) Broadcast(message bft.Message) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.messages = append(recorder.messages, message)
+}
+
+func (recorder *voteRecorder) votedAt(height uint64) bool {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	for _, message := range recorder.messages {
+		if message.Height == height && message.Type != bft.MessageCommit {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestGossipedBlocksAdvanceConsensus(t *testing.T) {
+	t.Parallel()
+
+	keys := make([]ed25519.PrivateKey, 0, 4)
+	validators := make([]bft.Validator, 0, 4)
+
+	for _, label := range []string{"a", "b", "c", "d"} {
+		seed := sha256.Sum256([]byte(label))
+		key := ed25519.NewKeyFromSeed(seed[:])
+		keys = append(keys, key)
+		validators = append(validators, bft.Validator{PublicKey: key.Public().(ed25519.PublicKey), Power: 1})
+	}
+
+	newEngine := func(key ed25519.PrivateKey, transport bft.Transport) *bft.Engine {
+		engine, err := bft.New(bft.Config{
+			Validators: validators,
+			PrivateKey: key,
+			Height:     1,
+			Timeouts:   bft.Timeouts{Propose: 50 * time.Millisecond, Prevote: 50 * time.Millisecond, Precommit: 50 * time.Millisecond, Delta: 10 * time.Millisecond, Gossip: 100 * time.Millisecond},
+			Transport:  transport,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return engine
+	}
+
+	config := testutil.Genesis("gochain-bft-gossip")
+	relay := newTestNodeWithEngine(t, "relay", config, p2p.Config{}, newEngine(keys[1], nil))
+	votes := &voteRecorder{}
+	engine := newEngine(keys[0], votes)
+	validator := newTestNodeWithEngine(t, "validator", config, p2p.Config{StaticPeers: []string{relay.server.Addr()}}, engine)
+
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { engine.Stop() })
+
+	testutil.Eventually(t, "peers to connect", func() bool {
+		return len(relay.server.Peers()) == 1
+	})
+
+	// The other validators committed block 1 without this one hearing their
+	// votes, and the block reaches it by gossip first.
+	parent, _ := relay.chain.GetBlock(0)
+	block := core.Block{Height: 1, PrevHash: parent.Hash, Timestamp: time.Now().UTC(), TxRoot: core.TxRoot(nil), StateRoot: parent.StateRoot, Commit: &core.Commit{}}
+	block.Hash = block.SealHash()
+
+	for _, key := range keys[1:] {
+		vote := bft.Message{Type: bft.MessagePrecommit, Height: 1, BlockHash: block.Hash}
+		vote.Sign(key)
+		block.Commit.Signatures = append(block.Commit.Signatures, core.Signature{Signer: vote.Validator, Signature: vote.Signature})
+	}
+
+	if err := relay.chain.ImportBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Eventually(t, "the block to reach the validator", func() bool {
+		return validator.chain.NodeInfo().Height == 1
+	})
+
+	testutil.Eventually(t, "the validator to vote on the next height", func() bool {
+		return votes.votedAt(2)
+	})
+}
+
+func TestForgedBodyDoesNotHideTheRealBlock(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-forged-body")
+	source := newTestNode(t, "source", config, p2p.Config{})
+	honest := newTestNode(t, "honest", config, p2p.Config{})
+	liar := newTestNode(t, "liar", config, p2p.Config{StaticPeers: []string{honest.server.Addr()}})
+
+	testutil.Eventually(t, "peers to connect", func() bool {
+		return len(honest.server.Peers()) == 1
+	})
+
+	source.chain.Credit([]byte("sender"), 100)
+	genuine, _ := source.chain.GetBlock(1)
+	forged := genuine
+	forged.Transactions = []core.Transaction{genuine.Transactions[0]}
+	forged.Transactions[0].Amount = 1
+
+	// The forgery arrives first under the real block's hash.
+	peer, _ := liar.server.Peer(honest.server.ID())
+
+	for _, block := range []core.Block{forged, genuine} {
+		if err := peer.Send(p2p.MessageBlock, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testutil.Eventually(t, "the real block to be imported", func() bool {
+		head, err := honest.chain.GetBlock(1)
+
+		return err == nil && bytes.Equal(head.Hash, genuine.Hash)
+	})
+}
+
+func TestInvalidBlocksGetPeerBanned(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-ban")
+	banListPath := filepath.Join(t.TempDir(), "bans.json")
+	honest := newTestNode(t, "honest", config, p2p.Config{BanListPath: banListPath})
+	attacker := newTestNode(t, "attacker", config, p2p.Config{StaticPeers: []string{honest.server.Addr()}})
+
+	testutil.Eventually(t, "peers to connect", func() bool {
+		return len(honest.server.Peers()) == 1
+	})
+
+	attacker.chain.Credit([]byte("sender"), 100)
+
+	testutil.Eventually(t, "credit block to reach the honest node", func() bool {
+		return honest.chain.NodeInfo().Height == 1
+	})
+
+	genuine, _ := attacker.chain.GetBlock(1)
+	peer, _ := attacker.server.Peer(honest.server.ID())
+
+	for i := 0; i < 3; i++ {
+		forged := genuine
+		forged.Height = 2
+		forged.PrevHash = genuine.Hash
+		forged.Hash = []byte{byte(i)}
+
+		if err := peer.Send(p2p.MessageBlock, forged); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testutil.Eventually(t, "attacker to be banned", func() bool {
+		bans := honest.server.Bans()
+
+		return len(bans) == 1 && bans[0].Target == attacker.server.ID() && len(honest.server.Peers()) == 0
+	})
+
+	// The attacker keeps redialling its static peer but is turned away.
+	time.Sleep(200 * time.Millisecond)
+
+	if peers := honest.server.Peers(); len(peers) != 0 {
+		t.Fatalf("got %d peers, want the banned attacker to stay disconnected", len(peers))
+	}
+
+	persisted, err := p2p.LoadBanList(banListPath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !persisted.Banned(attacker.server.ID()) {
+		t.Fatal("expected the ban to be persisted")
+	}
+
+	if removed, err := honest.server.Unban(attacker.server.ID()); err != nil || !removed {
+		t.Fatalf("got removed=%v err=%v, want the ban lifted", removed, err)
+	}
+
+	testutil.Eventually(t, "attacker to reconnect after the ban is lifted", func() bool {
+		return len(honest.server.Peers()) == 1
+	})
+}
+
+func TestMessageFloodGetsPeerBanned(t *testing.T) {
+	t.Parallel()
+
+	config := testutil.Genesis("gochain-flood")
+	target := newTestNode(t, "target", config, p2p.Config{MessageRate: 1, MessageBurst: 5})
+	flooder := newTestNode(t, "flooder", config, p2p.Config{})
+
+	if err := flooder.server.Connect(target.server.Addr()); err != nil {
+		t.Fatal(err)
+	}
+
+	peer, _ := flooder.server.Peer(target.server.ID())
+
+	for i := 0; i < 100; i++ {
+		if err := peer.Send(p2p.MessageGetPeers, struct{}{}); err != nil {
+			break
+		}
+	}
+
+	testutil.Eventually(t, "flooder to be banned", func() bool {
+		return len(target.server.Bans()) == 1 && len(target.server.Peers()) == 0
+	})
+
+	if ban := target.server.Bans()[0]; ban.Target != flooder.server.ID() {
+		t.Fatalf("got ban on %s, want %s", ban.Target, flooder.server.ID())
+	}
+}
+
+func TestBanRejectsInvalidTargets(t *testing.T) {
+	t.Parallel()
+
+	node := newTestNode(t, "ban-targets", testutil.Genesis("gochain-ban-targets"), p2p.Config{})
+
+	if _, err := node.server.Ban("not-a-peer", "test", time.Minute); err == nil {
+		t.Fatal("expected an invalid ban target to be rejected")
+	}
+
+	ban, err := node.server.Ban("192.0.2.1", "test", time.Minute)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Until(ban.Until) > time.Minute || ban.Reason != "test" {
+		t.Fatalf("got ban %+v, want a one minute ban with the given reason", ban)
+	}
+}
+
+func TestLoadOrCreateKeyPersistsIdentity(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "node.key")
+	created, err := p2p.LoadOrCreateKey(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := p2p.LoadOrCreateKey(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !created.Equal(loaded) {
+		t.Fatal("expected the persisted key to be reloaded")
+	}
+}
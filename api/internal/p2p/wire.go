@@ -0,0 +1,85 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	MessageHello       = "hello"
+	MessageAuth        = "auth"
+	MessageGetPeers    = "get_peers"
+	MessagePeers       = "peers"
+	MessageTransaction = "tx"
+	MessageBlock       = "block"
+)
+
+const maxFrameSize = 16 << 20
+
+// envelope is the frame exchanged between peers: a 4 byte big endian length
+// followed by the JSON encoded envelope.
+type envelope struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type peersMessage struct {
+	Addresses []string `json:"addresses"`
+}
+
+func newEnvelope(messageType string, payload any) (envelope, error) {
+	encoded, err := json.Marshal(payload)
+
+	if err != nil {
+		return envelope{}, err
+	}
+
+	return envelope{Type: messageType, Payload: encoded}, nil
+}
+
+func writeFrame(writer io.Writer, message envelope) error {
+	encoded, err := json.Marshal(message)
+
+	if err != nil {
+		return err
+	}
+
+	if len(encoded) > maxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the %d byte limit", len(encoded), maxFrameSize)
+	}
+
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(encoded)), uint32(len(encoded)))
+	_, err = writer.Write(append(frame, encoded...))
+
+	return err
+}
+
+func readFrame(reader io.Reader) (envelope, error) {
+	var header [4]byte
+
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return envelope{}, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+
+	if size > maxFrameSize {
+		return envelope{}, fmt.Errorf("frame of %d bytes exceeds the %d byte limit", size, maxFrameSize)
+	}
+
+	body := make([]byte, size)
+
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return envelope{}, err
+	}
+
+	var message envelope
+
+	if err := json.Unmarshal(body, &message); err != nil {
+		return envelope{}, err
+	}
+
+	return message, nil
+}
@@ -1,17 +1,127 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
 
 type Config struct {
-	Address string
-	Chain   string
+	Address           string
+	Chain             string
+	DataPath          string
+	AdoptGenesis      bool
+	GenesisPath       string
+	Consensus         string
+	ValidatorKey      string
+	NodeKeyPath       string
+	P2PAddress        string
+	P2PStaticPeers    []string
+	P2PBootstrapPeers []string
+	P2PMaxPeers       int
+	AdminToken        string
+	BitcoinNetworks   []string
+	BitcoinBackends   map[string]BitcoinBackend
+	EthereumGasMargin int
+	EVMNetworks       []string
+	EVMBackends       map[string]EVMBackend
 }
 
-func Load() Config {
-	return Config{
-		Address: getEnvironmentVariable("ADDR", "127.0.0.1:8080"),
-		Chain:   getEnvironmentVariable("CHAIN", "gochain"),
+// BitcoinBackend is where a bitcoin network's UTXOs are read from, set per
+// network with BITCOIN_<NETWORK>_RPC and BITCOIN_<NETWORK>_ESPLORA.
+type BitcoinBackend struct {
+	RPCURL     string
+	EsploraURL string
+}
+
+// EVMBackend configures an EVM network, set per network with
+// EVM_<NETWORK>_RPC, _CHAIN_ID, _SYMBOL, _EXPLORER, _TOKENS and
+// _ENS_REGISTRY. Empty values keep a known network's defaults. The ethereum
+// network also reads ETH_RPC and ETH_TOKENS.
+type EVMBackend struct {
+	RPCURLs     []string
+	ChainID     int
+	Symbol      string
+	ExplorerURL string
+	Tokens      []string
+	ENSRegistry string
+}
+
+// Load reads the configuration from the environment. An integer variable
+// that does not parse is an error rather than its default.
+func Load() (Config, error) {
+	var errs []error
+	integer := func(key string, defaultValue int) int {
+		value, err := getIntegerEnvironmentVariable(key, defaultValue)
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		return value
 	}
+
+	boolean := func(key string) bool {
+		value, err := getBooleanEnvironmentVariable(key)
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		return value
+	}
+
+	config := Config{
+		Address:           getEnvironmentVariable("ADDR", "127.0.0.1:8080"),
+		Chain:             getEnvironmentVariable("CHAIN", "gochain"),
+		DataPath:          getEnvironmentVariable("GOCHAIN_DATA_PATH", "data"),
+		AdoptGenesis:      boolean("GOCHAIN_ADOPT_GENESIS"),
+		GenesisPath:       getEnvironmentVariable("GOCHAIN_GENESIS", ""),
+		Consensus:         getEnvironmentVariable("GOCHAIN_CONSENSUS", ""),
+		ValidatorKey:      getEnvironmentVariable("GOCHAIN_VALIDATOR_KEY", ""),
+		NodeKeyPath:       getEnvironmentVariable("GOCHAIN_NODE_KEY", ""),
+		P2PAddress:        getEnvironmentVariable("GOCHAIN_P2P_ADDR", ""),
+		P2PStaticPeers:    getListEnvironmentVariable("GOCHAIN_P2P_STATIC_PEERS"),
+		P2PBootstrapPeers: getListEnvironmentVariable("GOCHAIN_P2P_BOOTSTRAP_PEERS"),
+		P2PMaxPeers:       integer("GOCHAIN_P2P_MAX_PEERS", 25),
+		AdminToken:        getEnvironmentVariable("GOCHAIN_ADMIN_TOKEN", ""),
+		BitcoinNetworks:   getListEnvironmentVariable("BITCOIN_NETWORKS", "mainnet", "testnet", "signet", "regtest"),
+		BitcoinBackends:   make(map[string]BitcoinBackend),
+		EthereumGasMargin: integer("ETH_GAS_MARGIN", 20),
+		EVMNetworks:       getListEnvironmentVariable("EVM_NETWORKS", "ethereum"),
+		EVMBackends:       make(map[string]EVMBackend),
+	}
+
+	for _, network := range config.BitcoinNetworks {
+		prefix := "BITCOIN_" + strings.ToUpper(network)
+		config.BitcoinBackends[network] = BitcoinBackend{
+			RPCURL:     getEnvironmentVariable(prefix+"_RPC", ""),
+			EsploraURL: getEnvironmentVariable(prefix+"_ESPLORA", ""),
+		}
+	}
+
+	for _, network := range config.EVMNetworks {
+		prefix := "EVM_" + strings.ToUpper(strings.ReplaceAll(network, "-", "_"))
+		backend := EVMBackend{
+			RPCURLs:     getListEnvironmentVariable(prefix + "_RPC"),
+			ChainID:     integer(prefix+"_CHAIN_ID", 0),
+			Symbol:      getEnvironmentVariable(prefix+"_SYMBOL", ""),
+			ExplorerURL: getEnvironmentVariable(prefix+"_EXPLORER", ""),
+			Tokens:      getListEnvironmentVariable(prefix + "_TOKENS"),
+			ENSRegistry: getEnvironmentVariable(prefix+"_ENS_REGISTRY", ""),
+		}
+
+		if network == "ethereum" {
+			backend.RPCURLs = append(backend.RPCURLs, getListEnvironmentVariable("ETH_RPC")...)
+			backend.Tokens = append(getListEnvironmentVariable("ETH_TOKENS"), backend.Tokens...)
+		}
+
+		config.EVMBackends[network] = backend
+	}
+
+	return config, errors.Join(errs...)
 }
 
 func getEnvironmentVariable(key string, defaultValue string) string {
@@ -23,3 +133,51 @@ func getEnvironmentVariable(key string, defaultValue string) string {
 
 	return value
 }
+
+func getListEnvironmentVariable(key string, defaultValues ...string) []string {
+	values := make([]string, 0)
+
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+
+	if len(values) == 0 {
+		return append(values, defaultValues...)
+	}
+
+	return values
+}
+
+func getIntegerEnvironmentVariable(key string, defaultValue int) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+
+	if err != nil {
+		return defaultValue, fmt.Errorf("%s: %q is not an integer", key, raw)
+	}
+
+	return value, nil
+}
+
+func getBooleanEnvironmentVariable(key string) (bool, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+
+	if raw == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+
+	if err != nil {
+		return false, fmt.Errorf("%s: %q is not a boolean", key, raw)
+	}
+
+	return value, nil
+}
@@ -1,12 +1,27 @@
 package config
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T) Config {
+	t.Helper()
+
+	config, err := Load()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
 
 func TestLoadDefaults(t *testing.T) {
 	t.Setenv("ADDR", "")
 	t.Setenv("CHAIN", "")
 
-	config := Load()
+	config := load(t)
 
 	if config.Address == "" || config.Chain == "" {
 		t.Fatalf("unexpected default configuration: %+v", config)
@@ -17,9 +32,106 @@ func TestLoadOverrides(t *testing.T) {
 	t.Setenv("ADDR", "127.0.0.1:9999")
 	t.Setenv("CHAIN", "gochain")
 
-	config := Load()
+	config := load(t)
 
 	if config.Address != "127.0.0.1:9999" || config.Chain != "gochain" {
 		t.Fatalf("expected overrides not applied, got: %+v", config)
 	}
 }
+
+func TestLoadConsensusDefaults(t *testing.T) {
+	t.Setenv("GOCHAIN_CONSENSUS", "")
+	t.Setenv("GOCHAIN_GENESIS", "")
+	t.Setenv("GOCHAIN_DATA_PATH", "")
+	t.Setenv("GOCHAIN_ADOPT_GENESIS", "")
+
+	config := load(t)
+
+	if config.Consensus != "" || config.GenesisPath != "" || config.DataPath != "data" || config.AdoptGenesis {
+		t.Fatalf("unexpected consensus defaults: %+v", config)
+	}
+}
+
+func TestLoadPeerLists(t *testing.T) {
+	t.Setenv("GOCHAIN_P2P_STATIC_PEERS", "127.0.0.1:30303, 127.0.0.1:30304,")
+	t.Setenv("GOCHAIN_P2P_MAX_PEERS", "")
+
+	config := load(t)
+
+	if len(config.P2PStaticPeers) != 2 || config.P2PStaticPeers[1] != "127.0.0.1:30304" || config.P2PMaxPeers != 25 {
+		t.Fatalf("unexpected peer configuration: %+v", config)
+	}
+}
+
+func TestLoadBitcoinNetworks(t *testing.T) {
+	t.Setenv("BITCOIN_NETWORKS", "")
+
+	if networks := load(t).BitcoinNetworks; len(networks) != 4 || networks[0] != "mainnet" {
+		t.Fatalf("unexpected default bitcoin networks: %v", networks)
+	}
+
+	t.Setenv("BITCOIN_NETWORKS", "regtest")
+
+	if networks := load(t).BitcoinNetworks; len(networks) != 1 || networks[0] != "regtest" {
+		t.Fatalf("unexpected bitcoin networks: %v", networks)
+	}
+}
+
+func TestLoadEthereum(t *testing.T) {
+	t.Setenv("ETH_GAS_MARGIN", "")
+
+	if config := load(t); config.EthereumGasMargin != 20 {
+		t.Fatalf("got=%d want=20", config.EthereumGasMargin)
+	}
+
+	t.Setenv("ETH_GAS_MARGIN", "35")
+	t.Setenv("ETH_TOKENS", "USDC:0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238:6, ")
+
+	if config := load(t); config.EthereumGasMargin != 35 || len(config.EVMBackends["ethereum"].Tokens) != 1 {
+		t.Fatalf("unexpected ethereum configuration: %+v", config)
+	}
+}
+
+func TestLoadEVMNetworks(t *testing.T) {
+	t.Setenv("EVM_NETWORKS", "")
+	t.Setenv("ETH_RPC", "")
+
+	if config := load(t); len(config.EVMNetworks) != 1 || config.EVMNetworks[0] != "ethereum" {
+		t.Fatalf("unexpected default EVM networks: %v", config.EVMNetworks)
+	}
+
+	t.Setenv("EVM_NETWORKS", "ethereum, base, my-chain")
+	t.Setenv("ETH_RPC", "http://eth.local")
+	t.Setenv("EVM_ETHEREUM_RPC", "http://primary.local")
+	t.Setenv("EVM_BASE_RPC", "http://base-1.local, http://base-2.local")
+	t.Setenv("EVM_MY_CHAIN_CHAIN_ID", "31337")
+	t.Setenv("EVM_MY_CHAIN_SYMBOL", "TST")
+
+	config := load(t)
+	ethereum, base, custom := config.EVMBackends["ethereum"], config.EVMBackends["base"], config.EVMBackends["my-chain"]
+
+	if len(ethereum.RPCURLs) != 2 || ethereum.RPCURLs[0] != "http://primary.local" || ethereum.RPCURLs[1] != "http://eth.local" {
+		t.Fatalf("unexpected ethereum RPC URLs: %v", ethereum.RPCURLs)
+	}
+
+	if len(base.RPCURLs) != 2 || base.RPCURLs[1] != "http://base-2.local" || base.ChainID != 0 {
+		t.Fatalf("unexpected base backend: %+v", base)
+	}
+
+	if custom.ChainID != 31337 || custom.Symbol != "TST" {
+		t.Fatalf("unexpected custom backend: %+v", custom)
+	}
+}
+
+func TestLoadRejectsMalformedIntegers(t *testing.T) {
+	t.Setenv("EVM_NETWORKS", "polygon")
+	t.Setenv("EVM_POLYGON_CHAIN_ID", "13x")
+	t.Setenv("ETH_GAS_MARGIN", "abc")
+	t.Setenv("GOCHAIN_ADOPT_GENESIS", "maybe")
+
+	_, err := Load()
+
+	if err == nil || !strings.Contains(err.Error(), "EVM_POLYGON_CHAIN_ID") || !strings.Contains(err.Error(), "ETH_GAS_MARGIN") || !strings.Contains(err.Error(), "GOCHAIN_ADOPT_GENESIS") {
+		t.Fatalf("got err=%v, want every variable reported", err)
+	}
+}
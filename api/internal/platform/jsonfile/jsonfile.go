@@ -0,0 +1,57 @@
+// Package jsonfile persists a value as a JSON file that is replaced whole on
+// every save, so a crash leaves either the old file or the new one. An empty
+// path keeps nothing on disk.
+package jsonfile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Load decodes the file into value. A missing file or an empty path leaves
+// value as it is.
+func Load(path string, value any) error {
+	if path == "" {
+		return nil
+	}
+
+	contents, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(contents, value)
+}
+
+// Save writes value to a temporary file next to the path and renames it over
+// the path, creating the directory if needed.
+func Save(path string, value any) error {
+	if path == "" {
+		return nil
+	}
+
+	encoded, err := json.MarshalIndent(value, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	temporary := path + ".tmp"
+
+	if err := os.WriteFile(temporary, encoded, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary, path)
+}
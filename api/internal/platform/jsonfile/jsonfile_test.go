@@ -0,0 +1,54 @@
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "values.json")
+	values := map[string]int{"kept": 1}
+
+	if err := Load(path, &values); err != nil || values["kept"] != 1 {
+		t.Fatalf("missing file: got=%v err=%v, want the value untouched", values, err)
+	}
+
+	if err := Save(path, map[string]int{"saved": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded map[string]int
+
+	if err := Load(path, &loaded); err != nil || len(loaded) != 1 || loaded["saved"] != 2 {
+		t.Fatalf("got=%v err=%v want=map[saved:2]", loaded, err)
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("got err=%v, want the temporary file renamed away", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Load(path, &loaded); err == nil {
+		t.Fatal("expected a corrupt file to be an error")
+	}
+}
+
+func TestEmptyPath(t *testing.T) {
+	t.Parallel()
+
+	if err := Save("", map[string]int{"saved": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded map[string]int
+
+	if err := Load("", &loaded); err != nil || loaded != nil {
+		t.Fatalf("got=%v err=%v, want nothing loaded", loaded, err)
+	}
+}
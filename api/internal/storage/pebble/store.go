@@ -1,18 +1,22 @@
 package pebble
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"path/filepath"
-	"time"
 
 	"github.com/cockroachdb/pebble"
 
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
 	"github.com/afrodynamic/gochain/api/internal/core"
 )
 
 type Store struct {
 	db           *pebble.DB
+	Genesis      genesis.Genesis
 	Blocks       []core.Block
 	Balances     map[string]uint64
 	Transactions []core.Transaction
@@ -20,13 +24,34 @@ type Store struct {
 }
 
 const (
+	genesisKey      = "genesis"
 	blocksKey       = "blocks"
 	balancesKey     = "balances"
 	transactionsKey = "transactions"
 	noncesKey       = "nonces"
 )
 
-func New(path string) (*Store, error) {
+// Options change how Open treats an existing data directory.
+type Options struct {
+	// AdoptGenesis records the configured genesis for a directory that has
+	// blocks but no recorded genesis, as written before genesis files
+	// existed. Without it such a directory is refused.
+	AdoptGenesis bool
+}
+
+// ErrUnrecordedGenesis is returned for a data directory holding blocks but
+// no genesis, which could have been started from any genesis.
+var ErrUnrecordedGenesis = errors.New("data directory has blocks but no recorded genesis")
+
+// New opens the data directory and initialises it from the genesis on first
+// use. A directory created from a different genesis, in particular one with a
+// different consensus engine, is refused.
+func New(path string, genesisConfig genesis.Genesis) (*Store, error) {
+	return Open(path, genesisConfig, Options{})
+}
+
+// Open is New with options.
+func Open(path string, genesisConfig genesis.Genesis, options Options) (*Store, error) {
 	if path == "" {
 		path = "data"
 	}
@@ -57,21 +82,63 @@ func New(path string) (*Store, error) {
 		return nil, err
 	}
 
+	if err := store.initGenesis(genesisConfig, options); err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (store *Store) initGenesis(genesisConfig genesis.Genesis, options Options) error {
+	var recorded *genesis.Genesis
+
+	if err := store.loadKey(genesisKey, &recorded); err != nil {
+		return err
+	}
+
+	if recorded != nil {
+		if recorded.Consensus.Engine != genesisConfig.Consensus.Engine {
+			return fmt.Errorf("data directory was initialised with consensus engine %q, configured %q", recorded.Consensus.Engine, genesisConfig.Consensus.Engine)
+		}
+
+		if !bytes.Equal(recorded.Hash(), genesisConfig.Hash()) {
+			return fmt.Errorf("data directory was initialised with genesis %x, configured %x", recorded.Hash(), genesisConfig.Hash())
+		}
+
+		store.Genesis = *recorded
+
+		return nil
+	}
+
+	if len(store.Blocks) > 0 {
+		if !options.AdoptGenesis {
+			return fmt.Errorf("%w; set GOCHAIN_ADOPT_GENESIS=true to record the configured genesis %x for it", ErrUnrecordedGenesis, genesisConfig.Hash())
+		}
+	}
+
+	store.Genesis = genesisConfig
+
 	if len(store.Blocks) == 0 {
-		store.Blocks = []core.Block{{
-			Hash:      []byte("genesis"),
-			Height:    0,
-			Timestamp: time.Now().UTC(),
-		}}
+		store.Blocks = []core.Block{genesisConfig.Block()}
 
-		if err := store.persistAll(); err != nil {
-			db.Close()
+		for address, amount := range genesisConfig.Alloc {
+			decoded, err := hex.DecodeString(address)
 
-			return nil, err
+			if err != nil {
+				return fmt.Errorf("genesis alloc %q: %w", address, err)
+			}
+
+			store.Balances[string(decoded)] += amount
 		}
 	}
 
-	return store, nil
+	if err := store.saveKey(genesisKey, store.Genesis); err != nil {
+		return err
+	}
+
+	return store.persistAll()
 }
 
 func (store *Store) Close() error {
@@ -0,0 +1,130 @@
+package pebble
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+)
+
+func TestNewInitialisesFromGenesis(t *testing.T) {
+	t.Parallel()
+
+	config := genesis.Default("pow", nil)
+	config.Alloc = map[string]uint64{"abcd": 42}
+
+	store, err := New(t.TempDir(), config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Close()
+
+	if !bytes.Equal(store.Blocks[0].Hash, config.Hash()) {
+		t.Fatalf("genesis block hash %x, want %x", store.Blocks[0].Hash, config.Hash())
+	}
+
+	address, _ := hex.DecodeString("abcd")
+
+	if store.Balances[string(address)] != 42 {
+		t.Fatalf("expected genesis allocation, got %d", store.Balances[string(address)])
+	}
+}
+
+func TestNewRejectsDifferentEngine(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	store, err := New(path, genesis.Default("pow", nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	_, err = New(path, genesis.Default("pos", bytes.Repeat([]byte{1}, 32)))
+
+	if err == nil || !strings.Contains(err.Error(), `consensus engine "pow"`) {
+		t.Fatalf("expected engine mismatch error, got %v", err)
+	}
+
+	reopened, err := New(path, genesis.Default("pow", nil))
+
+	if err != nil {
+		t.Fatalf("expected reopening with the same genesis to succeed: %v", err)
+	}
+
+	reopened.Close()
+}
+
+func TestNewRejectsDifferentGenesis(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	store, err := New(path, genesis.Default("pow", nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	changed := genesis.Default("pow", nil)
+	changed.ChainID = "other"
+
+	if _, err := New(path, changed); err == nil {
+		t.Fatal("expected genesis mismatch error")
+	}
+}
+
+func TestNewRefusesBlocksWithoutGenesis(t *testing.T) {
+	t.Parallel()
+
+	// A directory written before genesis files were recorded.
+	path := t.TempDir()
+	legacy := genesis.Default("pow", nil)
+	legacy.ChainID = "legacy"
+	store, err := New(path, legacy)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.db.Delete([]byte(genesisKey), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	config := genesis.Default("pow", nil)
+
+	if _, err := New(path, config); !errors.Is(err, ErrUnrecordedGenesis) {
+		t.Fatalf("got err=%v want=%v", err, ErrUnrecordedGenesis)
+	}
+
+	adopted, err := Open(path, config, Options{AdoptGenesis: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(adopted.Blocks) != 1 || !bytes.Equal(adopted.Blocks[0].Hash, legacy.Hash()) {
+		t.Fatalf("got %d blocks, want the existing chain kept", len(adopted.Blocks))
+	}
+
+	adopted.Close()
+
+	// Once adopted the genesis is recorded and the option is not needed.
+	reopened, err := New(path, config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened.Close()
+}
@@ -0,0 +1,73 @@
+// Package testutil holds the fixtures shared by the tests of the packages that
+// run chains and networks of nodes.
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/consensus/pow"
+	"github.com/afrodynamic/gochain/api/internal/storage/pebble"
+)
+
+// Timeout bounds how long Eventually waits. It is generous because a devnet
+// of several nodes under the race detector can take a while to converge.
+const Timeout = 30 * time.Second
+
+// Eventually polls the condition until it holds, failing the test once
+// Timeout has passed.
+func Eventually(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(Timeout)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", description)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Genesis is the default proof of work genesis at the lowest difficulty, so
+// blocks seal at once.
+func Genesis(chainID string) genesis.Genesis {
+	config := genesis.Default("pow", nil)
+	config.ChainID = chainID
+	config.Consensus.Difficulty = 1
+
+	return config
+}
+
+// NewChain opens a chain of the genesis in a temporary directory, sealed by
+// proof of work at the genesis difficulty.
+func NewChain(t *testing.T, config genesis.Genesis) *gochain.Chain {
+	t.Helper()
+
+	return NewChainWithEngine(t, config, pow.New(config.Consensus.Difficulty))
+}
+
+// NewChainWithEngine is NewChain with another consensus engine.
+func NewChainWithEngine(t *testing.T, config genesis.Genesis, engine consensus.Engine) *gochain.Chain {
+	t.Helper()
+
+	store, err := pebble.New(t.TempDir(), config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { store.Close() })
+
+	chain, err := gochain.New(engine, store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chain
+}
@@ -0,0 +1,388 @@
+// Package lightclient follows a gochain network through a single gochaind
+// node without trusting it. Headers are checked against the consensus rules
+// from a trusted genesis, and balances and transactions are only reported
+// once the node's Merkle proofs verify against those headers.
+//
+// Only BFT commits are final. Proof-of-work headers are followed by work, so
+// a node can move the client to a heavier branch and what was proven against
+// the old one no longer holds; Final reports which kind the client follows.
+// Proof-of-stake seals need no key to produce, so they cannot be verified and
+// such genesis configs are refused.
+package lightclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/consensus"
+	"github.com/afrodynamic/gochain/api/internal/consensus/bft"
+	"github.com/afrodynamic/gochain/api/internal/consensus/pow"
+	"github.com/afrodynamic/gochain/api/internal/core"
+	chainv1 "github.com/afrodynamic/gochain/api/proto/chain/v1"
+)
+
+const headerBatch = 256
+
+var (
+	ErrWrongNetwork       = errors.New("node serves a different network")
+	ErrInvalidHeader      = errors.New("invalid header")
+	ErrInvalidProof       = errors.New("invalid proof")
+	ErrConflictingBranch  = errors.New("node serves a conflicting branch")
+	ErrUnverifiableEngine = errors.New("consensus engine seals cannot be verified")
+)
+
+// errOtherBranch reports a node whose next header does not link to the head.
+var errOtherBranch = errors.New("does not link to the previous header")
+
+type Client struct {
+	mutex       sync.Mutex
+	chain       chainv1.ChainClient
+	genesisHash []byte
+	engine      consensus.Engine
+	headers     []core.Block
+	checked     bool
+}
+
+// New trusts only the genesis, given as the JSON the nodes are started with.
+func New(conn grpc.ClientConnInterface, genesisJSON []byte) (*Client, error) {
+	genesisConfig, err := genesis.Parse(genesisJSON)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := genesisConfig.Schedule()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if genesisConfig.Consensus.Engine == "pos" {
+		return nil, fmt.Errorf("%w: %q", ErrUnverifiableEngine, genesisConfig.Consensus.Engine)
+	}
+
+	registry := consensus.NewRegistry()
+	registry.Register("pow", pow.Factory)
+	registry.Register("bft", bft.Factory)
+
+	genesisBlock := genesisConfig.Block()
+	engine, err := registry.New(genesisConfig.Consensus.Engine, consensus.Params{
+		Genesis:  genesisConfig.Consensus,
+		Schedule: schedule,
+		Height:   1,
+		PrevHash: genesisBlock.Hash,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		chain:       chainv1.NewChainClient(conn),
+		genesisHash: genesisBlock.Hash,
+		engine:      engine,
+		headers:     []core.Block{genesisBlock},
+	}, nil
+}
+
+// Height is the height of the last verified header.
+func (client *Client) Height() uint64 {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return client.head().Height
+}
+
+// Sync downloads and verifies headers up to the node's head.
+func (client *Client) Sync(ctx context.Context) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return client.syncLocked(ctx)
+}
+
+// Final reports whether verified headers are final, which only BFT commits
+// are.
+func (client *Client) Final() bool {
+	return client.engine.Finality() == consensus.FinalityInstant
+}
+
+// syncLocked extends the verified headers to the node's head. If the node has
+// moved to another branch, the client follows it only when headers are not
+// final and the branch has more work than the one it replaces.
+func (client *Client) syncLocked(ctx context.Context) error {
+	if !client.checked {
+		info, err := client.chain.GetNodeInfo(ctx, &chainv1.GetNodeInfoRequest{})
+
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(info.GenesisHash, client.genesisHash) {
+			return fmt.Errorf("%w: genesis %x, expected %x", ErrWrongNetwork, info.GenesisHash, client.genesisHash)
+		}
+
+		client.checked = true
+	}
+
+	err := client.extendLocked(ctx)
+
+	if !errors.Is(err, errOtherBranch) {
+		return err
+	}
+
+	if client.Final() {
+		return fmt.Errorf("%w: committed header %d does not link to the verified one", ErrConflictingBranch, client.head().Height+1)
+	}
+
+	ancestor, err := client.commonAncestorLocked(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	current := client.headers
+	client.headers = append([]core.Block(nil), current[:ancestor+1]...)
+
+	if err := client.extendLocked(ctx); err != nil {
+		client.headers = current
+
+		return err
+	}
+
+	if client.weight(client.headers[ancestor+1:]).Cmp(client.weight(current[ancestor+1:])) <= 0 {
+		client.headers = current
+
+		return fmt.Errorf("%w: branch from height %d has no more work than the verified one", ErrConflictingBranch, ancestor+1)
+	}
+
+	return nil
+}
+
+func (client *Client) extendLocked(ctx context.Context) error {
+	for {
+		response, err := client.chain.GetHeaders(ctx, &chainv1.GetHeadersRequest{From: client.head().Height + 1, Count: headerBatch})
+
+		if err != nil {
+			return err
+		}
+
+		for _, message := range response.Headers {
+			header := convertHeader(message)
+
+			if err := client.checkHeader(header); errors.Is(err, errOtherBranch) {
+				return err
+			} else if err != nil {
+				return fmt.Errorf("%w %d: %v", ErrInvalidHeader, header.Height, err)
+			}
+
+			client.headers = append(client.headers, header)
+		}
+
+		if len(response.Headers) == 0 || client.head().Height >= response.Height {
+			return nil
+		}
+	}
+}
+
+// commonAncestorLocked finds the height of a verified header the node also
+// serves, stepping back from the head in growing steps. The genesis is
+// shared.
+func (client *Client) commonAncestorLocked(ctx context.Context) (uint64, error) {
+	head := client.head().Height
+
+	for step := uint64(1); ; step *= 2 {
+		height := head - min(step, head)
+
+		if height == 0 {
+			return 0, nil
+		}
+
+		response, err := client.chain.GetHeaders(ctx, &chainv1.GetHeadersRequest{From: height, Count: 1})
+
+		if err != nil {
+			return 0, err
+		}
+
+		if len(response.Headers) == 1 && bytes.Equal(response.Headers[0].Hash, client.headers[height].Hash) {
+			return height, nil
+		}
+	}
+}
+
+func (client *Client) weight(headers []core.Block) *big.Int {
+	total := new(big.Int)
+	weigher, weighs := client.engine.(consensus.Weigher)
+
+	for _, header := range headers {
+		if weighs {
+			total.Add(total, weigher.Weight(header))
+		} else {
+			total.Add(total, big.NewInt(1))
+		}
+	}
+
+	return total
+}
+
+func (client *Client) checkHeader(header core.Block) error {
+	previous := client.head()
+
+	if header.Height != previous.Height+1 {
+		return fmt.Errorf("expected height %d", previous.Height+1)
+	}
+
+	if !bytes.Equal(header.PrevHash, previous.Hash) {
+		return errOtherBranch
+	}
+
+	return client.engine.Validate(header)
+}
+
+func (client *Client) head() core.Block {
+	return client.headers[len(client.headers)-1]
+}
+
+// header returns the verified header at the height, syncing first when the
+// node has proven something against a header the client has not seen yet.
+func (client *Client) header(ctx context.Context, height uint64) (core.Block, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if height > client.head().Height {
+		if err := client.syncLocked(ctx); err != nil {
+			return core.Block{}, err
+		}
+	}
+
+	if height > client.head().Height {
+		return core.Block{}, fmt.Errorf("%w: no verified header at height %d", ErrInvalidProof, height)
+	}
+
+	return client.headers[height], nil
+}
+
+// VerifiedAccount returns the balance and nonce of the address, proven
+// against the state root of a verified header.
+func (client *Client) VerifiedAccount(ctx context.Context, address []byte) (core.Account, error) {
+	response, err := client.chain.GetAccountProof(ctx, &chainv1.GetAccountProofRequest{Address: address})
+
+	if err != nil {
+		return core.Account{}, err
+	}
+
+	header, err := client.header(ctx, response.Height)
+
+	if err != nil {
+		return core.Account{}, err
+	}
+
+	if !bytes.Equal(response.Address, address) || len(response.Accounts) != len(response.Proofs) {
+		return core.Account{}, ErrInvalidProof
+	}
+
+	proof := core.StateProof{Address: address}
+
+	for i, account := range response.Accounts {
+		proof.Accounts = append(proof.Accounts, core.Account{Address: account.Address, Balance: account.Balance, Nonce: account.Nonce})
+		proof.Proofs = append(proof.Proofs, convertMerkleProof(response.Proofs[i]))
+	}
+
+	account, err := core.VerifyStateProof(header.StateRoot, proof)
+
+	if err != nil {
+		return core.Account{}, fmt.Errorf("%w: account %x at height %d", ErrInvalidProof, address, header.Height)
+	}
+
+	return account, nil
+}
+
+func (client *Client) VerifiedBalance(ctx context.Context, address []byte) (uint64, error) {
+	account, err := client.VerifiedAccount(ctx, address)
+
+	return account.Balance, err
+}
+
+// VerifiedTransaction returns a mined transaction once its contents match the
+// hash and its inclusion is proven against a verified header.
+func (client *Client) VerifiedTransaction(ctx context.Context, hash []byte) (core.Transaction, error) {
+	response, err := client.chain.GetTransactionProof(ctx, &chainv1.GetTransactionProofRequest{Hash: hash})
+
+	if err != nil {
+		return core.Transaction{}, err
+	}
+
+	if response.Transaction == nil || response.Proof == nil {
+		return core.Transaction{}, ErrInvalidProof
+	}
+
+	tx := core.Transaction{
+		Hash:        hash,
+		From:        response.Transaction.From,
+		To:          response.Transaction.To,
+		Amount:      response.Transaction.Amount,
+		Fee:         response.Transaction.Fee,
+		Nonce:       response.Transaction.Nonce,
+		Data:        response.Transaction.Data,
+		Timestamp:   time.Unix(0, response.Transaction.TimestampUnixNano).UTC(),
+		BlockHeight: response.Transaction.BlockHeight,
+		Status:      core.TxStatusMined,
+	}
+
+	if !bytes.Equal(tx.ComputeHash(), hash) {
+		return core.Transaction{}, fmt.Errorf("%w: transaction does not match hash %x", ErrInvalidProof, hash)
+	}
+
+	header, err := client.header(ctx, tx.BlockHeight)
+
+	if err != nil {
+		return core.Transaction{}, err
+	}
+
+	if !core.VerifyMerkleProof(header.TxRoot, hash, convertMerkleProof(response.Proof)) {
+		return core.Transaction{}, fmt.Errorf("%w: transaction %x is not in block %d", ErrInvalidProof, hash, header.Height)
+	}
+
+	tx.BlockHash = header.Hash
+
+	return tx, nil
+}
+
+func convertHeader(message *chainv1.BlockHeader) core.Block {
+	header := core.Block{
+		Hash:      message.Hash,
+		Height:    message.Height,
+		PrevHash:  message.PrevHash,
+		Timestamp: time.Unix(0, message.TimestampUnixNano).UTC(),
+		TxRoot:    message.TxRoot,
+		StateRoot: message.StateRoot,
+		Nonce:     message.Nonce,
+		Proposer:  message.Proposer,
+	}
+
+	if message.Commit != nil {
+		header.Commit = &core.Commit{Round: message.Commit.Round}
+
+		for _, signature := range message.Commit.Signatures {
+			header.Commit.Signatures = append(header.Commit.Signatures, core.Signature{Signer: signature.Signer, Signature: signature.Signature})
+		}
+	}
+
+	return header
+}
+
+func convertMerkleProof(message *chainv1.MerkleProof) core.MerkleProof {
+	if message == nil {
+		return core.MerkleProof{}
+	}
+
+	return core.MerkleProof{Index: message.Index, Size: message.Size, Hashes: message.Hashes}
+}
@@ -0,0 +1,260 @@
+package lightclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	grpcapi "github.com/afrodynamic/gochain/api/internal/api/grpc"
+	"github.com/afrodynamic/gochain/api/internal/chain/genesis"
+	"github.com/afrodynamic/gochain/api/internal/chain/gochain"
+	"github.com/afrodynamic/gochain/api/internal/core"
+	"github.com/afrodynamic/gochain/api/internal/testutil"
+	"github.com/afrodynamic/gochain/api/lightclient"
+	chainv1 "github.com/afrodynamic/gochain/api/proto/chain/v1"
+)
+
+func testGenesis() genesis.Genesis {
+	config := testutil.Genesis("gochain-light")
+	config.Alloc = map[string]uint64{"aa": 500}
+
+	return config
+}
+
+func newChain(t *testing.T) *gochain.Chain {
+	t.Helper()
+
+	return testutil.NewChain(t, testGenesis())
+}
+
+func newClient(t *testing.T, service chainv1.ChainServer, genesisConfig genesis.Genesis) *lightclient.Client {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	chainv1.RegisterChainServer(server, service)
+
+	go server.Serve(listener)
+
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///light", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	genesisJSON, err := json.Marshal(genesisConfig)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := lightclient.New(conn, genesisJSON)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func TestVerifiedBalancesAndTransactions(t *testing.T) {
+	t.Parallel()
+
+	chain := newChain(t)
+	sender := []byte("sender")
+	recipient := []byte("recipient")
+	chain.Credit(sender, 100)
+	chain.Credit([]byte("other"), 7)
+
+	submitted, err := chain.SubmitTx(core.Tx{From: sender, To: recipient, Amount: 40, Fee: 1, Data: []byte("memo")})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := newClient(t, grpcapi.NewChain(chain, nil), testGenesis())
+	ctx := context.Background()
+
+	cases := map[string]uint64{"sender": 59, "recipient": 40, "other": 7, "\xaa": 500, "nobody": 0, "": 0, "zzz": 0}
+
+	for address, want := range cases {
+		balance, err := client.VerifiedBalance(ctx, []byte(address))
+
+		if err != nil {
+			t.Fatalf("%q: %v", address, err)
+		}
+
+		if balance != want {
+			t.Fatalf("%q: got=%d want=%d", address, balance, want)
+		}
+	}
+
+	if height := client.Height(); height != 3 {
+		t.Fatalf("got height=%d want=3", height)
+	}
+
+	verified, err := client.VerifiedTransaction(ctx, submitted.Hash)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verified.BlockHeight != 3 || verified.Amount != 40 || string(verified.Data) != "memo" {
+		t.Fatalf("got %+v", verified)
+	}
+}
+
+// lyingNode serves genuine headers but inflates balances and misreports
+// transactions.
+type lyingNode struct {
+	*grpcapi.ChainServer
+}
+
+func (node lyingNode) GetAccountProof(ctx context.Context, request *chainv1.GetAccountProofRequest) (*chainv1.GetAccountProofResponse, error) {
+	response, err := node.ChainServer.GetAccountProof(ctx, request)
+
+	for _, account := range response.GetAccounts() {
+		account.Balance += 1000
+	}
+
+	return response, err
+}
+
+func (node lyingNode) GetTransactionProof(ctx context.Context, request *chainv1.GetTransactionProofRequest) (*chainv1.GetTransactionProofResponse, error) {
+	response, err := node.ChainServer.GetTransactionProof(ctx, request)
+
+	if response != nil {
+		response.Transaction.BlockHeight = 1
+	}
+
+	return response, err
+}
+
+func TestRejectsForgedProofs(t *testing.T) {
+	t.Parallel()
+
+	chain := newChain(t)
+	chain.Credit([]byte("account"), 10)
+
+	submitted, err := chain.SubmitTx(core.Tx{From: []byte("account"), To: []byte("other"), Amount: 1, Fee: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := newClient(t, lyingNode{grpcapi.NewChain(chain, nil)}, testGenesis())
+	ctx := context.Background()
+
+	if _, err := client.VerifiedBalance(ctx, []byte("account")); !errors.Is(err, lightclient.ErrInvalidProof) {
+		t.Fatalf("got err=%v, want ErrInvalidProof", err)
+	}
+
+	if _, err := client.VerifiedTransaction(ctx, submitted.Hash); !errors.Is(err, lightclient.ErrInvalidProof) {
+		t.Fatalf("got err=%v, want ErrInvalidProof", err)
+	}
+}
+
+// forgingNode rewrites a header's state root, which breaks its proof of work.
+type forgingNode struct {
+	*grpcapi.ChainServer
+}
+
+func (node forgingNode) GetHeaders(ctx context.Context, request *chainv1.GetHeadersRequest) (*chainv1.GetHeadersResponse, error) {
+	response, err := node.ChainServer.GetHeaders(ctx, request)
+
+	for _, header := range response.GetHeaders() {
+		header.StateRoot = make([]byte, len(header.StateRoot))
+	}
+
+	return response, err
+}
+
+func TestRejectsInvalidHeadersAndOtherNetworks(t *testing.T) {
+	t.Parallel()
+
+	chain := newChain(t)
+	chain.Credit([]byte("account"), 10)
+	ctx := context.Background()
+
+	forged := newClient(t, forgingNode{grpcapi.NewChain(chain, nil)}, testGenesis())
+
+	if err := forged.Sync(ctx); !errors.Is(err, lightclient.ErrInvalidHeader) {
+		t.Fatalf("got err=%v, want ErrInvalidHeader", err)
+	}
+
+	otherGenesis := testGenesis()
+	otherGenesis.ChainID = "gochain-other"
+	other := newClient(t, grpcapi.NewChain(chain, nil), otherGenesis)
+
+	if err := other.Sync(ctx); !errors.Is(err, lightclient.ErrWrongNetwork) {
+		t.Fatalf("got err=%v, want ErrWrongNetwork", err)
+	}
+}
+
+func TestFollowsAHeavierBranch(t *testing.T) {
+	t.Parallel()
+
+	chain := newChain(t)
+	chain.Credit([]byte("first"), 1)
+	chain.Credit([]byte("first"), 2)
+
+	ctx := context.Background()
+	client := newClient(t, grpcapi.NewChain(chain, nil), testGenesis())
+
+	if err := client.Sync(ctx); err != nil || client.Height() != 2 || client.Final() {
+		t.Fatalf("got height=%d final=%v err=%v, want 2 and not final", client.Height(), client.Final(), err)
+	}
+
+	// The node reorganises onto a longer branch from the genesis.
+	branch := newChain(t)
+
+	for amount := range uint64(3) {
+		branch.Credit([]byte("second"), amount+1)
+	}
+
+	for height := uint64(1); height <= 3; height++ {
+		block, err := branch.GetBlock(height)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := chain.ImportBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := client.Sync(ctx); err != nil || client.Height() != 3 {
+		t.Fatalf("got height=%d err=%v, want the branch at 3", client.Height(), err)
+	}
+
+	if balance, err := client.VerifiedBalance(ctx, []byte("second")); err != nil || balance != 6 {
+		t.Fatalf("got balance=%d err=%v want=6", balance, err)
+	}
+}
+
+func TestRefusesProofOfStake(t *testing.T) {
+	t.Parallel()
+
+	genesisJSON, err := json.Marshal(genesis.Default("pos", nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lightclient.New(nil, genesisJSON); !errors.Is(err, lightclient.ErrUnverifiableEngine) {
+		t.Fatalf("got err=%v, want ErrUnverifiableEngine", err)
+	}
+}
@@ -65,7 +65,7 @@ const lookupBalance = async ({
 
     return {
       address,
-      balance: response.balance.toString(),
+      balance: response.balanceDecimal,
     };
   } catch (error) {
     throw toError(error, 'Unable to fetch balance');
@@ -132,8 +132,8 @@ const sendWalletTx = async ({
     const built = await walletClient.buildTx({
       from,
       to,
-      amount: amountBigInt,
-      feeHint: { maxFeePerGas: feeBigInt, maxPriorityFee: feeBigInt },
+      amountDecimal: amountBigInt.toString(),
+      feeHint: { maxFeePerGas: feeBigInt, maxPriorityFee: feeBigInt, feeRate: feeBigInt },
     });
 
     const signed = await walletClient.signTx({